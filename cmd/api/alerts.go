@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/middleware"
+	"github.com/Marcos1394/agritrust-backend/internal/outbox"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 🔔 ALERTAS: Destinatarios por empresa y tema
+// ---------------------------------------------------------
+
+// alertRecipients devuelve los correos configurados para el tema.
+// Si la empresa no configuró ninguno, la alerta va al dueño de la empresa.
+func alertRecipients(db *gorm.DB, tenantID uuid.UUID, topic string) []string {
+	var emails []string
+	db.Model(&domain.AlertRecipient{}).
+		Where("tenant_id = ? AND topic = ?", tenantID, topic).
+		Order("email").
+		Pluck("email", &emails)
+	if len(emails) > 0 {
+		return emails
+	}
+
+	var tenant domain.Tenant
+	if err := db.First(&tenant, "id = ?", tenantID).Error; err != nil || tenant.OwnerID == "" {
+		return nil
+	}
+	var owner domain.User
+	if err := db.Where("clerk_id = ? AND deleted_at IS NULL", tenant.OwnerID).First(&owner).Error; err != nil || owner.Email == "" {
+		return nil
+	}
+	return []string{owner.Email}
+}
+
+// queueAlert guarda la alerta en el outbox con tx (la transacción del cambio que la origina).
+// Devuelve cuántos correos quedaron en la fila: 0 si nadie recibe el tema.
+func queueAlert(tx *gorm.DB, tenantID uuid.UUID, topic, subject, htmlBody string) (int, error) {
+	recipients := alertRecipients(tx, tenantID, topic)
+	if len(recipients) == 0 {
+		return 0, nil
+	}
+	if err := outbox.EnqueueEmail(tx, topic, outbox.Email{To: recipients, Subject: subject, HTML: htmlBody}); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func validAlertTopic(topic string) bool {
+	for _, t := range domain.AlertTopics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+func registerAlertRoutes(scoped *gin.RouterGroup, tdb func(*gin.Context) *gorm.DB) {
+	canManage := middleware.RequirePermission(authz.TenantManage)
+
+	// Listar destinatarios (y los temas disponibles)
+	scoped.GET("/settings/alert-recipients", canManage, func(c *gin.Context) {
+		var recipients []domain.AlertRecipient
+		if err := tdb(c).Order("topic, email").Find(&recipients).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": recipients, "topics": domain.AlertTopics})
+	})
+
+	// Agregar destinatario
+	scoped.POST("/settings/alert-recipients", canManage, func(c *gin.Context) {
+		var req AddRecipientReq
+		if !apierr.BindJSON(c, &req) {
+			return
+		}
+		// El catálogo de temas crece con el código: no cabe en un tag oneof fijo
+		if !validAlertTopic(req.Topic) {
+			apierr.Respond(c, apierr.Validation(apierr.Field("topic", "oneof", strings.Join(domain.AlertTopics, " "))))
+			return
+		}
+		email := normalizeEmail(req.Email)
+
+		var existing int64
+		if err := tdb(c).Model(&domain.AlertRecipient{}).Where("topic = ? AND email = ?", req.Topic, email).Count(&existing).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		if existing > 0 {
+			apierr.Respond(c, apierr.New(http.StatusConflict, "alert_recipient_exists"))
+			return
+		}
+
+		recipient := domain.AlertRecipient{
+			Topic:     req.Topic,
+			Email:     email,
+			CreatedBy: middleware.Actor(c),
+			CreatedAt: time.Now(),
+		}
+		if err := tdb(c).Create(&recipient).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, recipient)
+	})
+
+	// Quitar destinatario
+	scoped.DELETE("/settings/alert-recipients/:id", canManage, func(c *gin.Context) {
+		var recipient domain.AlertRecipient
+		if !findOr404(c, tdb(c), &recipient, c.Param("id"), "alert_recipient") {
+			return
+		}
+		if err := tdb(c).Delete(&recipient).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Destinatario eliminado", "email": recipient.Email})
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 🔑 API KEYS (Service Accounts)
+// ---------------------------------------------------------
+// Gateways IoT, básculas de empaque e integraciones ERP no pueden iniciar sesión en Clerk.
+// El admin les genera una llave con permisos acotados; la llave solo se muestra una vez.
+func registerAPIKeyRoutes(scoped *gin.RouterGroup, tdb func(*gin.Context) *gorm.DB) {
+	manage := middleware.RequirePermission(authz.APIKeysManage)
+
+	// Crear llave
+	scoped.POST("/api-keys", manage, func(c *gin.Context) {
+		var req CreateKeyReq
+		if !apierr.BindJSON(c, &req) {
+			return
+		}
+		if strings.TrimSpace(req.ServiceAccount) == "" {
+			apierr.Respond(c, apierr.Validation(apierr.Field("service_account", "required", "")))
+			return
+		}
+		scopes := make([]string, 0, len(req.Scopes))
+		var denied []apierr.FieldError
+		for i, p := range req.Scopes {
+			if !authz.AllowedForAPIKey(p) {
+				denied = append(denied, apierr.Field(fmt.Sprintf("scopes[%d]", i), "scope_not_allowed", string(p)))
+			}
+			scopes = append(scopes, string(p))
+		}
+		if len(denied) > 0 {
+			apierr.Respond(c, apierr.Validation(denied...))
+			return
+		}
+
+		raw, prefix, hash, err := middleware.GenerateAPIKey()
+		if err != nil {
+			apierr.Respond(c, apierr.Internal(err))
+			return
+		}
+
+		key := domain.APIKey{
+			ServiceAccount: strings.TrimSpace(req.ServiceAccount),
+			Prefix:         prefix,
+			KeyHash:        hash,
+			Scopes:         strings.Join(scopes, ","),
+			CreatedBy:      c.GetString("clerk_user_id"),
+			CreatedAt:      time.Now(),
+		}
+		if req.ExpiresInDays > 0 {
+			expires := time.Now().AddDate(0, 0, req.ExpiresInDays)
+			key.ExpiresAt = &expires
+		}
+		if err := tdb(c).Create(&key).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Guarda esta llave ahora: no se volverá a mostrar",
+			"key":     raw,
+			"data":    key,
+		})
+	})
+
+	// Listar llaves (sin el secreto)
+	scoped.GET("/api-keys", manage, func(c *gin.Context) {
+		listPage[domain.APIKey](c, tdb(c), apiKeyList)
+	})
+
+	// Revocar llave (efecto inmediato)
+	scoped.DELETE("/api-keys/:id", manage, func(c *gin.Context) {
+		var key domain.APIKey
+		if !findOr404(c, tdb(c), &key, c.Param("id"), "api_key") {
+			return
+		}
+		if key.RevokedAt == nil {
+			now := time.Now()
+			key.RevokedAt = &now
+			if err := tdb(c).Model(&key).Update("revoked_at", now).Error; err != nil {
+				respondDBError(c, err)
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "API key revocada", "data": key})
+	})
+}
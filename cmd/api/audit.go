@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 📜 BITÁCORA DE AUDITORÍA (Certificadoras)
+// ---------------------------------------------------------
+// Solo lectura: los registros los escribe el paquete audit en cada alta, cambio y baja.
+
+func registerAuditRoutes(scoped *gin.RouterGroup, tdb func(*gin.Context) *gorm.DB) {
+	canRead := middleware.RequirePermission(authz.AuditRead)
+
+	// Consulta filtrada y paginada
+	// Filtros: entity_type, entity_id, user (Clerk ID), actor, action, from, to
+	scoped.GET("/audit-logs", canRead, func(c *gin.Context) {
+		listAuditLogs(c, tdb(c))
+	})
+
+	// Historial de una entidad (ej: /audit-logs/entities/products/<id>)
+	scoped.GET("/audit-logs/entities/:type/:id", canRead, func(c *gin.Context) {
+		listAuditLogs(c, tdb(c).Where("entity_type = ? AND entity_id = ?", c.Param("type"), c.Param("id")))
+	})
+
+	// Todo lo que cambió un usuario
+	scoped.GET("/audit-logs/users/:clerk_id", canRead, func(c *gin.Context) {
+		listAuditLogs(c, tdb(c).Where("actor = ?", "user:"+c.Param("clerk_id")))
+	})
+}
+
+// listAuditLogs: Más recientes primero, paginado por cursor (ver auditList)
+func listAuditLogs(c *gin.Context, q *gorm.DB) {
+	if v := c.Query("user"); v != "" {
+		q = q.Where("actor = ?", "user:"+v)
+	}
+	listPage[domain.AuditLog](c, q, auditList)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/audit"
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestAuditLogsFarmScope(t *testing.T) {
+	db := newTestDB(t, &domain.Farm{}, &domain.HarvestBatch{}, &domain.AuditLog{})
+	if err := audit.Register(db); err != nil {
+		t.Fatal(err)
+	}
+	tenantCtx := tenancy.WithTenant(context.Background(), uuid.New())
+	adminDB := db.WithContext(tenantCtx)
+
+	farmA, farmB := domain.Farm{Name: "Rancho A"}, domain.Farm{Name: "Rancho B"}
+	for _, f := range []*domain.Farm{&farmA, &farmB} {
+		if err := adminDB.Create(f).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	batchB := domain.HarvestBatch{FarmID: farmB.ID, BatchCode: "LOT-B", HarvestDate: time.Now()}
+	if err := adminDB.Create(&batchB).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := adminDB.Model(&batchB).Update("batch_code", "LOT-B2").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// get consulta como el usuario de ctx y devuelve los registros visibles
+	get := func(ctx context.Context, path string) []domain.AuditLog {
+		t.Helper()
+		r := gin.New()
+		scoped := r.Group("/", func(c *gin.Context) {
+			c.Request = c.Request.WithContext(ctx)
+			c.Set("permissions", authz.Set{authz.AuditRead: true})
+		})
+		registerAuditRoutes(scoped, func(c *gin.Context) *gorm.DB { return db.WithContext(c.Request.Context()) })
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", path, w.Code, w.Body)
+		}
+		var logs []domain.AuditLog
+		if err := json.Unmarshal(w.Body.Bytes(), &logs); err != nil {
+			t.Fatal(err)
+		}
+		return logs
+	}
+
+	// Sin restricción: alta de los dos ranchos, alta y cambio del lote
+	if logs := get(tenantCtx, "/audit-logs"); len(logs) != 4 {
+		t.Fatalf("administrador: %d registros, se esperaban 4", len(logs))
+	}
+
+	// El capataz del rancho A no ve nada del rancho B (ni el rancho ni sus lotes)
+	foreman := tenancy.WithFarms(tenantCtx, []uuid.UUID{farmA.ID})
+	logs := get(foreman, "/audit-logs")
+	if len(logs) != 1 || logs[0].EntityID != farmA.ID.String() {
+		t.Fatalf("capataz: %+v, se esperaba solo el alta del rancho A", logs)
+	}
+	if logs := get(foreman, "/audit-logs/entities/harvest_batches/"+batchB.ID.String()); len(logs) != 0 {
+		t.Fatalf("capataz ve %d registros del lote de otro rancho", len(logs))
+	}
+	if logs := get(foreman, "/audit-logs?entity_type=farms"); len(logs) != 1 {
+		t.Fatalf("capataz ve %d ranchos en la bitácora, se esperaba 1", len(logs))
+	}
+}
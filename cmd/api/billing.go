@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/audit"
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/entitlements"
+	"github.com/Marcos1394/agritrust-backend/internal/middleware"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/Marcos1394/agritrust-backend/internal/webhook"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 💳 FACTURACIÓN: Webhook de Stripe + consumo del plan
+// ---------------------------------------------------------
+// Configurar en Stripe → Developers → Webhooks → endpoint /webhooks/billing
+// con los eventos customer.subscription.created/updated/deleted.
+// La suscripción debe llevar metadata {"tenant_id": "<uuid>", "plan": "basic|pro|enterprise"}.
+// El secreto "whsec_..." va en BILLING_WEBHOOK_SECRET.
+
+// billingEvent: Sobre de un evento de Stripe (solo lo que usamos)
+type billingEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object struct {
+			Status   string            `json:"status"` // active, trialing, past_due, canceled, unpaid...
+			Metadata map[string]string `json:"metadata"`
+		} `json:"object"`
+	} `json:"data"`
+}
+
+// subscriptionActive: Estados de Stripe en los que la empresa puede operar
+func subscriptionActive(status string) bool {
+	return status == "active" || status == "trialing"
+}
+
+func billingWebhookHandler(db *gorm.DB, secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if secret == "" {
+			apierr.Respond(c, apierr.New(http.StatusServiceUnavailable, "webhook_not_configured"))
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+		if err != nil {
+			apierr.Respond(c, apierr.New(http.StatusBadRequest, apierr.CodeInvalidBody))
+			return
+		}
+		if err := webhook.VerifyStripe(secret, c.GetHeader(webhook.StripeSignatureHeader), body, time.Now()); err != nil {
+			slog.InfoContext(c.Request.Context(), "firma de webhook rechazada", "source", "billing", "error", err)
+			apierr.Respond(c, apierr.New(http.StatusUnauthorized, "invalid_signature"))
+			return
+		}
+
+		var event billingEvent
+		if err := json.Unmarshal(body, &event); err != nil || event.ID == "" {
+			apierr.Respond(c, apierr.New(http.StatusBadRequest, apierr.CodeInvalidBody))
+			return
+		}
+		sub := event.Data.Object
+
+		var active bool
+		switch event.Type {
+		case "customer.subscription.created", "customer.subscription.updated":
+			active = subscriptionActive(sub.Status)
+		case "customer.subscription.deleted":
+			active = false
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Evento ignorado", "type": event.Type})
+			return
+		}
+
+		tenantID, err := uuid.Parse(sub.Metadata["tenant_id"])
+		if err != nil {
+			apierr.Respond(c, apierr.New(http.StatusBadRequest, "missing_tenant_metadata"))
+			return
+		}
+		plan := sub.Metadata["plan"]
+		if plan != "" && !entitlements.ValidPlan(plan) {
+			apierr.Respond(c, apierr.New(http.StatusBadRequest, "unknown_plan", "plan", plan))
+			return
+		}
+
+		ctx := audit.WithActor(tenancy.WithoutScope(c.Request.Context()), "system:billing-webhook")
+		duplicate := false
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			if duplicate, err = recordWebhookEvent(tx, "billing", event.ID, event.Type); err != nil || duplicate {
+				return err
+			}
+
+			var tenant domain.Tenant
+			if err := tx.First(&tenant, "id = ?", tenantID).Error; err != nil {
+				return err
+			}
+			// Stripe no garantiza el orden: un evento viejo no pisa uno más reciente
+			if event.Created < tenant.BillingEventAt {
+				return nil
+			}
+			updates := map[string]interface{}{"active": active, "billing_event_at": event.Created}
+			if plan != "" {
+				updates["plan"] = plan
+			}
+			return tx.Model(&tenant).Updates(updates).Error
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierr.Respond(c, apierr.NotFound("tenant"))
+			return
+		}
+		if err != nil {
+			apierr.Respond(c, apierr.Internal(err))
+			return
+		}
+		if duplicate {
+			c.JSON(http.StatusOK, gin.H{"message": "Evento ya procesado", "id": event.ID})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Suscripción actualizada", "tenant_id": tenantID, "active": active})
+	}
+}
+
+func registerBillingRoutes(scoped *gin.RouterGroup, tdb func(*gin.Context) *gorm.DB) {
+	// Consumo vs límites del plan (para banners de "actualiza tu plan" en el frontend)
+	scoped.GET("/tenants/usage", middleware.RequirePermission(authz.DashboardRead), func(c *gin.Context) {
+		plan := middleware.Plan(c)
+		usage, err := entitlements.Report(tdb(c), plan)
+		if err != nil {
+			respondDBError(c, err)
+			return
+		}
+		limits := entitlements.For(plan)
+		c.JSON(http.StatusOK, gin.H{
+			"plan":                     plan,
+			"usage":                    usage,
+			"modules":                  limits.Modules,
+			"telemetry_retention_days": limits.TelemetryRetentionDays,
+		})
+	})
+}
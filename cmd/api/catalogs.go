@@ -0,0 +1,395 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/entitlements"
+	"github.com/Marcos1394/agritrust-backend/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// ✏️ CATÁLOGOS EDITABLES: Consulta, edición y baja por ID
+// ---------------------------------------------------------
+// Ranchos, químicos, cultivos, proveedores, productos, dispositivos, activos, contratos,
+// temporadas y categorías exponen, además del alta y el listado:
+//   GET    /<recurso>/:id → el registro, con ETag: "<version>"
+//   PATCH  /<recurso>/:id → cambia solo los campos que vienen en el body (la versión sube en uno)
+//   DELETE /<recurso>/:id → baja lógica: sale de los listados pero se conserva para la trazabilidad
+// Concurrencia optimista: el Web Admin manda If-Match con el ETag que leyó; si otro usuario
+// cambió el registro mientras tanto se responde 412 precondition_failed en vez de pisar su cambio.
+// Sin If-Match la escritura se aplica sobre la versión vigente.
+
+// versioned: Modelos que embeben domain.Editable
+type versioned interface {
+	CurrentVersion() int
+}
+
+// nextVersion: Toda escritura a un catálogo editable sube su versión (invalida los ETag anteriores)
+var nextVersion = gorm.Expr("version + 1")
+
+// editable: Rutas por ID de un catálogo. P es el body del PATCH: campos puntero con el mismo
+// nombre que en el modelo (nil = no cambia).
+type editable[T versioned, P any] struct {
+	path     string            // Ej: /farms
+	resource string            // Nombre en los errores (not_found.<resource>)
+	read     []gin.HandlerFunc // Permiso (y módulo del plan) para consultar
+	write    []gin.HandlerFunc // Permiso (y módulo del plan) para editar y dar de baja
+
+	// preload: Relaciones que se devuelven con el registro (opcional)
+	preload func(*gorm.DB) *gorm.DB
+	// check valida el PATCH contra el registro actual: referencias, rangos de fechas... (opcional)
+	check func(c *gin.Context, tx *gorm.DB, current *T, req *P) error
+	// deletable decide si el registro se puede dar de baja (opcional)
+	deletable func(c *gin.Context, tx *gorm.DB, current *T) error
+}
+
+func (e editable[T, P]) register(scoped *gin.RouterGroup, tdb func(*gin.Context) *gorm.DB) {
+	route := e.path + "/:id"
+
+	scoped.GET(route, chain(e.read, func(c *gin.Context) {
+		var row T
+		if !findOr404(c, e.load(tdb(c)), &row, c.Param("id"), e.resource) {
+			return
+		}
+		respondVersioned(c, row)
+	})...)
+
+	scoped.PATCH(route, chain(e.write, func(c *gin.Context) {
+		var req P
+		if !apierr.BindJSON(c, &req) {
+			return
+		}
+		var current T
+		if !findOr404(c, tdb(c), &current, c.Param("id"), e.resource) || !ifMatch(c, current) {
+			return
+		}
+		changes, err := patchColumns(tdb(c), &current, &req)
+		if err == nil && e.check != nil {
+			err = e.check(c, tdb(c), &current, &req)
+		}
+		if err != nil {
+			respondDBError(c, err)
+			return
+		}
+
+		// Body sin campos: no hay nada que guardar (ni versión que subir)
+		if len(changes) > 0 {
+			changes["version"] = nextVersion
+			res := tdb(c).Model(&current).Where("version = ?", current.CurrentVersion()).Updates(changes)
+			if res.Error != nil {
+				respondDBError(c, res.Error)
+				return
+			}
+			if res.RowsAffected == 0 {
+				// Otro cambio ganó entre la lectura y la escritura
+				apierr.Respond(c, apierr.New(http.StatusPreconditionFailed, "precondition_failed"))
+				return
+			}
+		}
+
+		var updated T
+		if !findOr404(c, e.load(tdb(c)), &updated, c.Param("id"), e.resource) {
+			return
+		}
+		respondVersioned(c, updated)
+	})...)
+
+	scoped.DELETE(route, chain(e.write, func(c *gin.Context) {
+		var current T
+		if !findOr404(c, tdb(c), &current, c.Param("id"), e.resource) || !ifMatch(c, current) {
+			return
+		}
+		if e.deletable != nil {
+			if err := e.deletable(c, tdb(c), &current); err != nil {
+				respondDBError(c, err)
+				return
+			}
+		}
+		// Baja lógica (deleted_at): la auditoría la registra como delete con la fila completa
+		res := tdb(c).Where("version = ?", current.CurrentVersion()).Delete(&current)
+		if res.Error != nil {
+			respondDBError(c, res.Error)
+			return
+		}
+		if res.RowsAffected == 0 {
+			apierr.Respond(c, apierr.New(http.StatusPreconditionFailed, "precondition_failed"))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Registro eliminado", "id": c.Param("id")})
+	})...)
+}
+
+func (e editable[T, P]) load(tx *gorm.DB) *gorm.DB {
+	if e.preload != nil {
+		return e.preload(tx)
+	}
+	return tx
+}
+
+// chain: Middlewares de la ruta + handler (sin compartir el arreglo entre rutas)
+func chain(middlewares []gin.HandlerFunc, handler gin.HandlerFunc) []gin.HandlerFunc {
+	return append(middlewares[:len(middlewares):len(middlewares)], handler)
+}
+
+// etag: ETag fuerte con la versión del registro (ej: "3")
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+func respondVersioned(c *gin.Context, row versioned) {
+	c.Header("ETag", etag(row.CurrentVersion()))
+	c.JSON(http.StatusOK, row)
+}
+
+// ifMatch compara If-Match con la versión vigente (comparación fuerte: W/"3" no coincide).
+// Sin header se acepta. Si no coincide responde 412 con el ETag vigente y devuelve false.
+func ifMatch(c *gin.Context, current versioned) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return true
+	}
+	tag := etag(current.CurrentVersion())
+	for _, candidate := range strings.Split(header, ",") {
+		if candidate = strings.TrimSpace(candidate); candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	c.Header("ETag", tag)
+	apierr.Respond(c, apierr.New(http.StatusPreconditionFailed, "precondition_failed"))
+	return false
+}
+
+// patchColumns: columna → valor de los campos del PATCH que sí vinieron (puntero no nil).
+// Cada campo del body se busca por nombre en el modelo.
+func patchColumns(db *gorm.DB, model interface{}, req interface{}) (map[string]interface{}, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	changes := map[string]interface{}{}
+	rv := reflect.Indirect(reflect.ValueOf(req))
+	for i := 0; i < rv.NumField(); i++ {
+		value := rv.Field(i)
+		if value.Kind() != reflect.Ptr || value.IsNil() {
+			continue
+		}
+		name := rv.Type().Field(i).Name
+		field := stmt.Schema.LookUpField(name)
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("patch: el campo %s no existe en %s", name, stmt.Schema.Name)
+		}
+		changes[field.DBName] = value.Elem().Interface()
+	}
+	return changes, nil
+}
+
+// withDeleted: Relaciones históricas (lote → cultivo, orden → proveedor) se muestran aunque
+// el registro referenciado ya se haya dado de baja
+func withDeleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+func registerCatalogRoutes(scoped *gin.RouterGroup, tdb func(*gin.Context) *gorm.DB) {
+	iotModule := middleware.RequireModule(entitlements.ModuleIoT)
+	fleetModule := middleware.RequireModule(entitlements.ModuleFleet)
+	procurementModule := middleware.RequireModule(entitlements.ModuleProcurement)
+	landModule := middleware.RequireModule(entitlements.ModuleLand)
+	financeModule := middleware.RequireModule(entitlements.ModuleFinance)
+
+	catalogRead := []gin.HandlerFunc{middleware.RequirePermission(authz.CatalogRead)}
+	catalogWrite := []gin.HandlerFunc{middleware.RequirePermission(authz.CatalogWrite)}
+
+	// --- CATÁLOGOS DE CAMPO ---
+	editable[domain.Farm, UpdateFarmReq]{
+		path: "/farms", resource: "farm", read: catalogRead, write: catalogWrite,
+	}.register(scoped, tdb)
+
+	// Los químicos globales (tenant_id NULL) se leen como los propios pero no se editan
+	editable[domain.Chemical, UpdateChemicalReq]{
+		path: "/chemicals", resource: "chemical", read: catalogRead, write: catalogWrite,
+		check: func(c *gin.Context, tx *gorm.DB, current *domain.Chemical, req *UpdateChemicalReq) error {
+			return privateChemical(current)
+		},
+		deletable: func(c *gin.Context, tx *gorm.DB, current *domain.Chemical) error {
+			return privateChemical(current)
+		},
+	}.register(scoped, tdb)
+
+	editable[domain.Crop, UpdateCropReq]{
+		path: "/crops", resource: "crop", read: catalogRead, write: catalogWrite,
+		check: func(c *gin.Context, tx *gorm.DB, current *domain.Crop, req *UpdateCropReq) error {
+			if req.FarmID == nil {
+				return nil
+			}
+			return ownedRef(tx, "farm_id", "farm", &domain.Farm{}, *req.FarmID)
+		},
+	}.register(scoped, tdb)
+
+	// --- COMPRAS E INVENTARIO ---
+	editable[domain.Supplier, UpdateSupplierReq]{
+		path: "/procurement/suppliers", resource: "supplier",
+		read:  []gin.HandlerFunc{middleware.RequirePermission(authz.ProcurementRead), procurementModule},
+		write: []gin.HandlerFunc{middleware.RequirePermission(authz.ProcurementWrite), procurementModule},
+	}.register(scoped, tdb)
+
+	// El stock y el costo promedio solo cambian con movimientos (kardex), no con PATCH
+	editable[domain.Product, UpdateProductReq]{
+		path: "/inventory/products", resource: "product",
+		read:  []gin.HandlerFunc{middleware.RequirePermission(authz.InventoryRead)},
+		write: []gin.HandlerFunc{middleware.RequirePermission(authz.InventoryWrite)},
+		check: func(c *gin.Context, tx *gorm.DB, current *domain.Product, req *UpdateProductReq) error {
+			if req.ChemicalID == nil {
+				return nil
+			}
+			return ownedRef(tx, "chemical_id", "chemical", &domain.Chemical{}, *req.ChemicalID)
+		},
+	}.register(scoped, tdb)
+
+	// --- IOT Y FLOTA ---
+	editable[domain.Device, UpdateDeviceReq]{
+		path: "/iot/devices", resource: "device",
+		read:  []gin.HandlerFunc{middleware.RequirePermission(authz.IoTRead), iotModule},
+		write: []gin.HandlerFunc{middleware.RequirePermission(authz.IoTWrite), iotModule},
+		check: func(c *gin.Context, tx *gorm.DB, current *domain.Device, req *UpdateDeviceReq) error {
+			// Los umbrales se validan juntos: el que no viene conserva su valor
+			minimum, maximum := current.MinThreshold, current.MaxThreshold
+			if req.MinThreshold != nil {
+				minimum = *req.MinThreshold
+			}
+			if req.MaxThreshold != nil {
+				maximum = *req.MaxThreshold
+			}
+			if maximum != 0 && maximum < minimum {
+				return apierr.Validation(apierr.Field("max_threshold", "gtefield", "min_threshold"))
+			}
+			if req.FarmID == nil {
+				return nil
+			}
+			return ownedRef(tx, "farm_id", "farm", &domain.Farm{}, *req.FarmID)
+		},
+	}.register(scoped, tdb)
+
+	// Las lecturas de uso se suman con /usage y el servicio se reprograma con /maintenance
+	editable[domain.Asset, UpdateAssetReq]{
+		path: "/fleet/assets", resource: "asset",
+		read:  []gin.HandlerFunc{middleware.RequirePermission(authz.FleetRead), fleetModule},
+		write: []gin.HandlerFunc{middleware.RequirePermission(authz.FleetWrite), fleetModule},
+	}.register(scoped, tdb)
+
+	// --- TIERRA ---
+	editable[domain.LeaseContract, UpdateContractReq]{
+		path: "/land/contracts", resource: "lease_contract",
+		read:  []gin.HandlerFunc{middleware.RequirePermission(authz.LandRead), landModule},
+		write: []gin.HandlerFunc{middleware.RequirePermission(authz.LandWrite), landModule},
+		preload: func(tx *gorm.DB) *gorm.DB {
+			return tx.Preload("Farm", withDeleted)
+		},
+		check: func(c *gin.Context, tx *gorm.DB, current *domain.LeaseContract, req *UpdateContractReq) error {
+			return checkDateRange(current.StartDate, current.EndDate, req.StartDate, req.EndDate)
+		},
+	}.register(scoped, tdb)
+
+	// --- FINANZAS ---
+	financeRead := []gin.HandlerFunc{middleware.RequirePermission(authz.FinanceRead), financeModule}
+	financeWrite := []gin.HandlerFunc{middleware.RequirePermission(authz.FinanceWrite), financeModule}
+
+	editable[domain.Season, UpdateSeasonReq]{
+		path: "/finance/seasons", resource: "season", read: financeRead, write: financeWrite,
+		check: func(c *gin.Context, tx *gorm.DB, current *domain.Season, req *UpdateSeasonReq) error {
+			return checkDateRange(current.StartDate, current.EndDate, req.StartDate, req.EndDate)
+		},
+	}.register(scoped, tdb)
+
+	editable[domain.CostCategory, UpdateCategoryReq]{
+		path: "/finance/categories", resource: "cost_category", read: financeRead, write: financeWrite,
+		preload: func(tx *gorm.DB) *gorm.DB {
+			return tx.Preload("Children")
+		},
+		check: func(c *gin.Context, tx *gorm.DB, current *domain.CostCategory, req *UpdateCategoryReq) error {
+			if req.ParentID == nil {
+				return nil
+			}
+			return checkCategoryParent(tx, current.ID, *req.ParentID)
+		},
+		// Una subcategoría activa con el padre dado de baja desaparecería del árbol
+		deletable: func(c *gin.Context, tx *gorm.DB, current *domain.CostCategory) error {
+			var children int64
+			if err := tx.Model(&domain.CostCategory{}).Where("parent_id = ?", current.ID).Count(&children).Error; err != nil {
+				return err
+			}
+			if children > 0 {
+				return apierr.New(http.StatusConflict, "cost_category_has_children", "children", children)
+			}
+			return nil
+		},
+	}.register(scoped, tdb)
+}
+
+// privateChemical: Solo el catálogo privado de la empresa se edita; los globales son del sistema
+func privateChemical(chem *domain.Chemical) error {
+	if chem.TenantID == nil {
+		return apierr.New(http.StatusForbidden, "chemical_global_readonly", "chemical", chem.Name)
+	}
+	return nil
+}
+
+// ownedRef: Versión de requireRefs para los checks (devuelve el error en vez de responder)
+func ownedRef(tx *gorm.DB, field, resource string, model interface{}, id uuid.UUID) error {
+	ok, err := owned(tx, model, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return apierr.Validation(apierr.MissingRef(field, resource))
+	}
+	return nil
+}
+
+// checkDateRange valida que el fin siga después del inicio; la fecha que no viene conserva su valor
+func checkDateRange(start, end time.Time, newStart, newEnd *time.Time) error {
+	if newStart != nil {
+		start = *newStart
+	}
+	if newEnd != nil {
+		end = *newEnd
+	}
+	if !end.After(start) {
+		return apierr.Validation(apierr.Field("end_date", "gtfield", "start_date"))
+	}
+	return nil
+}
+
+// checkCategoryParent: El nuevo padre debe existir y no puede ser la categoría misma
+// ni una de sus subcategorías (el árbol quedaría en ciclo)
+func checkCategoryParent(tx *gorm.DB, categoryID, parentID uuid.UUID) error {
+	if err := ownedRef(tx, "parent_id", "cost_category", &domain.CostCategory{}, parentID); err != nil {
+		return err
+	}
+	for id := &parentID; id != nil; {
+		if *id == categoryID {
+			return apierr.Validation(apierr.Field("parent_id", "category_cycle", ""))
+		}
+		var parent domain.CostCategory
+		err := tx.Unscoped().Select("id", "parent_id").First(&parent, "id = ?", *id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		id = parent.ParentID
+	}
+	return nil
+}
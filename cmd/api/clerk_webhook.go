@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/audit"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/Marcos1394/agritrust-backend/internal/webhook"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ---------------------------------------------------------
+// 🔔 WEBHOOK DE CLERK (Sincroniza domain.User)
+// ---------------------------------------------------------
+// Configurar en Clerk Dashboard → Webhooks → endpoint /webhooks/clerk
+// con los eventos user.created, user.updated y user.deleted.
+// El secreto "whsec_..." va en CLERK_WEBHOOK_SECRET.
+
+// maxWebhookBody: Un evento de usuario pesa unos pocos KB
+const maxWebhookBody = 1 << 20
+
+// clerkEvent: Sobre de un evento de Clerk
+type clerkEvent struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// clerkUser: Campos del objeto user de Clerk que nos interesan
+type clerkUser struct {
+	ID                    string `json:"id"`
+	FirstName             string `json:"first_name"`
+	LastName              string `json:"last_name"`
+	ImageURL              string `json:"image_url"`
+	PrimaryEmailAddressID string `json:"primary_email_address_id"`
+	EmailAddresses        []struct {
+		ID           string `json:"id"`
+		EmailAddress string `json:"email_address"`
+	} `json:"email_addresses"`
+	UpdatedAt int64 `json:"updated_at"` // ms
+	Deleted   bool  `json:"deleted"`
+}
+
+// primaryEmail: El correo principal (o el primero si Clerk no marca ninguno)
+func (u clerkUser) primaryEmail() string {
+	for _, e := range u.EmailAddresses {
+		if e.ID == u.PrimaryEmailAddressID {
+			return normalizeEmail(e.EmailAddress)
+		}
+	}
+	if len(u.EmailAddresses) > 0 {
+		return normalizeEmail(u.EmailAddresses[0].EmailAddress)
+	}
+	return ""
+}
+
+var errUnsupportedEvent = errors.New("evento no soportado")
+
+// recordWebhookEvent registra un mensaje entrante dentro de la transacción que lo aplica.
+// duplicate = true si el proveedor ya lo había entregado (reintento): no se reprocesa.
+func recordWebhookEvent(tx *gorm.DB, source, id, eventType string) (duplicate bool, err error) {
+	res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&domain.WebhookEvent{
+		ID:          source + ":" + id,
+		Source:      source,
+		Type:        eventType,
+		ProcessedAt: time.Now(),
+	})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 0, nil
+}
+
+func clerkWebhookHandler(db *gorm.DB, secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if secret == "" {
+			apierr.Respond(c, apierr.New(http.StatusServiceUnavailable, "webhook_not_configured"))
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+		if err != nil {
+			apierr.Respond(c, apierr.New(http.StatusBadRequest, apierr.CodeInvalidBody))
+			return
+		}
+		msgID, err := webhook.VerifySvix(secret, c.Request.Header, body, time.Now())
+		if err != nil {
+			slog.InfoContext(c.Request.Context(), "firma de webhook rechazada", "source", "clerk", "error", err)
+			apierr.Respond(c, apierr.New(http.StatusUnauthorized, "invalid_signature"))
+			return
+		}
+
+		var event clerkEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			apierr.Respond(c, apierr.New(http.StatusBadRequest, apierr.CodeInvalidBody))
+			return
+		}
+		var user clerkUser
+		if err := json.Unmarshal(event.Data, &user); err != nil || user.ID == "" {
+			apierr.Respond(c, apierr.New(http.StatusBadRequest, "missing_user"))
+			return
+		}
+
+		// Los usuarios son globales: se escribe "como sistema"
+		ctx := audit.WithActor(tenancy.WithoutScope(c.Request.Context()), "system:clerk-webhook")
+		sysDB := db.WithContext(ctx)
+		duplicate := false
+		err = sysDB.Transaction(func(tx *gorm.DB) error {
+			// 1. Registrar el mensaje: si ya existía es un reintento de Svix y no se reprocesa
+			var err error
+			if duplicate, err = recordWebhookEvent(tx, "clerk", msgID, event.Type); err != nil || duplicate {
+				return err
+			}
+
+			// 2. Aplicar el evento
+			switch event.Type {
+			case "user.created", "user.updated":
+				return upsertClerkUser(tx, user)
+			case "user.deleted":
+				return deleteClerkUser(tx, user.ID)
+			default:
+				return errUnsupportedEvent
+			}
+		})
+		if errors.Is(err, errUnsupportedEvent) {
+			// 200 para que Svix no reintente eventos que no nos suscribimos a procesar
+			c.JSON(http.StatusOK, gin.H{"message": "Evento ignorado", "type": event.Type})
+			return
+		}
+		if err != nil {
+			apierr.Respond(c, apierr.Internal(err))
+			return
+		}
+		if duplicate {
+			c.JSON(http.StatusOK, gin.H{"message": "Evento ya procesado", "id": msgID})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Usuario sincronizado", "type": event.Type, "clerk_id": user.ID})
+	}
+}
+
+// upsertClerkUser crea o actualiza el perfil por ClerkID.
+// Svix no garantiza el orden: un user.updated viejo no pisa datos más recientes.
+func upsertClerkUser(tx *gorm.DB, u clerkUser) error {
+	var existing domain.User
+	err := tx.Where("clerk_id = ?", u.ID).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	fullName := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Create(&domain.User{
+			ClerkID:        u.ID,
+			Email:          u.primaryEmail(),
+			FullName:       fullName,
+			ImageURL:       u.ImageURL,
+			ClerkUpdatedAt: u.UpdatedAt,
+			CreatedAt:      time.Now(),
+		}).Error
+	}
+
+	if existing.DeletedAt != nil || u.UpdatedAt < existing.ClerkUpdatedAt {
+		return nil
+	}
+	return tx.Model(&existing).Updates(map[string]interface{}{
+		"email":            u.primaryEmail(),
+		"full_name":        fullName,
+		"image_url":        u.ImageURL,
+		"clerk_updated_at": u.UpdatedAt,
+	}).Error
+}
+
+// deleteClerkUser marca el perfil como borrado y desactiva sus membresías en todas las empresas.
+// El perfil se conserva para seguir mostrando el nombre en el historial (aplicaciones, alertas).
+func deleteClerkUser(tx *gorm.DB, clerkID string) error {
+	now := time.Now()
+	var existing domain.User
+	err := tx.Where("clerk_id = ?", clerkID).First(&existing).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// Llegó antes que su user.created: la lápida evita que un created tardío lo reviva
+		if err := tx.Create(&domain.User{ClerkID: clerkID, DeletedAt: &now, CreatedAt: now}).Error; err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case existing.DeletedAt == nil:
+		if err := tx.Model(&existing).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(&domain.TeamMember{}).
+		Where("user_id = ? AND deactivated_at IS NULL", clerkID).
+		Update("deactivated_at", now).Error
+}
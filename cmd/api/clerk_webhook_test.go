@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/Marcos1394/agritrust-backend/internal/webhook"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var testClerkSecret = "whsec_" + base64.StdEncoding.EncodeToString([]byte("secreto-clerk-de-prueba"))
+
+type clerkHarness struct {
+	t      *testing.T
+	db     *gorm.DB
+	router *gin.Engine
+	secret string
+}
+
+func newClerkHarness(t *testing.T) *clerkHarness {
+	db := newTestDB(t, &domain.User{}, &domain.TeamMember{}, &domain.WebhookEvent{})
+	sysDB := db.WithContext(tenancy.WithoutScope(context.Background()))
+	h := &clerkHarness{t: t, db: sysDB, router: gin.New(), secret: testClerkSecret}
+	h.router.POST("/webhooks/clerk", clerkWebhookHandler(db, h.secret))
+	return h
+}
+
+// userEvent arma el sobre de Clerk para un usuario con su updated_at (ms)
+func userEvent(eventType, clerkID, email string, updatedAt int64) []byte {
+	body, _ := json.Marshal(gin.H{
+		"type": eventType,
+		"data": gin.H{
+			"id":                       clerkID,
+			"first_name":               "Ana",
+			"last_name":                "López",
+			"primary_email_address_id": "idn_1",
+			"email_addresses":          []gin.H{{"id": "idn_1", "email_address": email}},
+			"updated_at":               updatedAt,
+		},
+	})
+	return body
+}
+
+// send firma el body con secret y lo entrega como si fuera Svix
+func (h *clerkHarness) send(secret, msgID string, ts time.Time, body []byte) *httptest.ResponseRecorder {
+	h.t.Helper()
+	sig, err := webhook.SignSvix(secret, msgID, ts, body)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/clerk", bytes.NewReader(body))
+	req.Header.Set(webhook.SvixIDHeader, msgID)
+	req.Header.Set(webhook.SvixTimestampHeader, strconv.FormatInt(ts.Unix(), 10))
+	req.Header.Set(webhook.SvixSignatureHeader, sig)
+	w := httptest.NewRecorder()
+	h.router.ServeHTTP(w, req)
+	return w
+}
+
+func (h *clerkHarness) user(clerkID string) domain.User {
+	h.t.Helper()
+	var u domain.User
+	if err := h.db.Where("clerk_id = ?", clerkID).First(&u).Error; err != nil {
+		h.t.Fatal(err)
+	}
+	return u
+}
+
+func TestClerkWebhookSignature(t *testing.T) {
+	h := newClerkHarness(t)
+	now := time.Now()
+	body := userEvent("user.created", "user_1", "ana@rancho.mx", 1000)
+
+	if w := h.send(h.secret, "msg_1", now, body); w.Code != http.StatusOK {
+		t.Fatalf("firma válida: status %d %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		ts     time.Time
+		body   []byte
+	}{
+		{"secreto distinto (rotado sin actualizar el servidor)", "whsec_" + base64.StdEncoding.EncodeToString([]byte("otro")), now, body},
+		{"timestamp viejo", h.secret, now.Add(-10 * time.Minute), body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := h.send(tt.secret, "msg_2", tt.ts, tt.body); w.Code != http.StatusUnauthorized {
+				t.Fatalf("status %d, se esperaba 401", w.Code)
+			}
+		})
+	}
+
+	t.Run("body alterado", func(t *testing.T) {
+		sig, _ := webhook.SignSvix(h.secret, "msg_3", now, body)
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/clerk", bytes.NewReader(userEvent("user.created", "user_evil", "x@evil.com", 1000)))
+		req.Header.Set(webhook.SvixIDHeader, "msg_3")
+		req.Header.Set(webhook.SvixTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set(webhook.SvixSignatureHeader, sig)
+		w := httptest.NewRecorder()
+		h.router.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status %d, se esperaba 401", w.Code)
+		}
+		var n int64
+		h.db.Model(&domain.User{}).Where("clerk_id = ?", "user_evil").Count(&n)
+		if n != 0 {
+			t.Fatal("un body alterado creó un usuario")
+		}
+	})
+
+	t.Run("sin secreto configurado", func(t *testing.T) {
+		r := gin.New()
+		r.POST("/webhooks/clerk", clerkWebhookHandler(h.db, ""))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhooks/clerk", bytes.NewReader(body)))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("status %d, se esperaba 503", w.Code)
+		}
+	})
+}
+
+func TestClerkWebhookReplay(t *testing.T) {
+	h := newClerkHarness(t)
+	now := time.Now()
+
+	if w := h.send(h.secret, "msg_1", now, userEvent("user.created", "user_1", "ana@rancho.mx", 1000)); w.Code != http.StatusOK {
+		t.Fatalf("status %d %s", w.Code, w.Body)
+	}
+	if w := h.send(h.secret, "msg_2", now, userEvent("user.updated", "user_1", "ana@nuevo.mx", 2000)); w.Code != http.StatusOK {
+		t.Fatalf("status %d %s", w.Code, w.Body)
+	}
+
+	// Svix reintenta con el mismo svix-id: no se reaplica (aunque el perfil ya cambió)
+	w := h.send(h.secret, "msg_1", now, userEvent("user.created", "user_1", "ana@rancho.mx", 1000))
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte("Evento ya procesado")) {
+		t.Fatalf("reintento: status %d %s", w.Code, w.Body)
+	}
+	if u := h.user("user_1"); u.Email != "ana@nuevo.mx" {
+		t.Fatalf("el reintento pisó el correo: %s", u.Email)
+	}
+	var events int64
+	h.db.Model(&domain.WebhookEvent{}).Count(&events)
+	if events != 2 {
+		t.Fatalf("eventos registrados = %d, se esperaban 2", events)
+	}
+}
+
+func TestClerkWebhookOutOfOrder(t *testing.T) {
+	h := newClerkHarness(t)
+	now := time.Now()
+
+	// El updated más reciente llega primero: crea el perfil con su updated_at
+	h.send(h.secret, "msg_2", now, userEvent("user.updated", "user_1", "ana@nuevo.mx", 2000))
+	// El created (más viejo) llega tarde y no debe pisarlo
+	if w := h.send(h.secret, "msg_1", now, userEvent("user.created", "user_1", "ana@rancho.mx", 1000)); w.Code != http.StatusOK {
+		t.Fatalf("status %d %s", w.Code, w.Body)
+	}
+	u := h.user("user_1")
+	if u.Email != "ana@nuevo.mx" || u.ClerkUpdatedAt != 2000 {
+		t.Fatalf("un evento viejo pisó al nuevo: email=%s clerk_updated_at=%d", u.Email, u.ClerkUpdatedAt)
+	}
+
+	// Un updated posterior sí se aplica
+	h.send(h.secret, "msg_3", now, userEvent("user.updated", "user_1", "ana@final.mx", 3000))
+	if u := h.user("user_1"); u.Email != "ana@final.mx" {
+		t.Fatalf("email=%s, se esperaba ana@final.mx", u.Email)
+	}
+}
+
+func TestClerkWebhookDeleteBeforeCreate(t *testing.T) {
+	h := newClerkHarness(t)
+	now := time.Now()
+	tenantID := uuid.New()
+	if err := h.db.Create(&domain.TeamMember{TenantID: tenantID, UserID: "user_2", Role: "operator", JoinedAt: now}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// user.deleted llega antes que user.created: deja una lápida y desactiva las membresías
+	h.send(h.secret, "msg_del", now, userEvent("user.deleted", "user_2", "", 5000))
+	h.send(h.secret, "msg_new", now, userEvent("user.created", "user_2", "beto@rancho.mx", 1000))
+
+	u := h.user("user_2")
+	if u.DeletedAt == nil || u.Email != "" {
+		t.Fatalf("el created tardío revivió al usuario: deleted_at=%v email=%q", u.DeletedAt, u.Email)
+	}
+	var member domain.TeamMember
+	if err := h.db.Where("user_id = ?", "user_2").First(&member).Error; err != nil {
+		t.Fatal(err)
+	}
+	if member.DeactivatedAt == nil {
+		t.Fatal("la membresía de un usuario borrado sigue activa")
+	}
+}
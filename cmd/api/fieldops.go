@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/metrics"
+	"github.com/Marcos1394/agritrust-backend/internal/middleware"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/Marcos1394/agritrust-backend/pkg/mailer"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 🚜 OPERACIONES DE CAMPO: Lógica compartida
+// ---------------------------------------------------------
+// La usan las rutas en línea (/applications, /bins/scan, /fleet/assets/:id/usage) y las
+// operaciones que la app sube después de trabajar sin señal (/sync/push), para que ambas
+// apliquen exactamente las mismas reglas. Los errores son *apierr.Error o errores de la base.
+
+// recordApplication valida y registra una aplicación fitosanitaria junto con su salida de inventario.
+// Un químico prohibido se bloquea con un *bannedChemical: quien responde lo pasa por queueBannedAlert.
+func recordApplication(c *gin.Context, tx *gorm.DB, req ApplicationReq, appliedAt time.Time) (domain.ApplicationRecord, error) {
+	var app domain.ApplicationRecord
+	// Se valida antes de tocar inventario (el scope lo rechazaría hasta el INSERT final)
+	if req.TenantID != uuid.Nil && req.TenantID != middleware.TenantID(c) {
+		return app, tenancy.ErrTenantMismatch
+	}
+	var farm domain.Farm
+	if err := tx.First(&farm, "id = ?", req.FarmID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return app, apierr.Validation(apierr.MissingRef("farm_id", "farm"))
+	} else if err != nil {
+		return app, err
+	}
+	var chem domain.Chemical
+	if err := tx.First(&chem, "id = ?", req.ChemicalID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return app, apierr.Validation(apierr.MissingRef("chemical_id", "chemical"))
+	} else if err != nil {
+		return app, err
+	}
+	if chem.IsBanned {
+		// Datos para el reporte: usuario o service account que intentó la acción
+		userID := actorLabel(c, tx)
+		metrics.Applications.WithLabelValues(metrics.Tenant(middleware.TenantID(c)), metrics.ApplicationBlocked).Inc()
+
+		// La alerta la guarda quien responde (queueBannedAlert), fuera de la transacción de la operación
+		return app, &bannedChemical{
+			err: apierr.New(http.StatusForbidden, "chemical_banned",
+				"chemical", chem.Name, "banned_markets", chem.BannedMarkets, "status", "BLOCKED"),
+			tenantID: middleware.TenantID(c),
+			htmlBody: mailer.GetSecurityAlertTemplate(farm.Name, chem.Name, userID),
+			event: gin.H{
+				"farm_id": farm.ID, "farm_name": farm.Name,
+				"chemical_id": chem.ID, "chemical_name": chem.Name, "banned_markets": chem.BannedMarkets,
+				"dosage": req.Dosage, "unit": req.Unit, "attempted_by": userID, "attempted_at": appliedAt,
+			},
+		}
+	}
+
+	app = domain.ApplicationRecord{
+		FarmID:     req.FarmID,
+		ChemicalID: req.ChemicalID,
+		Dosage:     req.Dosage,
+		Unit:       req.Unit,
+		Notes:      req.Notes,
+		AppliedAt:  appliedAt,
+		Status:     "approved",
+	}
+	// Registro y descuento de inventario van juntos: si algo falla no queda stock descontado sin aplicación
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&app).Error; err != nil {
+			return err
+		}
+
+		// LÓGICA DE INVENTARIO: Descontar producto si existe link
+		// 1. Buscar si este químico está ligado a un producto de inventario
+		var product domain.Product
+		err := tx.Where("chemical_id = ?", app.ChemicalID).First(&product).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Stock insuficiente: se permite y queda negativo (no detenemos la operación de campo)
+
+		// Crear Movimiento de Salida (OUT)
+		mov := domain.StockMovement{
+			ProductID:   product.ID,
+			Type:        "OUT",
+			Quantity:    app.Dosage,
+			ReferenceID: app.ID.String(),
+			Reason:      "Aplicación Fitosanitaria",
+			CreatedAt:   time.Now(),
+		}
+		if err := tx.Create(&mov).Error; err != nil {
+			return err
+		}
+
+		// Descontar (en la base, para no perder salidas concurrentes del mismo producto)
+		return tx.Model(&product).Updates(map[string]interface{}{
+			"current_stock": gorm.Expr("current_stock - ?", app.Dosage), "version": nextVersion,
+		}).Error
+	})
+	if err != nil {
+		return app, err
+	}
+	metrics.Applications.WithLabelValues(metrics.Tenant(middleware.TenantID(c)), metrics.ApplicationApproved).Inc()
+	return app, nil
+}
+
+// bannedChemical: Rechazo por químico prohibido (403 chemical_banned) con la alerta de seguridad ya armada.
+// En /sync/push un rechazo deshace la transacción de la operación: si la alerta se guardara ahí, se perdería.
+type bannedChemical struct {
+	err      *apierr.Error
+	tenantID uuid.UUID
+	htmlBody string
+	event    gin.H // Datos del evento application.blocked
+}
+
+func (b *bannedChemical) Error() string {
+	return b.err.Error()
+}
+
+func (b *bannedChemical) Unwrap() error {
+	return b.err
+}
+
+// queueBannedAlert: Si err es un bloqueo por químico prohibido, guarda la alerta para los destinatarios de
+// seguridad (o el dueño) y el evento application.blocked antes de responder. Si no se puede guardar, responde 500 en vez del 403: la app
+// reintenta y la alerta no se pierde. Cualquier otro error pasa igual.
+func queueBannedAlert(db *gorm.DB, err error) error {
+	var banned *bannedChemical
+	if !errors.As(err, &banned) {
+		return err
+	}
+	qerr := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := queueAlert(tx, banned.tenantID, domain.AlertSecurity, "⛔ ALERTA CRÍTICA: Bloqueo Fitosanitario", banned.htmlBody); err != nil {
+			return err
+		}
+		return emitEvent(tx, domain.EventApplicationBlocked, banned.event)
+	})
+	if qerr != nil {
+		return qerr
+	}
+	return err
+}
+
+// scanBin liga la caja (por QR) a un lote de cosecha. Si la caja no existe se da de alta.
+// scannedAt es la hora del escaneo en campo y deviceID el dispositivo (vacío = en línea).
+// Un escaneo más viejo que el último que ya se registró responde bin_scan_outdated (409).
+func scanBin(c *gin.Context, tx *gorm.DB, req ScanRequest, scannedAt time.Time, deviceID string) (domain.Bin, error) {
+	var bin domain.Bin
+	// El tenant_id del body es opcional (apps viejas); si viene, debe coincidir
+	if req.TenantID != uuid.Nil && req.TenantID != middleware.TenantID(c) {
+		return bin, tenancy.ErrTenantMismatch
+	}
+	var batch domain.HarvestBatch
+	if err := tx.Select("id", "farm_id").First(&batch, "id = ?", req.HarvestBatchID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return bin, apierr.Validation(apierr.MissingRef("harvest_batch_id", "harvest_batch"))
+	} else if err != nil {
+		return bin, err
+	}
+
+	// El QR es único en la empresa: se busca sin la restricción por rancho para no
+	// intentar dar de alta una caja que ya existe en otro rancho
+	binDB := tx.WithContext(tenancy.WithoutFarms(tx.Statement.Context))
+	err := binDB.Where("qr_code = ?", req.QRCode).First(&bin).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bin.QRCode = req.QRCode
+	} else if err != nil {
+		return bin, err
+	}
+	if bin.FarmID != nil && !tenancy.FarmAllowed(tx.Statement.Context, *bin.FarmID) {
+		return domain.Bin{}, tenancy.ErrFarmForbidden
+	}
+
+	if bin.ID != uuid.Nil && binScanOutdated(bin, scannedAt) {
+		return bin, apierr.New(http.StatusConflict, "bin_scan_outdated",
+			"qr_code", bin.QRCode, "scanned_at", bin.ScannedAt, "scanned_by", bin.ScannedBy, "bin", bin)
+	}
+
+	bin.HarvestBatchID = &batch.ID
+	bin.FarmID = &batch.FarmID
+	bin.WeightKg = req.Weight
+	bin.Status = "full_in_field"
+	bin.ScannedAt = &scannedAt
+	bin.ScannedBy = deviceID
+	if bin.ID == uuid.Nil {
+		err = tx.Create(&bin).Error
+	} else {
+		err = binDB.Model(&bin).Select("harvest_batch_id", "farm_id", "weight_kg", "status", "scanned_at", "scanned_by", "updated_at").Updates(&bin).Error
+	}
+	if err != nil {
+		return bin, err
+	}
+
+	metrics.BinsScanned.WithLabelValues(metrics.Tenant(middleware.TenantID(c))).Inc()
+	return bin, nil
+}
+
+// binScanOutdated: Conflicto de la misma caja escaneada en dos dispositivos sin señal.
+// Gana el escaneo más reciente según la hora de campo; además, un escaneo viejo no regresa
+// a campo una caja que después ya avanzó (ej: recibida en empaque).
+func binScanOutdated(bin domain.Bin, scannedAt time.Time) bool {
+	if bin.ScannedAt != nil && scannedAt.Before(*bin.ScannedAt) {
+		return true
+	}
+	return bin.Status != "full_in_field" && bin.UpdatedAt.After(scannedAt)
+}
+
+// addAssetUsage suma horas o km al acumulado del activo (en la base, para no perder lecturas concurrentes)
+func addAssetUsage(tx *gorm.DB, asset *domain.Asset, amount float64) error {
+	if err := tx.Model(asset).Updates(map[string]interface{}{
+		"current_usage": gorm.Expr("current_usage + ?", amount), "version": nextVersion,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.First(asset, "id = ?", asset.ID).Error
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// scanContext: Petición de un miembro de la empresa (farms vacío = sin restricción por rancho)
+func scanContext(tenantID uuid.UUID, farms ...uuid.UUID) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx := tenancy.WithTenant(context.Background(), tenantID)
+	if len(farms) > 0 {
+		ctx = tenancy.WithFarms(ctx, farms)
+	}
+	c.Request = httptest.NewRequest("POST", "/bins/scan", nil).WithContext(ctx)
+	c.Set("tenant_id", tenantID.String())
+	return c
+}
+
+func TestScanBinFarmScope(t *testing.T) {
+	db := newTestDB(t, &domain.Farm{}, &domain.HarvestBatch{}, &domain.Bin{})
+	tenantID := uuid.New()
+	admin := scanContext(tenantID)
+	adminDB := db.WithContext(admin.Request.Context())
+
+	farmA, farmB := domain.Farm{Name: "Rancho A"}, domain.Farm{Name: "Rancho B"}
+	for _, f := range []*domain.Farm{&farmA, &farmB} {
+		if err := adminDB.Create(f).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	batchA := domain.HarvestBatch{FarmID: farmA.ID, BatchCode: "LOT-A", HarvestDate: time.Now()}
+	batchB := domain.HarvestBatch{FarmID: farmB.ID, BatchCode: "LOT-B", HarvestDate: time.Now()}
+	for _, b := range []*domain.HarvestBatch{&batchA, &batchB} {
+		if err := adminDB.Create(b).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// El administrador escanea una caja en el rancho B: hereda el rancho del lote
+	bin, err := scanBin(admin, adminDB, ScanRequest{QRCode: "QR-B", HarvestBatchID: batchB.ID, Weight: 20}, time.Now(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin.FarmID == nil || *bin.FarmID != farmB.ID {
+		t.Fatalf("farm_id = %v, se esperaba %s", bin.FarmID, farmB.ID)
+	}
+
+	// El capataz del rancho A no ve las cajas del rancho B...
+	foreman := scanContext(tenantID, farmA.ID)
+	foremanDB := db.WithContext(foreman.Request.Context())
+	var visible []domain.Bin
+	if err := foremanDB.Find(&visible).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(visible) != 0 {
+		t.Fatalf("el capataz ve %d cajas de otro rancho", len(visible))
+	}
+
+	// ...ni puede reasignarlas a su lote, ni escanear en un lote del rancho B
+	later := time.Now().Add(time.Minute)
+	if _, err := scanBin(foreman, foremanDB, ScanRequest{QRCode: "QR-B", HarvestBatchID: batchA.ID, Weight: 18}, later, ""); !errors.Is(err, tenancy.ErrFarmForbidden) {
+		t.Fatalf("reasignar caja de otro rancho: err=%v, se esperaba ErrFarmForbidden", err)
+	}
+	if _, err := scanBin(foreman, foremanDB, ScanRequest{QRCode: "QR-NUEVA", HarvestBatchID: batchB.ID, Weight: 18}, later, ""); err == nil {
+		t.Fatal("escaneo en un lote de otro rancho aceptado")
+	}
+	var stored domain.Bin
+	if err := adminDB.First(&stored, "qr_code = ?", "QR-B").Error; err != nil {
+		t.Fatal(err)
+	}
+	if *stored.HarvestBatchID != batchB.ID || stored.WeightKg != 20 {
+		t.Fatalf("la caja del rancho B cambió: %+v", stored)
+	}
+
+	// En su propio rancho sí escanea
+	if _, err := scanBin(foreman, foremanDB, ScanRequest{QRCode: "QR-A", HarvestBatchID: batchA.ID, Weight: 15}, time.Now(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := foremanDB.Find(&visible).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(visible) != 1 || visible[0].QRCode != "QR-A" {
+		t.Fatalf("cajas visibles = %+v, se esperaba solo QR-A", visible)
+	}
+}
+
+func TestCodesUniquePerTenant(t *testing.T) {
+	db := newTestDB(t, &domain.HarvestBatch{}, &domain.Bin{})
+	dbA := db.WithContext(tenancy.WithTenant(context.Background(), uuid.New()))
+	dbB := db.WithContext(tenancy.WithTenant(context.Background(), uuid.New()))
+
+	// Dos empresas pueden usar el mismo código de lote y el mismo QR
+	for name, tx := range map[string]*gorm.DB{"A": dbA, "B": dbB} {
+		if err := tx.Create(&domain.HarvestBatch{BatchCode: "LOT-20251025-A", HarvestDate: time.Now()}).Error; err != nil {
+			t.Fatalf("lote de %s: %v", name, err)
+		}
+		if err := tx.Create(&domain.Bin{QRCode: "QR-0001", Status: "empty"}).Error; err != nil {
+			t.Fatalf("caja de %s: %v", name, err)
+		}
+	}
+
+	// Dentro de una empresa siguen siendo únicos
+	if err := dbA.Create(&domain.HarvestBatch{BatchCode: "LOT-20251025-A", HarvestDate: time.Now()}).Error; err == nil {
+		t.Fatal("se repitió el código de lote dentro de la empresa")
+	}
+	if err := dbA.Create(&domain.Bin{QRCode: "QR-0001", Status: "empty"}).Error; err == nil {
+		t.Fatal("se repitió el QR dentro de la empresa")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/migrations"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 🩺 SALUD: Liveness y Readiness
+// ---------------------------------------------------------
+// /healthz: el proceso está vivo (no toca la base; si falla, el orquestador lo reinicia)
+// /readyz:  puede atender tráfico (Postgres responde y el esquema está migrado).
+//           Durante el apagado responde 503 para que el balanceador deje de mandar peticiones.
+
+// readyTimeout: Tope del chequeo de base (un probe lento cuenta como caído)
+const readyTimeout = 2 * time.Second
+
+func registerHealthRoutes(r *gin.Engine, db *gorm.DB, draining *atomic.Bool) {
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	r.GET("/readyz", func(c *gin.Context) {
+		if draining.Load() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+		defer cancel()
+
+		checks := gin.H{"database": "ok", "migrations": "ok"}
+		ready := true
+
+		sqlDB, err := db.DB()
+		if err == nil {
+			err = sqlDB.PingContext(ctx)
+		}
+		if err != nil {
+			checks["database"] = err.Error()
+			checks["migrations"] = "sin verificar"
+			ready = false
+		} else if err := migrations.Check(db.WithContext(ctx)); err != nil {
+			checks["migrations"] = err.Error()
+			ready = false
+		}
+
+		status, code := "ready", http.StatusOK
+		if !ready {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+		c.JSON(code, gin.H{"status": status, "checks": checks})
+	})
+}
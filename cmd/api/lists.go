@@ -0,0 +1,218 @@
+package main
+
+import "github.com/Marcos1394/agritrust-backend/internal/listing"
+
+// ---------------------------------------------------------
+// 📄 LISTADOS: Filtros y órdenes permitidos por endpoint
+// ---------------------------------------------------------
+// Todos aceptan ?limit=, ?sort= (con "-" para descendente) y ?cursor= (ver internal/listing).
+// El cursor compara (columna, id); una columna que admite NULL se compara como COALESCE(columna, cero).
+
+// withRange agrega los filtros de un rango de fechas
+func withRange(filters []listing.Filter, prefix, column string) []listing.Filter {
+	return append(filters, listing.Range(prefix, column)...)
+}
+
+var farmList = listing.Spec{
+	Filters:     withRange([]listing.Filter{listing.Eq("ownership_type", "ownership_type", "own", "rented", "litigation")}, "created", "created_at"),
+	Sorts:       map[string]string{"name": "name", "created_at": "created_at"},
+	DefaultSort: "name",
+}
+
+var chemicalList = listing.Spec{
+	Filters:     []listing.Filter{listing.Eq("is_banned", "is_banned", "true", "false")},
+	Sorts:       map[string]string{"name": "name", "created_at": "created_at"},
+	DefaultSort: "name",
+}
+
+var cropList = listing.Spec{
+	Filters: withRange([]listing.Filter{
+		listing.ID("farm_id", "farm_id"),
+		listing.Eq("status", "status", "growing", "harvesting", "finished"),
+	}, "planting", "planting_date"),
+	Sorts:       map[string]string{"name": "name", "planting_date": "planting_date"},
+	DefaultSort: "name",
+}
+
+var batchList = listing.Spec{
+	Filters: withRange([]listing.Filter{
+		listing.ID("farm_id", "farm_id"),
+		listing.ID("crop_id", "crop_id"),
+	}, "harvest", "harvest_date"),
+	Sorts:       map[string]string{"harvest_date": "harvest_date", "batch_code": "batch_code"},
+	DefaultSort: "-harvest_date",
+}
+
+var binList = listing.Spec{
+	Filters: withRange([]listing.Filter{
+		listing.ID("harvest_batch_id", "harvest_batch_id"),
+		listing.ID("shipment_id", "shipment_id"),
+		listing.ID("farm_id", "farm_id"),
+		// Ejemplo: ?status=full_in_field (inventario disponible para embarque)
+		listing.Eq("status", "status", "empty", "full_in_field", "received_in_packing"),
+	}, "updated", "updated_at"),
+	Sorts:       map[string]string{"updated_at": "updated_at", "weight_kg": "weight_kg"},
+	DefaultSort: "-updated_at",
+}
+
+var assetList = listing.Spec{
+	Filters: []listing.Filter{
+		listing.Eq("type", "type", "tractor", "truck", "pump", "implement"),
+		listing.Eq("status", "status", "active", "in_shop", "broken_down"),
+	},
+	Sorts:       map[string]string{"name": "name", "created_at": "created_at", "next_service_at": "next_service_at"},
+	DefaultSort: "name",
+}
+
+var deviceList = listing.Spec{
+	Filters: []listing.Filter{
+		listing.ID("farm_id", "farm_id"),
+		listing.Eq("type", "type", "moisture_sensor", "flow_meter", "valve"),
+		listing.Eq("status", "status", "online", "offline", "error"),
+	},
+	Sorts:       map[string]string{"name": "name", "created_at": "created_at"},
+	DefaultSort: "name",
+}
+
+// La telemetría alimenta gráficas: páginas más grandes y orden cronológico
+var telemetryList = listing.Spec{
+	Filters:      listing.Range("", "timestamp"),
+	Sorts:        map[string]string{"timestamp": "timestamp"},
+	DefaultSort:  "timestamp",
+	DefaultLimit: 1000,
+	MaxLimit:     5000,
+}
+
+var supplierList = listing.Spec{
+	Sorts:       map[string]string{"name": "name", "created_at": "created_at"},
+	DefaultSort: "name",
+}
+
+var orderList = listing.Spec{
+	Filters: withRange(withRange([]listing.Filter{
+		listing.ID("supplier_id", "supplier_id"),
+		listing.Eq("status", "status", "draft", "ordered", "received", "cancelled"),
+	}, "created", "created_at"), "expected", "expected_date"),
+	Sorts:       map[string]string{"created_at": "created_at", "expected_date": "expected_date", "total_amount": "total_amount"},
+	DefaultSort: "-created_at",
+}
+
+var productList = listing.Spec{
+	Filters: []listing.Filter{
+		listing.Eq("category", "category"),
+		listing.ID("chemical_id", "chemical_id"),
+	},
+	Sorts:       map[string]string{"name": "name", "current_stock": "current_stock", "created_at": "created_at"},
+	DefaultSort: "name",
+}
+
+var contractList = listing.Spec{
+	Filters: withRange([]listing.Filter{
+		listing.ID("farm_id", "farm_id"),
+		listing.Eq("status", "status", "active", "expired", "negotiation"),
+	}, "end", "end_date"),
+	Sorts:       map[string]string{"end_date": "end_date", "start_date": "start_date"},
+	DefaultSort: "end_date",
+}
+
+var claimList = listing.Spec{
+	Filters: withRange([]listing.Filter{
+		listing.ID("shipment_id", "shipment_id"),
+		listing.Eq("status", "status", "open", "disputed", "accepted"),
+	}, "claim", "claim_date"),
+	Sorts:       map[string]string{"claim_date": "claim_date", "amount_usd": "amount_usd"},
+	DefaultSort: "-claim_date",
+}
+
+var shipmentList = listing.Spec{
+	Filters:     withRange([]listing.Filter{listing.Eq("status", "status", "shipped", "delivered", "disputed")}, "departure", "departure_time"),
+	Sorts:       map[string]string{"departure_time": "departure_time"},
+	DefaultSort: "-departure_time",
+}
+
+var seasonList = listing.Spec{
+	Filters:     withRange([]listing.Filter{listing.Eq("active", "active", "true", "false")}, "start", "start_date"),
+	Sorts:       map[string]string{"start_date": "start_date", "name": "name"},
+	DefaultSort: "-start_date",
+}
+
+var categoryList = listing.Spec{
+	Sorts:       map[string]string{"name": "name"},
+	DefaultSort: "name",
+}
+
+var budgetList = listing.Spec{
+	Filters: []listing.Filter{
+		listing.ID("cost_category_id", "cost_category_id"),
+		listing.Eq("year", "year"),
+		listing.Eq("month", "month", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"),
+	},
+	Sorts:       map[string]string{"created_at": "created_at", "amount": "amount"},
+	DefaultSort: "created_at",
+}
+
+var apiKeyList = listing.Spec{
+	Sorts:       map[string]string{"created_at": "created_at", "service_account": "service_account"},
+	DefaultSort: "-created_at",
+}
+
+var tenantJobList = listing.Spec{
+	Filters: []listing.Filter{
+		listing.Eq("kind", "kind"),
+		listing.Eq("status", "status", "queued", "running", "completed", "failed"),
+	},
+	Sorts:       map[string]string{"created_at": "created_at"},
+	DefaultSort: "-created_at",
+}
+
+var jobRunList = listing.Spec{
+	Filters:     withRange([]listing.Filter{listing.Eq("status", "status", "running", "completed", "failed")}, "started", "started_at"),
+	Sorts:       map[string]string{"started_at": "started_at"},
+	DefaultSort: "-started_at",
+}
+
+var outboxList = listing.Spec{
+	Filters: withRange([]listing.Filter{
+		listing.Eq("status", "status", "pending", "sent", "dead"),
+		listing.Eq("topic", "topic"),
+	}, "created", "created_at"),
+	Sorts:       map[string]string{"created_at": "created_at", "next_attempt_at": "next_attempt_at"},
+	DefaultSort: "-created_at",
+}
+
+var webhookAttemptList = listing.Spec{
+	Filters: withRange([]listing.Filter{
+		listing.Eq("success", "success", "true", "false"),
+		listing.Eq("event_type", "event_type"),
+		listing.Eq("event_id", "event_id"),
+	}, "created", "created_at"),
+	Sorts:       map[string]string{"created_at": "created_at"},
+	DefaultSort: "-created_at",
+}
+
+// auditList: Bitácora (también /audit-logs/entities/... y /audit-logs/users/...). ?user=<clerk_id> se arma aparte.
+var auditList = listing.Spec{
+	Filters: withRange([]listing.Filter{
+		listing.Eq("entity_type", "entity_type"),
+		listing.Eq("entity_id", "entity_id"),
+		listing.Eq("actor", "actor"),
+		listing.Eq("action", "action", "create", "update", "delete"),
+	}, "", "created_at"),
+	Sorts:        map[string]string{"created_at": "created_at"},
+	DefaultSort:  "-created_at",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+var teamMemberList = listing.Spec{
+	Filters:     []listing.Filter{listing.Eq("role", "role")},
+	Sorts:       map[string]string{"joined_at": "joined_at"},
+	DefaultSort: "joined_at",
+}
+
+// inviteList: ?status= se arma aparte (expired se calcula con expires_at)
+var inviteList = listing.Spec{
+	Filters:     withRange([]listing.Filter{listing.Eq("email", "email")}, "created", "created_at"),
+	Sorts:       map[string]string{"created_at": "created_at", "expires_at": "expires_at"},
+	DefaultSort: "-created_at",
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 🚚 EMPAQUE Y EMBARQUES
+// ---------------------------------------------------------
+// Ciclo de una caja: full_in_field (escaneo en campo) -> received_in_packing (llega a empaque)
+// -> con shipment_id (sube al camión). Cada paso avisa a los webhooks de la empresa.
+// tx debe ser una transacción con la empresa (y los ranchos del usuario) en el contexto.
+
+// receiveBin marca la caja como recibida en empaque y emite bin.received
+func receiveBin(tx *gorm.DB, qrCode string) (domain.Bin, error) {
+	var bin domain.Bin
+	if err := tx.First(&bin, "qr_code = ?", qrCode).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return bin, apierr.NotFound("bin")
+	} else if err != nil {
+		return bin, err
+	}
+	if bin.Status != "full_in_field" {
+		return bin, apierr.New(http.StatusConflict, "bin_not_in_field", "qr_code", bin.QRCode, "status", bin.Status)
+	}
+	bin.Status = "received_in_packing"
+	if err := tx.Model(&bin).Select("status", "updated_at").Updates(&bin).Error; err != nil {
+		return bin, err
+	}
+	return bin, emitEvent(tx, domain.EventBinReceived, bin)
+}
+
+// dispatchShipment crea el embarque con las cajas indicadas y emite shipment.dispatched.
+// Las cajas deben estar en empaque y sin embarque; un capataz solo puede subir las de sus ranchos.
+func dispatchShipment(tx *gorm.DB, req CreateShipmentReq) (domain.Shipment, error) {
+	shipment := domain.Shipment{
+		CustomerName:  req.CustomerName,
+		Destination:   req.Destination,
+		TruckPlate:    req.TruckPlate,
+		DepartureTime: req.DepartureTime,
+		Status:        "shipped",
+	}
+	if shipment.DepartureTime.IsZero() {
+		shipment.DepartureTime = time.Now()
+	}
+
+	var bins []domain.Bin
+	if err := tx.Where("id IN ?", req.BinIDs).Find(&bins).Error; err != nil {
+		return shipment, err
+	}
+	byID := make(map[uuid.UUID]domain.Bin, len(bins))
+	for _, b := range bins {
+		byID[b.ID] = b
+	}
+	for i, id := range req.BinIDs {
+		b, ok := byID[id]
+		if !ok {
+			return shipment, apierr.Validation(apierr.MissingRef(fmt.Sprintf("bin_ids[%d]", i), "bin"))
+		}
+		if b.Status != "received_in_packing" || b.ShipmentID != nil {
+			return shipment, apierr.New(http.StatusConflict, "bin_not_in_packing", "qr_code", b.QRCode)
+		}
+	}
+
+	if err := tx.Create(&shipment).Error; err != nil {
+		return shipment, err
+	}
+	if err := tx.Model(&domain.Bin{}).Where("id IN ?", req.BinIDs).Update("shipment_id", shipment.ID).Error; err != nil {
+		return shipment, err
+	}
+	if err := tx.Where("shipment_id = ?", shipment.ID).Find(&shipment.Bins).Error; err != nil {
+		return shipment, err
+	}
+	return shipment, emitEvent(tx, domain.EventShipmentDispatched, shipment)
+}
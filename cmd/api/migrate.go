@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Marcos1394/agritrust-backend/internal/migrations"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 🗄️ SUBCOMANDO: migrate up | down [n] | status
+// ---------------------------------------------------------
+// Se corre aparte del servidor, ej: `go run ./cmd/api migrate up` en el pre-deploy de Render
+func runMigrate(db *gorm.DB, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "uso: migrate up | down [n] | status")
+		return 2
+	}
+
+	switch args[0] {
+	case "up":
+		ran, err := migrations.Up(db)
+		for _, m := range ran {
+			fmt.Printf("✅ %04d_%s aplicada\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❌", err)
+			return 1
+		}
+		if len(ran) == 0 {
+			fmt.Println("La base ya está al día")
+		}
+
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				fmt.Fprintln(os.Stderr, "n debe ser un entero positivo")
+				return 2
+			}
+		}
+		ran, err := migrations.Down(db, n)
+		for _, m := range ran {
+			fmt.Printf("↩️ %04d_%s revertida\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❌", err)
+			return 1
+		}
+		if len(ran) == 0 {
+			fmt.Println("No hay migraciones aplicadas")
+		}
+
+	case "status":
+		list, err := migrations.List(db)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❌", err)
+			return 1
+		}
+		for _, s := range list {
+			state := "pendiente"
+			if s.AppliedAt != nil {
+				state = "aplicada " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-45s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, "subcomando desconocido:", args[0])
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,564 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/config"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/entitlements"
+	"github.com/Marcos1394/agritrust-backend/internal/listing"
+	"github.com/Marcos1394/agritrust-backend/internal/middleware"
+	"github.com/Marcos1394/agritrust-backend/internal/openapi"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 📘 DOCUMENTACIÓN: /openapi.json y /docs
+// ---------------------------------------------------------
+// apiRoutes describe cada ruta registrada en newRouter, en el mismo orden.
+// Al agregar un endpoint se agrega aquí su fila; `go run ./cmd/api openapi check` (en CI)
+// falla si alguna ruta de gin no está en la tabla o si la tabla tiene rutas que ya no existen.
+
+// errorBody: Sobre de error de apierr (solo documentación; lo arma apierr.Respond)
+type errorBody struct {
+	Error     string        `json:"error" binding:"required"` // Texto para mostrar (según Accept-Language)
+	Code      string        `json:"code" binding:"required"`  // Ej: validation_failed, not_found, quota_exceeded
+	Details   []errorDetail `json:"details"`
+	RequestID string        `json:"request_id"`
+}
+
+type errorDetail struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+// listParams: Filtros, orden y cursor de un listado (ver lists.go)
+func listParams(spec listing.Spec) []openapi.Param {
+	docs := spec.Params()
+	params := make([]openapi.Param, 0, len(docs))
+	for _, d := range docs {
+		s := &openapi.Schema{Type: "string", Format: d.Format, Enum: d.Enum}
+		if d.Format == "integer" {
+			s = &openapi.Schema{Type: "integer"}
+		}
+		params = append(params, openapi.Param{Name: d.Name, Description: d.Description, Schema: s})
+	}
+	return params
+}
+
+// auditParams: Filtros de /audit-logs (los de auditList más ?user=)
+var auditParams = append(listParams(auditList), openapi.Param{Name: "user", Description: "Clerk ID del usuario", Schema: &openapi.Schema{Type: "string"}})
+
+// message: Respuesta {"message": "..."} con campos adicionales
+func message(extra openapi.Fields) openapi.Fields {
+	out := openapi.Fields{"message": ""}
+	for k, v := range extra {
+		out[k] = v
+	}
+	return out
+}
+
+func perm(p authz.Permission) string { return string(p) }
+
+func module(m entitlements.Module) string { return string(m) }
+
+var apiRoutes = []openapi.Route{
+	// --- PÚBLICAS ---
+	{Method: "GET", Path: "/ping", Tag: "Sistema", Summary: "Ping", Auth: openapi.AuthPublic,
+		Response: openapi.Fields{"status": "", "system": ""}},
+	{Method: "GET", Path: "/healthz", Tag: "Sistema", Summary: "Liveness (no toca la base)", Auth: openapi.AuthPublic,
+		Response: openapi.Fields{"status": ""}},
+	{Method: "GET", Path: "/readyz", Tag: "Sistema", Summary: "Readiness (base y migraciones; 503 si no está lista o se está apagando)", Auth: openapi.AuthPublic,
+		Response: openapi.Fields{"status": "", "checks": map[string]string{}}},
+	{Method: "GET", Path: "/metrics", Tag: "Sistema", Summary: "Métricas de Prometheus", Auth: openapi.AuthMetrics,
+		Response: "", Produces: "text/plain"},
+	{Method: "GET", Path: "/ops/jobs", Tag: "Sistema", Summary: "Estado de las tareas programadas",
+		Description: "Candado (réplica que la ejecuta), próxima corrida, intentos y último resultado de cada tarea.", Auth: openapi.AuthMetrics,
+		Response: openapi.Fields{"data": []domain.ScheduledJob{}, "server_time": time.Time{}}},
+	{Method: "GET", Path: "/ops/jobs/:name/runs", Tag: "Sistema", Summary: "Historial de corridas de una tarea", Auth: openapi.AuthMetrics,
+		Params: listParams(jobRunList), Response: []domain.JobRun{}, Paged: true},
+	{Method: "POST", Path: "/ops/jobs/:name/run", Tag: "Sistema", Summary: "Correr una tarea ya",
+		Description: "La toma la siguiente revisión de cualquier réplica. 409 scheduled_job_running si está corriendo.", Auth: openapi.AuthMetrics,
+		Status: http.StatusAccepted, Response: message(openapi.Fields{"name": ""})},
+	{Method: "GET", Path: "/openapi.json", Tag: "Sistema", Summary: "Esta especificación", Auth: openapi.AuthPublic, Limited: true,
+		Response: openapi.Fields{}},
+	{Method: "GET", Path: "/docs", Tag: "Sistema", Summary: "Documentación interactiva", Auth: openapi.AuthPublic, Limited: true,
+		Response: "", Produces: "text/html"},
+	{Method: "POST", Path: "/webhooks/clerk", Tag: "Webhooks", Summary: "Eventos de usuarios de Clerk",
+		Description: "Autenticado con la firma Svix (headers svix-id, svix-timestamp, svix-signature).", Auth: openapi.AuthWebhook, Limited: true,
+		Body: openapi.Fields{"type": "", "data": openapi.Fields{}}, Response: message(openapi.Fields{"type": "", "clerk_id": ""})},
+	{Method: "POST", Path: "/webhooks/billing", Tag: "Webhooks", Summary: "Eventos de suscripción de Stripe",
+		Description: "Autenticado con la firma Stripe-Signature.", Auth: openapi.AuthWebhook, Limited: true,
+		Body: openapi.Fields{"id": "", "type": "", "created": int64(0), "data": openapi.Fields{}}, Response: message(openapi.Fields{"tenant_id": uuid.UUID{}, "active": false})},
+	{Method: "GET", Path: "/public/passport/:qr_code", Tag: "Pasaporte", Summary: "Pasaporte digital de una caja (consumidor)", Auth: openapi.AuthPublic, Limited: true,
+		Response: openapi.Fields{
+			"product_name": "", "variety": "", "origin": "", "producer": "", "harvest_date": time.Time{},
+			"freshness_hrs": 0.0, "location": "", "certifications": []string{},
+			"journey": []openapi.Fields{{"stage": "", "date": time.Time{}, "desc": ""}},
+		}},
+
+	// --- CUENTA (sin empresa activa) ---
+	{Method: "GET", Path: "/tenants", Tag: "Empresa", Summary: "Empresas del usuario (dueño o miembro)", Auth: openapi.AuthUser,
+		Response: []domain.Tenant{}},
+	{Method: "POST", Path: "/team/join", Tag: "Equipo", Summary: "Aceptar invitación", Auth: openapi.AuthUser,
+		Body: JoinReq{}, Response: message(openapi.Fields{"role": "", "tenant_id": uuid.UUID{}})},
+	{Method: "POST", Path: "/tenants", Tag: "Empresa", Summary: "Crear empresa (solo administradores de la plataforma)", Auth: openapi.AuthUser,
+		Body: CreateTenantReq{}, Status: http.StatusCreated, Response: domain.Tenant{}},
+
+	// --- CATÁLOGOS ---
+	{Method: "GET", Path: "/farms", Tag: "Catálogos", Summary: "Listar ranchos", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogRead),
+		Params: listParams(farmList), Response: []domain.Farm{}, Paged: true},
+	{Method: "GET", Path: "/chemicals", Tag: "Catálogos", Summary: "Listar químicos (incluye los globales)", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogRead),
+		Params: listParams(chemicalList), Response: []domain.Chemical{}, Paged: true},
+	{Method: "GET", Path: "/crops", Tag: "Catálogos", Summary: "Listar cultivos", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogRead),
+		Params: listParams(cropList), Response: []domain.Crop{}, Paged: true},
+	{Method: "GET", Path: "/harvest-batches", Tag: "Catálogos", Summary: "Listar lotes de cosecha", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogRead),
+		Params: listParams(batchList), Response: []domain.HarvestBatch{}, Paged: true},
+
+	// --- OPERACIONES DE CAMPO ---
+	{Method: "POST", Path: "/applications", Tag: "Campo", Summary: "Registrar aplicación fitosanitaria",
+		Description: "Un químico prohibido responde 403 chemical_banned y dispara la alerta de seguridad. Descuenta inventario si el químico está ligado a un producto.",
+		Auth:        openapi.AuthTenant, Permission: perm(authz.ApplicationsCreate),
+		Body: ApplicationReq{}, Status: http.StatusCreated, Response: message(openapi.Fields{"data": domain.ApplicationRecord{}})},
+	{Method: "POST", Path: "/bins/scan", Tag: "Campo", Summary: "Escanear caja (vincula el QR a un lote)", Auth: openapi.AuthTenant, Permission: perm(authz.BinsWrite),
+		Body: ScanRequest{}, Response: message(openapi.Fields{"qr": ""})},
+	{Method: "POST", Path: "/bins/receive", Tag: "Campo", Summary: "Recibir caja en empaque",
+		Description: "Solo cajas llenas en campo (409 bin_not_in_field). Emite el evento bin.received.", Auth: openapi.AuthTenant, Permission: perm(authz.BinsWrite),
+		Body: ReceiveBinReq{}, Response: message(openapi.Fields{"data": domain.Bin{}})},
+
+	// --- SINCRONIZACIÓN OFFLINE ---
+	{Method: "GET", Path: "/sync/pull", Tag: "Sincronización", Summary: "Catálogos que cambiaron desde la última descarga",
+		Description: "Sin since devuelve todo (full: true). La siguiente descarga manda como since el watermark recibido; algunos registros pueden repetirse. " +
+			"deleted trae los IDs dados de baja desde since (vacío en la descarga completa).",
+		Auth: openapi.AuthTenant, Permission: perm(authz.CatalogRead),
+		Params: []openapi.Param{{Name: "since", Description: "watermark de la descarga anterior (RFC3339)", Schema: &openapi.Schema{Type: "string", Format: "date-time"}}},
+		Response: openapi.Fields{"watermark": time.Time{}, "full": false, "farms": []domain.Farm{}, "chemicals": []domain.Chemical{},
+			"crops": []domain.Crop{}, "harvest_batches": []domain.HarvestBatch{},
+			"deleted": openapi.Fields{"farms": []uuid.UUID{}, "chemicals": []uuid.UUID{}, "crops": []uuid.UUID{}}}},
+	{Method: "POST", Path: "/sync/push", Tag: "Sincronización", Summary: "Subir operaciones capturadas sin señal",
+		Description: "Aplica las operaciones en orden, cada una con el permiso de su tipo (bin_scan: " + string(authz.BinsWrite) + ", application: " + string(authz.ApplicationsCreate) +
+			", asset_usage: " + string(authz.FleetUsage) + "). Responde 200 con un resultado por operación: applied, rejected (ej: chemical_banned), " +
+			"conflict (bin_scan_outdated: otro dispositivo escaneó la caja después) o error (reintentar). Un op_id ya procesado devuelve el mismo resultado con replayed: true.",
+		Auth: openapi.AuthTenant,
+		Body: SyncPushReq{}, Response: openapi.Fields{"results": []syncResult{}, "server_time": time.Time{}}},
+
+	// --- CATÁLOGOS: CONSULTA, EDICIÓN Y BAJA POR ID (ver catalogs.go) ---
+	{Method: "GET", Path: "/farms/:id", Tag: "Catálogos", Summary: "Consultar rancho", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogRead),
+		Versioned: true, Response: domain.Farm{}},
+	{Method: "PATCH", Path: "/farms/:id", Tag: "Catálogos", Summary: "Editar rancho (solo los campos enviados)",
+		Auth: openapi.AuthTenant, Permission: perm(authz.CatalogWrite),
+		Versioned: true, Body: UpdateFarmReq{}, Response: domain.Farm{}},
+	{Method: "DELETE", Path: "/farms/:id", Tag: "Catálogos", Summary: "Dar de baja rancho (baja lógica)", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogWrite),
+		Versioned: true, Response: message(openapi.Fields{"id": ""})},
+	{Method: "GET", Path: "/chemicals/:id", Tag: "Catálogos", Summary: "Consultar químico", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogRead),
+		Versioned: true, Response: domain.Chemical{}},
+	{Method: "PATCH", Path: "/chemicals/:id", Tag: "Catálogos", Summary: "Editar químico (solo los campos enviados)",
+		Description: "Los químicos globales (tenant_id nulo) responden 403 chemical_global_readonly.",
+		Auth:        openapi.AuthTenant, Permission: perm(authz.CatalogWrite),
+		Versioned: true, Body: UpdateChemicalReq{}, Response: domain.Chemical{}},
+	{Method: "DELETE", Path: "/chemicals/:id", Tag: "Catálogos", Summary: "Dar de baja químico (baja lógica)", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogWrite),
+		Versioned: true, Response: message(openapi.Fields{"id": ""})},
+	{Method: "GET", Path: "/crops/:id", Tag: "Catálogos", Summary: "Consultar cultivo", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogRead),
+		Versioned: true, Response: domain.Crop{}},
+	{Method: "PATCH", Path: "/crops/:id", Tag: "Catálogos", Summary: "Editar cultivo (solo los campos enviados)",
+		Auth: openapi.AuthTenant, Permission: perm(authz.CatalogWrite),
+		Versioned: true, Body: UpdateCropReq{}, Response: domain.Crop{}},
+	{Method: "DELETE", Path: "/crops/:id", Tag: "Catálogos", Summary: "Dar de baja cultivo (baja lógica)", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogWrite),
+		Versioned: true, Response: message(openapi.Fields{"id": ""})},
+	{Method: "GET", Path: "/procurement/suppliers/:id", Tag: "Compras", Summary: "Consultar proveedor", Auth: openapi.AuthTenant, Permission: perm(authz.ProcurementRead), Module: module(entitlements.ModuleProcurement),
+		Versioned: true, Response: domain.Supplier{}},
+	{Method: "PATCH", Path: "/procurement/suppliers/:id", Tag: "Compras", Summary: "Editar proveedor (solo los campos enviados)",
+		Auth: openapi.AuthTenant, Permission: perm(authz.ProcurementWrite), Module: module(entitlements.ModuleProcurement),
+		Versioned: true, Body: UpdateSupplierReq{}, Response: domain.Supplier{}},
+	{Method: "DELETE", Path: "/procurement/suppliers/:id", Tag: "Compras", Summary: "Dar de baja proveedor (baja lógica)", Auth: openapi.AuthTenant, Permission: perm(authz.ProcurementWrite), Module: module(entitlements.ModuleProcurement),
+		Versioned: true, Response: message(openapi.Fields{"id": ""})},
+	{Method: "GET", Path: "/inventory/products/:id", Tag: "Inventario", Summary: "Consultar producto", Auth: openapi.AuthTenant, Permission: perm(authz.InventoryRead),
+		Versioned: true, Response: domain.Product{}},
+	{Method: "PATCH", Path: "/inventory/products/:id", Tag: "Inventario", Summary: "Editar producto (solo los campos enviados)",
+		Description: "El stock y el costo promedio solo cambian con movimientos de almacén.",
+		Auth:        openapi.AuthTenant, Permission: perm(authz.InventoryWrite),
+		Versioned: true, Body: UpdateProductReq{}, Response: domain.Product{}},
+	{Method: "DELETE", Path: "/inventory/products/:id", Tag: "Inventario", Summary: "Dar de baja producto (baja lógica)", Auth: openapi.AuthTenant, Permission: perm(authz.InventoryWrite),
+		Versioned: true, Response: message(openapi.Fields{"id": ""})},
+	{Method: "GET", Path: "/iot/devices/:id", Tag: "IoT", Summary: "Consultar dispositivo", Auth: openapi.AuthTenant, Permission: perm(authz.IoTRead), Module: module(entitlements.ModuleIoT),
+		Versioned: true, Response: domain.Device{}},
+	{Method: "PATCH", Path: "/iot/devices/:id", Tag: "IoT", Summary: "Editar dispositivo (solo los campos enviados)",
+		Auth: openapi.AuthTenant, Permission: perm(authz.IoTWrite), Module: module(entitlements.ModuleIoT),
+		Versioned: true, Body: UpdateDeviceReq{}, Response: domain.Device{}},
+	{Method: "DELETE", Path: "/iot/devices/:id", Tag: "IoT", Summary: "Dar de baja dispositivo (baja lógica)", Auth: openapi.AuthTenant, Permission: perm(authz.IoTWrite), Module: module(entitlements.ModuleIoT),
+		Versioned: true, Response: message(openapi.Fields{"id": ""})},
+	{Method: "GET", Path: "/fleet/assets/:id", Tag: "Flota", Summary: "Consultar activo", Auth: openapi.AuthTenant, Permission: perm(authz.FleetRead), Module: module(entitlements.ModuleFleet),
+		Versioned: true, Response: domain.Asset{}},
+	{Method: "PATCH", Path: "/fleet/assets/:id", Tag: "Flota", Summary: "Editar activo (solo los campos enviados)",
+		Description: "El uso acumulado se registra con /usage.",
+		Auth:        openapi.AuthTenant, Permission: perm(authz.FleetWrite), Module: module(entitlements.ModuleFleet),
+		Versioned: true, Body: UpdateAssetReq{}, Response: domain.Asset{}},
+	{Method: "DELETE", Path: "/fleet/assets/:id", Tag: "Flota", Summary: "Dar de baja activo (baja lógica)", Auth: openapi.AuthTenant, Permission: perm(authz.FleetWrite), Module: module(entitlements.ModuleFleet),
+		Versioned: true, Response: message(openapi.Fields{"id": ""})},
+	{Method: "GET", Path: "/land/contracts/:id", Tag: "Tierra", Summary: "Consultar contrato", Auth: openapi.AuthTenant, Permission: perm(authz.LandRead), Module: module(entitlements.ModuleLand),
+		Versioned: true, Response: domain.LeaseContract{}},
+	{Method: "PATCH", Path: "/land/contracts/:id", Tag: "Tierra", Summary: "Editar contrato (solo los campos enviados)",
+		Auth: openapi.AuthTenant, Permission: perm(authz.LandWrite), Module: module(entitlements.ModuleLand),
+		Versioned: true, Body: UpdateContractReq{}, Response: domain.LeaseContract{}},
+	{Method: "DELETE", Path: "/land/contracts/:id", Tag: "Tierra", Summary: "Dar de baja contrato (baja lógica)", Auth: openapi.AuthTenant, Permission: perm(authz.LandWrite), Module: module(entitlements.ModuleLand),
+		Versioned: true, Response: message(openapi.Fields{"id": ""})},
+	{Method: "GET", Path: "/finance/seasons/:id", Tag: "Finanzas", Summary: "Consultar temporada", Auth: openapi.AuthTenant, Permission: perm(authz.FinanceRead), Module: module(entitlements.ModuleFinance),
+		Versioned: true, Response: domain.Season{}},
+	{Method: "PATCH", Path: "/finance/seasons/:id", Tag: "Finanzas", Summary: "Editar temporada (solo los campos enviados)",
+		Auth: openapi.AuthTenant, Permission: perm(authz.FinanceWrite), Module: module(entitlements.ModuleFinance),
+		Versioned: true, Body: UpdateSeasonReq{}, Response: domain.Season{}},
+	{Method: "DELETE", Path: "/finance/seasons/:id", Tag: "Finanzas", Summary: "Dar de baja temporada (baja lógica)", Auth: openapi.AuthTenant, Permission: perm(authz.FinanceWrite), Module: module(entitlements.ModuleFinance),
+		Versioned: true, Response: message(openapi.Fields{"id": ""})},
+	{Method: "GET", Path: "/finance/categories/:id", Tag: "Finanzas", Summary: "Consultar categoría de costo", Auth: openapi.AuthTenant, Permission: perm(authz.FinanceRead), Module: module(entitlements.ModuleFinance),
+		Versioned: true, Response: domain.CostCategory{}},
+	{Method: "PATCH", Path: "/finance/categories/:id", Tag: "Finanzas", Summary: "Editar categoría de costo (solo los campos enviados)",
+		Description: "Una categoría con subcategorías activas no se puede borrar (409 cost_category_has_children).",
+		Auth:        openapi.AuthTenant, Permission: perm(authz.FinanceWrite), Module: module(entitlements.ModuleFinance),
+		Versioned: true, Body: UpdateCategoryReq{}, Response: domain.CostCategory{}},
+	{Method: "DELETE", Path: "/finance/categories/:id", Tag: "Finanzas", Summary: "Dar de baja categoría de costo (baja lógica)", Auth: openapi.AuthTenant, Permission: perm(authz.FinanceWrite), Module: module(entitlements.ModuleFinance),
+		Versioned: true, Response: message(openapi.Fields{"id": ""})},
+
+	{Method: "GET", Path: "/dashboard/stats", Tag: "Dashboard", Summary: "Estadísticas del día y tendencia semanal", Auth: openapi.AuthTenant, Permission: perm(authz.DashboardRead),
+		Response: openapi.Fields{
+			"total_harvest_today": 0.0, "active_batches": int64(0), "security_alerts": 0,
+			"weekly_trend": []struct {
+				Date  string  `json:"date"`
+				Value float64 `json:"value"`
+			}{},
+		}},
+
+	// --- FLOTA ---
+	{Method: "POST", Path: "/fleet/assets", Tag: "Flota", Summary: "Alta de maquinaria", Auth: openapi.AuthTenant, Permission: perm(authz.FleetWrite), Module: module(entitlements.ModuleFleet),
+		Body: CreateAssetReq{}, Status: http.StatusCreated, Response: domain.Asset{}},
+	{Method: "GET", Path: "/fleet/assets", Tag: "Flota", Summary: "Listar maquinaria", Auth: openapi.AuthTenant, Permission: perm(authz.FleetRead), Module: module(entitlements.ModuleFleet),
+		Params: listParams(assetList), Response: []domain.Asset{}, Paged: true},
+	{Method: "POST", Path: "/fleet/assets/:id/usage", Tag: "Flota", Summary: "Registrar uso diario (suma al acumulado)", Auth: openapi.AuthTenant, Permission: perm(authz.FleetUsage), Module: module(entitlements.ModuleFleet),
+		Body: UsageReq{}, Response: message(openapi.Fields{"new_total": 0.0})},
+	{Method: "POST", Path: "/fleet/assets/:id/maintenance", Tag: "Flota", Summary: "Registrar servicio de taller (reprograma el siguiente)", Auth: openapi.AuthTenant, Permission: perm(authz.FleetWrite), Module: module(entitlements.ModuleFleet),
+		Body: MaintenanceReq{}, Response: message(nil)},
+
+	// --- IOT ---
+	{Method: "POST", Path: "/iot/devices", Tag: "IoT", Summary: "Alta de dispositivo", Auth: openapi.AuthTenant, Permission: perm(authz.IoTWrite), Module: module(entitlements.ModuleIoT),
+		Body: CreateDeviceReq{}, Status: http.StatusCreated, Response: domain.Device{}},
+	{Method: "GET", Path: "/iot/devices", Tag: "IoT", Summary: "Listar dispositivos", Auth: openapi.AuthTenant, Permission: perm(authz.IoTRead), Module: module(entitlements.ModuleIoT),
+		Params: listParams(deviceList), Response: []domain.Device{}, Paged: true},
+	{Method: "GET", Path: "/iot/telemetry", Tag: "IoT", Summary: "Lecturas de un dispositivo (dentro de la retención del plan)", Auth: openapi.AuthTenant, Permission: perm(authz.IoTRead), Module: module(entitlements.ModuleIoT),
+		Query: TelemetryQuery{}, Params: listParams(telemetryList), Response: []domain.TelemetryData{}, Paged: true},
+	{Method: "POST", Path: "/iot/simulate/:device_id", Tag: "IoT", Summary: "Generar 24 h de lecturas de demostración", Auth: openapi.AuthTenant, Permission: perm(authz.IoTWrite), Module: module(entitlements.ModuleIoT),
+		Response: message(nil)},
+	{Method: "POST", Path: "/iot/telemetry", Tag: "IoT", Summary: "Ingesta de lecturas (gateways con API key)", Auth: openapi.AuthTenant, Permission: perm(authz.TelemetryWrite), Module: module(entitlements.ModuleIoT),
+		Body: IngestReq{}, Status: http.StatusCreated, Response: message(openapi.Fields{"count": 0})},
+
+	// --- COMPRAS ---
+	{Method: "POST", Path: "/procurement/suppliers", Tag: "Compras", Summary: "Alta de proveedor", Auth: openapi.AuthTenant, Permission: perm(authz.ProcurementWrite), Module: module(entitlements.ModuleProcurement),
+		Body: CreateSupplierReq{}, Status: http.StatusCreated, Response: domain.Supplier{}},
+	{Method: "GET", Path: "/procurement/suppliers", Tag: "Compras", Summary: "Listar proveedores", Auth: openapi.AuthTenant, Permission: perm(authz.ProcurementRead), Module: module(entitlements.ModuleProcurement),
+		Params: listParams(supplierList), Response: []domain.Supplier{}, Paged: true},
+	{Method: "POST", Path: "/procurement/orders", Tag: "Compras", Summary: "Crear orden de compra (borrador)", Auth: openapi.AuthTenant, Permission: perm(authz.ProcurementWrite), Module: module(entitlements.ModuleProcurement),
+		Body: CreateOrderReq{}, Status: http.StatusCreated, Response: domain.PurchaseOrder{}},
+	{Method: "GET", Path: "/procurement/orders", Tag: "Compras", Summary: "Listar órdenes de compra", Auth: openapi.AuthTenant, Permission: perm(authz.ProcurementRead), Module: module(entitlements.ModuleProcurement),
+		Params: listParams(orderList), Response: []domain.PurchaseOrder{}, Paged: true},
+	{Method: "POST", Path: "/procurement/orders/:id/receive", Tag: "Compras", Summary: "Recibir mercancía (entra a inventario y genera el gasto)", Auth: openapi.AuthTenant, Permission: perm(authz.ProcurementWrite), Module: module(entitlements.ModuleProcurement),
+		Response: message(nil)},
+
+	// --- INVENTARIO ---
+	{Method: "POST", Path: "/inventory/products", Tag: "Inventario", Summary: "Alta de producto (SKU)", Auth: openapi.AuthTenant, Permission: perm(authz.InventoryWrite),
+		Body: CreateProductReq{}, Status: http.StatusCreated, Response: domain.Product{}},
+	{Method: "GET", Path: "/inventory/products", Tag: "Inventario", Summary: "Listar productos", Auth: openapi.AuthTenant, Permission: perm(authz.InventoryRead),
+		Params: listParams(productList), Response: []domain.Product{}, Paged: true},
+	{Method: "POST", Path: "/inventory/movements/in", Tag: "Inventario", Summary: "Entrada de almacén (recalcula costo promedio)", Auth: openapi.AuthTenant, Permission: perm(authz.InventoryWrite),
+		Body: InReq{}, Response: message(openapi.Fields{"new_stock": 0.0})},
+
+	// --- EQUIPO ---
+	{Method: "POST", Path: "/team/invite", Tag: "Equipo", Summary: "Invitar por correo", Auth: openapi.AuthTenant, Permission: perm(authz.TeamManage),
+		Body: InviteReq{}, Status: http.StatusCreated, Response: message(openapi.Fields{"data": domain.Invitation{}})},
+	{Method: "POST", Path: "/team/invites/:id/resend", Tag: "Equipo", Summary: "Reenviar invitación (link nuevo)", Auth: openapi.AuthTenant, Permission: perm(authz.TeamManage),
+		Response: message(openapi.Fields{"data": domain.Invitation{}})},
+	{Method: "DELETE", Path: "/team/invites/:id", Tag: "Equipo", Summary: "Revocar invitación", Auth: openapi.AuthTenant, Permission: perm(authz.TeamManage),
+		Response: message(openapi.Fields{"data": domain.Invitation{}})},
+	{Method: "GET", Path: "/team", Tag: "Equipo", Summary: "Miembros, perfiles, ranchos asignados e invitaciones vigentes", Auth: openapi.AuthTenant, Permission: perm(authz.TeamRead),
+		Description: "Miembros paginados por cursor; `users` y `member_farms` corresponden a los miembros de la página e `invites` son las invitaciones vigentes.",
+		Params:      listParams(teamMemberList), Paged: true,
+		Response: openapi.Fields{
+			"members": []domain.TeamMember{}, "users": map[string]domain.User{},
+			"member_farms": map[string][]uuid.UUID{}, "invites": []domain.Invitation{},
+		}},
+	{Method: "GET", Path: "/team/invites", Tag: "Equipo", Summary: "Historial de invitaciones", Auth: openapi.AuthTenant, Permission: perm(authz.TeamRead),
+		Params:   append(listParams(inviteList), openapi.Param{Name: "status", Schema: &openapi.Schema{Type: "string", Enum: inviteStatuses}}),
+		Response: []domain.Invitation{}, Paged: true},
+	{Method: "PATCH", Path: "/team/members/:id", Tag: "Equipo", Summary: "Cambiar rol de un miembro", Auth: openapi.AuthTenant, Permission: perm(authz.TeamManage),
+		Body: MemberRoleReq{}, Response: domain.TeamMember{}},
+	{Method: "DELETE", Path: "/team/members/:id", Tag: "Equipo", Summary: "Eliminar miembro", Auth: openapi.AuthTenant, Permission: perm(authz.TeamManage),
+		Response: message(nil)},
+	{Method: "PUT", Path: "/team/members/:id/farms", Tag: "Equipo", Summary: "Asignar ranchos a un miembro (vacío = todos)", Auth: openapi.AuthTenant, Permission: perm(authz.TeamManage),
+		Body: FarmsReq{}, Response: openapi.Fields{"team_member_id": uuid.UUID{}, "farm_ids": []uuid.UUID{}, "restricted": false}},
+	{Method: "GET", Path: "/team/me", Tag: "Equipo", Summary: "Mi rol y permisos en la empresa activa", Auth: openapi.AuthTenant,
+		Response: openapi.Fields{"tenant_id": uuid.UUID{}, "role": "", "permissions": []authz.Permission{}, "farm_ids": []uuid.UUID{}}},
+	{Method: "GET", Path: "/team/roles", Tag: "Equipo", Summary: "Matriz de roles y permisos", Auth: openapi.AuthTenant, Permission: perm(authz.TeamRead),
+		Response: openapi.Fields{
+			"roles": []struct {
+				Role        string             `json:"role"`
+				Customized  bool               `json:"customized"`
+				Permissions []authz.Permission `json:"permissions"`
+			}{},
+			"catalog": []authz.Permission{},
+		}},
+	{Method: "PUT", Path: "/team/roles/:role", Tag: "Equipo", Summary: "Personalizar permisos de un rol", Auth: openapi.AuthTenant, Permission: perm(authz.TeamManage),
+		Body: RolePermissionsReq{}, Response: openapi.Fields{"role": "", "permissions": []authz.Permission{}}},
+	{Method: "DELETE", Path: "/team/roles/:role", Tag: "Equipo", Summary: "Restaurar permisos default de un rol", Auth: openapi.AuthTenant, Permission: perm(authz.TeamManage),
+		Response: message(openapi.Fields{"role": ""})},
+
+	// --- ALERTAS ---
+	{Method: "GET", Path: "/settings/alert-recipients", Tag: "Alertas", Summary: "Destinatarios de alertas y temas disponibles", Auth: openapi.AuthTenant, Permission: perm(authz.TenantManage),
+		Response: openapi.Fields{"data": []domain.AlertRecipient{}, "topics": []string{}}},
+	{Method: "POST", Path: "/settings/alert-recipients", Tag: "Alertas", Summary: "Agregar destinatario", Auth: openapi.AuthTenant, Permission: perm(authz.TenantManage),
+		Body: AddRecipientReq{}, Status: http.StatusCreated, Response: domain.AlertRecipient{}},
+	{Method: "DELETE", Path: "/settings/alert-recipients/:id", Tag: "Alertas", Summary: "Quitar destinatario", Auth: openapi.AuthTenant, Permission: perm(authz.TenantManage),
+		Response: message(openapi.Fields{"email": ""})},
+	{Method: "GET", Path: "/settings/outbox", Tag: "Alertas", Summary: "Mensajes en el outbox: correos y webhooks (pendientes, entregados y sin entregar)", Auth: openapi.AuthTenant, Permission: perm(authz.TenantManage),
+		Params: listParams(outboxList), Response: []domain.OutboxMessage{}, Paged: true},
+	{Method: "POST", Path: "/settings/outbox/:id/retry", Tag: "Alertas", Summary: "Reintentar la entrega de un mensaje",
+		Description: "Un dead vuelve a la fila con los intentos en cero. 409 outbox_already_sent si ya se entregó.", Auth: openapi.AuthTenant, Permission: perm(authz.TenantManage),
+		Status: http.StatusAccepted, Response: message(openapi.Fields{"data": domain.OutboxMessage{}})},
+
+	// --- WEBHOOKS SALIENTES ---
+	{Method: "GET", Path: "/settings/webhooks", Tag: "Webhooks", Summary: "Endpoints (sin secreto) y tipos de evento disponibles", Auth: openapi.AuthTenant, Permission: perm(authz.WebhooksManage),
+		Response: openapi.Fields{"data": []domain.WebhookEndpoint{}, "event_types": []string{}}},
+	{Method: "POST", Path: "/settings/webhooks", Tag: "Webhooks", Summary: "Registrar endpoint (el secreto solo se muestra aquí)",
+		Description: "Cada envío se firma con HMAC-SHA256 en el header AgriTrust-Signature (t=<segundos>,v1=<hex> de \"<t>.<body>\"). En producción la URL debe ser https.", Auth: openapi.AuthTenant, Permission: perm(authz.WebhooksManage),
+		Body: CreateWebhookReq{}, Status: http.StatusCreated, Response: message(openapi.Fields{"secret": "", "data": domain.WebhookEndpoint{}})},
+	{Method: "PATCH", Path: "/settings/webhooks/:id", Tag: "Webhooks", Summary: "Editar endpoint (URL, eventos, activar/desactivar)", Auth: openapi.AuthTenant, Permission: perm(authz.WebhooksManage),
+		Body: UpdateWebhookReq{}, Response: domain.WebhookEndpoint{}},
+	{Method: "DELETE", Path: "/settings/webhooks/:id", Tag: "Webhooks", Summary: "Eliminar endpoint y su bitácora", Auth: openapi.AuthTenant, Permission: perm(authz.WebhooksManage),
+		Response: message(openapi.Fields{"url": ""})},
+	{Method: "POST", Path: "/settings/webhooks/:id/rotate-secret", Tag: "Webhooks", Summary: "Rotar secreto (el nuevo solo se muestra aquí)", Auth: openapi.AuthTenant, Permission: perm(authz.WebhooksManage),
+		Response: message(openapi.Fields{"secret": "", "data": domain.WebhookEndpoint{}})},
+	{Method: "GET", Path: "/settings/webhooks/:id/deliveries", Tag: "Webhooks", Summary: "Bitácora de entregas (un registro por intento)", Auth: openapi.AuthTenant, Permission: perm(authz.WebhooksManage),
+		Params: listParams(webhookAttemptList), Response: []domain.WebhookAttempt{}, Paged: true},
+	{Method: "POST", Path: "/settings/webhooks/:id/events/:event_id/redeliver", Tag: "Webhooks", Summary: "Reenviar un evento al endpoint",
+		Description: "Los eventos se conservan 30 días. 409 webhook_endpoint_inactive si el endpoint está desactivado.", Auth: openapi.AuthTenant, Permission: perm(authz.WebhooksManage),
+		Status: http.StatusAccepted, Response: message(openapi.Fields{"event_id": "", "type": ""})},
+
+	// --- API KEYS ---
+	{Method: "POST", Path: "/api-keys", Tag: "API keys", Summary: "Crear llave (el secreto solo se muestra aquí)", Auth: openapi.AuthTenant, Permission: perm(authz.APIKeysManage),
+		Body: CreateKeyReq{}, Status: http.StatusCreated, Response: message(openapi.Fields{"key": "", "data": domain.APIKey{}})},
+	{Method: "GET", Path: "/api-keys", Tag: "API keys", Summary: "Listar llaves (sin secreto)", Auth: openapi.AuthTenant, Permission: perm(authz.APIKeysManage),
+		Params: listParams(apiKeyList), Response: []domain.APIKey{}, Paged: true},
+	{Method: "DELETE", Path: "/api-keys/:id", Tag: "API keys", Summary: "Revocar llave", Auth: openapi.AuthTenant, Permission: perm(authz.APIKeysManage),
+		Response: message(openapi.Fields{"data": domain.APIKey{}})},
+
+	// --- AUDITORÍA ---
+	{Method: "GET", Path: "/audit-logs", Tag: "Auditoría", Summary: "Bitácora de cambios", Auth: openapi.AuthTenant, Permission: perm(authz.AuditRead),
+		Params: auditParams, Response: []domain.AuditLog{}, Paged: true},
+	{Method: "GET", Path: "/audit-logs/entities/:type/:id", Tag: "Auditoría", Summary: "Historial de una entidad", Auth: openapi.AuthTenant, Permission: perm(authz.AuditRead),
+		Params: auditParams, Response: []domain.AuditLog{}, Paged: true},
+	{Method: "GET", Path: "/audit-logs/users/:clerk_id", Tag: "Auditoría", Summary: "Cambios hechos por un usuario", Auth: openapi.AuthTenant, Permission: perm(authz.AuditRead),
+		Params: auditParams, Response: []domain.AuditLog{}, Paged: true},
+
+	// --- PLAN ---
+	{Method: "GET", Path: "/tenants/usage", Tag: "Empresa", Summary: "Consumo vs límites del plan", Auth: openapi.AuthTenant, Permission: perm(authz.DashboardRead),
+		Response: openapi.Fields{
+			"plan": "", "usage": map[string]entitlements.Usage{},
+			"modules": []entitlements.Module{}, "telemetry_retention_days": 0,
+		}},
+
+	// --- EXPORTACIÓN Y BORRADO ---
+	{Method: "POST", Path: "/tenants/export", Tag: "Empresa", Summary: "Exportar todos los datos (trabajo asíncrono)", Auth: openapi.AuthPerson, Permission: perm(authz.TenantManage),
+		Status: http.StatusAccepted, Response: message(openapi.Fields{"data": domain.TenantJob{}, "status_url": ""})},
+	{Method: "POST", Path: "/tenants/deletion", Tag: "Empresa", Summary: "Borrar la empresa (solo el dueño, confirmando el nombre)", Auth: openapi.AuthPerson, Permission: perm(authz.TenantManage),
+		Body: DeletionReq{}, Status: http.StatusAccepted, Response: message(openapi.Fields{"data": domain.TenantJob{}, "status_url": ""})},
+	{Method: "GET", Path: "/tenants/jobs", Tag: "Empresa", Summary: "Historial de exportaciones y borrados", Auth: openapi.AuthTenant, Permission: perm(authz.TenantManage),
+		Params: listParams(tenantJobList), Response: []domain.TenantJob{}, Paged: true},
+	{Method: "GET", Path: "/tenant-jobs/:id", Tag: "Empresa", Summary: "Estado de un trabajo (polling)", Auth: openapi.AuthUser,
+		Response: openapi.Fields{"data": domain.TenantJob{}, "download_url": ""}},
+	{Method: "GET", Path: "/tenant-jobs/:id/download", Tag: "Empresa", Summary: "Descargar el ZIP de una exportación", Auth: openapi.AuthUser,
+		Response: &openapi.Schema{Type: "string", Format: "binary"}, Produces: "application/zip"},
+
+	// --- GESTIÓN ---
+	{Method: "PUT", Path: "/tenants", Tag: "Empresa", Summary: "Editar datos de la empresa activa", Auth: openapi.AuthTenant, Permission: perm(authz.TenantManage),
+		Body: UpdateTenantReq{}, Response: domain.Tenant{}},
+	{Method: "POST", Path: "/farms", Tag: "Catálogos", Summary: "Alta de rancho", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogWrite),
+		Body: CreateFarmReq{}, Status: http.StatusCreated, Response: domain.Farm{}},
+	{Method: "POST", Path: "/chemicals", Tag: "Catálogos", Summary: "Alta de químico (catálogo privado)", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogWrite),
+		Body: CreateChemicalReq{}, Status: http.StatusCreated, Response: domain.Chemical{}},
+	{Method: "POST", Path: "/crops", Tag: "Catálogos", Summary: "Alta de cultivo", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogWrite),
+		Body: CreateCropReq{}, Status: http.StatusCreated, Response: domain.Crop{}},
+	{Method: "POST", Path: "/harvest-batches", Tag: "Catálogos", Summary: "Alta de lote de cosecha", Auth: openapi.AuthTenant, Permission: perm(authz.CatalogWrite),
+		Body: CreateBatchReq{}, Status: http.StatusCreated, Response: domain.HarvestBatch{}},
+	{Method: "GET", Path: "/bins", Tag: "Campo", Summary: "Listar cajas", Auth: openapi.AuthTenant, Permission: perm(authz.BinsRead),
+		Params: listParams(binList), Response: []domain.Bin{}, Paged: true},
+
+	// --- TIERRA ---
+	{Method: "POST", Path: "/land/contracts", Tag: "Tierra", Summary: "Alta de contrato de arrendamiento", Auth: openapi.AuthTenant, Permission: perm(authz.LandWrite), Module: module(entitlements.ModuleLand),
+		Body: CreateContractReq{}, Status: http.StatusCreated, Response: domain.LeaseContract{}},
+	{Method: "GET", Path: "/land/contracts", Tag: "Tierra", Summary: "Listar contratos", Auth: openapi.AuthTenant, Permission: perm(authz.LandRead), Module: module(entitlements.ModuleLand),
+		Params: listParams(contractList), Response: []domain.LeaseContract{}, Paged: true},
+	{Method: "GET", Path: "/land/alerts", Tag: "Tierra", Summary: "Contratos activos que vencen en 60 días", Auth: openapi.AuthTenant, Permission: perm(authz.LandRead), Module: module(entitlements.ModuleLand),
+		Response: []domain.LeaseContract{}},
+
+	// --- LOGÍSTICA ---
+	{Method: "POST", Path: "/shipments", Tag: "Logística", Summary: "Despachar embarque con cajas recibidas en empaque",
+		Description: "Cada caja debe estar recibida en empaque y sin embarque (409 bin_not_in_packing). Emite el evento shipment.dispatched.", Auth: openapi.AuthTenant, Permission: perm(authz.LogisticsWrite),
+		Body: CreateShipmentReq{}, Status: http.StatusCreated, Response: domain.Shipment{}},
+	{Method: "POST", Path: "/claims", Tag: "Logística", Summary: "Registrar reclamo (marca el embarque en disputa)", Auth: openapi.AuthTenant, Permission: perm(authz.LogisticsWrite),
+		Body: CreateClaimReq{}, Status: http.StatusCreated, Response: domain.Claim{}},
+	{Method: "GET", Path: "/claims", Tag: "Logística", Summary: "Listar reclamos", Auth: openapi.AuthTenant, Permission: perm(authz.LogisticsRead),
+		Params: listParams(claimList), Response: []domain.Claim{}, Paged: true},
+	{Method: "GET", Path: "/shipments", Tag: "Logística", Summary: "Listar embarques enviados", Auth: openapi.AuthTenant, Permission: perm(authz.LogisticsRead),
+		Params: listParams(shipmentList), Response: []domain.Shipment{}, Paged: true},
+
+	// --- FINANZAS ---
+	{Method: "POST", Path: "/finance/seasons", Tag: "Finanzas", Summary: "Alta de temporada", Auth: openapi.AuthTenant, Permission: perm(authz.FinanceWrite), Module: module(entitlements.ModuleFinance),
+		Body: CreateSeasonReq{}, Status: http.StatusCreated, Response: domain.Season{}},
+	{Method: "GET", Path: "/finance/seasons", Tag: "Finanzas", Summary: "Listar temporadas", Auth: openapi.AuthTenant, Permission: perm(authz.FinanceRead), Module: module(entitlements.ModuleFinance),
+		Params: listParams(seasonList), Response: []domain.Season{}, Paged: true},
+	{Method: "POST", Path: "/finance/categories", Tag: "Finanzas", Summary: "Alta de categoría de costo", Auth: openapi.AuthTenant, Permission: perm(authz.FinanceWrite), Module: module(entitlements.ModuleFinance),
+		Body: CreateCategoryReq{}, Status: http.StatusCreated, Response: domain.CostCategory{}},
+	{Method: "GET", Path: "/finance/categories", Tag: "Finanzas", Summary: "Listar categorías padre con sus hijas", Auth: openapi.AuthTenant, Permission: perm(authz.FinanceRead), Module: module(entitlements.ModuleFinance),
+		Params: listParams(categoryList), Response: []domain.CostCategory{}, Paged: true},
+	{Method: "POST", Path: "/finance/budgets", Tag: "Finanzas", Summary: "Asignar presupuesto (crea 201 o actualiza 200)", Auth: openapi.AuthTenant, Permission: perm(authz.FinanceWrite), Module: module(entitlements.ModuleFinance),
+		Body: BudgetReq{}, Status: http.StatusCreated, Response: domain.Budget{}},
+	{Method: "GET", Path: "/finance/budgets", Tag: "Finanzas", Summary: "Presupuestos de una temporada y rancho", Auth: openapi.AuthTenant, Permission: perm(authz.FinanceRead), Module: module(entitlements.ModuleFinance),
+		Query: seasonFarmQuery{}, Params: listParams(budgetList), Response: []domain.Budget{}, Paged: true},
+	{Method: "POST", Path: "/finance/expenses", Tag: "Finanzas", Summary: "Registrar gasto", Auth: openapi.AuthTenant, Permission: perm(authz.FinanceWrite), Module: module(entitlements.ModuleFinance),
+		Body: CreateExpenseReq{}, Status: http.StatusCreated, Response: domain.Expense{}},
+	{Method: "GET", Path: "/finance/report/variance", Tag: "Finanzas", Summary: "Presupuesto vs gasto por categoría", Auth: openapi.AuthTenant, Permission: perm(authz.FinanceRead), Module: module(entitlements.ModuleFinance),
+		Query: seasonFarmQuery{}, Response: openapi.Fields{"budget_totals": []categoryTotal{}, "expense_totals": []categoryTotal{}}},
+}
+
+// categoryTotal: Fila de /finance/report/variance (los handlers la escanean sin tags JSON)
+type categoryTotal struct {
+	CostCategoryID uuid.UUID
+	Total          float64
+}
+
+// apiSpec: Documento generado una sola vez (la tabla no cambia en ejecución)
+var apiSpec = sync.OnceValue(func() *openapi.Document {
+	return openapi.Build(openapi.Options{
+		Info: openapi.Info{
+			Title:   "AgriTrust API",
+			Version: "1.0",
+			Description: "API multi-empresa de AgriTrust (web admin, app móvil, gateways IoT e integraciones). " +
+				"Los errores usan el sobre común (error, code, details); los listados devuelven un arreglo y paginan con " +
+				listing.NextCursorHeader + ". Cada grupo de rutas tiene un presupuesto de peticiones por ventana (headers RateLimit-*); " +
+				"al agotarlo responde 429 con Retry-After.",
+		},
+		ErrorBody:         errorBody{},
+		TenantHeader:      middleware.TenantHeader,
+		APIKeyHeader:      middleware.APIKeyHeader,
+		NextCursorHeader:  listing.NextCursorHeader,
+		IdempotencyHeader: middleware.IdempotencyHeader,
+	}, apiRoutes)
+})
+
+// docsPage: Swagger UI desde CDN apuntando a /openapi.json
+const docsPage = `<!DOCTYPE html>
+<html lang="es">
+<head>
+  <meta charset="utf-8">
+  <title>AgriTrust API</title>
+  <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui", persistAuthorization: true });
+  </script>
+</body>
+</html>`
+
+func registerDocsRoutes(r *gin.Engine, limit gin.HandlerFunc) {
+	r.GET("/openapi.json", limit, func(c *gin.Context) {
+		c.JSON(http.StatusOK, apiSpec())
+	})
+
+	r.GET("/docs", limit, func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+	})
+}
+
+// registeredRoutes: Rutas que gin tiene registradas
+func registeredRoutes(r *gin.Engine) []openapi.Registered {
+	routes := r.Routes()
+	out := make([]openapi.Registered, 0, len(routes))
+	for _, route := range routes {
+		out = append(out, openapi.Registered{Method: route.Method, Path: route.Path})
+	}
+	return out
+}
+
+// offlineRouter arma el router completo sin conexión a la base (solo para inspeccionar rutas)
+func offlineRouter() (*gin.Engine, error) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := middleware.NewTokenVerifier(middleware.VerifierConfig{AllowDevKey: true}, nil) // No valida tokens: solo se listan rutas
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config.Config{}
+	cfg.CORS.AllowedOrigins = []string{"*"}
+	var draining atomic.Bool
+	return newRouter(cfg, db, verifier, &draining), nil
+}
+
+// ---------------------------------------------------------
+// 📘 SUBCOMANDO: openapi check | dump
+// ---------------------------------------------------------
+// No necesita base ni configuración: `go run ./cmd/api openapi check` en CI
+func runOpenAPI(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "uso: openapi check | dump")
+		return 2
+	}
+
+	switch args[0] {
+	case "check":
+		gin.SetMode(gin.ReleaseMode)
+		r, err := offlineRouter()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❌", err)
+			return 1
+		}
+		registered := registeredRoutes(r)
+		missing := openapi.Missing(apiRoutes, registered)
+		stale := openapi.Stale(apiRoutes, registered)
+		for _, m := range missing {
+			fmt.Printf("❌ %-6s %s registrada en gin pero no documentada (agrégala a apiRoutes)\n", m.Method, m.Path)
+		}
+		for _, s := range stale {
+			fmt.Printf("❌ %-6s %s documentada pero ya no existe en el router\n", s.Method, s.Path)
+		}
+		if len(missing)+len(stale) > 0 {
+			return 1
+		}
+		fmt.Printf("✅ %d rutas documentadas\n", len(registered))
+
+	case "dump":
+		out, err := json.MarshalIndent(apiSpec(), "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❌", err)
+			return 1
+		}
+		fmt.Println(string(out))
+
+	default:
+		fmt.Fprintln(os.Stderr, "uso: openapi check | dump")
+		return 2
+	}
+	return 0
+}
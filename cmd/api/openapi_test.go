@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Marcos1394/agritrust-backend/internal/openapi"
+)
+
+// Cada ruta de gin debe estar en apiRoutes y viceversa (lo mismo que `openapi check`)
+func TestAPIRoutesMatchRouter(t *testing.T) {
+	r, err := offlineRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	registered := registeredRoutes(r)
+	for _, m := range openapi.Missing(apiRoutes, registered) {
+		t.Errorf("%s %s registrada en gin pero no documentada (agrégala a apiRoutes)", m.Method, m.Path)
+	}
+	for _, s := range openapi.Stale(apiRoutes, registered) {
+		t.Errorf("%s %s documentada pero ya no existe en el router", s.Method, s.Path)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/middleware"
+	"github.com/Marcos1394/agritrust-backend/internal/outbox"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 📮 ENTREGAS: Correos de la empresa en el outbox
+// ---------------------------------------------------------
+// Alertas, invitaciones y resúmenes se guardan junto con el cambio que los origina y los entrega
+// el despachador (internal/outbox). Aquí el admin ve cuáles siguen pendientes o se quedaron sin
+// entregar (dead) y los puede reintentar, por ejemplo después de corregir un correo mal escrito.
+
+func registerOutboxRoutes(scoped *gin.RouterGroup, tdb func(*gin.Context) *gorm.DB) {
+	canManage := middleware.RequirePermission(authz.TenantManage)
+
+	// Listar mensajes (ej: ?status=dead para los que agotaron los intentos)
+	scoped.GET("/settings/outbox", canManage, func(c *gin.Context) {
+		listPage[domain.OutboxMessage](c, tdb(c), outboxList)
+	})
+
+	// Reintentar ya: un dead vuelve a la fila con los intentos en cero
+	scoped.POST("/settings/outbox/:id/retry", canManage, func(c *gin.Context) {
+		var msg domain.OutboxMessage
+		if !findOr404(c, tdb(c), &msg, c.Param("id"), "outbox_message") {
+			return
+		}
+		if err := outbox.Retry(tdb(c), &msg); errors.Is(err, outbox.ErrAlreadySent) {
+			apierr.Respond(c, apierr.New(http.StatusConflict, "outbox_already_sent"))
+			return
+		} else if err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "Mensaje en la fila de entrega", "data": msg})
+	})
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/google/uuid"
+)
+
+// ---------------------------------------------------------
+// 📨 PETICIONES: Bodies y query strings de la API
+// ---------------------------------------------------------
+// Los handlers bindean estos structs (apierr.BindJSON / BindQuery) y la especificación
+// OpenAPI los publica tal cual (ver openapi.go): cambiar un tag aquí cambia ambos.
+
+// --- EMPRESA Y EQUIPO ---
+
+type CreateTenantReq struct {
+	Name string `json:"name" binding:"required,max=255"`
+	RFC  string `json:"rfc" binding:"omitempty,min=12,max=13"`
+	Plan string `json:"plan" binding:"omitempty,oneof=basic pro enterprise"`
+}
+
+type UpdateTenantReq struct {
+	Name string `json:"name" binding:"required,max=255"`
+	RFC  string `json:"rfc" binding:"omitempty,min=12,max=13"`
+}
+
+type DeletionReq struct {
+	Confirm string `json:"confirm" binding:"required"` // Nombre exacto de la empresa
+}
+
+type JoinReq struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type InviteReq struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=admin operator viewer"`
+}
+
+type MemberRoleReq struct {
+	Role string `json:"role" binding:"required,oneof=admin operator viewer"`
+}
+
+type FarmsReq struct {
+	FarmIDs []uuid.UUID `json:"farm_ids"`
+}
+
+type RolePermissionsReq struct {
+	Permissions []authz.Permission `json:"permissions" binding:"required,min=1"` // Para volver al default se usa DELETE
+}
+
+type AddRecipientReq struct {
+	Topic string `json:"topic" binding:"required"` // Ej: security
+	Email string `json:"email" binding:"required,email"`
+}
+
+type CreateKeyReq struct {
+	ServiceAccount string             `json:"service_account" binding:"required,max=100"` // Ej: "Gateway Riego Rancho Norte"
+	Scopes         []authz.Permission `json:"scopes" binding:"required,min=1"`            // Ej: ["telemetry:write"]
+	ExpiresInDays  int                `json:"expires_in_days" binding:"gte=0,max=3650"`   // 0 = no expira
+}
+
+type CreateWebhookReq struct {
+	URL         string   `json:"url" binding:"required,url,max=2048"`         // Ej: https://erp.example.com/agritrust
+	Description string   `json:"description" binding:"max=255"`               // Ej: "ERP Contabilidad"
+	EventTypes  []string `json:"event_types" binding:"required,min=1,max=20"` // Ej: ["claim.opened"]
+}
+
+// UpdateWebhookReq: Solo se cambian los campos que vienen
+type UpdateWebhookReq struct {
+	URL         *string   `json:"url" binding:"omitnil,url,max=2048"`
+	Description *string   `json:"description" binding:"omitnil,max=255"`
+	EventTypes  *[]string `json:"event_types" binding:"omitnil,min=1,max=20"`
+	Active      *bool     `json:"active"`
+}
+
+// --- CATÁLOGOS ---
+// Los Update*Req son bodies de PATCH: campos puntero con el mismo nombre que en el modelo
+// (ver catalogs.go). El campo que no viene no cambia.
+
+type CreateFarmReq struct {
+	tenantField
+	Name          string  `json:"name" binding:"required,max=255"`
+	TotalArea     float64 `json:"total_area" binding:"gte=0"`
+	Location      string  `json:"location" binding:"max=2000"`
+	OwnershipType string  `json:"ownership_type" binding:"omitempty,oneof=own rented litigation"`
+}
+
+type UpdateFarmReq struct {
+	Name          *string  `json:"name" binding:"omitnil,min=1,max=255"`
+	TotalArea     *float64 `json:"total_area" binding:"omitnil,gte=0"`
+	Location      *string  `json:"location" binding:"omitnil,max=2000"`
+	OwnershipType *string  `json:"ownership_type" binding:"omitnil,oneof=own rented litigation"`
+}
+
+type CreateChemicalReq struct {
+	TenantID         *uuid.UUID `json:"tenant_id"`
+	Name             string     `json:"name" binding:"required,max=255"`
+	ActiveIngredient string     `json:"active_ingredient" binding:"max=255"`
+	IsBanned         bool       `json:"is_banned"`
+	BannedMarkets    string     `json:"banned_markets" binding:"max=255"`
+}
+
+type UpdateChemicalReq struct {
+	Name             *string `json:"name" binding:"omitnil,min=1,max=255"`
+	ActiveIngredient *string `json:"active_ingredient" binding:"omitnil,max=255"`
+	IsBanned         *bool   `json:"is_banned"`
+	BannedMarkets    *string `json:"banned_markets" binding:"omitnil,max=255"`
+}
+
+type CreateCropReq struct {
+	tenantField
+	FarmID       uuid.UUID `json:"farm_id" binding:"required"`
+	Name         string    `json:"name" binding:"required,max=255"`
+	Variety      string    `json:"variety" binding:"max=255"`
+	PlantingDate time.Time `json:"planting_date"`
+	Status       string    `json:"status" binding:"omitempty,oneof=growing harvesting finished"`
+}
+
+type UpdateCropReq struct {
+	FarmID       *uuid.UUID `json:"farm_id"`
+	Name         *string    `json:"name" binding:"omitnil,min=1,max=255"`
+	Variety      *string    `json:"variety" binding:"omitnil,max=255"`
+	PlantingDate *time.Time `json:"planting_date"`
+	Status       *string    `json:"status" binding:"omitnil,oneof=growing harvesting finished"`
+}
+
+type CreateBatchReq struct {
+	tenantField
+	FarmID    uuid.UUID `json:"farm_id" binding:"required"`
+	CropID    uuid.UUID `json:"crop_id" binding:"required"`
+	BatchCode string    `json:"batch_code" binding:"max=50"`
+}
+
+// --- OPERACIONES DE CAMPO ---
+
+type ApplicationReq struct {
+	TenantID   uuid.UUID `json:"tenant_id"` // Opcional (apps viejas); si viene, debe coincidir
+	FarmID     uuid.UUID `json:"farm_id" binding:"required"`
+	ChemicalID uuid.UUID `json:"chemical_id" binding:"required"`
+	Dosage     float64   `json:"dosage" binding:"gt=0"`
+	Unit       string    `json:"unit" binding:"required,max=20"`
+	Notes      string    `json:"notes" binding:"max=2000"`
+}
+
+type ScanRequest struct {
+	QRCode         string    `json:"qr_code" binding:"required,max=255"`
+	HarvestBatchID uuid.UUID `json:"harvest_batch_id" binding:"required"`
+	Weight         float64   `json:"weight" binding:"gt=0"`
+	TenantID       uuid.UUID `json:"tenant_id"`
+}
+
+type ReceiveBinReq struct {
+	QRCode string `json:"qr_code" binding:"required,max=255"`
+}
+
+// --- SINCRONIZACIÓN OFFLINE ---
+
+type SyncOp struct {
+	OpID       string          `json:"op_id" binding:"required,max=100"` // Lo genera la app (UUID): identifica la operación en reenvíos
+	Type       string          `json:"type" binding:"required,max=30"`   // bin_scan, application, asset_usage
+	ClientTime time.Time       `json:"client_time" binding:"required"`   // Hora del dispositivo cuando pasó en campo
+	Payload    json.RawMessage `json:"payload" binding:"required"`       // ScanRequest, ApplicationReq o AssetUsageOp
+}
+
+type SyncPushReq struct {
+	DeviceID   string   `json:"device_id" binding:"required,max=100"`
+	Operations []SyncOp `json:"operations" binding:"required,min=1,max=500,dive"` // En el orden en que se capturaron
+}
+
+type AssetUsageOp struct {
+	AssetID   uuid.UUID `json:"asset_id" binding:"required"`
+	AddAmount float64   `json:"add_amount" binding:"gt=0"`
+}
+
+// --- FLOTA ---
+
+type CreateAssetReq struct {
+	tenantField
+	Name            string  `json:"name" binding:"required,max=255"`
+	Type            string  `json:"type" binding:"max=50"` // tractor, truck, pump, implement
+	Brand           string  `json:"brand" binding:"max=100"`
+	Model           string  `json:"model" binding:"max=100"`
+	SerialNumber    string  `json:"serial_number" binding:"max=100"`
+	UsageUnit       string  `json:"usage_unit" binding:"omitempty,oneof=hours km"`
+	CurrentUsage    float64 `json:"current_usage" binding:"gte=0"`
+	ServiceInterval float64 `json:"service_interval" binding:"gte=0"`
+	NextServiceAt   float64 `json:"next_service_at" binding:"gte=0"`
+}
+
+// UpdateAssetReq: El uso se suma con /usage; el siguiente servicio lo reprograma /maintenance o este PATCH
+type UpdateAssetReq struct {
+	Name            *string  `json:"name" binding:"omitnil,min=1,max=255"`
+	Type            *string  `json:"type" binding:"omitnil,max=50"`
+	Brand           *string  `json:"brand" binding:"omitnil,max=100"`
+	Model           *string  `json:"model" binding:"omitnil,max=100"`
+	SerialNumber    *string  `json:"serial_number" binding:"omitnil,max=100"`
+	Status          *string  `json:"status" binding:"omitnil,oneof=active in_shop broken_down"`
+	UsageUnit       *string  `json:"usage_unit" binding:"omitnil,oneof=hours km"`
+	ServiceInterval *float64 `json:"service_interval" binding:"omitnil,gte=0"`
+	NextServiceAt   *float64 `json:"next_service_at" binding:"omitnil,gte=0"`
+}
+
+type UsageReq struct {
+	AddAmount float64 `json:"add_amount" binding:"gt=0"`
+}
+
+type MaintenanceReq struct {
+	Type           string  `json:"type" binding:"omitempty,oneof=preventive corrective"`
+	Description    string  `json:"description" binding:"required,max=2000"`
+	Cost           float64 `json:"cost" binding:"gte=0"`
+	UsageAtService float64 `json:"usage_at_service" binding:"gte=0"`
+	MechanicName   string  `json:"mechanic_name" binding:"max=255"`
+}
+
+// --- IOT ---
+
+type CreateDeviceReq struct {
+	tenantField
+	FarmID       uuid.UUID `json:"farm_id" binding:"required"`
+	Name         string    `json:"name" binding:"required,max=100"`
+	Type         string    `json:"type" binding:"required,oneof=moisture_sensor flow_meter valve"`
+	MinThreshold float64   `json:"min_threshold"`
+	MaxThreshold float64   `json:"max_threshold"`
+}
+
+type UpdateDeviceReq struct {
+	FarmID       *uuid.UUID `json:"farm_id"`
+	Name         *string    `json:"name" binding:"omitnil,min=1,max=100"`
+	Type         *string    `json:"type" binding:"omitnil,oneof=moisture_sensor flow_meter valve"`
+	MinThreshold *float64   `json:"min_threshold"`
+	MaxThreshold *float64   `json:"max_threshold"`
+}
+
+type TelemetryQuery struct {
+	DeviceID string `form:"device_id" binding:"required,uuid"`
+	Period   string `form:"period" binding:"omitempty,oneof=24h 7d"`
+}
+
+type TelemetryReading struct {
+	DeviceID  uuid.UUID `json:"device_id" binding:"required"`
+	Value     float64   `json:"value"`
+	Timestamp time.Time `json:"timestamp"` // Vacío = hora de llegada
+}
+
+type IngestReq struct {
+	Readings []TelemetryReading `json:"readings" binding:"required,min=1,dive"`
+}
+
+// --- COMPRAS E INVENTARIO ---
+
+type CreateSupplierReq struct {
+	tenantField
+	Name        string `json:"name" binding:"required,max=255"`
+	TaxID       string `json:"tax_id" binding:"max=20"`
+	ContactName string `json:"contact_name" binding:"max=255"`
+	Email       string `json:"email" binding:"omitempty,email"`
+	Phone       string `json:"phone" binding:"max=30"`
+	CreditDays  int    `json:"credit_days" binding:"gte=0,lte=365"`
+}
+
+type UpdateSupplierReq struct {
+	Name        *string `json:"name" binding:"omitnil,min=1,max=255"`
+	TaxID       *string `json:"tax_id" binding:"omitnil,max=20"`
+	ContactName *string `json:"contact_name" binding:"omitnil,max=255"`
+	Email       *string `json:"email" binding:"omitnil,email"`
+	Phone       *string `json:"phone" binding:"omitnil,max=30"`
+	CreditDays  *int    `json:"credit_days" binding:"omitnil,gte=0,lte=365"`
+}
+
+type OrderItemReq struct {
+	ProductID uuid.UUID `json:"product_id" binding:"required"`
+	Quantity  float64   `json:"quantity" binding:"gt=0"`
+	UnitCost  float64   `json:"unit_cost" binding:"gte=0"`
+}
+
+type CreateOrderReq struct {
+	tenantField
+	SupplierID   uuid.UUID      `json:"supplier_id" binding:"required"`
+	OrderNumber  string         `json:"order_number" binding:"max=50"`
+	Notes        string         `json:"notes" binding:"max=2000"`
+	ExpectedDate time.Time      `json:"expected_date"`
+	Items        []OrderItemReq `json:"items" binding:"required,min=1,dive"`
+}
+
+type CreateProductReq struct {
+	tenantField
+	Name          string     `json:"name" binding:"required,max=255"`
+	SKU           string     `json:"sku" binding:"max=50"`
+	Category      string     `json:"category" binding:"max=50"` // Chemical, PackingMaterial, Fuel, SparePart
+	Unit          string     `json:"unit" binding:"required,max=20"`
+	CurrentStock  float64    `json:"current_stock" binding:"gte=0"`
+	MinStockLevel float64    `json:"min_stock_level" binding:"gte=0"`
+	AvgCost       float64    `json:"avg_cost" binding:"gte=0"`
+	ChemicalID    *uuid.UUID `json:"chemical_id"`
+}
+
+// UpdateProductReq: Stock y costo promedio solo cambian con movimientos de almacén
+type UpdateProductReq struct {
+	Name          *string    `json:"name" binding:"omitnil,min=1,max=255"`
+	SKU           *string    `json:"sku" binding:"omitnil,max=50"`
+	Category      *string    `json:"category" binding:"omitnil,max=50"`
+	Unit          *string    `json:"unit" binding:"omitnil,min=1,max=20"`
+	MinStockLevel *float64   `json:"min_stock_level" binding:"omitnil,gte=0"`
+	ChemicalID    *uuid.UUID `json:"chemical_id"`
+}
+
+type InReq struct {
+	ProductID   uuid.UUID `json:"product_id" binding:"required"`
+	Quantity    float64   `json:"quantity" binding:"gt=0"`
+	CostPerUnit float64   `json:"cost_per_unit" binding:"gte=0"`
+	Reference   string    `json:"reference" binding:"max=255"` // Factura
+}
+
+// --- TIERRA Y LOGÍSTICA ---
+
+type CreateContractReq struct {
+	tenantField
+	FarmID         uuid.UUID `json:"farm_id" binding:"required"`
+	LandownerName  string    `json:"landowner_name" binding:"required,max=255"`
+	StartDate      time.Time `json:"start_date" binding:"required"`
+	EndDate        time.Time `json:"end_date" binding:"required"`
+	PaymentAmount  float64   `json:"payment_amount" binding:"gte=0"`
+	PaymentFreq    string    `json:"payment_freq" binding:"omitempty,oneof=monthly yearly harvest_end"`
+	ContractDocURL string    `json:"contract_doc_url" binding:"omitempty,url"`
+}
+
+type UpdateContractReq struct {
+	LandownerName  *string    `json:"landowner_name" binding:"omitnil,min=1,max=255"`
+	StartDate      *time.Time `json:"start_date"`
+	EndDate        *time.Time `json:"end_date"`
+	PaymentAmount  *float64   `json:"payment_amount" binding:"omitnil,gte=0"`
+	PaymentFreq    *string    `json:"payment_freq" binding:"omitnil,oneof=monthly yearly harvest_end"`
+	ContractDocURL *string    `json:"contract_doc_url" binding:"omitnil,url"`
+	Status         *string    `json:"status" binding:"omitnil,oneof=active expired negotiation"`
+}
+
+type CreateShipmentReq struct {
+	CustomerName  string      `json:"customer_name" binding:"required,max=255"` // Ej: "Whole Foods Market"
+	Destination   string      `json:"destination" binding:"required,max=255"`   // Ej: "McAllen, TX"
+	TruckPlate    string      `json:"truck_plate" binding:"max=20"`
+	DepartureTime time.Time   `json:"departure_time"`                           // Vacío = ahora
+	BinIDs        []uuid.UUID `json:"bin_ids" binding:"required,min=1,max=500"` // Cajas recibidas en empaque que suben al camión
+}
+
+type CreateClaimReq struct {
+	tenantField
+	ShipmentID    uuid.UUID `json:"shipment_id" binding:"required"`
+	ClaimDate     time.Time `json:"claim_date"`
+	Reason        string    `json:"reason" binding:"required,max=255"`
+	AmountUSD     float64   `json:"amount_usd" binding:"gte=0"`
+	EvidenceURL   string    `json:"evidence_url" binding:"omitempty,url"`
+	InternalNotes string    `json:"internal_notes" binding:"max=2000"`
+}
+
+// --- FINANZAS ---
+
+type CreateSeasonReq struct {
+	tenantField
+	Name      string    `json:"name" binding:"required,max=100"`
+	StartDate time.Time `json:"start_date" binding:"required"`
+	EndDate   time.Time `json:"end_date" binding:"required"`
+}
+
+type UpdateSeasonReq struct {
+	Name      *string    `json:"name" binding:"omitnil,min=1,max=100"`
+	StartDate *time.Time `json:"start_date"`
+	EndDate   *time.Time `json:"end_date"`
+	Active    *bool      `json:"active"`
+}
+
+type CreateCategoryReq struct {
+	tenantField
+	Name     string     `json:"name" binding:"required,max=100"`
+	Code     string     `json:"code" binding:"max=20"`
+	Color    string     `json:"color" binding:"omitempty,hexcolor"`
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+type UpdateCategoryReq struct {
+	Name     *string    `json:"name" binding:"omitnil,min=1,max=100"`
+	Code     *string    `json:"code" binding:"omitnil,max=20"`
+	Color    *string    `json:"color" binding:"omitnil,hexcolor"`
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+type BudgetReq struct {
+	tenantField
+	SeasonID       uuid.UUID `json:"season_id" binding:"required"`
+	FarmID         uuid.UUID `json:"farm_id" binding:"required"`
+	CostCategoryID uuid.UUID `json:"cost_category_id" binding:"required"`
+	Month          int       `json:"month" binding:"required,min=1,max=12"`
+	Year           int       `json:"year" binding:"required,min=2000,max=2100"`
+	Amount         float64   `json:"amount" binding:"gte=0"`
+}
+
+type CreateExpenseReq struct {
+	tenantField
+	SeasonID       uuid.UUID `json:"season_id" binding:"required"`
+	FarmID         uuid.UUID `json:"farm_id" binding:"required"`
+	CostCategoryID uuid.UUID `json:"cost_category_id" binding:"required"`
+	Description    string    `json:"description" binding:"max=2000"`
+	ExpenseDate    time.Time `json:"expense_date"`
+	Amount         float64   `json:"amount" binding:"gt=0"`
+	ReceiptURL     string    `json:"receipt_url" binding:"omitempty,url"`
+}
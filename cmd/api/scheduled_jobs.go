@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/entitlements"
+	"github.com/Marcos1394/agritrust-backend/internal/metrics"
+	"github.com/Marcos1394/agritrust-backend/internal/middleware"
+	"github.com/Marcos1394/agritrust-backend/internal/outbox"
+	"github.com/Marcos1394/agritrust-backend/internal/ratelimit"
+	"github.com/Marcos1394/agritrust-backend/internal/scheduler"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/Marcos1394/agritrust-backend/pkg/mailer"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// ⏰ TAREAS PROGRAMADAS: Purgas y resúmenes por empresa
+// ---------------------------------------------------------
+// El motor (candado entre réplicas, reintentos, historial) está en internal/scheduler.
+// Las horas son UTC: 13:00 UTC = 7:00 en el centro de México.
+// Los resúmenes van a los destinatarios del tema de alerta (o al dueño) y solo se mandan si hay algo que avisar.
+// Se guardan en el outbox (internal/outbox): el proveedor de correo caído no hace fallar la corrida.
+
+// leaseAlertWindow: Un contrato activo que vence dentro de este plazo se avisa (/land/alerts y resumen semanal)
+const leaseAlertWindow = 60 * 24 * time.Hour
+
+// jobRunRetention: Historial de corridas que se conserva
+const jobRunRetention = 90 * 24 * time.Hour
+
+// outboxSentRetention y outboxDeadRetention: Correos entregados y sin entregar que se conservan en el outbox
+const (
+	outboxSentRetention = 30 * 24 * time.Hour
+	outboxDeadRetention = 90 * 24 * time.Hour
+)
+
+// serviceDueMargin: Un activo entra al resumen cuando le falta menos de esta fracción del intervalo para su servicio
+const serviceDueMargin = 0.1
+
+func registerScheduledJobs(s *scheduler.Scheduler, db *gorm.DB) error {
+	jobs := []scheduler.Job{
+		// Telemetría fuera de la retención del plan, ZIPs de exportación vencidos, respuestas guardadas por
+		// Idempotency-Key, historial viejo de estas mismas tareas y mensajes viejos del outbox
+		{Name: "daily_purge", Schedule: "0 9 * * *", Run: func(ctx context.Context, run *scheduler.Run) error {
+			return dailyPurge(ctx, db, run)
+		}},
+		// Lunes: contratos vencidos pasan a expired y se avisa de los que vencen pronto
+		{Name: "lease_expiry", Schedule: "0 13 * * 1", Run: func(ctx context.Context, run *scheduler.Run) error {
+			return forEachTenant(ctx, db, run, entitlements.ModuleLand, leaseExpiryDigest)
+		}},
+		{Name: "asset_service_due", Schedule: "0 13 * * *", Run: func(ctx context.Context, run *scheduler.Run) error {
+			return forEachTenant(ctx, db, run, entitlements.ModuleFleet, assetServiceDigest)
+		}},
+		{Name: "low_stock_digest", Schedule: "0 13 * * *", Run: func(ctx context.Context, run *scheduler.Run) error {
+			return forEachTenant(ctx, db, run, "", lowStockDigest)
+		}},
+	}
+	for _, job := range jobs {
+		if err := s.Register(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dailyPurge(ctx context.Context, db *gorm.DB, run *scheduler.Run) error {
+	sysDB := db.WithContext(tenancy.WithoutScope(ctx))
+	now := time.Now()
+	telemetry, errTelemetry := entitlements.PurgeTelemetry(sysDB, now)
+	exports, errExports := purgeExpiredExports(sysDB, now)
+	keys, errKeys := middleware.PurgeIdempotencyKeys(sysDB, now)
+	runs, errRuns := scheduler.PurgeRuns(sysDB, now.Add(-jobRunRetention))
+	messages, errMessages := outbox.Purge(sysDB, now.Add(-outboxSentRetention), now.Add(-outboxDeadRetention))
+	events, errEvents := purgeEvents(sysDB, now.Add(-eventRetention))
+	buckets, errBuckets := ratelimit.Purge(sysDB, now)
+	run.Summarize("%d lecturas de telemetría, %d exportaciones, %d idempotency keys, %d corridas, %d mensajes del outbox, %d eventos y entregas de webhooks, %d contadores de rate limit",
+		telemetry, exports, keys, runs, messages, events, buckets)
+	return errors.Join(errTelemetry, errExports, errKeys, errRuns, errMessages, errEvents, errBuckets)
+}
+
+// forEachTenant corre fn para cada empresa activa cuyo plan incluye el módulo (vacío = todas).
+// Una empresa que falla no detiene a las demás, y el reintento de la corrida salta las que ya
+// terminaron (no les repite el correo). fn devuelve cuántos avisos dejó en el outbox.
+func forEachTenant(ctx context.Context, db *gorm.DB, run *scheduler.Run, module entitlements.Module,
+	fn func(ctx context.Context, tdb *gorm.DB, tenant domain.Tenant) (int, error)) error {
+	var tenants []domain.Tenant
+	if err := db.WithContext(tenancy.WithoutScope(ctx)).Where("active = ?", true).Order("id").Find(&tenants).Error; err != nil {
+		return err
+	}
+	var errs []error
+	done, notices := 0, 0
+	for _, tenant := range tenants {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		if module != "" && !entitlements.For(tenant.Plan).HasModule(module) {
+			continue
+		}
+		if run.Done(tenant.ID.String()) {
+			continue
+		}
+		tenantCtx := tenancy.WithTenant(ctx, tenant.ID)
+		n, err := fn(tenantCtx, db.WithContext(tenantCtx), tenant)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("empresa %s: %w", tenant.ID, err))
+			continue
+		}
+		done++
+		notices += n
+		if err := run.Mark(ctx, tenant.ID.String()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	run.Summarize("%d empresas, %d avisos, %d errores", done, notices, len(errs))
+	return errors.Join(errs...)
+}
+
+// expiringLeases: Contratos activos que vencen dentro de leaseAlertWindow
+func expiringLeases(tdb *gorm.DB, now time.Time) ([]domain.LeaseContract, error) {
+	var leases []domain.LeaseContract
+	err := tdb.Preload("Farm", withDeleted).
+		Where("status = 'active' AND end_date BETWEEN ? AND ?", now, now.Add(leaseAlertWindow)).
+		Order("end_date").
+		Find(&leases).Error
+	return leases, err
+}
+
+// leaseExpiryDigest avisa de los contratos vencidos y por vencer, y marca los vencidos como expired.
+// El aviso y el cambio van en la misma transacción: si la actualización falla, el reintento vuelve a avisar.
+func leaseExpiryDigest(ctx context.Context, tdb *gorm.DB, tenant domain.Tenant) (int, error) {
+	now := time.Now()
+	var expired []domain.LeaseContract
+	if err := tdb.Preload("Farm", withDeleted).
+		Where("status = 'active' AND end_date < ?", now).
+		Order("end_date").
+		Find(&expired).Error; err != nil {
+		return 0, err
+	}
+	expiring, err := expiringLeases(tdb, now)
+	if err != nil {
+		return 0, err
+	}
+	if len(expired) == 0 && len(expiring) == 0 {
+		return 0, nil
+	}
+
+	rows := make([][]string, 0, len(expired)+len(expiring))
+	for _, l := range expired {
+		rows = append(rows, []string{l.Farm.Name, l.LandownerName, l.EndDate.Format("2006-01-02"), "Vencido"})
+	}
+	for _, l := range expiring {
+		days := int(l.EndDate.Sub(now).Hours() / 24)
+		rows = append(rows, []string{l.Farm.Name, l.LandownerName, l.EndDate.Format("2006-01-02"), "Vence en " + strconv.Itoa(days) + " días"})
+	}
+	body := mailer.GetDigestTemplate("📄 Contratos de renta por vencer",
+		fmt.Sprintf("%s: contratos vencidos o que vencen en los próximos %d días.", tenant.Name, int(leaseAlertWindow.Hours()/24)),
+		[]string{"Rancho", "Arrendador", "Vence", "Estado"}, rows)
+	sent := 0
+	err = tdb.Transaction(func(tx *gorm.DB) error {
+		n, err := queueAlert(tx, tenant.ID, domain.AlertLeases, "Contratos de renta por vencer", body)
+		if err != nil {
+			return err
+		}
+		sent = n
+		if len(expired) == 0 {
+			return nil
+		}
+		ids := make([]interface{}, len(expired))
+		for i, l := range expired {
+			ids[i] = l.ID
+		}
+		return tx.Model(&domain.LeaseContract{}).
+			Where("id IN ? AND status = 'active'", ids).
+			Updates(map[string]interface{}{"status": "expired", "version": nextVersion}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return sent, nil
+}
+
+// assetServiceDigest avisa de la maquinaria que ya llegó (o está por llegar) a su siguiente servicio
+func assetServiceDigest(ctx context.Context, tdb *gorm.DB, tenant domain.Tenant) (int, error) {
+	var assets []domain.Asset
+	if err := tdb.
+		Where("status <> 'in_shop' AND next_service_at > 0 AND current_usage >= next_service_at - service_interval * ?", serviceDueMargin).
+		Order("name").
+		Find(&assets).Error; err != nil {
+		return 0, err
+	}
+	if len(assets) == 0 {
+		return 0, nil
+	}
+
+	rows := make([][]string, 0, len(assets))
+	for _, a := range assets {
+		state := fmt.Sprintf("Faltan %g %s", a.NextServiceAt-a.CurrentUsage, a.UsageUnit)
+		if a.CurrentUsage >= a.NextServiceAt {
+			state = fmt.Sprintf("Atrasado %g %s", a.CurrentUsage-a.NextServiceAt, a.UsageUnit)
+		}
+		rows = append(rows, []string{a.Name, a.Type, fmt.Sprintf("%g %s", a.CurrentUsage, a.UsageUnit), fmt.Sprintf("%g %s", a.NextServiceAt, a.UsageUnit), state})
+	}
+	body := mailer.GetDigestTemplate("🔧 Servicios de maquinaria pendientes",
+		tenant.Name+": activos que ya requieren servicio o están por requerirlo.",
+		[]string{"Activo", "Tipo", "Uso actual", "Servicio a las", "Estado"}, rows)
+	return queueAlert(tdb, tenant.ID, domain.AlertMaintenance, "Servicios de maquinaria pendientes", body)
+}
+
+// lowStockDigest avisa de los productos en o bajo su mínimo de stock (los que no tienen mínimo no se revisan)
+func lowStockDigest(ctx context.Context, tdb *gorm.DB, tenant domain.Tenant) (int, error) {
+	var products []domain.Product
+	if err := tdb.
+		Where("min_stock_level > 0 AND current_stock <= min_stock_level").
+		Order("name").
+		Find(&products).Error; err != nil {
+		return 0, err
+	}
+	if len(products) == 0 {
+		return 0, nil
+	}
+
+	rows := make([][]string, 0, len(products))
+	for _, p := range products {
+		rows = append(rows, []string{p.Name, p.SKU, fmt.Sprintf("%g %s", p.CurrentStock, p.Unit), fmt.Sprintf("%g %s", p.MinStockLevel, p.Unit)})
+	}
+	body := mailer.GetDigestTemplate("📦 Productos bajo el mínimo de stock",
+		tenant.Name+": productos que conviene reordenar.",
+		[]string{"Producto", "SKU", "Stock", "Mínimo"}, rows)
+	return queueAlert(tdb, tenant.ID, domain.AlertInventory, "Productos bajo el mínimo de stock", body)
+}
+
+// registerJobRoutes: Estado e historial de las tareas (operación, mismo token que /metrics)
+func registerJobRoutes(r *gin.Engine, db *gorm.DB, token string) {
+	ops := r.Group("/ops", metrics.RequireToken(token))
+	sysDB := func(c *gin.Context) *gorm.DB {
+		return db.WithContext(tenancy.WithoutScope(c.Request.Context()))
+	}
+	findJob := func(c *gin.Context) (domain.ScheduledJob, bool) {
+		var job domain.ScheduledJob
+		err := sysDB(c).First(&job, "name = ?", c.Param("name")).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierr.Respond(c, apierr.NotFound("scheduled_job"))
+			return job, false
+		}
+		if err != nil {
+			respondDBError(c, err)
+			return job, false
+		}
+		return job, true
+	}
+
+	// Estado de todas las tareas (candado, próxima corrida, último resultado)
+	ops.GET("/jobs", func(c *gin.Context) {
+		var jobs []domain.ScheduledJob
+		if err := sysDB(c).Order("name").Find(&jobs).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": jobs, "server_time": time.Now().UTC()})
+	})
+
+	// Historial de corridas de una tarea
+	ops.GET("/jobs/:name/runs", func(c *gin.Context) {
+		job, ok := findJob(c)
+		if !ok {
+			return
+		}
+		listPage[domain.JobRun](c, sysDB(c).Where("job_name = ?", job.Name), jobRunList)
+	})
+
+	// Correr ya (la toma la siguiente revisión de cualquier réplica)
+	ops.POST("/jobs/:name/run", func(c *gin.Context) {
+		job, ok := findJob(c)
+		if !ok {
+			return
+		}
+		// Al terminar, la corrida en curso reprograma la tarea y pisaría la petición
+		if job.LockedUntil != nil && job.LockedUntil.After(time.Now()) {
+			apierr.Respond(c, apierr.New(http.StatusConflict, "scheduled_job_running", "locked_by", job.LockedBy))
+			return
+		}
+		if _, err := scheduler.RunNow(sysDB(c), job.Name); err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "Tarea programada para correr ya", "name": job.Name})
+	})
+}
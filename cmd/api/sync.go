@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/entitlements"
+	"github.com/Marcos1394/agritrust-backend/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ---------------------------------------------------------
+// 📶 SINCRONIZACIÓN OFFLINE (App Móvil)
+// ---------------------------------------------------------
+// Los operadores pasan horas sin señal. La app:
+//   1. Baja los catálogos que cambiaron desde su última marca (GET /sync/pull?since=<watermark>),
+//      junto con los IDs que se dieron de baja en ese lapso.
+//   2. Encola en el teléfono lo que registra en campo (escaneos, aplicaciones, horas de uso).
+//   3. Al recuperar señal sube la cola completa (POST /sync/push) y recibe un resultado por operación.
+// Cada operación se aplica en su propia transacción, en el orden de la cola, con las mismas reglas
+// que las rutas en línea (ver fieldops.go). Un reenvío del lote no duplica nada: el op_id ya
+// procesado devuelve el resultado guardado.
+
+// syncWatermarkLag: La marca que se devuelve va un poco atrás de la hora del servidor para no perder
+// cambios de transacciones que aún no confirmaban; la app recibe esos registros otra vez (upsert).
+const syncWatermarkLag = time.Minute
+
+// syncResult: Resultado de una operación de /sync/push
+type syncResult struct {
+	OpID     string      `json:"op_id"`
+	Type     string      `json:"type"`
+	Status   string      `json:"status"`             // applied, rejected, conflict, error (reintentar después)
+	Replayed bool        `json:"replayed,omitempty"` // Ya se había procesado en un envío anterior
+	Data     interface{} `json:"data,omitempty"`     // Registro creado o actualizado
+	Error    gin.H       `json:"error,omitempty"`    // Mismo sobre que las respuestas de error (code, error, details...)
+}
+
+// syncStatusError: Resultado transitorio (falla de la base): no se guarda y la app lo reintenta
+const syncStatusError = "error"
+
+// syncHandler: Permiso y módulo del plan que exige cada tipo de operación, y cómo aplicarla
+type syncHandler struct {
+	permission authz.Permission
+	module     entitlements.Module // Vacío = disponible en todos los planes
+	apply      func(c *gin.Context, tx *gorm.DB, op SyncOp, deviceID string) (interface{}, error)
+}
+
+var syncHandlers = map[string]syncHandler{
+	domain.SyncBinScan: {
+		permission: authz.BinsWrite,
+		apply: func(c *gin.Context, tx *gorm.DB, op SyncOp, deviceID string) (interface{}, error) {
+			var req ScanRequest
+			if err := apierr.DecodeJSON(op.Payload, &req); err != nil {
+				return nil, err
+			}
+			return scanBin(c, tx, req, op.ClientTime, deviceID)
+		},
+	},
+	domain.SyncApplication: {
+		permission: authz.ApplicationsCreate,
+		apply: func(c *gin.Context, tx *gorm.DB, op SyncOp, deviceID string) (interface{}, error) {
+			var req ApplicationReq
+			if err := apierr.DecodeJSON(op.Payload, &req); err != nil {
+				return nil, err
+			}
+			return recordApplication(c, tx, req, op.ClientTime)
+		},
+	},
+	domain.SyncAssetUsage: {
+		permission: authz.FleetUsage,
+		module:     entitlements.ModuleFleet,
+		apply: func(c *gin.Context, tx *gorm.DB, op SyncOp, deviceID string) (interface{}, error) {
+			var req AssetUsageOp
+			if err := apierr.DecodeJSON(op.Payload, &req); err != nil {
+				return nil, err
+			}
+			var asset domain.Asset
+			if err := tx.First(&asset, "id = ?", req.AssetID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apierr.Validation(apierr.MissingRef("asset_id", "asset"))
+			} else if err != nil {
+				return nil, err
+			}
+			if err := addAssetUsage(tx, &asset, req.AddAmount); err != nil {
+				return nil, err
+			}
+			return asset, nil
+		},
+	},
+}
+
+func registerSyncRoutes(scoped *gin.RouterGroup, tdb func(*gin.Context) *gorm.DB, heavy gin.HandlerFunc) {
+	// Catálogos que cambiaron desde la marca (sin since = todo)
+	scoped.GET("/sync/pull", middleware.RequirePermission(authz.CatalogRead), func(c *gin.Context) {
+		var since time.Time
+		if v := c.Query("since"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				apierr.Respond(c, apierr.Validation(apierr.Field("since", "rfc3339", "")))
+				return
+			}
+			since = t
+		}
+		watermark := time.Now().UTC().Add(-syncWatermarkLag)
+
+		changed := func(dest interface{}) error {
+			q := tdb(c)
+			if !since.IsZero() {
+				q = q.Where("updated_at >= ?", since)
+			}
+			return q.Order("updated_at, id").Find(dest).Error
+		}
+		var (
+			farms     []domain.Farm
+			chemicals []domain.Chemical // Incluye los globales del sistema
+			crops     []domain.Crop
+			batches   []domain.HarvestBatch
+		)
+		for _, dest := range []interface{}{&farms, &chemicals, &crops, &batches} {
+			if err := changed(dest); err != nil {
+				respondDBError(c, err)
+				return
+			}
+		}
+
+		// Bajas desde la marca: la app borra esos IDs de su copia local (en la descarga completa no hacen falta)
+		deleted := gin.H{}
+		if !since.IsZero() {
+			for key, model := range map[string]interface{}{"farms": &domain.Farm{}, "chemicals": &domain.Chemical{}, "crops": &domain.Crop{}} {
+				ids := []uuid.UUID{}
+				if err := tdb(c).Unscoped().Model(model).Where("deleted_at >= ?", since).Order("id").Pluck("id", &ids).Error; err != nil {
+					respondDBError(c, err)
+					return
+				}
+				deleted[key] = ids
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"watermark":       watermark, // Mandarla como since en la siguiente descarga
+			"full":            since.IsZero(),
+			"farms":           farms,
+			"chemicals":       chemicals,
+			"crops":           crops,
+			"harvest_batches": batches,
+			"deleted":         deleted,
+		})
+	})
+
+	// Cola de operaciones capturadas sin señal
+	scoped.POST("/sync/push", heavy, func(c *gin.Context) {
+		var req SyncPushReq
+		if !apierr.BindJSON(c, &req) {
+			return
+		}
+		results := make([]syncResult, 0, len(req.Operations))
+		for _, op := range req.Operations {
+			results = append(results, applySyncOp(c, tdb(c), req.DeviceID, op))
+		}
+		c.JSON(http.StatusOK, gin.H{"results": results, "server_time": time.Now().UTC()})
+	})
+}
+
+// applySyncOp aplica una operación de la cola y guarda su resultado (salvo errores transitorios)
+func applySyncOp(c *gin.Context, db *gorm.DB, deviceID string, op SyncOp) syncResult {
+	if prev, ok := syncReplay(db, op.OpID); ok {
+		return prev
+	}
+
+	// Un reloj adelantado no puede dejar registros "en el futuro" (ganarían cualquier conflicto)
+	if now := time.Now(); op.ClientTime.After(now) {
+		op.ClientTime = now
+	}
+	res := syncResult{OpID: op.OpID, Type: op.Type}
+	record := domain.SyncOperation{
+		OpID:       op.OpID,
+		DeviceID:   deviceID,
+		Type:       op.Type,
+		Actor:      middleware.Actor(c),
+		ClientTime: op.ClientTime,
+	}
+
+	handler, known := syncHandlers[op.Type]
+	var err error
+	switch {
+	case !known:
+		err = apierr.Validation(apierr.Field("type", "oneof", domain.SyncBinScan+" "+domain.SyncApplication+" "+domain.SyncAssetUsage))
+	case !middleware.Permissions(c).Has(handler.permission):
+		err = apierr.New(http.StatusForbidden, "permission_denied", "permission", handler.permission)
+	case handler.module != "" && !entitlements.For(middleware.Plan(c)).HasModule(handler.module):
+		err = apierr.New(http.StatusForbidden, "plan_upgrade_required", "module", handler.module, "plan", middleware.Plan(c))
+	default:
+		// La operación y su constancia van juntas: si el lote se reenvía a medio proceso, no se aplica dos veces
+		err = db.Transaction(func(tx *gorm.DB) error {
+			data, err := handler.apply(c, tx, op, deviceID)
+			if err != nil {
+				return err
+			}
+			res.Status, res.Data = domain.SyncApplied, data
+			record.Status = domain.SyncApplied
+			record.Result, _ = json.Marshal(res)
+			return tx.Create(&record).Error
+		})
+	}
+	if err == nil {
+		return res
+	}
+	err = queueBannedAlert(db, err)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_sync_operation" {
+		// Otro envío del mismo lote ganó la carrera
+		if prev, ok := syncReplay(db, op.OpID); ok {
+			return prev
+		}
+	}
+	status, body := apierr.Body(c, dbError(err))
+	res.Data, res.Error = nil, body
+	switch {
+	case status >= http.StatusInternalServerError:
+		res.Status = syncStatusError
+		return res
+	case status == http.StatusConflict && body["code"] == "bin_scan_outdated":
+		res.Status = domain.SyncConflict
+	default:
+		res.Status = domain.SyncRejected
+	}
+	record.Status = res.Status
+	record.Result, _ = json.Marshal(res)
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+		_ = c.Error(err) // El resultado igual llega a la app; solo se pierde la constancia
+	}
+	return res
+}
+
+// syncReplay devuelve el resultado guardado de una operación ya procesada
+func syncReplay(db *gorm.DB, opID string) (syncResult, bool) {
+	var prev domain.SyncOperation
+	if err := db.Where("op_id = ?", opID).First(&prev).Error; err != nil {
+		return syncResult{}, false
+	}
+	var res syncResult
+	if err := json.Unmarshal(prev.Result, &res); err != nil {
+		return syncResult{}, false
+	}
+	res.Replayed = true
+	return res, true
+}
@@ -0,0 +1,629 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/entitlements"
+	"github.com/Marcos1394/agritrust-backend/internal/listing"
+	"github.com/Marcos1394/agritrust-backend/internal/middleware"
+	"github.com/Marcos1394/agritrust-backend/internal/outbox"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/Marcos1394/agritrust-backend/pkg/mailer"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 👥 EQUIPO: Invitaciones, Miembros, Roles
+// ---------------------------------------------------------
+
+// inviteStatuses: Valores de ?status= en /team/invites
+var inviteStatuses = []string{domain.InvitePending, domain.InviteAccepted, domain.InviteRevoked, domain.InviteExpired}
+
+// inviteTTL: Vigencia del link de invitación (se renueva al reenviar)
+const inviteTTL = 7 * 24 * time.Hour
+
+// newInviteToken genera el código del link y el hash que se guarda en la base
+func newInviteToken() (raw, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	raw = base64.RawURLEncoding.EncodeToString(buf)
+	return raw, hashInviteToken(raw), nil
+}
+
+func hashInviteToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// queueInviteEmail guarda el correo con el link en claro (único lugar donde existe el token) en el outbox.
+// tx es la transacción que crea o renueva la invitación: sin correo no queda invitación, y viceversa.
+// frontendURL: Web Admin donde vive la pantalla /join (FRONTEND_BASE_URL)
+func queueInviteEmail(tx *gorm.DB, frontendURL string, invite domain.Invitation, rawToken string) error {
+	inviteLink := fmt.Sprintf("%s/join?token=%s", frontendURL, rawToken)
+	return outbox.EnqueueEmail(tx, domain.OutboxInvitation, outbox.Email{
+		To:      []string{invite.Email},
+		Subject: "Invitación a colaborar en AgriTrust",
+		HTML:    mailer.GetInviteTemplate(inviteLink, invite.Role),
+	})
+}
+
+// joiningUserEmail: Correo verificado del usuario que acepta la invitación.
+// Primero el claim "email" del JWT; si la sesión no lo trae, el perfil sincronizado en users.
+func joiningUserEmail(c *gin.Context, sysDB *gorm.DB) string {
+	if email := c.GetString("user_email"); email != "" {
+		return normalizeEmail(email)
+	}
+	var user domain.User
+	if err := sysDB.Where("clerk_id = ?", c.GetString("clerk_user_id")).First(&user).Error; err == nil {
+		return normalizeEmail(user.Email)
+	}
+	return ""
+}
+
+// isActiveMember: El correo ya pertenece al dueño o a un miembro activo de la empresa de tx
+func isActiveMember(tx *gorm.DB, tenantID uuid.UUID, email string) (bool, error) {
+	var clerkIDs []string
+	if err := tx.Model(&domain.User{}).Where("LOWER(email) = ? AND deleted_at IS NULL", email).Pluck("clerk_id", &clerkIDs).Error; err != nil {
+		return false, err
+	}
+	if len(clerkIDs) == 0 {
+		return false, nil
+	}
+	var owners, members int64
+	if err := tx.Model(&domain.Tenant{}).Where("id = ? AND owner_id IN ?", tenantID, clerkIDs).Count(&owners).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Model(&domain.TeamMember{}).Where("user_id IN ? AND deactivated_at IS NULL", clerkIDs).Count(&members).Error; err != nil {
+		return false, err
+	}
+	return owners+members > 0, nil
+}
+
+// joinTeamHandler: Aceptar Invitación (ruta de cuenta: el usuario aún no tiene empresa activa)
+func joinTeamHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clerkUserID := c.GetString("clerk_user_id")
+
+		var req JoinReq
+		if !apierr.BindJSON(c, &req) {
+			return
+		}
+
+		// 1. Buscar Invitación por hash (aún no hay empresa activa: se busca como sistema)
+		sysDB := db.WithContext(tenancy.WithoutScope(c.Request.Context()))
+		var invite domain.Invitation
+		if err := sysDB.Where("token_hash = ?", hashInviteToken(req.Token)).First(&invite).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			apierr.Respond(c, apierr.New(http.StatusNotFound, "invite_invalid"))
+			return
+		} else if err != nil {
+			respondDBError(c, err)
+			return
+		}
+		switch invite.EffectiveStatus(time.Now()) {
+		case domain.InvitePending:
+		case domain.InviteExpired:
+			apierr.Respond(c, apierr.New(http.StatusGone, "invite_expired"))
+			return
+		default:
+			apierr.Respond(c, apierr.New(http.StatusGone, "invite_unavailable"))
+			return
+		}
+
+		// 2. La invitación es personal: el correo del usuario debe coincidir
+		email := joiningUserEmail(c, sysDB)
+		if email == "" {
+			apierr.Respond(c, apierr.New(http.StatusForbidden, "email_unverified"))
+			return
+		}
+		if email != normalizeEmail(invite.Email) {
+			apierr.Respond(c, apierr.New(http.StatusForbidden, "invite_email_mismatch"))
+			return
+		}
+
+		// 3. Evitar membresías duplicadas (incluye al dueño de la empresa).
+		// Una membresía desactivada no cuenta: se reactiva al aceptar.
+		var tenant domain.Tenant
+		if !findOr404(c, sysDB, &tenant, invite.TenantID, "tenant") {
+			return
+		}
+		var previous domain.TeamMember
+		err := sysDB.Where("tenant_id = ? AND user_id = ?", invite.TenantID, clerkUserID).First(&previous).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			respondDBError(c, err)
+			return
+		}
+		rejoining := err == nil
+		if (rejoining && previous.DeactivatedAt == nil) || tenant.OwnerID == clerkUserID {
+			apierr.Respond(c, apierr.New(http.StatusConflict, "already_member"))
+			return
+		}
+
+		// 4. El lugar ya estaba apartado por la invitación; si la empresa bajó de plan puede no caber
+		inviteDB := db.WithContext(tenancy.WithTenant(c.Request.Context(), invite.TenantID))
+		if err := entitlements.CheckQuota(inviteDB, tenant.Plan, entitlements.ResourceTeamMembers, 0); err != nil {
+			respondDBError(c, err)
+			return
+		}
+
+		// 5. Crear Membresía y consumir la invitación en una sola transacción
+		err = inviteDB.Transaction(func(tx *gorm.DB) error {
+			if rejoining {
+				if err := tx.Model(&previous).Updates(map[string]interface{}{"role": invite.Role, "joined_at": time.Now(), "deactivated_at": nil}).Error; err != nil {
+					return err
+				}
+			} else {
+				member := domain.TeamMember{
+					UserID:   clerkUserID,
+					Role:     invite.Role,
+					JoinedAt: time.Now(),
+				}
+				if err := tx.Create(&member).Error; err != nil {
+					return err
+				}
+			}
+			now := time.Now()
+			// El WHERE por status evita que dos clics simultáneos consuman la misma invitación
+			res := tx.Model(&domain.Invitation{}).
+				Where("id = ? AND status = ?", invite.ID, domain.InvitePending).
+				Updates(map[string]interface{}{"status": domain.InviteAccepted, "accepted_by": clerkUserID, "accepted_at": now})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errInviteConsumed
+			}
+			return nil
+		})
+		if errors.Is(err, errInviteConsumed) {
+			apierr.Respond(c, apierr.New(http.StatusConflict, "invite_consumed"))
+			return
+		}
+		if err != nil {
+			respondDBError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "¡Bienvenido al equipo!", "role": invite.Role, "tenant_id": invite.TenantID})
+	}
+}
+
+var errInviteConsumed = errors.New("invitación ya utilizada")
+
+// userProfiles: Perfiles sincronizados desde Clerk, indexados por Clerk ID
+func userProfiles(sysDB *gorm.DB, clerkIDs []string) (map[string]domain.User, error) {
+	profiles := map[string]domain.User{}
+	if len(clerkIDs) == 0 {
+		return profiles, nil
+	}
+	var users []domain.User
+	if err := sysDB.Where("clerk_id IN ?", clerkIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		profiles[u.ClerkID] = u
+	}
+	return profiles, nil
+}
+
+// actorLabel: Nombre y correo de quien hace la petición (para alertas).
+// Si el perfil aún no llegó por el webhook de Clerk, se usa el identificador crudo.
+func actorLabel(c *gin.Context, db *gorm.DB) string {
+	if middleware.IsServiceAccount(c) {
+		return middleware.Actor(c)
+	}
+	sysDB := db.WithContext(tenancy.WithoutScope(c.Request.Context()))
+	var user domain.User
+	if err := sysDB.Where("clerk_id = ?", c.GetString("clerk_user_id")).First(&user).Error; err == nil {
+		if name := user.DisplayName(); name != "" {
+			return name
+		}
+	}
+	return middleware.Actor(c)
+}
+
+func registerTeamRoutes(scoped *gin.RouterGroup, tdb func(*gin.Context) *gorm.DB, frontendURL string) {
+	canRead := middleware.RequirePermission(authz.TeamRead)
+	canManage := middleware.RequirePermission(authz.TeamManage)
+
+	// Invitar Colaborador
+	scoped.POST("/team/invite", canManage, func(c *gin.Context) {
+		var req InviteReq
+		if !apierr.BindJSON(c, &req) {
+			return
+		}
+		email := normalizeEmail(req.Email)
+
+		// Una sola invitación vigente por correo: para mandar otra se usa "reenviar"
+		var pending int64
+		if err := tdb(c).Model(&domain.Invitation{}).
+			Where("email = ? AND status = ? AND expires_at > ?", email, domain.InvitePending, time.Now()).
+			Count(&pending).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		if pending > 0 {
+			apierr.Respond(c, apierr.New(http.StatusConflict, "invite_pending_exists", "email", email))
+			return
+		}
+
+		// Invitar a quien ya está dentro solo apartaría un lugar del plan
+		member, err := isActiveMember(tdb(c), middleware.TenantID(c), email)
+		if err != nil {
+			respondDBError(c, err)
+			return
+		}
+		if member {
+			apierr.Respond(c, apierr.New(http.StatusConflict, "invite_already_member", "email", email))
+			return
+		}
+
+		// Las invitaciones vigentes apartan lugar en el plan
+		if !checkQuota(c, tdb(c), entitlements.ResourceTeamMembers, 1) {
+			return
+		}
+
+		rawToken, tokenHash, err := newInviteToken()
+		if err != nil {
+			apierr.Respond(c, apierr.Internal(err))
+			return
+		}
+
+		// Crear Invitación (en la empresa activa del admin)
+		now := time.Now()
+		invite := domain.Invitation{
+			Email:     email,
+			Role:      req.Role,
+			TokenHash: tokenHash,
+			Status:    domain.InvitePending,
+			InvitedBy: c.GetString("clerk_user_id"),
+			ExpiresAt: now.Add(inviteTTL),
+			SentAt:    now,
+			CreatedAt: now,
+		}
+		// La invitación y su correo van juntos (el outbox lo entrega con reintentos)
+		err = tdb(c).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&invite).Error; err != nil {
+				return err
+			}
+			return queueInviteEmail(tx, frontendURL, invite, rawToken)
+		})
+		if err != nil {
+			respondDBError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Invitación enviada por correo a " + invite.Email,
+			"data":    invite,
+		})
+	})
+
+	// Reenviar Invitación: genera un link nuevo (el anterior deja de servir) y renueva la vigencia
+	scoped.POST("/team/invites/:id/resend", canManage, func(c *gin.Context) {
+		var invite domain.Invitation
+		if !findOr404(c, tdb(c), &invite, c.Param("id"), "invitation") {
+			return
+		}
+		if invite.Status != domain.InvitePending {
+			apierr.Respond(c, apierr.New(http.StatusConflict, "invite_not_pending"))
+			return
+		}
+
+		rawToken, tokenHash, err := newInviteToken()
+		if err != nil {
+			apierr.Respond(c, apierr.Internal(err))
+			return
+		}
+		now := time.Now()
+		invite.TokenHash = tokenHash
+		invite.ExpiresAt = now.Add(inviteTTL)
+		invite.SentAt = now
+		err = tdb(c).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&invite).Updates(map[string]interface{}{
+				"token_hash": tokenHash, "expires_at": invite.ExpiresAt, "sent_at": now,
+			}).Error; err != nil {
+				return err
+			}
+			return queueInviteEmail(tx, frontendURL, invite, rawToken)
+		})
+		if err != nil {
+			respondDBError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invitación reenviada a " + invite.Email, "data": invite})
+	})
+
+	// Revocar Invitación
+	scoped.DELETE("/team/invites/:id", canManage, func(c *gin.Context) {
+		var invite domain.Invitation
+		if !findOr404(c, tdb(c), &invite, c.Param("id"), "invitation") {
+			return
+		}
+		if invite.Status == domain.InviteAccepted {
+			apierr.Respond(c, apierr.New(http.StatusConflict, "invite_already_accepted"))
+			return
+		}
+		if invite.Status != domain.InviteRevoked {
+			now := time.Now()
+			invite.Status = domain.InviteRevoked
+			invite.RevokedAt = &now
+			if err := tdb(c).Model(&invite).Updates(map[string]interface{}{"status": invite.Status, "revoked_at": now}).Error; err != nil {
+				respondDBError(c, err)
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Invitación revocada", "data": invite})
+	})
+
+	// Listar Miembros del Equipo (paginado por cursor) e invitaciones vigentes.
+	// Las vigentes apartan lugar en el plan, así que están acotadas; el historial va en /team/invites.
+	scoped.GET("/team", canRead, func(c *gin.Context) {
+		q, page, err := listing.Apply(c, tdb(c).Model(&domain.TeamMember{}), teamMemberList)
+		if err != nil {
+			apierr.Respond(c, err)
+			return
+		}
+		var members []domain.TeamMember
+		if err := q.Find(&members).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		members, ok := listing.Trim(c, page, members)
+		if !ok {
+			return
+		}
+
+		var invites []domain.Invitation
+		if err := tdb(c).Where("status = ? AND expires_at > ?", domain.InvitePending, time.Now()).
+			Order("created_at desc").Find(&invites).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+
+		// Nombre y correo de cada miembro de la página (sincronizados por el webhook de Clerk)
+		clerkIDs := make([]string, 0, len(members))
+		memberIDs := make([]uuid.UUID, 0, len(members))
+		for _, m := range members {
+			clerkIDs = append(clerkIDs, m.UserID)
+			memberIDs = append(memberIDs, m.ID)
+		}
+		profiles, err := userProfiles(tdb(c), clerkIDs)
+		if err != nil {
+			respondDBError(c, err)
+			return
+		}
+
+		// Ranchos asignados por miembro (los que no aparecen ven toda la empresa)
+		memberFarms := map[uuid.UUID][]uuid.UUID{}
+		if len(memberIDs) > 0 {
+			var assignments []domain.MemberFarm
+			if err := tdb(c).Where("team_member_id IN ?", memberIDs).Find(&assignments).Error; err != nil {
+				respondDBError(c, err)
+				return
+			}
+			for _, a := range assignments {
+				memberFarms[a.TeamMemberID] = append(memberFarms[a.TeamMemberID], a.FarmID)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"members": members, "users": profiles, "member_farms": memberFarms, "invites": invites})
+	})
+
+	// Historial de invitaciones (todos los estados), paginado por cursor
+	scoped.GET("/team/invites", canRead, func(c *gin.Context) {
+		q := tdb(c).Model(&domain.Invitation{})
+		now := time.Now()
+		switch status := c.Query("status"); status {
+		case "":
+		case domain.InvitePending:
+			q = q.Where("status = ? AND expires_at > ?", domain.InvitePending, now)
+		case domain.InviteExpired:
+			q = q.Where("status = ? AND expires_at <= ?", domain.InvitePending, now)
+		case domain.InviteAccepted, domain.InviteRevoked:
+			q = q.Where("status = ?", status)
+		default:
+			apierr.Respond(c, apierr.Validation(apierr.Field("status", "oneof", strings.Join(inviteStatuses, " "))))
+			return
+		}
+
+		q, page, err := listing.Apply(c, q, inviteList)
+		if err != nil {
+			apierr.Respond(c, err)
+			return
+		}
+		var invites []domain.Invitation
+		if err := q.Find(&invites).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		for i := range invites {
+			invites[i].Status = invites[i].EffectiveStatus(now)
+		}
+		listing.Respond(c, page, invites)
+	})
+
+	// Cambiar Rol de un Miembro
+	scoped.PATCH("/team/members/:id", canManage, func(c *gin.Context) {
+		var req MemberRoleReq
+		if !apierr.BindJSON(c, &req) {
+			return
+		}
+
+		var member domain.TeamMember
+		if !findOr404(c, tdb(c), &member, c.Param("id"), "team_member") {
+			return
+		}
+		member.Role = req.Role
+		if err := tdb(c).Model(&member).Update("role", req.Role).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, member)
+	})
+
+	// Eliminar Miembro (pierde acceso inmediato a la empresa)
+	scoped.DELETE("/team/members/:id", canManage, func(c *gin.Context) {
+		var member domain.TeamMember
+		if !findOr404(c, tdb(c), &member, c.Param("id"), "team_member") {
+			return
+		}
+		err := tdb(c).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("team_member_id = ?", member.ID).Delete(&domain.MemberFarm{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&member).Error
+		})
+		if err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Miembro eliminado del equipo"})
+	})
+
+	// Asignar Ranchos a un Miembro (lista vacía = acceso a todos los ranchos)
+	// La restricción aplica a operadores y lectores; un admin siempre ve toda la empresa.
+	scoped.PUT("/team/members/:id/farms", canManage, func(c *gin.Context) {
+		var req FarmsReq
+		if !apierr.BindJSON(c, &req) {
+			return
+		}
+
+		var member domain.TeamMember
+		if !findOr404(c, tdb(c), &member, c.Param("id"), "team_member") {
+			return
+		}
+		unique := map[uuid.UUID]bool{}
+		refs := make([]ref, 0, len(req.FarmIDs))
+		for i, farmID := range req.FarmIDs {
+			refs = append(refs, ref{fmt.Sprintf("farm_ids[%d]", i), "farm", &domain.Farm{}, farmID})
+			unique[farmID] = true
+		}
+		if !requireRefs(c, tdb(c), refs...) {
+			return
+		}
+
+		err := tdb(c).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("team_member_id = ?", member.ID).Delete(&domain.MemberFarm{}).Error; err != nil {
+				return err
+			}
+			for farmID := range unique {
+				if err := tx.Create(&domain.MemberFarm{TeamMemberID: member.ID, FarmID: farmID, CreatedAt: time.Now()}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			respondDBError(c, err)
+			return
+		}
+		farmIDs := make([]uuid.UUID, 0, len(unique))
+		for farmID := range unique {
+			farmIDs = append(farmIDs, farmID)
+		}
+		c.JSON(http.StatusOK, gin.H{"team_member_id": member.ID, "farm_ids": farmIDs, "restricted": len(farmIDs) > 0})
+	})
+
+	// Mis permisos en la empresa activa (para que el frontend oculte lo que no puedo hacer)
+	scoped.GET("/team/me", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"tenant_id":   middleware.TenantID(c),
+			"role":        c.GetString("tenant_role"),
+			"permissions": middleware.Permissions(c).List(),
+			"farm_ids":    middleware.FarmIDs(c), // null = todos los ranchos
+		})
+	})
+
+	// Matriz de Roles y Permisos de la empresa
+	scoped.GET("/team/roles", canRead, func(c *gin.Context) {
+		type RoleView struct {
+			Role        string             `json:"role"`
+			Customized  bool               `json:"customized"`
+			Permissions []authz.Permission `json:"permissions"`
+		}
+		roles := []RoleView{}
+		for _, role := range []string{authz.RoleAdmin, authz.RoleOperator, authz.RoleViewer} {
+			set, err := authz.Resolve(tdb(c), middleware.TenantID(c), role)
+			if err != nil {
+				respondDBError(c, err)
+				return
+			}
+			var overrides int64
+			if err := tdb(c).Model(&domain.RolePermission{}).Where("role = ?", role).Count(&overrides).Error; err != nil {
+				respondDBError(c, err)
+				return
+			}
+			roles = append(roles, RoleView{Role: role, Customized: overrides > 0, Permissions: set.List()})
+		}
+		c.JSON(http.StatusOK, gin.H{"roles": roles, "catalog": authz.All})
+	})
+
+	// Personalizar permisos de un rol (reemplaza la matriz default para esta empresa)
+	scoped.PUT("/team/roles/:role", canManage, func(c *gin.Context) {
+		role := c.Param("role")
+		if !authz.ValidRole(role) || role == authz.RoleAdmin {
+			apierr.Respond(c, apierr.New(http.StatusBadRequest, "role_not_customizable"))
+			return
+		}
+		var req RolePermissionsReq
+		if !apierr.BindJSON(c, &req) {
+			return
+		}
+		var unknown []apierr.FieldError
+		for i, p := range req.Permissions {
+			if !authz.ValidPermission(p) {
+				unknown = append(unknown, apierr.Field(fmt.Sprintf("permissions[%d]", i), "invalid", string(p)))
+			}
+		}
+		if len(unknown) > 0 {
+			apierr.Respond(c, apierr.Validation(unknown...))
+			return
+		}
+
+		err := tdb(c).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("role = ?", role).Delete(&domain.RolePermission{}).Error; err != nil {
+				return err
+			}
+			rows := make([]domain.RolePermission, 0, len(req.Permissions))
+			for _, p := range req.Permissions {
+				rows = append(rows, domain.RolePermission{Role: role, Permission: string(p), CreatedAt: time.Now()})
+			}
+			return tx.Create(&rows).Error
+		})
+		if err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"role": role, "permissions": req.Permissions})
+	})
+
+	// Restaurar la matriz default de un rol
+	scoped.DELETE("/team/roles/:role", canManage, func(c *gin.Context) {
+		role := c.Param("role")
+		if err := tdb(c).Where("role = ?", role).Delete(&domain.RolePermission{}).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Permisos restaurados al default", "role": role})
+	})
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/logging"
+	"github.com/Marcos1394/agritrust-backend/internal/middleware"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/Marcos1394/agritrust-backend/internal/tenantdata"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 📦 EXPORTACIÓN Y BORRADO DE LA EMPRESA (Trabajos asíncronos)
+// ---------------------------------------------------------
+// El admin pide el trabajo, recibe 202 con el ID y consulta el estado hasta que termine.
+// El estado se consulta por cuenta (no por empresa): después de un borrado la empresa ya no existe.
+
+// exportTTL: Tiempo que el ZIP queda disponible para descarga
+const exportTTL = 7 * 24 * time.Hour
+
+// runTenantJob ejecuta un trabajo en segundo plano y deja el resultado en la base.
+// Si el apagado corta ctx, el trabajo queda "running" y resumeTenantJobs lo relanza al arrancar.
+func runTenantJob(ctx context.Context, db *gorm.DB, jobID uuid.UUID) {
+	sysDB := db.WithContext(tenancy.WithoutScope(ctx))
+
+	var job domain.TenantJob
+	if err := sysDB.Omit("archive").First(&job, "id = ?", jobID).Error; err != nil {
+		slog.ErrorContext(ctx, "trabajo de empresa no encontrado", "job_id", jobID, "error", err)
+		return
+	}
+	started := time.Now()
+	sysDB.Model(&job).Updates(map[string]interface{}{"status": domain.JobRunning, "started_at": started})
+
+	updates := map[string]interface{}{"status": domain.JobCompleted, "error": ""}
+	switch job.Kind {
+	case domain.TenantJobExport:
+		archive, err := tenantdata.Export(ctx, db, job.TenantID)
+		if err != nil {
+			updates = map[string]interface{}{"status": domain.JobFailed, "error": err.Error()}
+			break
+		}
+		updates["archive"] = archive
+		updates["archive_size"] = len(archive)
+		updates["expires_at"] = time.Now().Add(exportTTL)
+	case domain.TenantJobDeletion:
+		if err := tenantdata.Erase(ctx, db, job.TenantID); err != nil {
+			updates = map[string]interface{}{"status": domain.JobFailed, "error": err.Error()}
+		}
+	default:
+		updates = map[string]interface{}{"status": domain.JobFailed, "error": "tipo de trabajo desconocido: " + job.Kind}
+	}
+	updates["finished_at"] = time.Now()
+
+	if err := sysDB.Model(&domain.TenantJob{}).Where("id = ?", job.ID).Updates(updates).Error; err != nil {
+		slog.ErrorContext(ctx, "no se pudo guardar el resultado del trabajo", "job_id", job.ID, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "trabajo de empresa terminado", "job_id", job.ID, "kind", job.Kind,
+		"status", updates["status"], "duration_ms", time.Since(started).Milliseconds())
+}
+
+// resumeTenantJobs relanza los trabajos que quedaron a medias por un reinicio.
+// Ambos tipos son idempotentes: reexportar o reborrar da el mismo resultado.
+func resumeTenantJobs(db *gorm.DB) {
+	sysDB := db.WithContext(tenancy.WithoutScope(context.Background()))
+	var ids []uuid.UUID
+	if err := sysDB.Model(&domain.TenantJob{}).
+		Where("status IN ?", []string{domain.JobQueued, domain.JobRunning}).
+		Pluck("id", &ids).Error; err != nil {
+		slog.Error("no se pudieron reanudar los trabajos de empresa", "error", err)
+		return
+	}
+	for _, id := range ids {
+		id := id
+		tasks.Go(logging.With(context.Background(), "tenant_job_id", id.String()), func(ctx context.Context) { runTenantJob(ctx, db, id) })
+	}
+}
+
+// purgeExpiredExports descarta los ZIP cuyo plazo de descarga ya venció (el registro del trabajo se conserva).
+// Devuelve cuántos descartó.
+func purgeExpiredExports(sysDB *gorm.DB, now time.Time) (int64, error) {
+	res := sysDB.Model(&domain.TenantJob{}).
+		Where("archive IS NOT NULL AND expires_at < ?", now).
+		Updates(map[string]interface{}{"archive": nil, "archive_size": 0})
+	return res.RowsAffected, res.Error
+}
+
+// requesterJob: Trabajo visible para el usuario que lo pidió
+func requesterJob(c *gin.Context, db *gorm.DB, withArchive bool) (domain.TenantJob, bool) {
+	sysDB := db.WithContext(tenancy.WithoutScope(c.Request.Context()))
+	q := sysDB.Where("requested_by = ?", c.GetString("clerk_user_id"))
+	if !withArchive {
+		q = q.Omit("archive")
+	}
+	var job domain.TenantJob
+	return job, findOr404(c, q, &job, c.Param("id"), "tenant_job")
+}
+
+func registerTenantJobRoutes(protected, scoped *gin.RouterGroup, db *gorm.DB, tdb func(*gin.Context) *gorm.DB, heavy gin.HandlerFunc) {
+	canManage := middleware.RequirePermission(authz.TenantManage)
+
+	// enqueue crea el trabajo (uno activo por tipo a la vez) y lo lanza
+	enqueue := func(c *gin.Context, kind string) {
+		var active int64
+		if err := tdb(c).Model(&domain.TenantJob{}).
+			Where("kind = ? AND status IN ?", kind, []string{domain.JobQueued, domain.JobRunning}).
+			Count(&active).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		if active > 0 {
+			apierr.Respond(c, apierr.New(http.StatusConflict, "job_in_progress"))
+			return
+		}
+		job := domain.TenantJob{
+			Kind:        kind,
+			Status:      domain.JobQueued,
+			RequestedBy: c.GetString("clerk_user_id"),
+		}
+		if err := tdb(c).Create(&job).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		tasks.Go(c.Request.Context(), func(ctx context.Context) { runTenantJob(ctx, db, job.ID) })
+		c.JSON(http.StatusAccepted, gin.H{"message": "Trabajo en proceso", "data": job, "status_url": "/tenant-jobs/" + job.ID.String()})
+	}
+
+	// Exportar todos los datos de la empresa (ZIP con JSON + CSV + manifest)
+	scoped.POST("/tenants/export", canManage, middleware.RequireUser(), heavy, func(c *gin.Context) {
+		enqueue(c, domain.TenantJobExport)
+	})
+
+	// Borrar la empresa y todos sus datos (irreversible: solo el dueño, confirmando el nombre)
+	scoped.POST("/tenants/deletion", canManage, middleware.RequireUser(), func(c *gin.Context) {
+		var req DeletionReq
+		if !apierr.BindJSON(c, &req) {
+			return
+		}
+		var tenant domain.Tenant
+		if !findOr404(c, tdb(c), &tenant, middleware.TenantID(c), "tenant") {
+			return
+		}
+		if tenant.OwnerID != c.GetString("clerk_user_id") {
+			apierr.Respond(c, apierr.New(http.StatusForbidden, "owner_required"))
+			return
+		}
+		if strings.TrimSpace(req.Confirm) != tenant.Name {
+			apierr.Respond(c, apierr.Validation(apierr.Field("confirm", "confirm_name", "")))
+			return
+		}
+		// Mientras se borra, la empresa queda en solo lectura
+		if err := tdb(c).Model(&tenant).Update("active", false).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		enqueue(c, domain.TenantJobDeletion)
+	})
+
+	// Historial de trabajos de la empresa
+	scoped.GET("/tenants/jobs", canManage, func(c *gin.Context) {
+		listPage[domain.TenantJob](c, tdb(c).Omit("archive"), tenantJobList)
+	})
+
+	// Estado de un trabajo (polling)
+	protected.GET("/tenant-jobs/:id", middleware.RequireUser(), func(c *gin.Context) {
+		job, ok := requesterJob(c, db, false)
+		if !ok {
+			return
+		}
+		resp := gin.H{"data": job}
+		if job.Kind == domain.TenantJobExport && job.Status == domain.JobCompleted && job.ArchiveSize > 0 {
+			resp["download_url"] = "/tenant-jobs/" + job.ID.String() + "/download"
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+
+	// Descargar el ZIP de una exportación
+	protected.GET("/tenant-jobs/:id/download", middleware.RequireUser(), func(c *gin.Context) {
+		job, ok := requesterJob(c, db, true)
+		if !ok {
+			return
+		}
+		if job.Kind != domain.TenantJobExport || job.Status != domain.JobCompleted || len(job.Archive) == 0 {
+			apierr.Respond(c, apierr.New(http.StatusConflict, "export_not_ready", "status", job.Status))
+			return
+		}
+		if job.ExpiresAt != nil && time.Now().After(*job.ExpiresAt) {
+			apierr.Respond(c, apierr.New(http.StatusGone, "export_expired"))
+			return
+		}
+		filename := fmt.Sprintf("agritrust-export-%s-%s.zip", job.TenantID, job.FinishedAt.Format("20060102"))
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Data(http.StatusOK, "application/zip", job.Archive)
+	})
+}
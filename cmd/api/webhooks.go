@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/middleware"
+	"github.com/Marcos1394/agritrust-backend/internal/outbox"
+	"github.com/Marcos1394/agritrust-backend/internal/webhook"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 🪝 WEBHOOKS SALIENTES: Eventos para los sistemas de la empresa
+// ---------------------------------------------------------
+// El cambio que origina un evento lo emite (emitEvent) en su misma transacción: se guarda el evento
+// y un mensaje del outbox por cada endpoint suscrito. El despachador de webhooks (internal/outbox)
+// lo firma y lo manda; si falla, reintenta con espera exponencial y cada intento queda en la bitácora
+// del endpoint. La firma y los headers están en internal/webhook.
+
+// webhookTimeout: Tope de cada envío (conexión + respuesta)
+const webhookTimeout = 10 * time.Second
+
+// eventRetention: Eventos y bitácora de entregas que se conservan (un evento más viejo ya no se reenvía)
+const eventRetention = 30 * 24 * time.Hour
+
+// webhookDelivery: Contenido del mensaje del outbox (el cuerpo se arma al enviar, desde el evento)
+type webhookDelivery struct {
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	EventID    uuid.UUID `json:"event_id"`
+}
+
+// emitEvent guarda el evento y lo pone en la fila de cada endpoint activo suscrito a su tipo.
+// tx debe ser la transacción del cambio que lo origina. Sin endpoints suscritos no guarda nada.
+func emitEvent(tx *gorm.DB, eventType string, data interface{}) error {
+	subscribed, err := subscribedEndpoints(tx, eventType)
+	if err != nil || len(subscribed) == 0 {
+		return err
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	event := domain.Event{Type: eventType, Data: body, CreatedAt: time.Now()}
+	if err := tx.Create(&event).Error; err != nil {
+		return err
+	}
+	for _, e := range subscribed {
+		if err := queueWebhook(tx, e, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscribedEndpoints: Endpoints activos de la empresa suscritos a eventType
+func subscribedEndpoints(tx *gorm.DB, eventType string) ([]domain.WebhookEndpoint, error) {
+	var endpoints []domain.WebhookEndpoint
+	if err := tx.Where("active = ?", true).Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	subscribed := endpoints[:0]
+	for _, e := range endpoints {
+		if subscribes(e, eventType) {
+			subscribed = append(subscribed, e)
+		}
+	}
+	return subscribed, nil
+}
+
+// emitThresholdBreaches emite sensor.threshold_breached cuando una lectura sale del rango de su dispositivo
+// y la anterior estaba dentro (una sola vez por salida, no en cada lectura fuera de rango). Se llama antes
+// de guardar points, que vienen en el orden del gateway: la anterior de la primera es la última guardada.
+func emitThresholdBreaches(tx *gorm.DB, devices map[uuid.UUID]domain.Device, points []domain.TelemetryData) error {
+	if subscribed, err := subscribedEndpoints(tx, domain.EventSensorThreshold); err != nil || len(subscribed) == 0 {
+		return err
+	}
+	byDevice := map[uuid.UUID][]domain.TelemetryData{}
+	for _, p := range points {
+		byDevice[p.DeviceID] = append(byDevice[p.DeviceID], p)
+	}
+	for id, readings := range byDevice {
+		device := devices[id]
+		if device.MinThreshold == 0 && device.MaxThreshold == 0 {
+			continue // Sin rango configurado
+		}
+		sort.SliceStable(readings, func(i, j int) bool { return readings[i].Timestamp.Before(readings[j].Timestamp) })
+
+		var last domain.TelemetryData
+		err := tx.Where("device_id = ? AND timestamp <= ?", id, readings[0].Timestamp).Order("timestamp DESC").First(&last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		wasOut := err == nil && outOfRange(device, last.Value)
+		for _, r := range readings {
+			out := outOfRange(device, r.Value)
+			if out && !wasOut {
+				data := gin.H{
+					"device_id": device.ID, "device_name": device.Name, "farm_id": device.FarmID,
+					"value": r.Value, "timestamp": r.Timestamp,
+					"min_threshold": device.MinThreshold, "max_threshold": device.MaxThreshold,
+				}
+				if err := emitEvent(tx, domain.EventSensorThreshold, data); err != nil {
+					return err
+				}
+			}
+			wasOut = out
+		}
+	}
+	return nil
+}
+
+// outOfRange: Un umbral en 0 se considera no configurado
+func outOfRange(device domain.Device, value float64) bool {
+	return (device.MinThreshold != 0 && value < device.MinThreshold) ||
+		(device.MaxThreshold != 0 && value > device.MaxThreshold)
+}
+
+func queueWebhook(tx *gorm.DB, endpoint domain.WebhookEndpoint, event domain.Event) error {
+	return outbox.Enqueue(tx, domain.OutboxWebhook, domain.OutboxWebhook, endpoint.URL, event.Type,
+		webhookDelivery{EndpointID: endpoint.ID, EventID: event.ID})
+}
+
+func subscribes(endpoint domain.WebhookEndpoint, eventType string) bool {
+	for _, t := range strings.Split(endpoint.EventTypes, ",") {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func validEventType(eventType string) bool {
+	for _, t := range domain.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWebhookInput valida lo que no cabe en tags: tipos de evento del catálogo y, en producción, https
+func checkWebhookInput(rawURL *string, eventTypes *[]string, requireHTTPS bool) []apierr.FieldError {
+	var details []apierr.FieldError
+	if rawURL != nil {
+		if u, err := url.Parse(*rawURL); err != nil || (u.Scheme != "https" && (requireHTTPS || u.Scheme != "http")) {
+			details = append(details, apierr.Field("url", "https_url", ""))
+		}
+	}
+	if eventTypes != nil {
+		for i, t := range *eventTypes {
+			if !validEventType(t) {
+				details = append(details, apierr.Field(fmt.Sprintf("event_types[%d]", i), "oneof", strings.Join(domain.EventTypes, " ")))
+			}
+		}
+	}
+	return details
+}
+
+// deliverWebhook: Handler del outbox para domain.OutboxWebhook. ctx trae la empresa del mensaje.
+func deliverWebhook(db *gorm.DB, client *http.Client) outbox.Handler {
+	return func(ctx context.Context, msg domain.OutboxMessage) error {
+		var d webhookDelivery
+		if err := json.Unmarshal(msg.Payload, &d); err != nil {
+			return fmt.Errorf("%w: contenido inválido: %v", outbox.ErrPermanent, err)
+		}
+		tdb := db.WithContext(ctx)
+		var endpoint domain.WebhookEndpoint
+		if err := tdb.First(&endpoint, "id = ?", d.EndpointID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: el endpoint se eliminó", outbox.ErrPermanent)
+		} else if err != nil {
+			return err
+		}
+		if !endpoint.Active {
+			return fmt.Errorf("%w: el endpoint está desactivado", outbox.ErrPermanent)
+		}
+		var event domain.Event
+		if err := tdb.First(&event, "id = ?", d.EventID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: el evento ya no existe", outbox.ErrPermanent)
+		} else if err != nil {
+			return err
+		}
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		res, err := webhook.Post(ctx, client, endpoint.URL, endpoint.Secret, event.ID.String(), event.Type, body)
+		attempt := domain.WebhookAttempt{
+			EndpointID:   endpoint.ID,
+			EventID:      event.ID,
+			EventType:    event.Type,
+			MessageID:    msg.ID,
+			Attempt:      msg.Attempts,
+			Success:      err == nil,
+			StatusCode:   res.StatusCode,
+			ResponseBody: res.Body,
+			DurationMS:   res.Duration.Milliseconds(),
+			CreatedAt:    time.Now(),
+		}
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+		if lerr := tdb.Create(&attempt).Error; lerr != nil {
+			slog.WarnContext(ctx, "no se pudo guardar el intento del webhook", "error", lerr)
+		}
+		if errors.Is(err, webhook.ErrPrivateAddress) {
+			return fmt.Errorf("%w: %v", outbox.ErrPermanent, err)
+		}
+		return err
+	}
+}
+
+// purgeEvents borra los eventos y la bitácora de entregas anteriores a before
+func purgeEvents(sysDB *gorm.DB, before time.Time) (int64, error) {
+	attempts := sysDB.Where("created_at < ?", before).Delete(&domain.WebhookAttempt{})
+	if attempts.Error != nil {
+		return attempts.RowsAffected, attempts.Error
+	}
+	events := sysDB.Where("created_at < ?", before).Delete(&domain.Event{})
+	return attempts.RowsAffected + events.RowsAffected, events.Error
+}
+
+// requireHTTPS: En producción los endpoints deben ser https (en desarrollo se permite http://localhost)
+func registerWebhookRoutes(scoped *gin.RouterGroup, tdb func(*gin.Context) *gorm.DB, requireHTTPS bool) {
+	manage := middleware.RequirePermission(authz.WebhooksManage)
+
+	// Listar endpoints (sin secreto) y los tipos de evento disponibles
+	scoped.GET("/settings/webhooks", manage, func(c *gin.Context) {
+		var endpoints []domain.WebhookEndpoint
+		if err := tdb(c).Order("created_at").Find(&endpoints).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": endpoints, "event_types": domain.EventTypes})
+	})
+
+	// Registrar endpoint (el secreto solo se muestra aquí y al rotarlo)
+	scoped.POST("/settings/webhooks", manage, func(c *gin.Context) {
+		var req CreateWebhookReq
+		if !apierr.BindJSON(c, &req) {
+			return
+		}
+		if details := checkWebhookInput(&req.URL, &req.EventTypes, requireHTTPS); len(details) > 0 {
+			apierr.Respond(c, apierr.Validation(details...))
+			return
+		}
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			apierr.Respond(c, apierr.Internal(err))
+			return
+		}
+		endpoint := domain.WebhookEndpoint{
+			URL:         req.URL,
+			Description: req.Description,
+			EventTypes:  strings.Join(req.EventTypes, ","),
+			Secret:      secret,
+			Active:      true,
+			CreatedBy:   c.GetString("clerk_user_id"),
+		}
+		if err := tdb(c).Create(&endpoint).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Guarda este secreto ahora: no se volverá a mostrar",
+			"secret":  secret,
+			"data":    endpoint,
+		})
+	})
+
+	// Editar endpoint (URL, descripción, eventos o activar/desactivar)
+	scoped.PATCH("/settings/webhooks/:id", manage, func(c *gin.Context) {
+		var endpoint domain.WebhookEndpoint
+		if !findOr404(c, tdb(c), &endpoint, c.Param("id"), "webhook_endpoint") {
+			return
+		}
+		var req UpdateWebhookReq
+		if !apierr.BindJSON(c, &req) {
+			return
+		}
+		if details := checkWebhookInput(req.URL, req.EventTypes, requireHTTPS); len(details) > 0 {
+			apierr.Respond(c, apierr.Validation(details...))
+			return
+		}
+		updates := map[string]interface{}{}
+		if req.URL != nil {
+			updates["url"] = *req.URL
+		}
+		if req.Description != nil {
+			updates["description"] = *req.Description
+		}
+		if req.EventTypes != nil {
+			updates["event_types"] = strings.Join(*req.EventTypes, ",")
+		}
+		if req.Active != nil {
+			updates["active"] = *req.Active
+		}
+		if len(updates) > 0 {
+			if err := tdb(c).Model(&endpoint).Updates(updates).Error; err != nil {
+				respondDBError(c, err)
+				return
+			}
+		}
+		c.JSON(http.StatusOK, endpoint)
+	})
+
+	// Rotar secreto: los envíos siguientes se firman con el nuevo
+	scoped.POST("/settings/webhooks/:id/rotate-secret", manage, func(c *gin.Context) {
+		var endpoint domain.WebhookEndpoint
+		if !findOr404(c, tdb(c), &endpoint, c.Param("id"), "webhook_endpoint") {
+			return
+		}
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			apierr.Respond(c, apierr.Internal(err))
+			return
+		}
+		if err := tdb(c).Model(&endpoint).Update("secret", secret).Error; err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Guarda este secreto ahora: no se volverá a mostrar", "secret": secret, "data": endpoint})
+	})
+
+	// Eliminar endpoint (y su bitácora). Los envíos pendientes quedan como dead al intentarse.
+	scoped.DELETE("/settings/webhooks/:id", manage, func(c *gin.Context) {
+		var endpoint domain.WebhookEndpoint
+		if !findOr404(c, tdb(c), &endpoint, c.Param("id"), "webhook_endpoint") {
+			return
+		}
+		err := tdb(c).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("endpoint_id = ?", endpoint.ID).Delete(&domain.WebhookAttempt{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&endpoint).Error
+		})
+		if err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Endpoint eliminado", "url": endpoint.URL})
+	})
+
+	// Bitácora de entregas (un registro por intento, el más reciente primero)
+	scoped.GET("/settings/webhooks/:id/deliveries", manage, func(c *gin.Context) {
+		var endpoint domain.WebhookEndpoint
+		if !findOr404(c, tdb(c), &endpoint, c.Param("id"), "webhook_endpoint") {
+			return
+		}
+		listPage[domain.WebhookAttempt](c, tdb(c).Where("endpoint_id = ?", endpoint.ID), webhookAttemptList)
+	})
+
+	// Reenviar un evento al endpoint (aunque ya se haya entregado o no esté suscrito a su tipo)
+	scoped.POST("/settings/webhooks/:id/events/:event_id/redeliver", manage, func(c *gin.Context) {
+		var endpoint domain.WebhookEndpoint
+		if !findOr404(c, tdb(c), &endpoint, c.Param("id"), "webhook_endpoint") {
+			return
+		}
+		if !endpoint.Active {
+			apierr.Respond(c, apierr.New(http.StatusConflict, "webhook_endpoint_inactive"))
+			return
+		}
+		var event domain.Event
+		if !findOr404(c, tdb(c), &event, c.Param("event_id"), "event") {
+			return
+		}
+		if err := queueWebhook(tdb(c), endpoint, event); err != nil {
+			respondDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "Evento en la fila de entrega", "event_id": event.ID, "type": event.Type})
+	})
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/background"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/outbox"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/Marcos1394/agritrust-backend/internal/webhook"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// receiver: Sistema de la empresa que recibe los webhooks (responde status y guarda lo que le llega)
+type receiver struct {
+	*httptest.Server
+	status     atomic.Int32
+	mu         sync.Mutex
+	eventIDs   []string
+	eventTypes []string
+	bodies     [][]byte
+	verified   []error
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	rc := &receiver{}
+	rc.status.Store(http.StatusOK)
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		rc.eventIDs = append(rc.eventIDs, r.Header.Get(webhook.EventIDHeader))
+		rc.eventTypes = append(rc.eventTypes, r.Header.Get(webhook.EventTypeHeader))
+		rc.bodies = append(rc.bodies, body)
+		rc.verified = append(rc.verified, webhook.Verify(secret, r.Header.Get(webhook.SignatureHeader), body, time.Now()))
+		rc.mu.Unlock()
+		w.WriteHeader(int(rc.status.Load()))
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) received() ([]string, []error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]string(nil), rc.eventIDs...), append([]error(nil), rc.verified...)
+}
+
+type webhookHarness struct {
+	t          *testing.T
+	ctx        context.Context // Con la empresa
+	base       *gorm.DB
+	db         *gorm.DB // base con ctx
+	sysDB      *gorm.DB
+	dispatcher *outbox.Dispatcher
+	endpoint   domain.WebhookEndpoint
+	event      domain.Event
+	receiver   *receiver
+}
+
+func newWebhookHarness(t *testing.T, maxAttempts int) *webhookHarness {
+	db := newTestDB(t, &domain.WebhookEndpoint{}, &domain.Event{}, &domain.WebhookAttempt{}, &domain.OutboxMessage{},
+		&domain.Bin{}, &domain.Shipment{})
+	ctx := tenancy.WithTenant(context.Background(), uuid.New())
+	h := &webhookHarness{
+		t:     t,
+		ctx:   ctx,
+		base:  db,
+		db:    db.WithContext(ctx),
+		sysDB: db.WithContext(tenancy.WithoutScope(context.Background())),
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.receiver = newReceiver(t, secret)
+	h.endpoint = domain.WebhookEndpoint{URL: h.receiver.URL, EventTypes: domain.EventClaimOpened, Secret: secret, Active: true}
+	if err := h.db.Create(&h.endpoint).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := emitEvent(h.db, domain.EventClaimOpened, gin.H{"claim_id": uuid.New()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.db.First(&h.event).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	h.dispatcher = outbox.New(db, background.New(), outbox.Options{MaxAttempts: maxAttempts})
+	h.dispatcher.Handle(domain.OutboxWebhook, deliverWebhook(db, webhook.NewClient(time.Second, true)))
+	return h
+}
+
+// messages: Mensajes del outbox en orden de creación
+func (h *webhookHarness) messages() []domain.OutboxMessage {
+	h.t.Helper()
+	var msgs []domain.OutboxMessage
+	if err := h.sysDB.Order("created_at").Find(&msgs).Error; err != nil {
+		h.t.Fatal(err)
+	}
+	return msgs
+}
+
+// due adelanta el siguiente intento (sin esperar el backoff)
+func (h *webhookHarness) due() {
+	h.t.Helper()
+	err := h.sysDB.Model(&domain.OutboxMessage{}).Where("status = ?", domain.OutboxPending).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error
+	if err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+func TestWebhookDeliverySigned(t *testing.T) {
+	h := newWebhookHarness(t, 3)
+
+	if n := h.dispatcher.Poll(context.Background()); n != 1 {
+		t.Fatalf("mensajes = %d, se esperaba 1", n)
+	}
+	ids, verified := h.receiver.received()
+	if len(ids) != 1 || ids[0] != h.event.ID.String() {
+		t.Fatalf("eventos recibidos = %v", ids)
+	}
+	if verified[0] != nil {
+		t.Fatalf("la firma no coincide con el cuerpo: %v", verified[0])
+	}
+	if msg := h.messages()[0]; msg.Status != domain.OutboxSent || msg.Attempts != 1 {
+		t.Fatalf("mensaje = %s con %d intentos", msg.Status, msg.Attempts)
+	}
+
+	var attempts []domain.WebhookAttempt
+	h.db.Find(&attempts)
+	if len(attempts) != 1 || !attempts[0].Success || attempts[0].StatusCode != http.StatusOK {
+		t.Fatalf("bitácora = %+v", attempts)
+	}
+}
+
+func TestWebhookRetriesUntilDead(t *testing.T) {
+	h := newWebhookHarness(t, 3)
+	h.receiver.status.Store(http.StatusServiceUnavailable)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		before := time.Now()
+		if n := h.dispatcher.Poll(context.Background()); n != 1 {
+			t.Fatalf("intento %d: mensajes = %d, se esperaba 1", attempt, n)
+		}
+		msg := h.messages()[0]
+		if msg.Attempts != attempt {
+			t.Fatalf("intentos = %d, se esperaba %d", msg.Attempts, attempt)
+		}
+		if attempt < 3 {
+			// 5xx: sigue pendiente y espera el backoff antes del siguiente intento
+			wait := msg.NextAttemptAt.Sub(before)
+			if msg.Status != domain.OutboxPending || wait < outbox.Backoff(attempt) || wait > outbox.Backoff(attempt)+time.Minute {
+				t.Fatalf("intento %d: status=%s, espera=%s (backoff %s)", attempt, msg.Status, wait, outbox.Backoff(attempt))
+			}
+			if n := h.dispatcher.Poll(context.Background()); n != 0 {
+				t.Fatal("se reintentó antes del backoff")
+			}
+			h.due()
+			continue
+		}
+		// Se agotaron los intentos: queda dead y ya no se manda
+		if msg.Status != domain.OutboxDead || msg.LastError == "" {
+			t.Fatalf("status=%s last_error=%q, se esperaba dead", msg.Status, msg.LastError)
+		}
+	}
+	h.due()
+	if n := h.dispatcher.Poll(context.Background()); n != 0 {
+		t.Fatal("un mensaje dead se volvió a mandar")
+	}
+
+	ids, _ := h.receiver.received()
+	var attempts []domain.WebhookAttempt
+	h.db.Order("attempt").Find(&attempts)
+	if len(ids) != 3 || len(attempts) != 3 {
+		t.Fatalf("envíos = %d, bitácora = %d; se esperaban 3", len(ids), len(attempts))
+	}
+	for i, a := range attempts {
+		if a.Attempt != i+1 || a.Success || a.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("bitácora[%d] = %+v", i, a)
+		}
+	}
+}
+
+func TestWebhookRedeliver(t *testing.T) {
+	h := newWebhookHarness(t, 3)
+	h.dispatcher.Poll(context.Background())
+
+	r := gin.New()
+	scoped := r.Group("/", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(h.ctx)
+		c.Set("permissions", authz.Set{authz.WebhooksManage: true})
+	})
+	registerWebhookRoutes(scoped, func(c *gin.Context) *gorm.DB { return h.base.WithContext(c.Request.Context()) }, false)
+	redeliver := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/settings/webhooks/"+h.endpoint.ID.String()+"/events/"+h.event.ID.String()+"/redeliver", nil))
+		return w
+	}
+
+	// Reenvío manual de un evento ya entregado: mismo event id, nueva firma válida
+	if w := redeliver(); w.Code != http.StatusAccepted {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if n := h.dispatcher.Poll(context.Background()); n != 1 {
+		t.Fatalf("mensajes = %d, se esperaba 1", n)
+	}
+	ids, verified := h.receiver.received()
+	if len(ids) != 2 || ids[1] != h.event.ID.String() || verified[1] != nil {
+		t.Fatalf("eventos = %v, firmas = %v", ids, verified)
+	}
+
+	// Un endpoint desactivado no acepta reenvíos
+	if err := h.db.Model(&h.endpoint).Update("active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if w := redeliver(); w.Code != http.StatusConflict {
+		t.Fatalf("endpoint inactivo: status %d: %s", w.Code, w.Body)
+	}
+	if msgs := h.messages(); len(msgs) != 2 {
+		t.Fatalf("mensajes en el outbox = %d, se esperaban 2", len(msgs))
+	}
+}
+
+func TestWebhookBinAndShipmentEvents(t *testing.T) {
+	h := newWebhookHarness(t, 3)
+	h.dispatcher.Poll(context.Background())
+	if err := h.db.Model(&h.endpoint).Update("event_types", domain.EventBinReceived+","+domain.EventShipmentDispatched).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	bin := domain.Bin{QRCode: "QR-1", Status: "full_in_field", WeightKg: 20}
+	if err := h.db.Create(&bin).Error; err != nil {
+		t.Fatal(err)
+	}
+	inTx := func(fn func(tx *gorm.DB) error) error { return h.db.Transaction(fn) }
+
+	// Empaque recibe la caja (una sola vez)
+	if err := inTx(func(tx *gorm.DB) error { _, err := receiveBin(tx, "QR-1"); return err }); err != nil {
+		t.Fatal(err)
+	}
+	if err := inTx(func(tx *gorm.DB) error { _, err := receiveBin(tx, "QR-1"); return err }); err == nil {
+		t.Fatal("la caja se recibió dos veces")
+	}
+
+	// El camión sale con la caja (que ya no puede subir a otro embarque)
+	req := CreateShipmentReq{CustomerName: "Whole Foods Market", Destination: "McAllen, TX", BinIDs: []uuid.UUID{bin.ID}}
+	var shipment domain.Shipment
+	if err := inTx(func(tx *gorm.DB) (err error) { shipment, err = dispatchShipment(tx, req); return err }); err != nil {
+		t.Fatal(err)
+	}
+	if len(shipment.Bins) != 1 || shipment.Bins[0].ShipmentID == nil || *shipment.Bins[0].ShipmentID != shipment.ID {
+		t.Fatalf("cajas del embarque = %+v", shipment.Bins)
+	}
+	if err := inTx(func(tx *gorm.DB) error { _, err := dispatchShipment(tx, req); return err }); err == nil {
+		t.Fatal("la caja subió a dos embarques")
+	}
+
+	if n := h.dispatcher.Poll(context.Background()); n != 2 {
+		t.Fatalf("mensajes = %d, se esperaban 2", n)
+	}
+	h.receiver.mu.Lock()
+	defer h.receiver.mu.Unlock()
+	types := h.receiver.eventTypes[1:]
+	if len(types) != 2 || types[0] != domain.EventBinReceived || types[1] != domain.EventShipmentDispatched {
+		t.Fatalf("eventos recibidos = %v", types)
+	}
+	for i, err := range h.receiver.verified {
+		if err != nil {
+			t.Fatalf("envío %d: la firma no coincide con el cuerpo: %v", i, err)
+		}
+	}
+	var sent domain.Event
+	var data domain.Shipment
+	if err := json.Unmarshal(h.receiver.bodies[2], &sent); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(sent.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.ID != shipment.ID || len(data.Bins) != 1 || data.Bins[0].QRCode != "QR-1" {
+		t.Fatalf("embarque en el evento = %+v", data)
+	}
+}
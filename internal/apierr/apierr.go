@@ -0,0 +1,286 @@
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ---------------------------------------------------------
+// 🧯 ERRORES DE LA API (Sobre único, validación e idioma)
+// ---------------------------------------------------------
+// Toda respuesta de error tiene la misma forma:
+//
+//	{"error": "Datos inválidos (dosage: debe ser mayor que 0)", "code": "validation_failed",
+//	 "details": [{"field": "dosage", "code": "gt", "param": "0", "message": "debe ser mayor que 0"}],
+//	 "request_id": "..."}
+//
+// "error" es el texto para mostrar (español o inglés según Accept-Language);
+// los clientes deciden con "code" y, en errores de validación, con "details".
+// Algunos errores agregan datos propios al mismo nivel (ej: plan y limit en quota_exceeded).
+
+// Códigos genéricos (los específicos de cada caso viven en el catálogo de mensajes)
+const (
+	CodeInvalidBody  = "invalid_body"
+	CodeValidation   = "validation_failed"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeInternal     = "internal"
+)
+
+// Error: Respuesta de error de la API
+type Error struct {
+	Status  int
+	Code    string
+	Params  map[string]any // Se sustituyen en el mensaje ({plan}) y viajan en la respuesta
+	Details []FieldError
+
+	key   string // Mensaje del catálogo (default: Code)
+	cause error  // Error original (solo para el log, nunca para el cliente)
+}
+
+// FieldError: Problema en un campo de la petición
+type FieldError struct {
+	Field string `json:"field"`           // Ruta JSON: items[0].quantity
+	Code  string `json:"code"`            // Regla que falló: required, gt, oneof, not_found...
+	Param string `json:"param,omitempty"` // Parámetro de la regla: gt=0 -> "0"
+
+	key string // Mensaje del catálogo (default: "field." + Code)
+}
+
+// New crea un error. params son pares clave/valor: New(409, "invite_pending_exists", "email", email)
+func New(status int, code string, params ...any) *Error {
+	e := &Error{Status: status, Code: code}
+	for i := 0; i+1 < len(params); i += 2 {
+		if e.Params == nil {
+			e.Params = map[string]any{}
+		}
+		e.Params[fmt.Sprint(params[i])] = params[i+1]
+	}
+	return e
+}
+
+// Variant elige una variante del mensaje sin cambiar el código: "quota_exceeded" -> "quota_exceeded.farms"
+func (e *Error) Variant(v string) *Error {
+	e.key = e.Code + "." + v
+	return e
+}
+
+// NotFound: 404 de un recurso (el mensaje depende del recurso: "Rancho no encontrado")
+func NotFound(resource string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, "resource", resource).Variant(resource)
+}
+
+// Validation: 400 con el detalle de cada campo inválido
+func Validation(details ...FieldError) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidation, Details: details}
+}
+
+// Field: Campo inválido (para reglas que no se pueden expresar con tags)
+func Field(field, code, param string) FieldError {
+	return FieldError{Field: field, Code: code, Param: param}
+}
+
+// MissingRef: El campo apunta a un registro que no existe en la empresa activa
+func MissingRef(field, resource string) FieldError {
+	return FieldError{Field: field, Code: CodeNotFound, Param: resource, key: CodeNotFound + "." + resource}
+}
+
+// Internal: 500 sin filtrar el error original al cliente (queda en el log de la petición)
+func Internal(err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, cause: err}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Code + ": " + e.cause.Error()
+	}
+	return e.message(ES)
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// message: Texto del error en el idioma pedido
+func (e *Error) message(lang string) string {
+	key := e.key
+	if key == "" {
+		key = e.Code
+	}
+	msg := translate(lang, key, e.Params)
+	if len(e.Details) > 0 {
+		parts := make([]string, 0, len(e.Details))
+		for _, d := range e.Details {
+			parts = append(parts, d.Field+": "+d.message(lang))
+		}
+		msg += " (" + strings.Join(parts, "; ") + ")"
+	}
+	return msg
+}
+
+func (f FieldError) message(lang string) string {
+	key := f.key
+	if key == "" {
+		key = "field." + f.Code
+	}
+	return translate(lang, key, map[string]any{"param": strings.ReplaceAll(f.Param, " ", ", ")})
+}
+
+// ---- 📤 RESPUESTA ----
+
+// fieldView: FieldError con su mensaje ya traducido
+type fieldView struct {
+	FieldError
+	Message string `json:"message"`
+}
+
+// Respond escribe el error y corta la cadena de handlers.
+// Acepta un *Error, un error de binding (JSON o validación) o cualquier otro error (500).
+func Respond(c *gin.Context, err error) {
+	status, body := Body(c, err)
+	c.AbortWithStatusJSON(status, body)
+}
+
+// Body arma el sobre de error sin escribirlo (ej: el resultado de una operación dentro de un lote).
+// Los errores internos se registran aquí, una sola vez.
+func Body(c *gin.Context, err error) (int, gin.H) {
+	e := From(err)
+	lang := Lang(c)
+
+	body := gin.H{}
+	for k, v := range e.Params {
+		body[k] = v
+	}
+	body["error"] = e.message(lang)
+	body["code"] = e.Code
+	if len(e.Details) > 0 {
+		details := make([]fieldView, 0, len(e.Details))
+		for _, d := range e.Details {
+			details = append(details, fieldView{FieldError: d, Message: d.message(lang)})
+		}
+		body["details"] = details
+	}
+	if id := c.GetString("request_id"); id != "" {
+		body["request_id"] = id
+	}
+
+	if e.Status >= http.StatusInternalServerError && e.cause != nil {
+		_ = c.Error(e.cause) // AccessLog lo registra junto con la petición
+		slog.ErrorContext(c.Request.Context(), "error interno", "code", e.Code, "error", e.cause)
+	}
+	return e.Status, body
+}
+
+// From convierte cualquier error en un *Error
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		details := make([]FieldError, 0, len(verrs))
+		for _, fe := range verrs {
+			details = append(details, fromValidator(fe))
+		}
+		return Validation(details...)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return Validation(Field(typeErr.Field, "type", ""))
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &Error{Status: http.StatusBadRequest, Code: CodeInvalidBody, cause: err}
+	}
+	return Internal(err)
+}
+
+// ---- ✅ BINDING Y VALIDACIÓN ----
+
+// BindJSON llena el DTO y lo valida (tags `binding`). Si falla, responde 400 y devuelve false.
+func BindJSON(c *gin.Context, req any) bool {
+	return bind(c, req, binding.JSON)
+}
+
+// BindQuery: Igual que BindJSON para filtros del query string (tags `form`)
+func BindQuery(c *gin.Context, req any) bool {
+	return bind(c, req, binding.Query)
+}
+
+// DecodeJSON llena y valida el DTO a partir de un JSON ya leído (ej: el payload de una operación
+// dentro de un lote). No responde: devuelve el *Error para que el llamador decida.
+func DecodeJSON(data []byte, req any) error {
+	if err := binding.JSON.BindBody(data, req); err != nil {
+		return bindError(err)
+	}
+	return nil
+}
+
+func bind(c *gin.Context, req any, b binding.Binding) bool {
+	err := c.ShouldBindWith(req, b)
+	if err == nil {
+		return true
+	}
+	Respond(c, bindError(err))
+	return false
+}
+
+func bindError(err error) *Error {
+	e := From(err)
+	if e.Code == CodeInternal {
+		// Valores que el decoder no pudo convertir (ej: un UUID o una fecha mal formados)
+		e = &Error{Status: http.StatusBadRequest, Code: CodeInvalidBody, cause: err}
+	}
+	return e
+}
+
+// fromValidator traduce la regla que falló a un FieldError
+func fromValidator(fe validator.FieldError) FieldError {
+	field := fe.Namespace()
+	if i := strings.Index(field, "."); i >= 0 {
+		field = field[i+1:] // Sin el nombre del struct: "CreateReq.items[0].quantity" -> "items[0].quantity"
+	}
+	f := FieldError{Field: field, Code: fe.Tag(), Param: fe.Param()}
+	// min/max/len hablan de longitud en textos y listas, de valor en números
+	switch fe.Tag() {
+	case "min", "max", "len":
+		switch fe.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+			f.key = "field." + fe.Tag() + ".len"
+		}
+	}
+	return f
+}
+
+// fieldName: Los errores usan el nombre del JSON (o del query string), no el del struct de Go
+func fieldName(fld reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name := strings.SplitN(fld.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return fld.Name
+}
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(fieldName)
+	}
+}
@@ -0,0 +1,206 @@
+package apierr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Idiomas soportados (español es el default: la mayoría de los usuarios opera en campo en México)
+const (
+	ES = "es"
+	EN = "en"
+)
+
+// Lang elige el idioma según Accept-Language (respeta los pesos q; default: español)
+func Lang(c *gin.Context) string {
+	best, bestQ := ES, 0.0
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag, q := strings.TrimSpace(part), 1.0
+		if i := strings.Index(tag, ";"); i >= 0 {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(tag[i+1:]), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+			tag = tag[:i]
+		}
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if (base == ES || base == EN) && q > bestQ {
+			best, bestQ = base, q
+		}
+	}
+	return best
+}
+
+// text: Mensaje en cada idioma. {nombre} se sustituye con el parámetro del error.
+type text struct {
+	es, en string
+}
+
+// translate busca el mensaje; si la llave no existe usa la más general ("not_found.farm" -> "not_found")
+func translate(lang, key string, params map[string]any) string {
+	msg, ok := messages[key]
+	for !ok {
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			return key
+		}
+		key = key[:i]
+		msg, ok = messages[key]
+	}
+	s := msg.es
+	if lang == EN {
+		s = msg.en
+	}
+	for k, v := range params {
+		s = strings.ReplaceAll(s, "{"+k+"}", fmt.Sprint(v))
+	}
+	return s
+}
+
+// messages: Catálogo de mensajes por código
+var messages = map[string]text{
+	// ---- Genéricos ----
+	CodeInvalidBody:  {"El cuerpo de la petición no es válido (JSON mal formado o con valores que no se pudieron leer)", "The request body is not valid (malformed JSON or unreadable values)"},
+	CodeValidation:   {"Datos inválidos", "Invalid data"},
+	CodeNotFound:     {"Registro no encontrado", "Record not found"},
+	CodeConflict:     {"Ya existe un registro con esos datos", "A record with the same data already exists"},
+	CodeUnauthorized: {"No autenticado", "Not authenticated"},
+	CodeForbidden:    {"Acceso denegado", "Access denied"},
+	CodeInternal:     {"Error interno: intenta de nuevo más tarde", "Internal error: please try again later"},
+	"invalid_value":  {"Un valor de la petición tiene un formato inválido (ej: un ID que no es UUID)", "A request value has an invalid format (e.g. an ID that is not a UUID)"},
+
+	// ---- Recursos no encontrados ----
+	"not_found.alert_recipient":  {"Destinatario no encontrado", "Recipient not found"},
+	"not_found.api_key":          {"API key no encontrada", "API key not found"},
+	"not_found.asset":            {"Activo no encontrado", "Asset not found"},
+	"not_found.chemical":         {"Químico no encontrado", "Chemical not found"},
+	"not_found.cost_category":    {"Categoría no encontrada", "Cost category not found"},
+	"not_found.crop":             {"Cultivo no encontrado", "Crop not found"},
+	"not_found.device":           {"Dispositivo no encontrado", "Device not found"},
+	"not_found.event":            {"Evento no encontrado (se conservan 30 días)", "Event not found (events are kept for 30 days)"},
+	"not_found.farm":             {"Rancho no encontrado", "Farm not found"},
+	"not_found.bin":              {"Caja no encontrada", "Bin not found"},
+	"not_found.harvest_batch":    {"Lote de cosecha no encontrado", "Harvest batch not found"},
+	"not_found.invitation":       {"Invitación no encontrada", "Invitation not found"},
+	"not_found.lease_contract":   {"Contrato no encontrado", "Lease contract not found"},
+	"not_found.outbox_message":   {"Mensaje no encontrado", "Message not found"},
+	"not_found.passport":         {"Producto no encontrado. Verifique el código.", "Product not found. Please check the code."},
+	"not_found.product":          {"Producto no encontrado", "Product not found"},
+	"not_found.purchase_order":   {"Orden no encontrada", "Purchase order not found"},
+	"not_found.scheduled_job":    {"Tarea programada no encontrada", "Scheduled job not found"},
+	"not_found.season":           {"Temporada no encontrada", "Season not found"},
+	"not_found.shipment":         {"Embarque no encontrado", "Shipment not found"},
+	"not_found.supplier":         {"Proveedor no encontrado", "Supplier not found"},
+	"not_found.team_member":      {"Miembro no encontrado", "Team member not found"},
+	"not_found.tenant":           {"Empresa no encontrada", "Company not found"},
+	"not_found.tenant_job":       {"Trabajo no encontrado", "Job not found"},
+	"not_found.webhook_endpoint": {"Webhook no encontrado", "Webhook not found"},
+
+	// ---- Reglas de validación por campo ----
+	"field":                   {"no es válido", "is not valid"},
+	"field.required":          {"es obligatorio", "is required"},
+	"field.gt":                {"debe ser mayor que {param}", "must be greater than {param}"},
+	"field.gte":               {"debe ser mayor o igual a {param}", "must be greater than or equal to {param}"},
+	"field.lt":                {"debe ser menor que {param}", "must be less than {param}"},
+	"field.lte":               {"debe ser menor o igual a {param}", "must be less than or equal to {param}"},
+	"field.min":               {"debe ser al menos {param}", "must be at least {param}"},
+	"field.max":               {"debe ser como máximo {param}", "must be at most {param}"},
+	"field.len":               {"debe ser {param}", "must be {param}"},
+	"field.min.len":           {"debe tener al menos {param} caracteres o elementos", "must have at least {param} characters or items"},
+	"field.max.len":           {"debe tener como máximo {param} caracteres o elementos", "must have at most {param} characters or items"},
+	"field.len.len":           {"debe tener exactamente {param} caracteres o elementos", "must have exactly {param} characters or items"},
+	"field.oneof":             {"debe ser uno de: {param}", "must be one of: {param}"},
+	"field.email":             {"debe ser un correo válido", "must be a valid email address"},
+	"field.uuid":              {"debe ser un UUID válido", "must be a valid UUID"},
+	"field.url":               {"debe ser una URL válida", "must be a valid URL"},
+	"field.https_url":         {"debe ser una URL https", "must be an https URL"},
+	"field.hexcolor":          {"debe ser un color hexadecimal (ej: #10b981)", "must be a hex color (e.g. #10b981)"},
+	"field.rfc3339":           {"debe ser una fecha RFC3339 (ej: 2025-01-31T00:00:00Z)", "must be an RFC3339 date (e.g. 2025-01-31T00:00:00Z)"},
+	"field.type":              {"tiene un tipo de dato inválido", "has an invalid type"},
+	"field.gtfield":           {"debe ser posterior a {param}", "must be after {param}"},
+	"field.gtefield":          {"debe ser mayor o igual a {param}", "must be greater than or equal to {param}"},
+	"field.scope_not_allowed": {"no está permitido para API keys", "is not allowed for API keys"},
+	"field.confirm_name":      {"debe ser el nombre exacto de la empresa", "must match the company name exactly"},
+	"field.category_cycle":    {"no puede ser la misma categoría ni una de sus subcategorías", "cannot be the category itself or one of its subcategories"},
+
+	// ---- Autenticación y acceso ----
+	"missing_token":           {"Falta token de autorización", "Missing authorization token"},
+	"invalid_auth_scheme":     {"Formato Bearer inválido", "Invalid Bearer format"},
+	"invalid_token":           {"Token inválido o expirado", "Invalid or expired token"},
+	"token_without_subject":   {"Token sin usuario (sub)", "Token has no user (sub)"},
+	"invalid_api_key":         {"API key inválida, revocada o expirada", "Invalid, revoked or expired API key"},
+	"api_key_not_allowed":     {"Esta ruta no está disponible para API keys", "This route is not available for API keys"},
+	"api_key_tenant_mismatch": {"La API key no pertenece a esta empresa", "The API key does not belong to this company"},
+	"permission_denied":       {"Acceso denegado: tu rol no tiene el permiso requerido.", "Access denied: your role lacks the required permission."},
+	"platform_admin_required": {"Acceso denegado: Se requieren permisos de Administrador.", "Access denied: platform administrator permissions are required."},
+	"invalid_tenant_header":   {"X-Tenant-ID inválido", "Invalid X-Tenant-ID"},
+	"no_tenant":               {"No perteneces a ninguna empresa", "You do not belong to any company"},
+	"tenant_required":         {"Tienes varias empresas: especifica el header X-Tenant-ID", "You belong to several companies: send the X-Tenant-ID header"},
+	"tenant_access_denied":    {"No tienes acceso a esta empresa", "You do not have access to this company"},
+	"tenant_mismatch":         {"El tenant_id enviado no corresponde a la empresa activa", "The tenant_id sent does not match the active company"},
+	"farm_forbidden":          {"No tienes asignado este rancho", "You are not assigned to this farm"},
+
+	// ---- Plan y suscripción ----
+	"subscription_inactive":       {"La suscripción de la empresa está inactiva: solo puedes consultar información", "The company subscription is inactive: read-only access"},
+	"plan_upgrade_required":       {"El módulo {module} no está incluido en tu plan {plan}: actualiza tu plan para usarlo", "The {module} module is not included in your {plan} plan: upgrade to use it"},
+	"quota_exceeded":              {"Tu plan {plan} llegó a su límite ({limit}): actualiza tu plan para agregar más", "Your {plan} plan reached its limit ({limit}): upgrade to add more"},
+	"quota_exceeded.farms":        {"Tu plan {plan} permite hasta {limit} ranchos: actualiza tu plan para agregar más", "Your {plan} plan allows up to {limit} farms: upgrade to add more"},
+	"quota_exceeded.devices":      {"Tu plan {plan} permite hasta {limit} dispositivos IoT: actualiza tu plan para agregar más", "Your {plan} plan allows up to {limit} IoT devices: upgrade to add more"},
+	"quota_exceeded.team_members": {"Tu plan {plan} permite hasta {limit} miembros del equipo: actualiza tu plan para agregar más", "Your {plan} plan allows up to {limit} team members: upgrade to add more"},
+	"webhook_not_configured":      {"Webhook no configurado", "Webhook not configured"},
+	"invalid_signature":           {"Firma del webhook inválida", "Invalid webhook signature"},
+	"missing_tenant_metadata":     {"La suscripción no trae metadata.tenant_id", "The subscription has no metadata.tenant_id"},
+	"unknown_plan":                {"Plan desconocido: {plan}", "Unknown plan: {plan}"},
+	"missing_user":                {"Evento sin usuario", "Event without user"},
+
+	// ---- Operación ----
+	"chemical_banned":        {"ALERTA CRÍTICA: Intento de aplicar producto prohibido ({chemical} prohibido en: {banned_markets})", "CRITICAL ALERT: attempt to apply a banned product ({chemical} banned in: {banned_markets})"},
+	"order_already_received": {"Esta orden ya fue recibida", "This order was already received"},
+	"bin_scan_outdated":      {"La caja {qr_code} ya tiene un escaneo más reciente", "Bin {qr_code} already has a more recent scan"},
+	"bin_not_in_field":       {"La caja {qr_code} no está llena en campo (estado: {status})", "Bin {qr_code} is not full in the field (status: {status})"},
+	"bin_not_in_packing":     {"La caja {qr_code} no se ha recibido en empaque o ya se embarcó", "Bin {qr_code} has not been received in packing or was already shipped"},
+
+	// ---- Edición de catálogos (ETag / If-Match) ----
+	"precondition_failed":        {"El registro cambió desde que lo consultaste: recárgalo y vuelve a intentar", "The record changed since you loaded it: reload it and try again"},
+	"chemical_global_readonly":   {"{chemical} es un químico global del sistema: no se puede editar ni borrar", "{chemical} is a global system chemical: it cannot be edited or deleted"},
+	"cost_category_has_children": {"La categoría tiene {children} subcategorías: bórralas o muévelas primero", "The category has {children} subcategories: delete or move them first"},
+
+	// ---- Idempotencia (reintentos con Idempotency-Key) ----
+	"invalid_idempotency_key":         {"Idempotency-Key inválida (máximo {max} caracteres ASCII visibles)", "Invalid Idempotency-Key (at most {max} visible ASCII characters)"},
+	"idempotency_key_reused":          {"Esta Idempotency-Key ya se usó con otra petición: genera una nueva para cada operación", "This Idempotency-Key was already used with a different request: generate a new one for each operation"},
+	"idempotency_request_in_progress": {"La petición original con esta Idempotency-Key sigue en proceso: reintenta en un momento", "The original request with this Idempotency-Key is still in progress: retry in a moment"},
+	"request_too_large":               {"El cuerpo de la petición excede {max}", "The request body exceeds {max}"},
+
+	// ---- Rate limiting ----
+	"rate_limited": {"Demasiadas peticiones: intenta de nuevo en {retry_after} segundos", "Too many requests: try again in {retry_after} seconds"},
+
+	// ---- Equipo ----
+	"invite_invalid":          {"Invitación inválida o expirada", "Invalid or expired invitation"},
+	"invite_expired":          {"La invitación expiró: pide al administrador que la reenvíe", "The invitation expired: ask the administrator to resend it"},
+	"invite_unavailable":      {"La invitación ya no está disponible", "The invitation is no longer available"},
+	"invite_consumed":         {"La invitación ya fue utilizada", "The invitation was already used"},
+	"invite_email_mismatch":   {"Esta invitación fue enviada a otro correo", "This invitation was sent to a different email"},
+	"invite_pending_exists":   {"Ya existe una invitación vigente para {email}: usa reenviar", "There is already an active invitation for {email}: resend it instead"},
+	"invite_not_pending":      {"Solo se pueden reenviar invitaciones pendientes o expiradas", "Only pending or expired invitations can be resent"},
+	"invite_already_accepted": {"La invitación ya fue aceptada: elimina al miembro en su lugar", "The invitation was already accepted: remove the member instead"},
+	"email_unverified":        {"No pudimos verificar tu correo. Cierra sesión y vuelve a entrar.", "We could not verify your email. Sign out and sign in again."},
+	"invite_already_member":   {"{email} ya es miembro de esta empresa", "{email} is already a member of this company"},
+	"already_member":          {"Ya eres miembro de esta empresa", "You are already a member of this company"},
+	"role_not_customizable":   {"Solo se pueden personalizar los roles operator y viewer", "Only the operator and viewer roles can be customized"},
+
+	// ---- Configuración de la empresa ----
+	"alert_recipient_exists":    {"Ese correo ya recibe las alertas de este tema", "That email already receives alerts for this topic"},
+	"job_in_progress":           {"Ya hay un trabajo de este tipo en proceso", "A job of this kind is already running"},
+	"owner_required":            {"Solo el dueño de la empresa puede borrarla", "Only the company owner can delete it"},
+	"export_not_ready":          {"La exportación no está lista", "The export is not ready"},
+	"export_expired":            {"La exportación expiró: genera una nueva", "The export expired: generate a new one"},
+	"outbox_already_sent":       {"El mensaje ya se entregó", "The message was already delivered"},
+	"webhook_endpoint_inactive": {"El webhook está desactivado: actívalo antes de reenviar", "The webhook is disabled: enable it before redelivering"},
+
+	// ---- Tareas programadas (operación) ----
+	"scheduled_job_running": {"La tarea está corriendo en {locked_by}: espera a que termine", "The job is running on {locked_by}: wait for it to finish"},
+}
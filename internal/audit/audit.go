@@ -0,0 +1,372 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ---------------------------------------------------------
+// 📜 AUDITORÍA: Bitácora de cada alta, cambio y baja
+// ---------------------------------------------------------
+// Se implementa una sola vez con callbacks de GORM (no en cada handler):
+//   - create: guarda el registro nuevo completo en "after".
+//   - update: lee las filas antes y después y guarda solo los campos que cambiaron.
+//   - delete: guarda la fila borrada completa en "before".
+//
+// La bitácora se escribe en la misma transacción que el cambio: si el cambio
+// hace rollback, su registro de auditoría también.
+// Los campos con json:"-" (hashes de llaves y tokens) nunca se copian a la bitácora.
+
+// ErrAppendOnly: La bitácora no se puede modificar ni borrar
+var ErrAppendOnly = errors.New("la bitácora de auditoría es de solo inserción")
+
+type ctxKey struct{}
+
+// SystemActor: Cambios hechos por procesos internos (webhooks, jobs, migraciones)
+const SystemActor = "system"
+
+// WithActor guarda en el contexto quién ejecuta los cambios ("user:<id>", "service:<nombre>")
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, actor)
+}
+
+// ActorFrom devuelve el actor del contexto (SystemActor si no hay)
+func ActorFrom(ctx context.Context) string {
+	if actor, ok := ctx.Value(ctxKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return SystemActor
+}
+
+type disabledKey struct{}
+
+// WithoutAudit desactiva la bitácora para la operación. Solo para borrar una empresa completa
+// (derecho al olvido): es la única forma de purgar sus registros de auditoría.
+func WithoutAudit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, disabledKey{}, true)
+}
+
+func disabled(ctx context.Context) bool {
+	off, _ := ctx.Value(disabledKey{}).(bool)
+	return off
+}
+
+const beforeKey = "audit:before_rows"
+
+var auditTable string
+
+// ignored: Tablas que no se auditan (datos crudos de alto volumen o registros técnicos)
+var ignored = map[string]bool{}
+
+// Register instala los callbacks. Debe llamarse después de tenancy.Register
+// para que la lectura "antes" respete el filtro por empresa.
+// ignore: modelos que no se auditan (ej: &domain.TelemetryData{}).
+func Register(db *gorm.DB, ignore ...interface{}) error {
+	table := func(model interface{}) (string, error) {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return "", err
+		}
+		return stmt.Schema.Table, nil
+	}
+	var err error
+	if auditTable, err = table(&domain.AuditLog{}); err != nil {
+		return err
+	}
+	for _, model := range ignore {
+		name, err := table(model)
+		if err != nil {
+			return err
+		}
+		ignored[name] = true
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("audit:create", afterCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").After("tenancy:scope_update").Register("audit:before_update", captureBefore); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("audit:update", afterUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").After("tenancy:scope_delete").Register("audit:before_delete", captureBefore); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("audit:delete", afterDelete)
+}
+
+// audited indica si la sentencia toca una tabla auditada
+func audited(db *gorm.DB) bool {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Schema.PrioritizedPrimaryField == nil || disabled(db.Statement.Context) {
+		return false
+	}
+	return !ignored[db.Statement.Schema.Table] && db.Statement.Schema.Table != auditTable
+}
+
+// snapshot: Fila como mapa columna → valor, sin campos ocultos (json:"-")
+type snapshot map[string]interface{}
+
+func redact(sch *schema.Schema, row map[string]interface{}) snapshot {
+	out := snapshot{}
+	for col, v := range row {
+		if f := sch.LookUpField(col); f != nil && f.Tag.Get("json") == "-" {
+			continue
+		}
+		out[col] = v
+	}
+	return out
+}
+
+// newSession: Consulta auxiliar en la misma conexión/transacción, sin hooks de modelo
+func newSession(db *gorm.DB, ctx context.Context) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true, SkipHooks: true, Context: ctx})
+}
+
+// captureBefore lee las filas que la sentencia va a modificar o borrar
+func captureBefore(db *gorm.DB) {
+	if db.Statement.Schema != nil && db.Statement.Schema.Table == auditTable && !disabled(db.Statement.Context) {
+		db.AddError(ErrAppendOnly)
+		return
+	}
+	if !audited(db) {
+		return
+	}
+
+	q := newSession(db, db.Statement.Context).Table(db.Statement.Table)
+	if where, ok := db.Statement.Clauses["WHERE"]; ok {
+		if expr, ok := where.Expression.(clause.Where); ok {
+			q.Statement.AddClause(clause.Where{Exprs: append([]clause.Expression(nil), expr.Exprs...)})
+		}
+	}
+	// db.Save(&x) / db.Model(&x).Update(...): la llave primaria aún no está en el WHERE
+	pk := db.Statement.Schema.PrioritizedPrimaryField
+	if ids := primaryKeys(db, pk); len(ids) > 0 {
+		q = q.Where(clause.IN{Column: clause.Column{Name: pk.DBName}, Values: ids})
+	}
+	if _, ok := q.Statement.Clauses["WHERE"]; !ok {
+		return // Sin condición GORM rechaza la sentencia (ErrMissingWhereClause)
+	}
+
+	var rows []map[string]interface{}
+	if err := q.Find(&rows).Error; err != nil {
+		db.AddError(fmt.Errorf("auditoría: %w", err))
+		return
+	}
+	db.InstanceSet(beforeKey, rows)
+}
+
+// primaryKeys devuelve las llaves primarias no vacías del modelo de la sentencia
+func primaryKeys(db *gorm.DB, pk *schema.Field) []interface{} {
+	ctx := db.Statement.Context
+	var ids []interface{}
+	add := func(rv reflect.Value) {
+		if rv.Kind() != reflect.Struct {
+			return
+		}
+		if v, zero := pk.ValueOf(ctx, rv); !zero {
+			ids = append(ids, v)
+		}
+	}
+	rv := reflect.Indirect(db.Statement.ReflectValue)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			add(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		add(rv)
+	}
+	return ids
+}
+
+func beforeRows(db *gorm.DB) []map[string]interface{} {
+	v, ok := db.InstanceGet(beforeKey)
+	if !ok {
+		return nil
+	}
+	rows, _ := v.([]map[string]interface{})
+	return rows
+}
+
+func afterCreate(db *gorm.DB) {
+	if !audited(db) || db.Statement.RowsAffected == 0 {
+		return
+	}
+	sch := db.Statement.Schema
+	ctx := db.Statement.Context
+
+	var entries []domain.AuditLog
+	add := func(rv reflect.Value) {
+		if rv.Kind() != reflect.Struct {
+			return
+		}
+		row := map[string]interface{}{}
+		for _, f := range sch.Fields {
+			if f.DBName == "" {
+				continue
+			}
+			v, _ := f.ValueOf(ctx, rv)
+			row[f.DBName] = v
+		}
+		snap := redact(sch, row)
+		entries = append(entries, entry(db, domain.AuditCreate, snap, nil, snap))
+	}
+	rv := reflect.Indirect(db.Statement.ReflectValue)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			add(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		add(rv)
+	}
+	write(db, entries)
+}
+
+func afterUpdate(db *gorm.DB) {
+	rows := beforeRows(db)
+	if !audited(db) || len(rows) == 0 || db.Statement.RowsAffected == 0 {
+		return
+	}
+	sch := db.Statement.Schema
+	pk := sch.PrioritizedPrimaryField.DBName
+
+	ids := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row[pk])
+	}
+	var current []map[string]interface{}
+	if err := newSession(db, db.Statement.Context).Table(db.Statement.Table).Where(clause.IN{Column: clause.Column{Name: pk}, Values: ids}).Find(&current).Error; err != nil {
+		db.AddError(fmt.Errorf("auditoría: %w", err))
+		return
+	}
+	afterByID := map[string]map[string]interface{}{}
+	for _, row := range current {
+		afterByID[fmt.Sprint(row[pk])] = row
+	}
+
+	var entries []domain.AuditLog
+	for _, old := range rows {
+		updated, ok := afterByID[fmt.Sprint(old[pk])]
+		if !ok {
+			continue
+		}
+		full := redact(sch, updated)
+		before, after := diff(redact(sch, old), full)
+		if len(after) == 0 {
+			continue // Save sin cambios reales
+		}
+		entries = append(entries, entry(db, domain.AuditUpdate, full, before, after))
+	}
+	write(db, entries)
+}
+
+func afterDelete(db *gorm.DB) {
+	rows := beforeRows(db)
+	if !audited(db) || len(rows) == 0 || db.Statement.RowsAffected == 0 {
+		return
+	}
+	entries := make([]domain.AuditLog, 0, len(rows))
+	for _, row := range rows {
+		snap := redact(db.Statement.Schema, row)
+		entries = append(entries, entry(db, domain.AuditDelete, snap, snap, nil))
+	}
+	write(db, entries)
+}
+
+// diff devuelve solo las columnas que cambiaron (valor anterior y nuevo)
+func diff(old, updated snapshot) (snapshot, snapshot) {
+	before, after := snapshot{}, snapshot{}
+	for col, v := range updated {
+		if !sameValue(old[col], v) {
+			before[col] = old[col]
+			after[col] = v
+		}
+	}
+	return before, after
+}
+
+func sameValue(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+// entry arma el registro. row es la fila completa: de ahí salen la llave y la empresa.
+func entry(db *gorm.DB, action string, row, before, after snapshot) domain.AuditLog {
+	sch := db.Statement.Schema
+	log := domain.AuditLog{
+		ID:         uuid.New(), // Se escribe sin hooks: el UUID se asigna aquí
+		Actor:      ActorFrom(db.Statement.Context),
+		EntityType: sch.Table,
+		EntityID:   fmt.Sprint(row[sch.PrioritizedPrimaryField.DBName]),
+		Action:     action,
+		CreatedAt:  time.Now(),
+	}
+	log.TenantID = rowTenant(db, row, log.EntityID)
+	log.FarmID = rowFarm(db, row, log.EntityID)
+	if before != nil {
+		log.Before, _ = json.Marshal(before)
+	}
+	if after != nil {
+		log.After, _ = json.Marshal(after)
+	}
+	return log
+}
+
+// rowTenant: tenant_id de la fila; la tabla tenants se audita dentro de sí misma.
+// Registros globales (ej: químicos con tenant_id NULL) quedan en la empresa del contexto.
+func rowTenant(db *gorm.DB, row snapshot, entityID string) uuid.UUID {
+	if db.Statement.Schema.Table == "tenants" {
+		id, _ := uuid.Parse(entityID)
+		return id
+	}
+	if v := reflect.Indirect(reflect.ValueOf(row["tenant_id"])); v.IsValid() {
+		if id, err := uuid.Parse(fmt.Sprint(v.Interface())); err == nil {
+			return id
+		}
+	}
+	id, _ := tenancy.FromContext(db.Statement.Context)
+	return id
+}
+
+// rowFarm: farm_id de la fila (los ranchos son su propio rancho). nil si el registro no es de un rancho.
+func rowFarm(db *gorm.DB, row snapshot, entityID string) *uuid.UUID {
+	raw := row["farm_id"]
+	if db.Statement.Schema.Table == "farms" {
+		raw = entityID
+	}
+	v := reflect.Indirect(reflect.ValueOf(raw))
+	if !v.IsValid() {
+		return nil
+	}
+	id, err := uuid.Parse(fmt.Sprint(v.Interface()))
+	if err != nil || id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
+
+// write inserta en la misma transacción; la empresa ya viene resuelta en cada registro
+func write(db *gorm.DB, entries []domain.AuditLog) {
+	if len(entries) == 0 {
+		return
+	}
+	sysCtx := tenancy.WithoutScope(db.Statement.Context)
+	if err := newSession(db, sysCtx).Create(&entries).Error; err != nil {
+		db.AddError(fmt.Errorf("auditoría: %w", err))
+	}
+}
@@ -0,0 +1,153 @@
+package authz
+
+import (
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Permission: Acción concreta que un rol puede ejecutar ("modulo:accion")
+type Permission string
+
+const (
+	DashboardRead Permission = "dashboard:read"
+
+	CatalogRead  Permission = "catalog:read"  // Ranchos, químicos, cultivos, lotes
+	CatalogWrite Permission = "catalog:write" // Alta de catálogos
+
+	ApplicationsCreate Permission = "applications:create" // Aplicaciones fitosanitarias
+	BinsRead           Permission = "bins:read"
+	BinsWrite          Permission = "bins:write" // Escaneo de cajas
+
+	FleetRead  Permission = "fleet:read"
+	FleetWrite Permission = "fleet:write" // Alta de maquinaria y taller
+	FleetUsage Permission = "fleet:usage" // Bitácora diaria del operador
+
+	IoTRead        Permission = "iot:read"
+	IoTWrite       Permission = "iot:write"
+	TelemetryWrite Permission = "telemetry:write" // Ingesta de lecturas (gateways)
+
+	ProcurementRead  Permission = "procurement:read"
+	ProcurementWrite Permission = "procurement:write"
+
+	InventoryRead  Permission = "inventory:read"
+	InventoryWrite Permission = "inventory:write"
+
+	LandRead  Permission = "land:read"
+	LandWrite Permission = "land:write"
+
+	LogisticsRead  Permission = "logistics:read" // Embarques y reclamos
+	LogisticsWrite Permission = "logistics:write"
+
+	FinanceRead  Permission = "finance:read"
+	FinanceWrite Permission = "finance:write"
+
+	TeamRead     Permission = "team:read"
+	TeamManage   Permission = "team:manage" // Invitaciones, roles y permisos
+	TenantManage Permission = "tenant:manage"
+
+	APIKeysManage  Permission = "apikeys:manage"  // Llaves de máquina y service accounts
+	WebhooksManage Permission = "webhooks:manage" // Endpoints que reciben eventos (integraciones)
+
+	AuditRead Permission = "audit:read" // Bitácora de cambios (certificadoras)
+)
+
+// Roles de un TeamMember dentro de una empresa
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+)
+
+// All: Catálogo completo de permisos (el admin los tiene todos)
+var All = []Permission{
+	DashboardRead, CatalogRead, CatalogWrite, ApplicationsCreate, BinsRead, BinsWrite,
+	FleetRead, FleetWrite, FleetUsage, IoTRead, IoTWrite, TelemetryWrite, ProcurementRead, ProcurementWrite,
+	InventoryRead, InventoryWrite, LandRead, LandWrite, LogisticsRead, LogisticsWrite,
+	FinanceRead, FinanceWrite, TeamRead, TeamManage, TenantManage, APIKeysManage, WebhooksManage, AuditRead,
+}
+
+// NotForAPIKeys: Permisos que una llave de máquina nunca puede tener (administrar personas, llaves o
+// a dónde salen los datos)
+var NotForAPIKeys = []Permission{TeamManage, TenantManage, APIKeysManage, WebhooksManage}
+
+// DefaultMatrix: Permisos por rol cuando la empresa no ha personalizado el rol
+var DefaultMatrix = map[string][]Permission{
+	RoleAdmin: All,
+	// El capataz: lee catálogos y registra la operación de campo
+	RoleOperator: {
+		DashboardRead, CatalogRead, ApplicationsCreate, BinsRead, BinsWrite,
+		FleetRead, FleetUsage, IoTRead, InventoryRead, LandRead,
+	},
+	// Solo lectura (dirección, auditores, socios)
+	RoleViewer: {
+		DashboardRead, CatalogRead, BinsRead, FleetRead, IoTRead, ProcurementRead,
+		InventoryRead, LandRead, LogisticsRead, FinanceRead, TeamRead, AuditRead,
+	},
+}
+
+// Set: Permisos efectivos de un usuario en la empresa activa
+type Set map[Permission]bool
+
+// Has indica si el set incluye el permiso
+func (s Set) Has(p Permission) bool { return s[p] }
+
+// List devuelve los permisos en el orden del catálogo
+func (s Set) List() []Permission {
+	out := []Permission{}
+	for _, p := range All {
+		if s[p] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ValidRole indica si el rol existe en la matriz
+func ValidRole(role string) bool {
+	_, ok := DefaultMatrix[role]
+	return ok
+}
+
+// AllowedForAPIKey indica si el permiso puede asignarse a una llave de máquina
+func AllowedForAPIKey(p Permission) bool {
+	for _, denied := range NotForAPIKeys {
+		if denied == p {
+			return false
+		}
+	}
+	return ValidPermission(p)
+}
+
+// ValidPermission indica si el permiso existe en el catálogo
+func ValidPermission(p Permission) bool {
+	for _, known := range All {
+		if known == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve calcula los permisos de un rol en una empresa.
+// Si la empresa personalizó el rol (filas en role_permissions), esas reemplazan la matriz default.
+// El rol admin nunca se personaliza: así una empresa no puede quedarse sin administrador.
+func Resolve(db *gorm.DB, tenantID uuid.UUID, role string) (Set, error) {
+	set := Set{}
+	if role != RoleAdmin {
+		var overrides []domain.RolePermission
+		if err := db.Where("tenant_id = ? AND role = ?", tenantID, role).Find(&overrides).Error; err != nil {
+			return nil, err
+		}
+		if len(overrides) > 0 {
+			for _, o := range overrides {
+				set[Permission(o.Permission)] = true
+			}
+			return set, nil
+		}
+	}
+	for _, p := range DefaultMatrix[role] {
+		set[p] = true
+	}
+	return set, nil
+}
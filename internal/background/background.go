@@ -0,0 +1,77 @@
+package background
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+)
+
+// ---------------------------------------------------------
+// 🧵 TRABAJO EN SEGUNDO PLANO (correos, exportaciones, tareas periódicas)
+// ---------------------------------------------------------
+// Las goroutines sueltas mueren a media tarea cuando el proceso se apaga en un deploy.
+// Group las cuenta para que el apagado espere a que terminen (hasta un tope de tiempo).
+
+// Group: Conjunto de goroutines que el apagado debe drenar
+type Group struct {
+	wg       sync.WaitGroup
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping chan struct{}
+	once     sync.Once
+}
+
+// New crea un grupo listo para usar
+func New() *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{ctx: ctx, cancel: cancel, stopping: make(chan struct{})}
+}
+
+// Go lanza fn en segundo plano. ctx hereda los valores de parent (request_id, empresa, actor)
+// pero no su cancelación: la petición termina antes que la tarea. Solo se cancela si el apagado
+// se queda sin tiempo; una tarea corta (ej: mandar un correo) termina normalmente durante el drenado.
+func (g *Group) Go(parent context.Context, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
+	stop := context.AfterFunc(g.ctx, cancel)
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer stop()
+		defer cancel()
+		defer func() {
+			// Un panic en segundo plano no debe tumbar el servidor
+			if rec := recover(); rec != nil {
+				slog.ErrorContext(ctx, "panic en tarea de segundo plano", "panic", fmt.Sprint(rec), "stack", string(debug.Stack()))
+			}
+		}()
+		fn(ctx)
+	}()
+}
+
+// Stopping se cierra al iniciar el apagado: los ciclos periódicos deben salir al verlo
+func (g *Group) Stopping() <-chan struct{} {
+	return g.stopping
+}
+
+// Shutdown avisa a los ciclos, espera a que terminen las tareas y, si ctx vence antes,
+// cancela su contexto y devuelve ctx.Err() sin esperar más (lo que quedó a medias se reanuda al arrancar).
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.once.Do(func() { close(g.stopping) })
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		g.cancel()
+		return nil
+	case <-ctx.Done():
+		g.cancel()
+		return ctx.Err()
+	}
+}
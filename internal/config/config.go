@@ -0,0 +1,497 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------
+// ⚙️ CONFIGURACIÓN TIPADA
+// ---------------------------------------------------------
+// Orden de carga (lo último gana):
+//   1. Defaults del entorno (APP_ENV: development | test | staging | production)
+//   2. Archivo JSON opcional (CONFIG_FILE)
+//   3. Variables de entorno
+// En staging/production no hay defaults para valores sensibles: si faltan, el servidor no arranca.
+
+// Entornos soportados
+const (
+	EnvDevelopment = "development"
+	EnvTest        = "test"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
+
+// localDSN: Postgres del docker-compose (solo desarrollo)
+const localDSN = "host=localhost user=agritrust_user password=secret_password dbname=agritrust_db port=5432 sslmode=disable"
+
+// Config: Toda la configuración del backend
+type Config struct {
+	Env string `json:"env"`
+
+	HTTP     HTTPConfig     `json:"http"`
+	Database DatabaseConfig `json:"database"`
+	CORS     CORSConfig     `json:"cors"`
+	Mailer   MailerConfig   `json:"mailer"`
+	Clerk    ClerkConfig    `json:"clerk"`
+	Billing  BillingConfig  `json:"billing"`
+	Log      LogConfig      `json:"log"`
+	Metrics  MetricsConfig  `json:"metrics"`
+
+	Idempotency IdempotencyConfig `json:"idempotency"`
+	Jobs        JobsConfig        `json:"jobs"`
+	Outbox      OutboxConfig      `json:"outbox"`
+	RateLimit   RateLimitConfig   `json:"rate_limit"`
+
+	// FrontendBaseURL: Web Admin donde viven las pantallas enlazadas en correos (ej: /join)
+	FrontendBaseURL string `json:"frontend_base_url"`
+}
+
+type HTTPConfig struct {
+	Addr            string   `json:"addr"`             // Ej: ":8080"
+	ShutdownTimeout Duration `json:"shutdown_timeout"` // Tope para drenar peticiones y tareas al apagar
+	// TrustedProxies: IPs o CIDRs del balanceador cuyo X-Forwarded-For se respeta para la IP del cliente.
+	// Vacío = se confía en cualquiera (el default de gin): el rate limit por IP se puede burlar.
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+type DatabaseConfig struct {
+	URL              string   `json:"url"`
+	MaxOpenConns     int      `json:"max_open_conns"`
+	MaxIdleConns     int      `json:"max_idle_conns"`
+	ConnMaxLifetime  Duration `json:"conn_max_lifetime"`
+	ConnMaxIdleTime  Duration `json:"conn_max_idle_time"`
+	StatementTimeout Duration `json:"statement_timeout"` // 0 = sin límite
+	SlowQuery        Duration `json:"slow_query"`        // Queries más lentas se registran como warning
+}
+
+type MetricsConfig struct {
+	Token string `json:"token"` // Bearer que debe mandar Prometheus a /metrics (vacío = abierto, solo desarrollo)
+}
+
+type IdempotencyConfig struct {
+	TTL Duration `json:"ttl"` // Cuánto se guarda la respuesta de un POST con Idempotency-Key
+}
+
+type JobsConfig struct {
+	PollInterval Duration `json:"poll_interval"` // Cada cuánto cada réplica busca tareas programadas pendientes
+	Lease        Duration `json:"lease"`         // Candado de una tarea en curso (si la réplica muere, otra la retoma al vencer)
+}
+
+type OutboxConfig struct {
+	PollInterval Duration `json:"poll_interval"` // Cada cuánto cada réplica busca correos pendientes de entregar
+	MaxAttempts  int      `json:"max_attempts"`  // Intentos antes de dejar un correo como dead (se reintenta a mano)
+}
+
+type RateLimitConfig struct {
+	Store   string `json:"store"`   // memory (por réplica) | postgres (compartido entre réplicas)
+	Public  Rate   `json:"public"`  // Por IP: pasaporte público
+	Account Rate   `json:"account"` // Por usuario: rutas sin empresa activa (/tenants, /team/join)
+	Tenant  Rate   `json:"tenant"`  // Por API key, o por usuario y empresa: el resto de la API
+	Heavy   Rate   `json:"heavy"`   // Igual que Tenant, además: simulador IoT, exportaciones, /sync/push
+}
+
+type LogConfig struct {
+	Format string `json:"format"` // json (producción) | text (desarrollo)
+	Level  string `json:"level"`  // debug | info | warn | error
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `json:"allowed_origins"` // "*" solo se acepta fuera de producción
+}
+
+type MailerConfig struct {
+	ResendAPIKey string `json:"resend_api_key"` // Vacío = correos simulados en consola
+	From         string `json:"from"`           // Ej: "AgriTrust <notificaciones@kinetis.org>"
+}
+
+type ClerkConfig struct {
+	JWKSURL           string   `json:"jwks_url"`
+	PEMKey            string   `json:"pem_public_key"`
+	Issuer            string   `json:"issuer"`
+	Audience          string   `json:"audience"`
+	AuthorizedParties []string `json:"authorized_parties"`
+	ClockSkew         Duration `json:"clock_skew"`
+	WebhookSecret     string   `json:"webhook_secret"`
+}
+
+type BillingConfig struct {
+	WebhookSecret string `json:"webhook_secret"`
+}
+
+// Duration acepta "30s", "2m" en el archivo JSON
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Rate: Presupuesto de peticiones por ventana. Se escribe "<peticiones>/<ventana>" ("60/1m");
+// "off" (o "0") = sin límite.
+type Rate struct {
+	Limit  int
+	Window Duration
+}
+
+func parseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return Rate{}, nil
+	}
+	count, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("%q no tiene el formato <peticiones>/<ventana> (ej: 60/1m)", s)
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil {
+		return Rate{}, fmt.Errorf("%q: número de peticiones inválido", s)
+	}
+	w, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil {
+		return Rate{}, fmt.Errorf("%q: ventana inválida", s)
+	}
+	return Rate{Limit: limit, Window: Duration(w)}, nil
+}
+
+func (r Rate) String() string {
+	if r.Limit == 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", r.Limit, time.Duration(r.Window))
+}
+
+func (r *Rate) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := parseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// Strict indica si el entorno exige configuración explícita (sin defaults de desarrollo)
+func (c *Config) Strict() bool {
+	return c.Env == EnvStaging || c.Env == EnvProduction
+}
+
+// AllowAllOrigins: CORS abierto (solo desarrollo)
+func (c *Config) AllowAllOrigins() bool {
+	for _, o := range c.CORS.AllowedOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Load arma la configuración y la valida. Cualquier error debe detener el arranque.
+func Load() (*Config, error) {
+	env := strings.TrimSpace(os.Getenv("APP_ENV"))
+	if env == "" {
+		env = EnvDevelopment
+	}
+	cfg, err := defaults(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, fmt.Errorf("leyendo %s: %w", path, err)
+		}
+		cfg.Env = env // El entorno lo decide APP_ENV, no el archivo
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// defaults: Valores de partida según el entorno
+func defaults(env string) (*Config, error) {
+	cfg := &Config{
+		Env: env,
+		// Render da 30s entre SIGTERM y SIGKILL
+		HTTP: HTTPConfig{Addr: ":8080", ShutdownTimeout: Duration(25 * time.Second)},
+		Database: DatabaseConfig{
+			MaxOpenConns:     20,
+			MaxIdleConns:     10,
+			ConnMaxLifetime:  Duration(30 * time.Minute),
+			ConnMaxIdleTime:  Duration(5 * time.Minute),
+			StatementTimeout: Duration(60 * time.Second),
+			SlowQuery:        Duration(500 * time.Millisecond),
+		},
+		Log:         LogConfig{Format: "json", Level: "info"},
+		Clerk:       ClerkConfig{ClockSkew: Duration(30 * time.Second)},
+		Idempotency: IdempotencyConfig{TTL: Duration(24 * time.Hour)},
+		Jobs:        JobsConfig{PollInterval: Duration(30 * time.Second), Lease: Duration(5 * time.Minute)},
+		Outbox:      OutboxConfig{PollInterval: Duration(5 * time.Second), MaxAttempts: 8},
+		RateLimit: RateLimitConfig{
+			Store:   "memory",
+			Public:  Rate{Limit: 60, Window: Duration(time.Minute)},
+			Account: Rate{Limit: 60, Window: Duration(time.Minute)},
+			Tenant:  Rate{Limit: 1200, Window: Duration(time.Minute)},
+			Heavy:   Rate{Limit: 10, Window: Duration(time.Minute)},
+		},
+	}
+	switch env {
+	case EnvDevelopment, EnvTest:
+		cfg.Database.URL = localDSN
+		cfg.CORS.AllowedOrigins = []string{"*"}
+		cfg.FrontendBaseURL = "http://localhost:3000"
+		cfg.Mailer.From = "AgriTrust <notificaciones@localhost>"
+		cfg.Log.Format = "text"
+	case EnvStaging, EnvProduction:
+		// Todo lo sensible se declara explícitamente
+	default:
+		return nil, fmt.Errorf("APP_ENV inválido: %q (development, test, staging, production)", env)
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields() // Un typo en el archivo es un error, no un valor ignorado
+	return dec.Decode(c)
+}
+
+// loadEnv sobreescribe con las variables de entorno presentes
+func (c *Config) loadEnv() error {
+	var errs []error
+	str := func(dst *string, key string) {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = strings.TrimSpace(v)
+		}
+	}
+	list := func(dst *[]string, key string) {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = splitList(v)
+		}
+	}
+	integer := func(dst *int, key string) {
+		if v := os.Getenv(key); v != "" {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s inválido: %w", key, err))
+				return
+			}
+			*dst = n
+		}
+	}
+	rate := func(dst *Rate, key string) {
+		if v := os.Getenv(key); v != "" {
+			r, err := parseRate(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s inválido: %w", key, err))
+				return
+			}
+			*dst = r
+		}
+	}
+	duration := func(dst *Duration, key string) {
+		if v := os.Getenv(key); v != "" {
+			d, err := time.ParseDuration(strings.TrimSpace(v))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s inválido: %w", key, err))
+				return
+			}
+			*dst = Duration(d)
+		}
+	}
+
+	if port := os.Getenv("PORT"); port != "" { // Render asigna el puerto por PORT
+		c.HTTP.Addr = ":" + strings.TrimSpace(port)
+	}
+	str(&c.HTTP.Addr, "HTTP_ADDR")
+	duration(&c.HTTP.ShutdownTimeout, "SHUTDOWN_TIMEOUT")
+	list(&c.HTTP.TrustedProxies, "TRUSTED_PROXIES")
+
+	str(&c.Database.URL, "DATABASE_URL")
+	integer(&c.Database.MaxOpenConns, "DB_MAX_OPEN_CONNS")
+	integer(&c.Database.MaxIdleConns, "DB_MAX_IDLE_CONNS")
+	duration(&c.Database.ConnMaxLifetime, "DB_CONN_MAX_LIFETIME")
+	duration(&c.Database.ConnMaxIdleTime, "DB_CONN_MAX_IDLE_TIME")
+	duration(&c.Database.StatementTimeout, "DB_STATEMENT_TIMEOUT")
+	duration(&c.Database.SlowQuery, "DB_SLOW_QUERY")
+	str(&c.Log.Format, "LOG_FORMAT")
+	str(&c.Log.Level, "LOG_LEVEL")
+	str(&c.Metrics.Token, "METRICS_TOKEN")
+	duration(&c.Idempotency.TTL, "IDEMPOTENCY_TTL")
+	duration(&c.Jobs.PollInterval, "JOBS_POLL_INTERVAL")
+	duration(&c.Jobs.Lease, "JOBS_LEASE")
+	duration(&c.Outbox.PollInterval, "OUTBOX_POLL_INTERVAL")
+	integer(&c.Outbox.MaxAttempts, "OUTBOX_MAX_ATTEMPTS")
+	str(&c.RateLimit.Store, "RATE_LIMIT_STORE")
+	rate(&c.RateLimit.Public, "RATE_LIMIT_PUBLIC")
+	rate(&c.RateLimit.Account, "RATE_LIMIT_ACCOUNT")
+	rate(&c.RateLimit.Tenant, "RATE_LIMIT_TENANT")
+	rate(&c.RateLimit.Heavy, "RATE_LIMIT_HEAVY")
+
+	list(&c.CORS.AllowedOrigins, "CORS_ALLOWED_ORIGINS")
+	str(&c.Mailer.ResendAPIKey, "RESEND_API_KEY")
+	str(&c.Mailer.From, "MAIL_FROM")
+	str(&c.FrontendBaseURL, "FRONTEND_BASE_URL")
+
+	str(&c.Clerk.JWKSURL, "CLERK_JWKS_URL")
+	str(&c.Clerk.PEMKey, "CLERK_PEM_PUBLIC_KEY")
+	str(&c.Clerk.Issuer, "CLERK_ISSUER")
+	str(&c.Clerk.Audience, "CLERK_AUDIENCE")
+	list(&c.Clerk.AuthorizedParties, "CLERK_AUTHORIZED_PARTIES")
+	str(&c.Clerk.WebhookSecret, "CLERK_WEBHOOK_SECRET")
+	duration(&c.Clerk.ClockSkew, "CLERK_CLOCK_SKEW")
+
+	str(&c.Billing.WebhookSecret, "BILLING_WEBHOOK_SECRET")
+	return errors.Join(errs...)
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Validate junta todos los problemas en un solo error (para corregirlos de una vez)
+func (c *Config) Validate() error {
+	var errs []error
+	required := func(value, key string) {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("%s es requerido en %s", key, c.Env))
+		}
+	}
+
+	required(c.HTTP.Addr, "HTTP_ADDR/PORT")
+	required(c.Database.URL, "DATABASE_URL")
+	required(c.FrontendBaseURL, "FRONTEND_BASE_URL")
+	required(c.Mailer.From, "MAIL_FROM")
+
+	if c.FrontendBaseURL != "" {
+		if u, err := url.Parse(c.FrontendBaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("FRONTEND_BASE_URL no es una URL absoluta: %q", c.FrontendBaseURL))
+		}
+	}
+	if c.Mailer.From != "" {
+		if _, err := mail.ParseAddress(c.Mailer.From); err != nil {
+			errs = append(errs, fmt.Errorf("MAIL_FROM inválido: %q", c.Mailer.From))
+		}
+	}
+	if len(c.CORS.AllowedOrigins) == 0 {
+		errs = append(errs, fmt.Errorf("CORS_ALLOWED_ORIGINS es requerido en %s", c.Env))
+	}
+	for _, o := range c.CORS.AllowedOrigins {
+		if o == "*" {
+			continue
+		}
+		if u, err := url.Parse(o); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			errs = append(errs, fmt.Errorf("origen CORS inválido: %q (ej: https://app.ejemplo.com)", o))
+		}
+	}
+	if c.HTTP.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT debe ser mayor a 0"))
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("DB_MAX_OPEN_CONNS y DB_MAX_IDLE_CONNS no pueden ser negativos"))
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("DB_MAX_IDLE_CONNS no puede ser mayor que DB_MAX_OPEN_CONNS"))
+	}
+	if c.Database.ConnMaxLifetime < 0 || c.Database.ConnMaxIdleTime < 0 || c.Database.StatementTimeout < 0 {
+		errs = append(errs, errors.New("los tiempos de DB_* no pueden ser negativos"))
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT inválido: %q (json, text)", c.Log.Format))
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_LEVEL inválido: %q (debug, info, warn, error)", c.Log.Level))
+	}
+	if c.Idempotency.TTL <= 0 {
+		errs = append(errs, errors.New("IDEMPOTENCY_TTL debe ser mayor a 0"))
+	}
+	if c.Jobs.PollInterval <= 0 || c.Jobs.Lease <= 0 {
+		errs = append(errs, errors.New("JOBS_POLL_INTERVAL y JOBS_LEASE deben ser mayores a 0"))
+	}
+	if c.Outbox.PollInterval <= 0 || c.Outbox.MaxAttempts <= 0 {
+		errs = append(errs, errors.New("OUTBOX_POLL_INTERVAL y OUTBOX_MAX_ATTEMPTS deben ser mayores a 0"))
+	}
+	if c.RateLimit.Store != "memory" && c.RateLimit.Store != "postgres" {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_STORE inválido: %q (memory, postgres)", c.RateLimit.Store))
+	}
+	rate := func(r Rate, key string) {
+		if r.Limit < 0 || (r.Limit > 0 && time.Duration(r.Window) < time.Second) {
+			errs = append(errs, fmt.Errorf("%s inválido: %s (peticiones >= 0, ventana de al menos 1s)", key, r))
+		}
+	}
+	rate(c.RateLimit.Public, "RATE_LIMIT_PUBLIC")
+	rate(c.RateLimit.Account, "RATE_LIMIT_ACCOUNT")
+	rate(c.RateLimit.Tenant, "RATE_LIMIT_TENANT")
+	rate(c.RateLimit.Heavy, "RATE_LIMIT_HEAVY")
+	for _, p := range c.HTTP.TrustedProxies {
+		if net.ParseIP(p) == nil {
+			if _, _, err := net.ParseCIDR(p); err != nil {
+				errs = append(errs, fmt.Errorf("TRUSTED_PROXIES: %q no es una IP ni un CIDR", p))
+			}
+		}
+	}
+	if c.Clerk.ClockSkew < 0 {
+		errs = append(errs, errors.New("CLERK_CLOCK_SKEW no puede ser negativo"))
+	}
+
+	if c.Strict() {
+		if c.AllowAllOrigins() {
+			errs = append(errs, fmt.Errorf("CORS_ALLOWED_ORIGINS no puede ser \"*\" en %s", c.Env))
+		}
+		required(c.Mailer.ResendAPIKey, "RESEND_API_KEY")
+		required(c.Metrics.Token, "METRICS_TOKEN") // /metrics expone IDs de empresas
+		// Sin secreto los webhooks responden 503: los usuarios y el plan dejarían de sincronizarse
+		required(c.Clerk.WebhookSecret, "CLERK_WEBHOOK_SECRET")
+		required(c.Billing.WebhookSecret, "BILLING_WEBHOOK_SECRET")
+	}
+	// La llave PEM embebida en el código es solo para desarrollo
+	if c.Env != EnvDevelopment && c.Clerk.JWKSURL == "" && c.Clerk.PEMKey == "" {
+		errs = append(errs, fmt.Errorf("CLERK_JWKS_URL (o CLERK_PEM_PUBLIC_KEY) es requerido en %s", c.Env))
+	}
+	return errors.Join(errs...)
+}
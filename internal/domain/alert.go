@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Temas de alerta: cada empresa decide quién recibe cada tipo de aviso
+const (
+	AlertSecurity    = "security"    // Bloqueos fitosanitarios (químico prohibido)
+	AlertLeases      = "leases"      // Resumen semanal de contratos de renta por vencer
+	AlertMaintenance = "maintenance" // Resumen diario de maquinaria con servicio pendiente
+	AlertInventory   = "inventory"   // Resumen diario de productos bajo el mínimo de stock
+)
+
+// AlertTopics: Catálogo de temas válidos
+var AlertTopics = []string{AlertSecurity, AlertLeases, AlertMaintenance, AlertInventory}
+
+// AlertRecipient: Correo que recibe las alertas de un tema dentro de una empresa.
+// Si la empresa no configura ninguno, las alertas van al dueño (Tenant.OwnerID).
+type AlertRecipient struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	TenantID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_alert_recipient" json:"tenant_id"`
+	Topic     string    `gorm:"size:50;not null;uniqueIndex:idx_alert_recipient" json:"topic"`
+	Email     string    `gorm:"size:255;not null;uniqueIndex:idx_alert_recipient" json:"email"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (a *AlertRecipient) BeforeCreate(tx *gorm.DB) (err error) {
+	a.ID = uuid.New()
+	return
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// APIKey: Credencial de máquina (gateway IoT, báscula de empaque, ERP) ligada a una empresa.
+// La llave en claro solo se muestra al crearla; en la base guardamos su hash SHA-256.
+type APIKey struct {
+	ID       uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	TenantID uuid.UUID `gorm:"type:uuid;not null;index" json:"tenant_id"`
+
+	ServiceAccount string `gorm:"size:100;not null" json:"service_account"` // Ej: "Báscula Empaque Norte"
+	Prefix         string `gorm:"size:16;index" json:"prefix"`              // Primeros caracteres, para identificarla en la UI
+	KeyHash        string `gorm:"size:64;uniqueIndex" json:"-"`
+	Scopes         string `json:"scopes"` // Permisos separados por coma (ej: "telemetry:write,bins:write")
+
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+
+	CreatedBy string    `json:"created_by"` // Clerk ID del admin que la generó
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (k *APIKey) BeforeCreate(tx *gorm.DB) (err error) {
+	k.ID = uuid.New()
+	return
+}
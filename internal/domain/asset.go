@@ -29,6 +29,8 @@ type Asset struct {
 	ServiceInterval float64 `json:"service_interval"` // Cada cuánto se da servicio (Ej: cada 200 hrs)
 	NextServiceAt   float64 `json:"next_service_at"`  // A qué lectura toca el siguiente (Ej: a las 4600 hrs)
 
+	Editable // Versión (ETag) y baja lógica
+
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
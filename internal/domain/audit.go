@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditLog: Bitácora inmutable de cambios (quién cambió qué y cuándo).
+// La escribe el paquete audit desde callbacks de GORM; nunca se actualiza ni se borra.
+type AuditLog struct {
+	ID         uuid.UUID       `gorm:"type:uuid;primary_key;" json:"id"`
+	TenantID   uuid.UUID       `gorm:"type:uuid;index:idx_audit_entity,priority:1;index:idx_audit_actor,priority:1" json:"tenant_id"`
+	Actor      string          `gorm:"size:150;index:idx_audit_actor,priority:2" json:"actor"`        // user:<clerk_id>, service:<nombre>, system
+	EntityType string          `gorm:"size:100;index:idx_audit_entity,priority:2" json:"entity_type"` // Nombre de la tabla (products, assets...)
+	EntityID   string          `gorm:"size:100;index:idx_audit_entity,priority:3" json:"entity_id"`
+	FarmID     *uuid.UUID      `gorm:"type:uuid;index" json:"farm_id"`     // Rancho del registro: un usuario restringido solo ve los de sus ranchos
+	Action     string          `gorm:"size:20" json:"action"`              // create, update, delete
+	Before     json.RawMessage `gorm:"type:jsonb" json:"before,omitempty"` // En update solo los campos que cambiaron
+	After      json.RawMessage `gorm:"type:jsonb" json:"after,omitempty"`
+	CreatedAt  time.Time       `gorm:"index" json:"created_at"`
+}
+
+// Acciones registradas en la bitácora
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+func (a *AuditLog) BeforeCreate(tx *gorm.DB) (err error) {
+	a.ID = uuid.New()
+	return
+}
@@ -14,8 +14,11 @@ type Chemical struct {
 	ActiveIngredient string     `gorm:"size:255" json:"active_ingredient"`
 	IsBanned         bool       `gorm:"default:false" json:"is_banned"` // El switch de la muerte 💀
 	BannedMarkets    string     `json:"banned_markets"`                 // ej: "EU, USA, JAPAN"
-	CreatedAt        time.Time  `json:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at"`
+
+	Editable // Versión (ETag) y baja lógica
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 func (c *Chemical) BeforeCreate(tx *gorm.DB) (err error) {
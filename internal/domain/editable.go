@@ -0,0 +1,17 @@
+package domain
+
+import "gorm.io/gorm"
+
+// Editable: Campos comunes de los catálogos que se editan y se dan de baja desde el Web Admin.
+//   - Version sube con cada cambio: es el ETag del registro (concurrencia optimista con If-Match).
+//   - DeletedAt es la baja lógica: el registro sale de los listados pero se conserva para la
+//     trazabilidad (aplicaciones, lotes y pasaportes viejos lo siguen referenciando).
+type Editable struct {
+	Version   int            `gorm:"not null;default:1" json:"version"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// CurrentVersion: Versión que se publica como ETag
+func (e Editable) CurrentVersion() int {
+	return e.Version
+}
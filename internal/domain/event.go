@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tipos de evento que una empresa puede recibir en sus webhooks
+const (
+	EventApplicationBlocked    = "application.blocked"       // Intento de aplicar un químico prohibido
+	EventHarvestBatchCreated   = "harvest_batch.created"     // Alta de un lote de cosecha
+	EventClaimOpened           = "claim.opened"              // Reclamo de un cliente sobre un embarque
+	EventPurchaseOrderReceived = "purchase_order.received"   // Mercancía recibida en almacén
+	EventSensorThreshold       = "sensor.threshold_breached" // Una lectura salió del rango del dispositivo
+	EventBinReceived           = "bin.received"              // Caja llena recibida en empaque
+	EventShipmentDispatched    = "shipment.dispatched"       // Embarque que salió con sus cajas
+)
+
+// EventTypes: Catálogo de eventos válidos
+var EventTypes = []string{EventApplicationBlocked, EventHarvestBatchCreated, EventClaimOpened, EventPurchaseOrderReceived, EventSensorThreshold,
+	EventBinReceived, EventShipmentDispatched}
+
+// Event: Hecho de negocio de una empresa. Es el cuerpo que reciben sus webhooks; solo se guarda si
+// algún endpoint está suscrito a su tipo (se conserva para poder reenviarlo).
+type Event struct {
+	ID        uuid.UUID       `gorm:"type:uuid;primary_key;" json:"id"`
+	TenantID  uuid.UUID       `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	Type      string          `gorm:"size:100;not null" json:"type"`
+	Data      json.RawMessage `gorm:"type:jsonb" json:"data"` // El registro afectado, como lo devuelve la API
+	CreatedAt time.Time       `gorm:"index" json:"created_at"`
+}
+
+// WebhookEndpoint: URL de una empresa que recibe eventos (ej: su ERP o un bot de WhatsApp).
+// El secreto firma cada envío (HMAC-SHA256); solo se muestra al crearlo o rotarlo.
+type WebhookEndpoint struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	TenantID    uuid.UUID `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	URL         string    `gorm:"size:2048;not null" json:"url"`
+	Description string    `gorm:"size:255" json:"description"`
+	EventTypes  string    `gorm:"not null" json:"event_types"` // Tipos separados por coma (ej: "claim.opened,purchase_order.received")
+	Secret      string    `gorm:"size:100;not null" json:"-"`
+	Active      bool      `gorm:"not null" json:"active"` // Desactivado: no recibe eventos nuevos
+
+	CreatedBy string    `json:"created_by"` // Clerk ID del admin que lo dio de alta
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookAttempt: Bitácora de entregas a un endpoint (un registro por intento)
+type WebhookAttempt struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	TenantID     uuid.UUID `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	EndpointID   uuid.UUID `gorm:"type:uuid;not null;index:idx_webhook_attempt,priority:1" json:"endpoint_id"`
+	EventID      uuid.UUID `gorm:"type:uuid;not null" json:"event_id"`
+	EventType    string    `gorm:"size:100;not null" json:"event_type"`
+	MessageID    uuid.UUID `gorm:"type:uuid;not null" json:"message_id"` // Mensaje del outbox (reintentos automáticos)
+	Attempt      int       `gorm:"not null" json:"attempt"`
+	Success      bool      `gorm:"not null" json:"success"`
+	StatusCode   int       `json:"status_code,omitempty"` // 0 = sin respuesta (timeout, DNS, conexión rechazada)
+	Error        string    `json:"error,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"` // Primeros bytes de la respuesta
+	DurationMS   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `gorm:"not null;index:idx_webhook_attempt,priority:2" json:"created_at"`
+}
+
+func (e *Event) BeforeCreate(tx *gorm.DB) (err error) {
+	e.ID = uuid.New()
+	return
+}
+func (w *WebhookEndpoint) BeforeCreate(tx *gorm.DB) (err error) {
+	w.ID = uuid.New()
+	return
+}
+func (a *WebhookAttempt) BeforeCreate(tx *gorm.DB) (err error) {
+	a.ID = uuid.New()
+	return
+}
@@ -15,8 +15,11 @@ type Farm struct {
 	TotalArea     float64   `json:"total_area"`                          // Hectáreas totales
 	Location      string    `json:"location"`                            // Coordenadas o dirección simple por ahora
 	OwnershipType string    `gorm:"default:'own'" json:"ownership_type"` // own, rented, litigation
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+
+	Editable // Versión (ETag) y baja lógica
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 func (f *Farm) BeforeCreate(tx *gorm.DB) (err error) {
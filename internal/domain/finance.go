@@ -21,6 +21,8 @@ type Season struct {
 	EndDate   time.Time `gorm:"not null" json:"end_date"`
 	Active    bool      `gorm:"default:true" json:"active"`
 
+	Editable // Versión (ETag) y baja lógica
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -38,6 +40,8 @@ type CostCategory struct {
 	// Jerarquía (Self-Referencing)
 	ParentID  *uuid.UUID     `gorm:"type:uuid;index" json:"parent_id"`
 	Children  []CostCategory `gorm:"foreignKey:ParentID" json:"children,omitempty"`
+
+	Editable // Versión (ETag) y baja lógica
 }
 
 // ---------------------------------------------------------
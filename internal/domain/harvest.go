@@ -16,30 +16,38 @@ type Crop struct {
 	Variety      string    `json:"variety"`
 	PlantingDate time.Time `json:"planting_date"`
 	Status       string    `json:"status"` // growing, harvesting, finished
+	Editable               // Versión (ETag) y baja lógica
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 // HarvestBatch: Representa un día de corte en un rancho
 type HarvestBatch struct {
 	ID          uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	TenantID    uuid.UUID `gorm:"type:uuid;index" json:"tenant_id"`
+	TenantID    uuid.UUID `gorm:"type:uuid;index;uniqueIndex:idx_harvest_batches_tenant_batch_code,priority:1" json:"tenant_id"`
 	FarmID      uuid.UUID `gorm:"type:uuid;index" json:"farm_id"`
 	CropID      uuid.UUID `gorm:"type:uuid;index" json:"crop_id"`
-	BatchCode   string    `gorm:"unique" json:"batch_code"` // Ej: LOT-20251025-A
+	BatchCode   string    `gorm:"uniqueIndex:idx_harvest_batches_tenant_batch_code,priority:2" json:"batch_code"` // Ej: LOT-20251025-A (único por empresa)
 	HarvestDate time.Time `json:"harvest_date"`
 	TotalBins   int       `json:"total_bins"` // Contador de cajas
+	UpdatedAt   time.Time `json:"updated_at"`
 	Crop        Crop      `json:"crop,omitempty" gorm:"foreignKey:CropID"`
 }
 
 // Bin: La caja física con QR
 type Bin struct {
 	ID             uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
-	TenantID       uuid.UUID  `gorm:"type:uuid;index" json:"tenant_id"`
-	QRCode         string     `gorm:"unique;index" json:"qr_code"`             // El string único del QR
-	HarvestBatchID *uuid.UUID `gorm:"type:uuid;index" json:"harvest_batch_id"` // Puede ser null si la caja está vacía
+	TenantID       uuid.UUID  `gorm:"type:uuid;index;uniqueIndex:idx_bins_tenant_qr_code,priority:1" json:"tenant_id"`
+	QRCode         string     `gorm:"index;uniqueIndex:idx_bins_tenant_qr_code,priority:2" json:"qr_code"` // El string del QR (único por empresa)
+	HarvestBatchID *uuid.UUID `gorm:"type:uuid;index" json:"harvest_batch_id"`                             // Puede ser null si la caja está vacía
+	FarmID         *uuid.UUID `gorm:"type:uuid;index" json:"farm_id"`                                      // Rancho del lote actual (restricción por rancho)
 	WeightKg       float64    `json:"weight_kg"`
 	Status         string     `json:"status"` // empty, full_in_field, received_in_packing
 	UpdatedAt      time.Time  `json:"updated_at"`
 	ShipmentID     *uuid.UUID `gorm:"type:uuid;index" json:"shipment_id"` // El camión donde se fue
+
+	// Último escaneo en campo: hora del dispositivo (puede llegar horas después por /sync) y quién lo hizo
+	ScannedAt *time.Time `json:"scanned_at"`
+	ScannedBy string     `gorm:"size:100" json:"scanned_by,omitempty"` // device_id de la app (vacío = escaneo en línea)
 }
 
 func (c *Crop) BeforeCreate(tx *gorm.DB) (err error) {
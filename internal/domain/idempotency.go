@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IdempotencyKey: Primera respuesta de un POST que trajo el header Idempotency-Key.
+// Los reintentos con la misma llave (ej: la app móvil sin señal) reciben esa misma respuesta
+// en vez de registrar dos veces una aplicación o descontar dos veces el inventario.
+// La llave es única por empresa; en las rutas de cuenta (sin empresa) es única por usuario.
+type IdempotencyKey struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	TenantID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_idempotency_key" json:"tenant_id"`         // uuid.Nil en rutas de cuenta
+	UserID      string    `gorm:"size:100;not null;default:'';uniqueIndex:idx_idempotency_key" json:"user_id"` // Solo en rutas de cuenta
+	Key         string    `gorm:"size:255;not null;uniqueIndex:idx_idempotency_key" json:"key"`
+	Method      string    `gorm:"size:10;not null" json:"method"`
+	Path        string    `gorm:"size:255;not null" json:"path"`
+	RequestHash string    `gorm:"size:64;not null" json:"-"` // sha256 de quién, método, ruta y cuerpo
+
+	// Respuesta guardada (Status 0 = la primera petición sigue en proceso)
+	Status       int    `json:"status"`
+	ContentType  string `gorm:"size:100" json:"content_type"`
+	ResponseBody []byte `json:"-"`
+
+	// LockedUntil: Mientras la primera petición corre se renueva; si vence, la réplica que la atendía murió
+	LockedUntil *time.Time `json:"locked_until"`
+
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+func (k *IdempotencyKey) BeforeCreate(tx *gorm.DB) (err error) {
+	k.ID = uuid.New()
+	return
+}
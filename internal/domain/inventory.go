@@ -25,6 +25,8 @@ type Product struct {
 	// Relación con Químicos (Si es un agroquímico)
 	ChemicalID *uuid.UUID `json:"chemical_id,omitempty"`
 
+	Editable // Versión (ETag) y baja lógica
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
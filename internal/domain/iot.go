@@ -21,6 +21,8 @@ type Device struct {
 	MinThreshold float64 `json:"min_threshold"` // Ej: Humedad mínima 30%
 	MaxThreshold float64 `json:"max_threshold"` // Ej: Humedad máxima 80%
 
+	Editable // Versión (ETag) y baja lógica
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -23,6 +23,8 @@ type LeaseContract struct {
 	ContractDocURL string `json:"contract_doc_url"` // PDF en S3 (simulado por ahora)
 	Status         string `json:"status"`           // active, expired, negotiation
 
+	Editable // Versión (ETag) y baja lógica
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OutboxMessage: Efecto externo (ej: un correo) pendiente de entregar. Se guarda en la misma transacción
+// que el cambio que lo origina: si la transacción se deshace el mensaje no existe, y si el proveedor
+// falla el despachador lo reintenta (internal/outbox). Después de MaxAttempts queda como dead.
+type OutboxMessage struct {
+	ID       uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	TenantID uuid.UUID `gorm:"type:uuid;not null;index:idx_outbox_tenant,priority:1" json:"tenant_id"`
+	Kind     string    `gorm:"size:20;not null" json:"kind"`  // email, webhook
+	Topic    string    `gorm:"size:50;not null" json:"topic"` // Origen: security, invitation, leases...
+	Target   string    `json:"target"`                        // Destinatarios o URL, para mostrar en la vista de entregas
+	Subject  string    `json:"subject"`
+
+	// Contenido que recibe el handler. Se borra al entregarse: una invitación trae el link en claro.
+	Payload json.RawMessage `gorm:"type:jsonb" json:"-"`
+
+	Status        string     `gorm:"size:20;not null;index:idx_outbox_due,priority:1" json:"status"` // pending, sent, dead
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_due,priority:2" json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	LockedBy      string     `gorm:"size:150;not null;default:''" json:"-"` // Réplica que lo está entregando
+	LockedUntil   *time.Time `json:"-"`
+	SentAt        *time.Time `json:"sent_at"`
+
+	CreatedAt time.Time `gorm:"index:idx_outbox_tenant,priority:2" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Tipos y estados de OutboxMessage
+const (
+	OutboxEmail   = "email"
+	OutboxWebhook = "webhook" // Un evento para un endpoint (también es su tema)
+
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead" // Agotó los intentos: solo se reintenta a mano
+)
+
+// Tema de los correos que no son alertas (las alertas usan su AlertTopic; los webhooks, OutboxWebhook)
+const OutboxInvitation = "invitation"
+
+func (m *OutboxMessage) BeforeCreate(tx *gorm.DB) (err error) {
+	m.ID = uuid.New()
+	return
+}
@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RolePermission: Personalización de permisos de un rol dentro de una empresa.
+// Si una empresa no tiene filas para un rol, aplica la matriz default de authz.
+type RolePermission struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	TenantID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_role_permission" json:"tenant_id"`
+	Role       string    `gorm:"size:50;not null;uniqueIndex:idx_role_permission" json:"role"`        // operator, viewer
+	Permission string    `gorm:"size:100;not null;uniqueIndex:idx_role_permission" json:"permission"` // Ej: finance:read
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (r *RolePermission) BeforeCreate(tx *gorm.DB) (err error) {
+	r.ID = uuid.New()
+	return
+}
@@ -19,6 +19,8 @@ type Supplier struct {
 	Phone         string    `json:"phone"`
 	CreditDays    int       `json:"credit_days"` // Días de crédito (Ej: 30, 60)
 	
+	Editable // Versión (ETag) y baja lógica
+
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
@@ -26,9 +28,9 @@ type Supplier struct {
 // PurchaseOrder: El documento formal de pedido (PO)
 type PurchaseOrder struct {
 	ID            uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	TenantID      uuid.UUID `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	TenantID      uuid.UUID `gorm:"type:uuid;not null;index;uniqueIndex:idx_purchase_orders_tenant_order_number,priority:1" json:"tenant_id"`
 	
-	OrderNumber   string    `gorm:"uniqueIndex" json:"order_number"` // Ej: PO-2025-001
+	OrderNumber   string    `gorm:"uniqueIndex:idx_purchase_orders_tenant_order_number,priority:2" json:"order_number"` // Ej: PO-2025-001 (único por empresa)
 	SupplierID    uuid.UUID `gorm:"type:uuid;not null;index" json:"supplier_id"`
 	Supplier      Supplier  `json:"supplier,omitempty"`
 	
@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// RateLimitBucket: Contador de peticiones de una llave (IP, usuario o API key) en la ventana actual.
+// Solo lo usa el store de Postgres del rate limiter (internal/ratelimit), para que todas las réplicas
+// compartan el presupuesto. Hay una fila por llave: al empezar otra ventana se reinicia.
+type RateLimitBucket struct {
+	Key         string    `gorm:"size:255;primary_key" json:"key"` // Grupo y quién: "public:ip:203.0.113.7", "tenant:key:<id>"
+	WindowStart time.Time `gorm:"not null" json:"window_start"`
+	Count       int       `gorm:"not null" json:"count"`
+	ExpiresAt   time.Time `gorm:"not null;index" json:"expires_at"` // Fin de la ventana (la purga borra las vencidas)
+}
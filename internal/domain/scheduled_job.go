@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ScheduledJob: Tarea periódica del sistema (purgas, resúmenes por correo). Una fila por tarea,
+// compartida por todas las réplicas: la que toma el candado (LockedBy / LockedUntil) la ejecuta.
+// Los estados de la última corrida usan las mismas constantes que TenantJob (running, completed, failed).
+type ScheduledJob struct {
+	Name     string `gorm:"primaryKey;size:100" json:"name"`
+	Schedule string `gorm:"size:100;not null" json:"schedule"` // Cron de 5 campos en UTC (ej: "0 13 * * *")
+
+	DueAt     time.Time `gorm:"not null" json:"due_at"`            // Hora programada de la corrida pendiente (no cambia con los reintentos)
+	NextRunAt time.Time `gorm:"not null;index" json:"next_run_at"` // Próximo intento (DueAt o la hora del reintento)
+	Attempt   int       `gorm:"not null;default:0" json:"attempt"` // Intentos hechos para DueAt
+
+	// Candado: la réplica que la ejecuta lo renueva mientras corre; si muere, vence y otra la retoma
+	LockedBy    string     `gorm:"size:150;not null;default:''" json:"locked_by,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+
+	// Avance de la corrida pendiente (ej: empresas a las que ya se les mandó el resumen): un reintento no las repite
+	Checkpoint json.RawMessage `gorm:"type:jsonb" json:"-"`
+
+	LastStatus     string     `gorm:"size:20;not null;default:''" json:"last_status"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastStartedAt  *time.Time `json:"last_started_at"`
+	LastFinishedAt *time.Time `json:"last_finished_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// JobRun: Historial de ejecuciones (un registro por intento)
+type JobRun struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	JobName    string     `gorm:"size:100;not null;index:idx_job_run,priority:1" json:"job_name"`
+	DueAt      time.Time  `gorm:"not null" json:"due_at"`
+	Attempt    int        `gorm:"not null" json:"attempt"`
+	Worker     string     `gorm:"size:150;not null" json:"worker"` // Réplica que lo ejecutó
+	Status     string     `gorm:"size:20;not null" json:"status"`  // running, completed, failed
+	Summary    string     `json:"summary,omitempty"`               // Ej: "3 empresas, 2 avisos"
+	Error      string     `json:"error,omitempty"`
+	RetryAt    *time.Time `json:"retry_at,omitempty"` // Solo en fallas que se reintentan
+	StartedAt  time.Time  `gorm:"not null;index:idx_job_run,priority:2" json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+func (r *JobRun) BeforeCreate(tx *gorm.DB) (err error) {
+	r.ID = uuid.New()
+	return
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tipos de operación que la app móvil encola sin señal y sube por /sync/push
+const (
+	SyncBinScan     = "bin_scan"
+	SyncApplication = "application"
+	SyncAssetUsage  = "asset_usage"
+)
+
+// Resultado de cada operación
+const (
+	SyncApplied  = "applied"  // Se registró
+	SyncRejected = "rejected" // Datos inválidos o regla de negocio (ej: químico prohibido)
+	SyncConflict = "conflict" // Otro dispositivo ya registró algo más reciente (ej: la misma caja)
+)
+
+// SyncOperation: Operación offline ya procesada. El op_id lo genera la app; si el lote se
+// vuelve a subir (se cortó la señal a medio envío), la operación no se aplica dos veces
+// y se devuelve el mismo resultado.
+type SyncOperation struct {
+	ID         uuid.UUID       `gorm:"type:uuid;primary_key;" json:"id"`
+	TenantID   uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_sync_operation" json:"tenant_id"`
+	OpID       string          `gorm:"size:100;not null;uniqueIndex:idx_sync_operation" json:"op_id"`
+	DeviceID   string          `gorm:"size:100;index" json:"device_id"`
+	Type       string          `gorm:"size:30;not null" json:"type"`
+	Status     string          `gorm:"size:20;not null" json:"status"`
+	Actor      string          `gorm:"size:150" json:"actor"`
+	ClientTime time.Time       `json:"client_time"`              // Cuándo pasó en campo (reloj del dispositivo)
+	Result     json.RawMessage `gorm:"type:jsonb" json:"result"` // Respuesta que recibió la app
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+func (s *SyncOperation) BeforeCreate(tx *gorm.DB) (err error) {
+	s.ID = uuid.New()
+	return
+}
@@ -9,14 +9,17 @@ import (
 
 // Tenant representa a una Agrícola (Cliente del SaaS)
 type Tenant struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	Name      string    `gorm:"size:255;not null" json:"name"`
-	RFC       string    `gorm:"size:13;unique" json:"rfc"`   // Contexto México
-	Plan      string    `gorm:"default:'basic'" json:"plan"` // basic, pro, enterprise
-	Active    bool      `gorm:"default:true" json:"active"`
-	OwnerID   string    `gorm:"size:255;index" json:"owner_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID      uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	Name    string    `gorm:"size:255;not null" json:"name"`
+	RFC     string    `gorm:"size:13;unique" json:"rfc"`   // Contexto México
+	Plan    string    `gorm:"default:'basic'" json:"plan"` // basic, pro, enterprise
+	Active  bool      `gorm:"default:true" json:"active"`
+	OwnerID string    `gorm:"size:255;index" json:"owner_id"`
+
+	// Facturación: el webhook de billing actualiza Plan y Active
+	BillingEventAt int64     `json:"-"` // "created" del último evento aplicado: descarta eventos fuera de orden
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 // BeforeCreate es un Hook de GORM para generar el UUID automáticamente antes de guardar
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TenantJob: Exportación completa o borrado de una empresa (se procesan en segundo plano).
+// Sobrevive al borrado de la empresa: es la constancia de quién lo pidió y cuándo terminó.
+type TenantJob struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	TenantID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	Kind        string     `gorm:"size:20;not null" json:"kind"`   // export, deletion
+	Status      string     `gorm:"size:20;not null" json:"status"` // queued, running, completed, failed
+	RequestedBy string     `gorm:"size:255;index" json:"requested_by"`
+	Error       string     `json:"error,omitempty"`
+	Archive     []byte     `gorm:"type:bytea" json:"-"` // ZIP de la exportación
+	ArchiveSize int64      `json:"archive_size,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // El ZIP se descarta después de esta fecha
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Tipos y estados de TenantJob
+const (
+	TenantJobExport   = "export"
+	TenantJobDeletion = "deletion"
+
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+func (j *TenantJob) BeforeCreate(tx *gorm.DB) (err error) {
+	j.ID = uuid.New()
+	return
+}
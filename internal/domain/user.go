@@ -7,35 +7,87 @@ import (
 )
 
 // User: El perfil global (vinculado a Clerk)
+// Se sincroniza con el webhook de Clerk (user.created / user.updated / user.deleted).
 type User struct {
-	ID           uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	ClerkID      string    `gorm:"unique;index" json:"clerk_id"`
-	Email        string    `json:"email"`
-	FullName     string    `json:"full_name"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	ClerkID        string     `gorm:"unique;index" json:"clerk_id"`
+	Email          string     `json:"email"`
+	FullName       string     `json:"full_name"`
+	ImageURL       string     `json:"image_url"`
+	ClerkUpdatedAt int64      `json:"-"`             // updated_at de Clerk (ms): descarta eventos que llegan fuera de orden
+	DeletedAt      *time.Time `json:"deleted_at"`    // Borrado en Clerk: se conserva el perfil para el historial
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// DisplayName: Nombre legible para correos y reportes ("Nombre <correo>")
+func (u *User) DisplayName() string {
+	switch {
+	case u.FullName != "" && u.Email != "":
+		return u.FullName + " <" + u.Email + ">"
+	case u.FullName != "":
+		return u.FullName
+	default:
+		return u.Email
+	}
 }
 
 // TeamMember: La relación "¿Quién trabaja dónde?"
+// Un usuario solo puede tener una membresía por empresa (idx_team_member).
 type TeamMember struct {
     ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-    TenantID  uuid.UUID `gorm:"type:uuid;index" json:"tenant_id"`
-    UserID    string    `gorm:"index" json:"user_clerk_id"` // Clerk ID del empleado
+    TenantID  uuid.UUID `gorm:"type:uuid;index;uniqueIndex:idx_team_member" json:"tenant_id"`
+    UserID    string    `gorm:"index;uniqueIndex:idx_team_member" json:"user_clerk_id"` // Clerk ID del empleado
     Role      string    `json:"role"` // admin, operator, viewer
     JoinedAt  time.Time `json:"joined_at"`
+    DeactivatedAt *time.Time `json:"deactivated_at"` // El usuario fue borrado en Clerk: ya no da acceso
 }
 
-// Invitation: Invitaciones pendientes por correo
+// MemberFarm: Ranchos asignados a un miembro del equipo.
+// Si un miembro no tiene filas aquí, ve todos los ranchos de la empresa.
+type MemberFarm struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
+	TenantID     uuid.UUID `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	TeamMemberID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_member_farm" json:"team_member_id"`
+	FarmID       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_member_farm" json:"farm_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Invitation: Invitaciones por correo
+// Del token solo guardamos su hash: el link en claro únicamente viaja en el correo.
 type Invitation struct {
-    ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-    TenantID  uuid.UUID `gorm:"type:uuid;index" json:"tenant_id"`
-    Email     string    `json:"email"`
-    Role      string    `json:"role"`
-    Token     string    `gorm:"index" json:"token"` // Código único para el link
-    Status    string    `json:"status"` // pending, accepted
-    CreatedAt time.Time `json:"created_at"`
+    ID         uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+    TenantID   uuid.UUID  `gorm:"type:uuid;index" json:"tenant_id"`
+    Email      string     `gorm:"index" json:"email"`
+    Role       string     `json:"role"`
+    TokenHash  string     `gorm:"size:64;index" json:"-"` // SHA-256 del código del link
+    Status     string     `json:"status"` // pending, accepted, revoked (expired se calcula con ExpiresAt)
+    InvitedBy  string     `json:"invited_by"` // Clerk ID del admin
+    ExpiresAt  time.Time  `json:"expires_at"`
+    SentAt     time.Time  `json:"sent_at"` // Último envío (cambia al reenviar)
+    AcceptedBy string     `json:"accepted_by,omitempty"`
+    AcceptedAt *time.Time `json:"accepted_at"`
+    RevokedAt  *time.Time `json:"revoked_at"`
+    CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Estados de una invitación
+const (
+    InvitePending  = "pending"
+    InviteAccepted = "accepted"
+    InviteRevoked  = "revoked"
+    InviteExpired  = "expired"
+)
+
+// EffectiveStatus: Una invitación pendiente cuyo plazo venció se reporta como "expired"
+func (i *Invitation) EffectiveStatus(now time.Time) string {
+    if i.Status == InvitePending && now.After(i.ExpiresAt) {
+        return InviteExpired
+    }
+    return i.Status
 }
 
 // Hooks para generar UUIDs... (pégalos como siempre)
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) { u.ID = uuid.New(); return }
 func (t *TeamMember) BeforeCreate(tx *gorm.DB) (err error) { t.ID = uuid.New(); return }
+func (m *MemberFarm) BeforeCreate(tx *gorm.DB) (err error) { m.ID = uuid.New(); return }
 func (i *Invitation) BeforeCreate(tx *gorm.DB) (err error) { i.ID = uuid.New(); return }
\ No newline at end of file
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// WebhookEvent: Mensajes de webhooks entrantes ya procesados (idempotencia ante reintentos).
+// El ID es "<source>:<id del proveedor>" (svix-id en Clerk, evt_... en facturación).
+type WebhookEvent struct {
+	ID          string    `gorm:"primaryKey;size:100" json:"id"`
+	Source      string    `gorm:"size:50;index" json:"source"` // clerk
+	Type        string    `gorm:"size:100" json:"type"`        // user.created, user.updated, user.deleted
+	ProcessedAt time.Time `json:"processed_at"`
+}
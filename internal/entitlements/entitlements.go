@@ -0,0 +1,218 @@
+package entitlements
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 💳 PLANES: Límites y módulos según Tenant.Plan
+// ---------------------------------------------------------
+// El núcleo (catálogos, aplicaciones, cajas, inventario, embarques) va en todos los planes.
+// Los módulos y los topes de recursos dependen del plan contratado.
+
+// Planes comerciales
+const (
+	PlanBasic      = "basic"
+	PlanPro        = "pro"
+	PlanEnterprise = "enterprise"
+)
+
+// Module: Área funcional que se habilita por plan
+type Module string
+
+const (
+	ModuleIoT         Module = "iot"
+	ModuleFleet       Module = "fleet"
+	ModuleProcurement Module = "procurement"
+	ModuleLand        Module = "land"
+	ModuleFinance     Module = "finance"
+)
+
+// Resource: Recurso contable contra un tope del plan
+type Resource string
+
+const (
+	ResourceFarms       Resource = "farms"
+	ResourceDevices     Resource = "devices"
+	ResourceTeamMembers Resource = "team_members"
+)
+
+// Unlimited: Valor de un tope sin límite
+const Unlimited = -1
+
+// Limits: Lo que incluye un plan
+type Limits struct {
+	Farms                  int      `json:"farms"`
+	Devices                int      `json:"devices"`
+	TeamMembers            int      `json:"team_members"` // Sin contar al dueño; las invitaciones vigentes apartan lugar
+	TelemetryRetentionDays int      `json:"telemetry_retention_days"`
+	Modules                []Module `json:"modules"`
+}
+
+// Plans: Catálogo comercial (cambiarlo aquí cambia lo que se cobra)
+var Plans = map[string]Limits{
+	PlanBasic: {
+		Farms:                  2,
+		Devices:                0,
+		TeamMembers:            3,
+		TelemetryRetentionDays: 30,
+		Modules:                []Module{},
+	},
+	PlanPro: {
+		Farms:                  15,
+		Devices:                100,
+		TeamMembers:            20,
+		TelemetryRetentionDays: 365,
+		Modules:                []Module{ModuleIoT, ModuleFleet, ModuleProcurement, ModuleLand},
+	},
+	PlanEnterprise: {
+		Farms:                  Unlimited,
+		Devices:                Unlimited,
+		TeamMembers:            Unlimited,
+		TelemetryRetentionDays: 5 * 365, // Historial completo para certificaciones
+		Modules:                []Module{ModuleIoT, ModuleFleet, ModuleProcurement, ModuleLand, ModuleFinance},
+	},
+}
+
+// ValidPlan indica si el plan existe en el catálogo
+func ValidPlan(plan string) bool {
+	_, ok := Plans[plan]
+	return ok
+}
+
+// For devuelve los límites del plan (un plan desconocido se trata como basic)
+func For(plan string) Limits {
+	if limits, ok := Plans[plan]; ok {
+		return limits
+	}
+	return Plans[PlanBasic]
+}
+
+// HasModule indica si el plan incluye el módulo
+func (l Limits) HasModule(m Module) bool {
+	for _, included := range l.Modules {
+		if included == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Limit devuelve el tope de un recurso
+func (l Limits) Limit(r Resource) int {
+	switch r {
+	case ResourceFarms:
+		return l.Farms
+	case ResourceDevices:
+		return l.Devices
+	case ResourceTeamMembers:
+		return l.TeamMembers
+	}
+	return 0
+}
+
+// TelemetryCutoff: Lecturas anteriores a esta fecha ya no se conservan
+func (l Limits) TelemetryCutoff(now time.Time) time.Time {
+	return now.AddDate(0, 0, -l.TelemetryRetentionDays)
+}
+
+// QuotaError: La empresa llegó al tope de su plan
+type QuotaError struct {
+	Plan     string
+	Resource Resource
+	Limit    int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("Tu plan %s permite hasta %d %s: actualiza tu plan para agregar más", e.Plan, e.Limit, resourceNames[e.Resource])
+}
+
+var resourceNames = map[Resource]string{
+	ResourceFarms:       "ranchos",
+	ResourceDevices:     "dispositivos IoT",
+	ResourceTeamMembers: "miembros del equipo",
+}
+
+// Count devuelve el consumo actual de un recurso. db debe estar ligada a la empresa (tenancy).
+func Count(db *gorm.DB, r Resource) (int64, error) {
+	var n int64
+	var err error
+	switch r {
+	case ResourceFarms:
+		err = db.Model(&domain.Farm{}).Count(&n).Error
+	case ResourceDevices:
+		err = db.Model(&domain.Device{}).Count(&n).Error
+	case ResourceTeamMembers:
+		var members, pending int64
+		if err = db.Model(&domain.TeamMember{}).Where("deactivated_at IS NULL").Count(&members).Error; err != nil {
+			return 0, err
+		}
+		err = db.Model(&domain.Invitation{}).
+			Where("status = ? AND expires_at > ?", domain.InvitePending, time.Now()).
+			Count(&pending).Error
+		n = members + pending
+	}
+	return n, err
+}
+
+// CheckQuota verifica que quepan "adding" unidades más del recurso en el plan.
+// Devuelve *QuotaError si se pasa del tope.
+func CheckQuota(db *gorm.DB, plan string, r Resource, adding int) error {
+	limit := For(plan).Limit(r)
+	if limit == Unlimited {
+		return nil
+	}
+	used, err := Count(db, r)
+	if err != nil {
+		return err
+	}
+	if used+int64(adding) > int64(limit) {
+		return &QuotaError{Plan: plan, Resource: r, Limit: limit}
+	}
+	return nil
+}
+
+// Usage: Consumo contra límites de cada recurso
+type Usage struct {
+	Used  int64 `json:"used"`
+	Limit int   `json:"limit"` // -1 = ilimitado
+}
+
+// Report calcula el consumo de todos los recursos de la empresa
+func Report(db *gorm.DB, plan string) (map[Resource]Usage, error) {
+	limits := For(plan)
+	out := map[Resource]Usage{}
+	for _, r := range []Resource{ResourceFarms, ResourceDevices, ResourceTeamMembers} {
+		used, err := Count(db, r)
+		if err != nil {
+			return nil, err
+		}
+		out[r] = Usage{Used: used, Limit: limits.Limit(r)}
+	}
+	return out, nil
+}
+
+// PurgeTelemetry borra las lecturas que ya salieron de la ventana de retención de cada empresa.
+// Se corre como sistema (la telemetría no tiene tenant_id: se llega por el dispositivo).
+func PurgeTelemetry(sysDB *gorm.DB, now time.Time) (int64, error) {
+	var tenants []domain.Tenant
+	if err := sysDB.Select("id", "plan").Find(&tenants).Error; err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, t := range tenants {
+		res := sysDB.Where("timestamp < ? AND device_id IN (?)",
+			For(t.Plan).TelemetryCutoff(now),
+			sysDB.Model(&domain.Device{}).Select("id").Where("tenant_id = ?", t.ID),
+		).Delete(&domain.TelemetryData{})
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+	}
+	return total, nil
+}
@@ -0,0 +1,378 @@
+package listing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ---------------------------------------------------------
+// 📄 LISTADOS: Paginación por cursor, filtros y orden
+// ---------------------------------------------------------
+// Cada endpoint de listado declara un Spec con los filtros y órdenes que acepta (lista blanca).
+// El cliente pide:
+//
+//	GET /bins?status=full_in_field&updated_from=2025-01-01&sort=-updated_at&limit=200
+//
+// y recibe el arreglo de siempre en el body. Si hay más resultados, la respuesta trae
+// X-Next-Cursor (y un Link rel="next"); la siguiente página es la misma URL con ?cursor=<valor>.
+// El cursor apunta al último registro visto (keyset), así que no se salta ni repite filas
+// aunque se inserten registros mientras se pagina, y no se degrada con OFFSET grandes.
+
+// NextCursorHeader: Header con el cursor de la siguiente página (ausente en la última)
+const NextCursorHeader = "X-Next-Cursor"
+
+// Límites por default de un listado
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Spec: Reglas de un endpoint de listado
+type Spec struct {
+	Filters      []Filter
+	Sorts        map[string]string // Llave pública -> columna ("name" -> "name")
+	DefaultSort  string            // Ej: "-created_at" (el "-" indica descendente)
+	DefaultLimit int               // 0 = DefaultLimit
+	MaxLimit     int               // 0 = MaxLimit
+}
+
+// Filter: Parámetro del query string permitido en un listado
+type Filter struct {
+	Param  string
+	Column string
+	kind   filterKind
+	values []string
+}
+
+type filterKind int
+
+const (
+	kindEq filterKind = iota
+	kindID
+	kindFrom
+	kindTo
+)
+
+// Eq: Igualdad con un valor del query string. Si se dan values, solo esos se aceptan.
+func Eq(param, column string, values ...string) Filter {
+	return Filter{Param: param, Column: column, kind: kindEq, values: values}
+}
+
+// ID: Igualdad con un UUID (ej: farm_id, season_id)
+func ID(param, column string) Filter {
+	return Filter{Param: param, Column: column, kind: kindID}
+}
+
+// Range: Rango de fechas en dos parámetros, <prefix>_from (incluido) y <prefix>_to (excluido).
+// Sin prefijo los parámetros son from y to. Aceptan RFC3339 o solo fecha (2025-01-31).
+func Range(prefix, column string) []Filter {
+	from, to := "from", "to"
+	if prefix != "" {
+		from, to = prefix+"_from", prefix+"_to"
+	}
+	return []Filter{
+		{Param: from, Column: column, kind: kindFrom},
+		{Param: to, Column: column, kind: kindTo},
+	}
+}
+
+// Page: Estado de la página pedida; se usa después de la consulta para armar la respuesta
+type Page struct {
+	Limit int
+
+	sortKey string
+	desc    bool
+	field   *schema.Field
+	idField *schema.Field
+}
+
+// cursor: Posición del último registro entregado
+type cursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    uuid.UUID       `json:"id"`
+}
+
+// Apply agrega filtros, orden, cursor y límite a q. q debe tener el modelo (tdb(c).Model(&domain.Bin{})).
+// Si el query string no es válido devuelve un *apierr.Error con el detalle de cada parámetro.
+func Apply(c *gin.Context, q *gorm.DB, spec Spec) (*gorm.DB, *Page, error) {
+	var details []apierr.FieldError
+
+	// 1. Filtros
+	for _, f := range spec.Filters {
+		v := c.Query(f.Param)
+		if v == "" {
+			continue
+		}
+		col := clause.Column{Table: clause.CurrentTable, Name: f.Column}
+		switch f.kind {
+		case kindEq:
+			if len(f.values) > 0 && !contains(f.values, v) {
+				details = append(details, apierr.Field(f.Param, "oneof", strings.Join(f.values, " ")))
+				continue
+			}
+			q = q.Where("? = ?", col, v)
+		case kindID:
+			id, err := uuid.Parse(v)
+			if err != nil {
+				details = append(details, apierr.Field(f.Param, "uuid", ""))
+				continue
+			}
+			q = q.Where("? = ?", col, id)
+		case kindFrom, kindTo:
+			t, err := parseTime(v)
+			if err != nil {
+				details = append(details, apierr.Field(f.Param, "rfc3339", ""))
+				continue
+			}
+			if f.kind == kindFrom {
+				q = q.Where("? >= ?", col, t)
+			} else {
+				q = q.Where("? < ?", col, t)
+			}
+		}
+	}
+
+	// 2. Límite
+	page := &Page{Limit: spec.DefaultLimit}
+	if page.Limit == 0 {
+		page.Limit = DefaultLimit
+	}
+	maxLimit := spec.MaxLimit
+	if maxLimit == 0 {
+		maxLimit = MaxLimit
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		switch {
+		case err != nil:
+			details = append(details, apierr.Field("limit", "type", ""))
+		case n < 1:
+			details = append(details, apierr.Field("limit", "min", "1"))
+		case n > maxLimit:
+			details = append(details, apierr.Field("limit", "max", strconv.Itoa(maxLimit)))
+		default:
+			page.Limit = n
+		}
+	}
+
+	// 3. Orden
+	page.sortKey = c.DefaultQuery("sort", spec.DefaultSort)
+	key := strings.TrimPrefix(page.sortKey, "-")
+	page.desc = strings.HasPrefix(page.sortKey, "-")
+	column, ok := spec.Sorts[key]
+	if !ok {
+		details = append(details, apierr.Field("sort", "oneof", strings.Join(sortKeys(spec.Sorts), " ")))
+	}
+	if len(details) > 0 {
+		return nil, nil, apierr.Validation(details...)
+	}
+
+	if err := q.Statement.Parse(q.Statement.Model); err != nil {
+		return nil, nil, apierr.Internal(err)
+	}
+	page.field = q.Statement.Schema.LookUpField(column)
+	page.idField = q.Statement.Schema.PrioritizedPrimaryField
+	if page.field == nil || page.idField == nil {
+		return nil, nil, apierr.Internal(fmt.Errorf("listado de %s: columna de orden %q o llave primaria inexistente", q.Statement.Table, column))
+	}
+
+	idCol := clause.Column{Table: clause.CurrentTable, Name: page.idField.DBName}
+	var sortCol interface{} = clause.Column{Table: clause.CurrentTable, Name: page.field.DBName}
+	// Una columna que admite NULL se ordena como su valor cero (COALESCE): la fila escaneada en Go
+	// trae ese mismo cero, así que el cursor la ubica igual que la base. Las NOT NULL usan su índice.
+	if !page.field.NotNull && !page.field.PrimaryKey {
+		sortCol = clause.Expr{SQL: "COALESCE(?, ?)", Vars: []interface{}{sortCol, page.zero()}}
+	}
+
+	// 4. Cursor: solo filas posteriores al último registro entregado
+	if raw := c.Query("cursor"); raw != "" {
+		cur, value, err := page.decode(raw)
+		if err != nil {
+			return nil, nil, apierr.Validation(apierr.Field("cursor", "invalid", ""))
+		}
+		op := ">"
+		if page.desc {
+			op = "<"
+		}
+		q = q.Where("(?, ?) "+op+" (?, ?)", sortCol, idCol, value, cur.ID)
+	}
+
+	// El ID desempata registros con el mismo valor de orden; se pide uno extra para saber si hay más
+	dir := " ASC"
+	if page.desc {
+		dir = " DESC"
+	}
+	q = q.Order(clause.OrderBy{Expression: clause.Expr{SQL: "?" + dir + ", ?" + dir, Vars: []interface{}{sortCol, idCol}}}).Limit(page.Limit + 1)
+	return q, page, nil
+}
+
+// Respond escribe la página (el arreglo, como siempre) y el cursor de la siguiente si hay más
+func Respond[T any](c *gin.Context, page *Page, rows []T) {
+	rows, ok := Trim(c, page, rows)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
+
+// Trim recorta la fila extra y pone los headers de la siguiente página.
+// Para respuestas que envuelven la página en un objeto; ok = false si ya se respondió un error.
+func Trim[T any](c *gin.Context, page *Page, rows []T) ([]T, bool) {
+	if len(rows) > page.Limit {
+		rows = rows[:page.Limit]
+		next, err := page.encode(c, reflect.ValueOf(&rows[len(rows)-1]).Elem())
+		if err != nil {
+			apierr.Respond(c, apierr.Internal(err))
+			return nil, false
+		}
+		u := *c.Request.URL
+		query := u.Query()
+		query.Set("cursor", next)
+		u.RawQuery = query.Encode()
+		c.Header(NextCursorHeader, next)
+		c.Header("Link", "<"+u.RequestURI()+`>; rel="next"`)
+	}
+	if rows == nil {
+		rows = []T{}
+	}
+	return rows, true
+}
+
+// zero: Valor cero del tipo de la columna de orden (lo que trae en Go una fila con NULL)
+func (p *Page) zero() interface{} {
+	return reflect.Zero(p.valueType()).Interface()
+}
+
+// valueType: Tipo de la columna de orden sin puntero (*time.Time -> time.Time)
+func (p *Page) valueType() reflect.Type {
+	t := p.field.FieldType
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func (p *Page) encode(c *gin.Context, row reflect.Value) (string, error) {
+	value, _ := p.field.ValueOf(c.Request.Context(), row)
+	id, _ := p.idField.ValueOf(c.Request.Context(), row)
+	uid, ok := id.(uuid.UUID)
+	if !ok {
+		return "", fmt.Errorf("llave primaria no es UUID: %T", id)
+	}
+	rawValue, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(cursor{Sort: p.sortKey, Value: rawValue, ID: uid})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decode valida el cursor y convierte el valor al tipo de la columna de orden
+func (p *Page) decode(raw string) (cursor, interface{}, error) {
+	var cur cursor
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cur, nil, err
+	}
+	if err := json.Unmarshal(b, &cur); err != nil {
+		return cur, nil, err
+	}
+	// Un cursor solo sirve con el orden con el que se generó
+	if cur.Sort != p.sortKey || cur.ID == uuid.Nil {
+		return cur, nil, errors.New("cursor de otro orden")
+	}
+	// Un campo puntero en NULL viaja como null y se lee como el valor cero (igual que el COALESCE)
+	value := reflect.New(p.valueType())
+	if err := json.Unmarshal(cur.Value, value.Interface()); err != nil {
+		return cur, nil, err
+	}
+	return cur, value.Elem().Interface(), nil
+}
+
+// ParamDoc: Parámetro que acepta un listado (para la especificación OpenAPI)
+type ParamDoc struct {
+	Name        string
+	Description string
+	Format      string // uuid, date-time o integer; vacío = texto libre
+	Enum        []string
+}
+
+// Params describe los parámetros del listado: filtros del spec, limit, sort y cursor
+func (s Spec) Params() []ParamDoc {
+	params := make([]ParamDoc, 0, len(s.Filters)+3)
+	for _, f := range s.Filters {
+		p := ParamDoc{Name: f.Param}
+		switch f.kind {
+		case kindEq:
+			p.Enum = f.values
+		case kindID:
+			p.Format = "uuid"
+		case kindFrom:
+			p.Format, p.Description = "date-time", "Desde (incluido); RFC3339 o fecha (2025-01-31)"
+		case kindTo:
+			p.Format, p.Description = "date-time", "Hasta (excluido); RFC3339 o fecha (2025-01-31)"
+		}
+		params = append(params, p)
+	}
+
+	limit, maxLimit := s.DefaultLimit, s.MaxLimit
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	if maxLimit == 0 {
+		maxLimit = MaxLimit
+	}
+	sorts := make([]string, 0, 2*len(s.Sorts))
+	for _, k := range sortKeys(s.Sorts) {
+		sorts = append(sorts, k, "-"+k)
+	}
+	return append(params,
+		ParamDoc{Name: "limit", Format: "integer", Description: fmt.Sprintf("Registros por página (default %d, máximo %d)", limit, maxLimit)},
+		ParamDoc{Name: "sort", Enum: sorts, Description: fmt.Sprintf("Orden; \"-\" indica descendente (default %s)", s.DefaultSort)},
+		ParamDoc{Name: "cursor", Description: "Valor de " + NextCursorHeader + " de la página anterior"},
+	)
+}
+
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, v)
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func sortKeys(sorts map[string]string) []string {
+	keys := make([]string, 0, len(sorts))
+	for k := range sorts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
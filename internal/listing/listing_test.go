@@ -0,0 +1,138 @@
+package listing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// item: Modelo de prueba con columnas de orden que admiten NULL
+type item struct {
+	ID       uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	Name     string     `gorm:"not null"`
+	WeightKg float64    // NULL en filas viejas (se lee como 0)
+	SeenAt   *time.Time // NULL = nunca
+}
+
+var itemList = Spec{
+	Sorts:       map[string]string{"name": "name", "weight_kg": "weight_kg", "seen_at": "seen_at"},
+	DefaultSort: "name",
+}
+
+func newItemsDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&item{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < 9; i++ {
+		row := map[string]interface{}{"id": uuid.New(), "name": string(rune('a' + i))}
+		// Una de cada tres filas trae NULL en las columnas opcionales
+		if i%3 != 0 {
+			row["weight_kg"] = float64(i % 4)
+			row["seen_at"] = now.Add(time.Duration(i%2) * time.Hour)
+		}
+		if err := db.Model(&item{}).Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// pageThrough recorre todas las páginas siguiendo X-Next-Cursor
+func pageThrough(t *testing.T, db *gorm.DB, query url.Values) []item {
+	t.Helper()
+	var all []item
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("la paginación no termina")
+		}
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/items?"+query.Encode(), nil)
+
+		q, page, err := Apply(c, db.Model(&item{}), itemList)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rows []item
+		if err := q.Find(&rows).Error; err != nil {
+			t.Fatal(err)
+		}
+		rows, ok := Trim(c, page, rows)
+		if !ok {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		all = append(all, rows...)
+
+		next := w.Header().Get(NextCursorHeader)
+		if next == "" {
+			return all
+		}
+		query.Set("cursor", next)
+	}
+}
+
+func TestCursorWithNullableSortColumns(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newItemsDB(t)
+
+	for _, sort := range []string{"weight_kg", "-weight_kg", "seen_at", "-seen_at", "name", "-name"} {
+		t.Run(sort, func(t *testing.T) {
+			rows := pageThrough(t, db, url.Values{"sort": {sort}, "limit": {"2"}})
+			if len(rows) != 9 {
+				t.Fatalf("filas = %d, se esperaban 9", len(rows))
+			}
+			seen := map[uuid.UUID]bool{}
+			for _, r := range rows {
+				if seen[r.ID] {
+					t.Fatalf("fila %s repetida", r.Name)
+				}
+				seen[r.ID] = true
+			}
+		})
+	}
+}
+
+func TestCursorRejectsOtherSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newItemsDB(t)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/items?sort=name&limit=2", nil)
+	q, page, err := Apply(c, db.Model(&item{}), itemList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []item
+	if err := q.Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	Trim(c, page, rows)
+	next := w.Header().Get(NextCursorHeader)
+
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/items?sort=weight_kg&cursor="+next, nil)
+	if _, _, err := Apply(c, db.Model(&item{}), itemList); err == nil {
+		t.Fatal("un cursor de otro orden fue aceptado")
+	}
+}
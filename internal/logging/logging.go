@@ -0,0 +1,150 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// ---------------------------------------------------------
+// 📝 LOGS ESTRUCTURADOS (slog)
+// ---------------------------------------------------------
+// Cada línea lleva request_id, tenant_id y user_id cuando existen en el contexto,
+// también en el trabajo de segundo plano que nace de una petición.
+// Tokens, correos y payloads de claims nunca llegan al log en claro.
+
+// Options: Formato y nivel del logger
+type Options struct {
+	Format string // json | text
+	Level  string // debug | info | warn | error
+}
+
+// Setup crea el logger y lo deja como default de slog (y del paquete log)
+func Setup(w io.Writer, opts Options) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: ParseLevel(opts.Level), ReplaceAttr: redactAttr}
+	var inner slog.Handler
+	if opts.Format == "json" {
+		inner = slog.NewJSONHandler(w, handlerOpts)
+	} else {
+		inner = slog.NewTextHandler(w, handlerOpts)
+	}
+	logger := slog.New(&contextHandler{inner: inner})
+	slog.SetDefault(logger)
+	return logger
+}
+
+// ParseLevel traduce el nivel de configuración (default: info)
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// ---- 🧵 CAMPOS DEL CONTEXTO ----
+
+type ctxKey struct{}
+
+// With agrega campos que acompañarán a cada línea registrada con este contexto.
+// Ej: logging.With(ctx, "request_id", id)
+func With(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	attrs := make([]slog.Attr, len(prev), len(prev)+len(args)/2)
+	copy(attrs, prev)
+	r := slog.Record{}
+	r.Add(args...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, ctxKey{}, attrs)
+}
+
+// Attrs devuelve los campos del contexto (ej: para copiarlos a otro contexto)
+func Attrs(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	return attrs
+}
+
+// contextHandler agrega a cada registro los campos guardados con With
+type contextHandler struct {
+	inner slog.Handler
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := Attrs(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{inner: h.inner.WithGroup(name)}
+}
+
+// ---- 🔒 REDACCIÓN ----
+
+// Redacted: Lo que se escribe en lugar del valor sensible
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys: Campos que nunca se registran, sin importar su contenido
+var sensitiveKeys = []string{
+	"authorization", "token", "jwt", "claims", "password", "secret", "cookie",
+	"api_key", "apikey", "key_hash", "email", "to", "recipients",
+}
+
+var (
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	secretPattern = regexp.MustCompile(`\b(agt|whsec|sk|rk|re)_[A-Za-z0-9+/=_-]{8,}`)
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+)
+
+// RedactString tapa JWTs, llaves y correos dentro de un texto libre (ej: un mensaje de error)
+func RedactString(s string) string {
+	s = jwtPattern.ReplaceAllString(s, Redacted)
+	s = secretPattern.ReplaceAllString(s, Redacted)
+	return emailPattern.ReplaceAllString(s, "[EMAIL]")
+}
+
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range sensitiveKeys {
+		if key == k || strings.HasSuffix(key, "_"+k) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAttr se aplica a cada campo antes de escribirlo
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if sensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, RedactString(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, RedactString(err.Error()))
+		}
+	}
+	return a
+}
@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ---------------------------------------------------------
+// 📈 MÉTRICAS (Prometheus)
+// ---------------------------------------------------------
+// Etiquetas con cardinalidad acotada: la ruta es el patrón de gin (/fleet/assets/:id), nunca el path real.
+// Los contadores de negocio llevan tenant (el número de empresas es manejable); los de HTTP y DB no,
+// porque se multiplicarían por rutas y tablas.
+
+// Registry: Registro propio (no el global) para exponer solo lo nuestro + runtime de Go
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agritrust_http_requests_total",
+		Help: "Peticiones HTTP atendidas por método, ruta y código de respuesta.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "agritrust_http_request_duration_seconds",
+		Help:    "Latencia de las peticiones HTTP por método y ruta.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "agritrust_db_query_duration_seconds",
+		Help:    "Duración de las queries de GORM por operación y tabla.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "table"})
+
+	DBQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agritrust_db_query_errors_total",
+		Help: "Queries de GORM que terminaron en error (sin contar 'registro no encontrado').",
+	}, []string{"operation", "table"})
+
+	BinsScanned = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agritrust_bins_scanned_total",
+		Help: "Cajas escaneadas en campo.",
+	}, []string{"tenant"})
+
+	Applications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agritrust_applications_total",
+		Help: "Aplicaciones fitosanitarias por resultado (approved, blocked por químico prohibido).",
+	}, []string{"tenant", "result"})
+
+	PurchaseOrdersReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agritrust_purchase_orders_received_total",
+		Help: "Órdenes de compra recibidas en almacén.",
+	}, []string{"tenant"})
+
+	TelemetryPoints = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agritrust_telemetry_points_total",
+		Help: "Lecturas de telemetría ingeridas.",
+	}, []string{"tenant"})
+
+	Emails = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agritrust_emails_total",
+		Help: "Correos del mailer por resultado (sent, failed, simulated).",
+	}, []string{"result"})
+
+	JobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agritrust_job_runs_total",
+		Help: "Corridas de tareas programadas por tarea y resultado (completed, failed).",
+	}, []string{"job", "status"})
+
+	JobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "agritrust_job_duration_seconds",
+		Help:    "Duración de las corridas de tareas programadas.",
+		Buckets: []float64{.1, .5, 1, 5, 15, 30, 60, 300, 900},
+	}, []string{"job"})
+
+	OutboxDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agritrust_outbox_deliveries_total",
+		Help: "Intentos de entrega del outbox por tipo y resultado (sent, retry, dead).",
+	}, []string{"kind", "result"})
+
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agritrust_rate_limited_total",
+		Help: "Peticiones rechazadas con 429 por grupo de rutas (public, account, tenant, heavy).",
+	}, []string{"policy"})
+)
+
+// Resultados de aplicaciones, correos y entregas del outbox
+const (
+	ApplicationApproved = "approved"
+	ApplicationBlocked  = "blocked"
+
+	EmailSent      = "sent"
+	EmailFailed    = "failed"
+	EmailSimulated = "simulated"
+
+	OutboxSent  = "sent"
+	OutboxRetry = "retry"
+	OutboxDead  = "dead"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration, DBQueryDuration, DBQueryErrors,
+		BinsScanned, Applications, PurchaseOrdersReceived, TelemetryPoints, Emails, JobRuns, JobDuration,
+		OutboxDeliveries,
+		RateLimited,
+	)
+}
+
+// RegisterDBStats expone el estado del pool de conexiones (abiertas, en uso, esperas)
+func RegisterDBStats(sqlDB *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(sqlDB, "agritrust"))
+}
+
+// Tenant: Valor de la etiqueta tenant
+func Tenant(id uuid.UUID) string {
+	return id.String()
+}
+
+// Middleware mide cada petición. Rutas inexistentes se agrupan en "unmatched" (evita cardinalidad por 404s).
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler expone /metrics. Si token no está vacío, exige "Authorization: Bearer <token>".
+func Handler(token string) gin.HandlerFunc {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	auth := RequireToken(token)
+	return func(c *gin.Context) {
+		if auth(c); c.IsAborted() {
+			return
+		}
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// RequireToken protege las rutas de operación (/metrics, /ops/...) con el mismo Bearer.
+// Token vacío = abierto (solo desarrollo: la configuración lo exige en producción).
+func RequireToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/audit"
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix: Las llaves de máquina empiezan así para distinguirlas de un JWT de Clerk
+const APIKeyPrefix = "agt_"
+
+// APIKeyHeader: Header alternativo a "Authorization: Bearer agt_..."
+const APIKeyHeader = "X-API-Key"
+
+var errInvalidAPIKey = apierr.New(http.StatusUnauthorized, "invalid_api_key")
+
+// GenerateAPIKey crea una llave nueva. Devuelve el texto en claro (mostrar una sola vez),
+// su prefijo visible y el hash que se guarda en la base.
+func GenerateAPIKey() (raw, prefix, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	raw = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return raw, raw[:12], HashAPIKey(raw), nil
+}
+
+// HashAPIKey: SHA-256 en hex (las llaves tienen 256 bits de entropía, no hace falta bcrypt)
+func HashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyScopes convierte el campo Scopes en un set de permisos
+func APIKeyScopes(key *domain.APIKey) authz.Set {
+	set := authz.Set{}
+	for _, s := range strings.Split(key.Scopes, ",") {
+		if p := authz.Permission(strings.TrimSpace(s)); authz.AllowedForAPIKey(p) {
+			set[p] = true
+		}
+	}
+	return set
+}
+
+// extractAPIKey busca la llave en X-API-Key o en "Authorization: Bearer agt_..."
+func extractAPIKey(c *gin.Context) string {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return key
+	}
+	if token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "); strings.HasPrefix(token, APIKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// authenticateAPIKey valida la llave y deja al service account como actor de la petición
+func authenticateAPIKey(c *gin.Context, db *gorm.DB, raw string) error {
+	sysDB := db.WithContext(tenancy.WithoutScope(c.Request.Context()))
+
+	var key domain.APIKey
+	if err := sysDB.Where("key_hash = ?", HashAPIKey(raw)).First(&key).Error; err != nil {
+		return errInvalidAPIKey
+	}
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return errInvalidAPIKey
+	}
+
+	// Registrar último uso (como mucho una vez por minuto para no escribir en cada telemetría)
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > time.Minute {
+		sysDB.Model(&domain.APIKey{}).Where("id = ?", key.ID).Update("last_used_at", now)
+	}
+
+	c.Set("actor_type", "service")
+	c.Set("api_key_id", key.ID.String())
+	c.Set("service_account", key.ServiceAccount)
+	c.Set("api_key_tenant_id", key.TenantID.String())
+	c.Set("api_key_scopes", APIKeyScopes(&key))
+	return nil
+}
+
+// IsServiceAccount indica si la petición viene de una API key (no de un usuario de Clerk)
+func IsServiceAccount(c *gin.Context) bool {
+	return c.GetString("actor_type") == "service"
+}
+
+// Actor: Identificador legible de quién hizo la petición ("user:<clerk_id>" o "service:<nombre>")
+func Actor(c *gin.Context) string {
+	if IsServiceAccount(c) {
+		return "service:" + c.GetString("service_account")
+	}
+	return "user:" + c.GetString("clerk_user_id")
+}
+
+// withAuditActor deja al actor en el contexto para que la bitácora sepa quién hizo cada cambio
+func withAuditActor(c *gin.Context) {
+	c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), Actor(c)))
+}
+
+// RequireUser: Rutas de cuenta que solo tienen sentido para personas (no para API keys)
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsServiceAccount(c) {
+			apierr.Respond(c, apierr.New(http.StatusForbidden, "api_key_not_allowed"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// apiKeyTenant devuelve la empresa dueña de la API key autenticada
+func apiKeyTenant(c *gin.Context) (uuid.UUID, authz.Set) {
+	id, _ := uuid.Parse(c.GetString("api_key_tenant_id"))
+	scopes, _ := c.Get("api_key_scopes")
+	set, _ := scopes.(authz.Set)
+	return id, set
+}
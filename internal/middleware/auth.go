@@ -1,21 +1,25 @@
 package middleware
 
 import (
+	"context"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 // Tu Clave Pública de Clerk (PEGA AQUÍ EL CONTENIDO SI ESTÁS EN LOCAL Y NO QUIERES LIDIAR CON ENV)
-// En producción, esto debe leerse de os.Getenv("CLERK_PEM_PUBLIC_KEY")
+// En producción se usa CLERK_JWKS_URL (rotación de llaves); esto es solo el fallback local (AllowDevKey).
 const HARDCODED_PEM_KEY = `-----BEGIN PUBLIC KEY-----
 MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAtH6qtcH9e9/A1XiHw8tR
 OTlGpxckeQeEZFHC/TiHwDLVjj3uHyiPIh+OjUaLse4nk8+2jmejaM9/HVM16shi
@@ -27,72 +31,168 @@ hQIDAQAB
 -----END PUBLIC KEY-----
 `
 
-func AuthMiddleware() gin.HandlerFunc {
+// VerifierConfig: Reglas de validación de los JWT de Clerk
+type VerifierConfig struct {
+	JWKSURL           string        // Ej: https://<tu-app>.clerk.accounts.dev/.well-known/jwks.json
+	PEMKey            string        // Llave estática (solo desarrollo, si no hay JWKS)
+	Issuer            string        // "iss" esperado (default: derivado del JWKS URL)
+	Audience          string        // "aud" esperado (opcional)
+	AuthorizedParties []string      // Orígenes válidos en "azp" (ej: https://agritrust-phi.vercel.app)
+	ClockSkew         time.Duration // Tolerancia de reloj para exp/nbf/iat
+	AllowDevKey       bool          // Sin JWKS ni PEM usa HARDCODED_PEM_KEY (solo APP_ENV=development)
+}
+
+// TokenVerifier valida firma y claims de los JWT de Clerk
+type TokenVerifier struct {
+	cfg       VerifierConfig
+	jwks      *JWKS
+	staticKey *rsa.PublicKey
+	parser    *jwt.Parser
+}
+
+// maxClockSkew: Tope de tolerancia para que una mala configuración no acepte tokens viejos
+const maxClockSkew = 2 * time.Minute
+
+// NewTokenVerifier prepara el verificador. El PEM se parsea UNA sola vez aquí.
+// client permite inyectar un http.Client (ej: para apuntar a un JWKS local).
+func NewTokenVerifier(cfg VerifierConfig, client *http.Client) (*TokenVerifier, error) {
+	if cfg.ClockSkew < 0 || cfg.ClockSkew > maxClockSkew {
+		return nil, fmt.Errorf("clock skew fuera de rango (0 - %s): %s", maxClockSkew, cfg.ClockSkew)
+	}
+
+	v := &TokenVerifier{cfg: cfg}
+	if cfg.JWKSURL != "" {
+		v.jwks = NewJWKS(cfg.JWKSURL, client)
+		if v.cfg.Issuer == "" {
+			v.cfg.Issuer = strings.TrimSuffix(cfg.JWKSURL, "/.well-known/jwks.json")
+		}
+	} else {
+		pemString := cfg.PEMKey
+		if pemString == "" {
+			if !cfg.AllowDevKey {
+				return nil, errors.New("falta CLERK_JWKS_URL o CLERK_PEM_PUBLIC_KEY")
+			}
+			pemString = HARDCODED_PEM_KEY
+		}
+		key, err := parseRSAPublicKey(pemString)
+		if err != nil {
+			return nil, fmt.Errorf("llave PEM de Clerk inválida: %w", err)
+		}
+		v.staticKey = key
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithLeeway(v.cfg.ClockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if v.cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(v.cfg.Issuer))
+	}
+	if v.cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(v.cfg.Audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+	return v, nil
+}
+
+// Verify parsea el token y valida firma, exp, nbf, iat, iss, aud y azp
+func (v *TokenVerifier) Verify(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if v.jwks == nil {
+			return v.staticKey, nil
+		}
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("el token no trae kid")
+		}
+		return v.jwks.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// azp: Clerk indica desde qué origen se emitió el token (protege contra tokens de otra app)
+	if len(v.cfg.AuthorizedParties) > 0 {
+		azp, _ := claims["azp"].(string)
+		if !contains(v.cfg.AuthorizedParties, azp) {
+			return nil, fmt.Errorf("azp no autorizado: %q", azp)
+		}
+	}
+	return claims, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthMiddleware acepta dos tipos de credencial:
+// - JWT de Clerk (personas: web admin y app móvil)
+// - API key "agt_..." (máquinas: gateways IoT, básculas, ERP), atribuida a su service account
+func AuthMiddleware(verifier *TokenVerifier, db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if rawKey := extractAPIKey(c); rawKey != "" {
+			if err := authenticateAPIKey(c, db, rawKey); err != nil {
+				apierr.Respond(c, err)
+				return
+			}
+			withAuditActor(c)
+			withLogFields(c)
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Falta token de autorización"})
+			apierr.Respond(c, apierr.New(http.StatusUnauthorized, "missing_token"))
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Formato Bearer inválido"})
+			apierr.Respond(c, apierr.New(http.StatusUnauthorized, "invalid_auth_scheme"))
 			return
 		}
 
 		tokenString := parts[1]
 
-		// 1. Parsear y Validar
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, fmt.Errorf("método de firma inesperado: %v", token.Header["alg"])
-			}
-			pemString := os.Getenv("CLERK_PEM_PUBLIC_KEY")
-			if pemString == "" {
-				pemString = HARDCODED_PEM_KEY
-			}
-			return parseRSAPublicKey(pemString)
-		})
-
-		if err != nil || !token.Valid {
-			fmt.Println("❌ Error validando token:", err)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o expirado"})
+		// 1. Parsear y Validar (firma contra JWKS + claims)
+		claims, err := verifier.Verify(c.Request.Context(), tokenString)
+		if err != nil {
+			slog.InfoContext(c.Request.Context(), "token rechazado", "error", err)
+			apierr.Respond(c, apierr.New(http.StatusUnauthorized, "invalid_token"))
 			return
 		}
 
-		// 2. LOGS DE DEPURACIÓN (ESTO ES LO NUEVO) 🕵️‍♂️
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		// 2. ID de Usuario (obligatorio: sin "sub" no sabemos quién es)
+		sub, _ := claims["sub"].(string)
+		if sub == "" {
+			apierr.Respond(c, apierr.New(http.StatusUnauthorized, "token_without_subject"))
+			return
+		}
+		c.Set("clerk_user_id", sub)
 
-			// A. ID de Usuario
-			if sub, ok := claims["sub"].(string); ok {
-				c.Set("clerk_user_id", sub)
-				fmt.Println("✅ Usuario Autenticado:", sub)
-			}
+		// Correo (si la plantilla de sesión de Clerk lo incluye): se usa para validar invitaciones
+		if email, ok := claims["email"].(string); ok {
+			c.Set("user_email", email)
+		}
 
-			// B. Intento de extracción de ROL
-			fmt.Println("🔍 Claims completos recibidos:", claims) // <--- ESTO NOS DIRÁ QUÉ TRAE EL TOKEN
-
-			// Verificamos si existe la metadata
-			if metadataRaw, ok := claims["public_metadata"]; ok {
-				fmt.Printf("   -> Metadata encontrada (Tipo %T): %v\n", metadataRaw, metadataRaw)
-
-				// Intentamos hacer casting a mapa
-				if metadata, ok := metadataRaw.(map[string]interface{}); ok {
-					if role, ok := metadata["role"].(string); ok {
-						c.Set("user_role", role)
-						fmt.Println("   -> Rol extraído exitosamente:", role)
-					} else {
-						fmt.Println("   ⚠️ Metadata existe, pero no el campo 'role' o no es string")
-					}
-				} else {
-					fmt.Println("   ⚠️ Error de casting: public_metadata no es map[string]interface{}")
-				}
-			} else {
-				fmt.Println("   ⚠️ EL TOKEN NO TRAE 'public_metadata'. ¿Cerraste sesión después de asignar el rol?")
+		// 3. Rol global (public_metadata.role): solo lo trae el token si se asignó en Clerk
+		if metadata, ok := claims["public_metadata"].(map[string]interface{}); ok {
+			if role, ok := metadata["role"].(string); ok {
+				c.Set("user_role", role)
 			}
 		}
 
+		withAuditActor(c)
+		withLogFields(c)
 		c.Next()
 	}
 }
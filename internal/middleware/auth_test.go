@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signToken firma un JWT RS256 con el kid indicado
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestTokenVerifierClaims(t *testing.T) {
+	key := newRSAKey(t)
+	srv := newJWKSServer(t, map[string]*rsa.PublicKey{"k1": &key.PublicKey})
+	verifier, err := NewTokenVerifier(VerifierConfig{
+		JWKSURL:           srv.URL + "/.well-known/jwks.json",
+		Audience:          "agritrust-api",
+		AuthorizedParties: []string{"https://app.agritrust.mx"},
+		ClockSkew:         30 * time.Second,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub": "user_123",
+			"iss": srv.URL,
+			"aud": "agritrust-api",
+			"azp": "https://app.agritrust.mx",
+			"iat": now.Unix(),
+			"nbf": now.Unix(),
+			"exp": now.Add(5 * time.Minute).Unix(),
+		}
+	}
+	with := func(key string, value interface{}) jwt.MapClaims {
+		claims := valid()
+		claims[key] = value
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		kid    string
+		ok     bool
+	}{
+		{"válido", valid(), "k1", true},
+		{"nbf dentro de la tolerancia", with("nbf", now.Add(20*time.Second).Unix()), "k1", true},
+		{"exp vencido dentro de la tolerancia", with("exp", now.Add(-20*time.Second).Unix()), "k1", true},
+		{"iss de otra instancia", with("iss", "https://otra.clerk.accounts.dev"), "k1", false},
+		{"aud equivocado", with("aud", "otra-api"), "k1", false},
+		{"azp no autorizado", with("azp", "https://evil.example.com"), "k1", false},
+		{"sin azp", with("azp", nil), "k1", false},
+		{"nbf en el futuro", with("nbf", now.Add(5*time.Minute).Unix()), "k1", false},
+		{"exp vencido fuera de la tolerancia", with("exp", now.Add(-time.Minute).Unix()), "k1", false},
+		{"sin exp", with("exp", nil), "k1", false},
+		{"kid desconocido", valid(), "k2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.claims {
+				if v == nil {
+					delete(tt.claims, k)
+				}
+			}
+			_, err := verifier.Verify(context.Background(), signToken(t, key, tt.kid, tt.claims))
+			if tt.ok && err != nil {
+				t.Fatalf("se esperaba aceptado: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("se esperaba rechazado")
+			}
+		})
+	}
+}
+
+func TestTokenVerifierRejectsForeignSignature(t *testing.T) {
+	key, attacker := newRSAKey(t), newRSAKey(t)
+	srv := newJWKSServer(t, map[string]*rsa.PublicKey{"k1": &key.PublicKey})
+	verifier, err := NewTokenVerifier(VerifierConfig{JWKSURL: srv.URL + "/.well-known/jwks.json"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := signToken(t, attacker, "k1", jwt.MapClaims{
+		"sub": "user_123",
+		"iss": srv.URL,
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	if _, err := verifier.Verify(context.Background(), token); err == nil {
+		t.Fatal("un token firmado con otra llave fue aceptado")
+	}
+}
+
+func TestNewTokenVerifierClockSkewBounds(t *testing.T) {
+	for _, skew := range []time.Duration{-time.Second, maxClockSkew + time.Second, time.Hour} {
+		if _, err := NewTokenVerifier(VerifierConfig{JWKSURL: "https://x.clerk.accounts.dev/.well-known/jwks.json", ClockSkew: skew}, nil); err == nil {
+			t.Fatalf("clock skew %s aceptado, el tope es %s", skew, maxClockSkew)
+		}
+	}
+	if _, err := NewTokenVerifier(VerifierConfig{JWKSURL: "https://x.clerk.accounts.dev/.well-known/jwks.json", ClockSkew: maxClockSkew}, nil); err != nil {
+		t.Fatalf("clock skew en el tope rechazado: %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/gin-gonic/gin"
+)
+
+// Permissions devuelve los permisos efectivos que TenantMiddleware resolvió para la petición
+func Permissions(c *gin.Context) authz.Set {
+	if v, ok := c.Get("permissions"); ok {
+		if set, ok := v.(authz.Set); ok {
+			return set
+		}
+	}
+	return authz.Set{}
+}
+
+// RequirePermission bloquea la petición si el rol del usuario en la empresa activa
+// no tiene el permiso. Debe montarse después de TenantMiddleware.
+func RequirePermission(perm authz.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Permissions(c).Has(perm) {
+			apierr.Respond(c, apierr.New(http.StatusForbidden, "permission_denied", "permission", perm))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePlatformAdmin: Solo para operaciones de la plataforma (no de una empresa).
+// Usa el rol global que Clerk manda en public_metadata.
+func RequirePlatformAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("user_role") != "admin" {
+			apierr.Respond(c, apierr.New(http.StatusForbidden, "platform_admin_required"))
+			return
+		}
+		c.Next()
+	}
+}
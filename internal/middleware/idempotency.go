@@ -0,0 +1,275 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ---------------------------------------------------------
+// 🔁 IDEMPOTENCIA: Reintentos seguros de POST
+// ---------------------------------------------------------
+// La app de campo reintenta cuando se cae la señal; sin esto un escaneo o una aplicación
+// podían registrarse dos veces (y descontar dos veces el inventario).
+//   1. El cliente manda Idempotency-Key (un UUID por operación, el mismo en cada reintento).
+//   2. La primera petición se ejecuta y su respuesta se guarda durante la retención (IDEMPOTENCY_TTL).
+//   3. Los reintentos reciben la respuesta guardada con Idempotent-Replayed: true.
+//   4. La misma llave con otro cuerpo, otra ruta u otro usuario responde 409.
+// Mientras la primera petición corre, su candado se renueva: un reintento no la ejecuta
+// otra vez aunque tarde minutos (exportación, /sync/push grande).
+// Sin el header la petición se ejecuta como siempre.
+
+const (
+	// IdempotencyHeader: Llave que manda el cliente en cada POST que quiera reintentar
+	IdempotencyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader: Marca las respuestas servidas desde la llave guardada
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKey = 255
+	// maxIdempotentBody: Tope del cuerpo que se lee a memoria para la huella (413 si lo pasa)
+	maxIdempotentBody = 10 << 20
+)
+
+// idempotencyLock: Vigencia del candado de una llave "en proceso"; se renueva cada tercio
+// mientras el handler corre, así que solo vence si la réplica murió (ej: reinicio).
+// Variable para que las pruebas no esperen un minuto.
+var idempotencyLock = time.Minute
+
+// Idempotency guarda y repite la primera respuesta de cada POST con Idempotency-Key.
+// Debe montarse después de AuthMiddleware (y de TenantMiddleware en rutas con empresa).
+func Idempotency(db *gorm.DB, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey || !printable(key) {
+			apierr.Respond(c, apierr.New(http.StatusBadRequest, "invalid_idempotency_key", "max", maxIdempotencyKey))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				apierr.Respond(c, apierr.New(http.StatusRequestEntityTooLarge, "request_too_large", "max", "10 MB"))
+				return
+			}
+			apierr.Respond(c, apierr.New(http.StatusBadRequest, apierr.CodeInvalidBody))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// La llave es de la empresa activa; en rutas de cuenta, del usuario
+		record := domain.IdempotencyKey{
+			TenantID:    TenantID(c),
+			Key:         key,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			RequestHash: requestHash(c, body),
+			ExpiresAt:   time.Now().Add(ttl),
+		}
+		if record.TenantID == uuid.Nil {
+			record.UserID = c.GetString("clerk_user_id")
+		}
+		// Las escrituras no dependen de que el cliente siga conectado
+		sysDB := db.WithContext(tenancy.WithoutScope(context.WithoutCancel(c.Request.Context())))
+
+		claimed, existing, err := claimIdempotencyKey(sysDB, &record)
+		if err != nil {
+			apierr.Respond(c, apierr.Internal(err))
+			return
+		}
+		if !claimed {
+			replayIdempotent(c, existing, record.RequestHash)
+			return
+		}
+
+		stopHeartbeat := keepIdempotencyLock(sysDB, record.ID)
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		completed := false
+		defer func() {
+			stopHeartbeat()
+			// Un panic o un error transitorio libera la llave: el reintento se vuelve a ejecutar
+			if !completed {
+				if err := sysDB.Delete(&domain.IdempotencyKey{}, "id = ?", record.ID).Error; err != nil {
+					slog.ErrorContext(c.Request.Context(), "error liberando idempotency key", "error", err)
+				}
+			}
+		}()
+
+		c.Next()
+
+		status := recorder.Status()
+		if !storableStatus(status) {
+			return
+		}
+		err = sysDB.Model(&record).Updates(map[string]interface{}{
+			"status":        status,
+			"content_type":  recorder.Header().Get("Content-Type"),
+			"response_body": recorder.body.Bytes(),
+		}).Error
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "error guardando respuesta idempotente", "error", err)
+			return
+		}
+		completed = true
+	}
+}
+
+// claimIdempotencyKey inserta la llave "en proceso". Si ya existe (y sigue vigente) la devuelve.
+func claimIdempotencyKey(sysDB *gorm.DB, record *domain.IdempotencyKey) (bool, *domain.IdempotencyKey, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		lockedUntil := time.Now().Add(idempotencyLock)
+		record.LockedUntil = &lockedUntil
+		res := sysDB.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if res.Error != nil {
+			return false, nil, res.Error
+		}
+		if res.RowsAffected == 1 {
+			return true, nil, nil
+		}
+
+		var existing domain.IdempotencyKey
+		err := sysDB.Where("tenant_id = ? AND user_id = ? AND key = ?", record.TenantID, record.UserID, record.Key).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue // Se liberó entre el INSERT y el SELECT
+		}
+		if err != nil {
+			return false, nil, err
+		}
+		now := time.Now()
+		abandoned := existing.Status == 0 && lockExpired(&existing, now)
+		if now.Before(existing.ExpiresAt) && !abandoned {
+			return false, &existing, nil
+		}
+		// Vencida o abandonada: se borra (solo si nadie la tomó antes) y se vuelve a intentar
+		if err := sysDB.Delete(&domain.IdempotencyKey{}, "id = ?", existing.ID).Error; err != nil {
+			return false, nil, err
+		}
+	}
+	return false, nil, errors.New("no se pudo reservar la idempotency key")
+}
+
+// keepIdempotencyLock renueva el candado de la llave hasta que se llame a la función devuelta
+func keepIdempotencyLock(sysDB *gorm.DB, id uuid.UUID) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(idempotencyLock / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				err := sysDB.Model(&domain.IdempotencyKey{}).Where("id = ? AND status = 0", id).
+					Update("locked_until", now.Add(idempotencyLock)).Error
+				if err != nil {
+					slog.Error("error renovando idempotency key", "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished // Que una renovación tardía no pise la respuesta guardada
+	}
+}
+
+// lockExpired: El candado venció (las llaves anteriores a locked_until usan created_at)
+func lockExpired(k *domain.IdempotencyKey, now time.Time) bool {
+	if k.LockedUntil == nil {
+		return now.Sub(k.CreatedAt) > idempotencyLock
+	}
+	return now.After(*k.LockedUntil)
+}
+
+// replayIdempotent responde a un reintento con la llave ya registrada
+func replayIdempotent(c *gin.Context, existing *domain.IdempotencyKey, hash string) {
+	switch {
+	case existing.RequestHash != hash:
+		apierr.Respond(c, apierr.New(http.StatusConflict, "idempotency_key_reused"))
+	case existing.Status == 0:
+		c.Header("Retry-After", "1")
+		apierr.Respond(c, apierr.New(http.StatusConflict, "idempotency_request_in_progress"))
+	default:
+		c.Header(IdempotentReplayedHeader, "true")
+		contentType := existing.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		c.Data(existing.Status, contentType, existing.ResponseBody)
+		c.Abort()
+	}
+}
+
+// requestHash: Huella de quién manda la petición y qué manda.
+// Incluye al usuario (o API key) para que otro miembro de la empresa no reciba una respuesta ajena.
+func requestHash(c *gin.Context, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{c.GetString("clerk_user_id"), c.GetString("api_key_id"), c.Request.Method, c.Request.URL.Path} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// storableStatus: Las respuestas que dependen de un estado pasajero no se guardan
+// (5xx, 429, 408, 401 y 403): el reintento vuelve a ejecutar la petición.
+func storableStatus(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+func printable(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x21 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// responseRecorder copia lo que el handler escribe para poder repetirlo
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// PurgeIdempotencyKeys borra las llaves vencidas. Devuelve cuántas borró.
+func PurgeIdempotencyKeys(sysDB *gorm.DB, now time.Time) (int64, error) {
+	res := sysDB.Where("expires_at < ?", now).Delete(&domain.IdempotencyKey{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newIdempotencyRouter(t *testing.T, handler gin.HandlerFunc) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&domain.IdempotencyKey{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	r := gin.New()
+	r.POST("/ops", func(c *gin.Context) { c.Set("clerk_user_id", "user_1") }, Idempotency(db, time.Hour), handler)
+	return r
+}
+
+func postWithKey(r http.Handler, key string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/ops", bytes.NewReader(body))
+	req.Header.Set(IdempotencyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyBodyLimit(t *testing.T) {
+	var calls atomic.Int32
+	r := newIdempotencyRouter(t, func(c *gin.Context) {
+		calls.Add(1)
+		c.JSON(http.StatusCreated, gin.H{"ok": true})
+	})
+
+	w := postWithKey(r, "llave-grande", bytes.Repeat([]byte("a"), maxIdempotentBody+1))
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "request_too_large") {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if calls.Load() != 0 {
+		t.Fatal("el handler corrió con un cuerpo excedido")
+	}
+
+	if w := postWithKey(r, "llave-normal", []byte(`{"a":1}`)); w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestIdempotencyLockOutlivesSlowHandler(t *testing.T) {
+	lock := idempotencyLock
+	idempotencyLock = 60 * time.Millisecond
+	t.Cleanup(func() { idempotencyLock = lock })
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	r := newIdempotencyRouter(t, func(c *gin.Context) {
+		if calls.Add(1) == 1 {
+			<-release
+		}
+		c.JSON(http.StatusCreated, gin.H{"ok": true})
+	})
+	body := []byte(`{"ops":[1,2,3]}`)
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- postWithKey(r, "llave-lenta", body) }()
+
+	// El handler sigue corriendo varias veces más que el candado: el reintento no lo ejecuta otra vez
+	time.Sleep(5 * idempotencyLock)
+	w := postWithKey(r, "llave-lenta", body)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "idempotency_request_in_progress") {
+		t.Fatalf("reintento: status %d: %s", w.Code, w.Body)
+	}
+
+	close(release)
+	if w := <-first; w.Code != http.StatusCreated {
+		t.Fatalf("original: status %d: %s", w.Code, w.Body)
+	}
+	w = postWithKey(r, "llave-lenta", body)
+	if w.Code != http.StatusCreated || w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("repetición: status %d, replayed=%q", w.Code, w.Header().Get(IdempotentReplayedHeader))
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("el handler corrió %d veces", n)
+	}
+}
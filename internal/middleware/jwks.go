@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// JWKS: Cliente con caché en memoria del JSON Web Key Set de Clerk.
+//   - Las llaves se indexan por "kid".
+//   - Un kid desconocido fuerza un refresh (rotación de llaves), limitado por minRefresh
+//     para que un token con kid inventado no nos haga golpear a Clerk en cada petición.
+//   - Pasado maxAge, el set se vuelve a descargar en la siguiente búsqueda.
+//   - Los refresh concurrentes se deduplican: N peticiones con un kid nuevo = 1 descarga.
+type JWKS struct {
+	url        string
+	client     *http.Client
+	minRefresh time.Duration
+	maxAge     time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	refresh   singleflight.Group
+}
+
+// ErrUnknownKID: El token está firmado con una llave que no existe en el JWKS
+var ErrUnknownKID = errors.New("kid desconocido en el JWKS")
+
+// NewJWKS crea el cliente. Si client es nil se usa uno con timeout de 5s.
+func NewJWKS(url string, client *http.Client) *JWKS {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &JWKS{
+		url:        url,
+		client:     client,
+		minRefresh: time.Minute,
+		maxAge:     time.Hour,
+		keys:       map[string]*rsa.PublicKey{},
+	}
+}
+
+// Key devuelve la llave pública para el kid, refrescando el set si hace falta
+func (j *JWKS) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	j.mu.RLock()
+	key, ok := j.keys[kid]
+	stale := time.Since(j.fetchedAt) > j.maxAge
+	canRefresh := time.Since(j.fetchedAt) > j.minRefresh
+	j.mu.RUnlock()
+
+	if ok && !stale {
+		return key, nil
+	}
+	if !ok && !canRefresh && !j.fetchedAt.IsZero() {
+		return nil, ErrUnknownKID
+	}
+
+	// Quien llegue mientras otra petición ya está descargando espera ese mismo resultado
+	_, err, _ := j.refresh.Do("jwks", func() (interface{}, error) {
+		return nil, j.Refresh(ctx)
+	})
+	if err != nil {
+		// Si Clerk no responde pero tenemos la llave en caché, seguimos usándola
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKID
+}
+
+// Refresh descarga el JWKS y reemplaza la caché completa
+func (j *JWKS) Refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("descargando JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("descargando JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("JWKS malformado: %w", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		pub, err := rsaKeyFromJWK(k.N, k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	j.mu.Unlock()
+	return nil
+}
+
+// rsaKeyFromJWK arma la llave desde el módulo (n) y exponente (e) en base64url
+func rsaKeyFromJWK(n, e string) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(eBytes)
+	if !exp.IsInt64() || exp.Int64() < 3 {
+		return nil, errors.New("exponente RSA inválido")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: int(exp.Int64())}, nil
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksServer: JWKS falso con llaves intercambiables y contador de descargas
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetches atomic.Int32
+	delay   time.Duration
+}
+
+func newJWKSServer(t *testing.T, keys map[string]*rsa.PublicKey) *jwksServer {
+	t.Helper()
+	s := &jwksServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		time.Sleep(s.delay)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		set := map[string][]map[string]string{"keys": {}}
+		for kid, pub := range s.keys {
+			set["keys"] = append(set["keys"], map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// rotate reemplaza el set publicado (simula la rotación de llaves de Clerk)
+func (s *jwksServer) rotate(keys map[string]*rsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestJWKSKeyRotation(t *testing.T) {
+	oldKey, newKey := newRSAKey(t), newRSAKey(t)
+	srv := newJWKSServer(t, map[string]*rsa.PublicKey{"old": &oldKey.PublicKey})
+	jwks := NewJWKS(srv.URL, nil)
+	jwks.minRefresh = 0
+	ctx := context.Background()
+
+	got, err := jwks.Key(ctx, "old")
+	if err != nil || !got.Equal(&oldKey.PublicKey) {
+		t.Fatalf("kid old: key=%v err=%v", got, err)
+	}
+
+	srv.rotate(map[string]*rsa.PublicKey{"new": &newKey.PublicKey})
+	got, err = jwks.Key(ctx, "new")
+	if err != nil || !got.Equal(&newKey.PublicKey) {
+		t.Fatalf("kid new tras rotar: key=%v err=%v", got, err)
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Fatalf("descargas = %d, se esperaban 2", n)
+	}
+
+	// La llave retirada deja de existir al reemplazarse la caché completa
+	if _, err := jwks.Key(ctx, "old"); !errors.Is(err, ErrUnknownKID) {
+		t.Fatalf("kid old tras rotar: err=%v, se esperaba ErrUnknownKID", err)
+	}
+}
+
+func TestJWKSUnknownKIDRefreshCooldown(t *testing.T) {
+	key := newRSAKey(t)
+	srv := newJWKSServer(t, map[string]*rsa.PublicKey{"a": &key.PublicKey})
+	jwks := NewJWKS(srv.URL, nil)
+	ctx := context.Background()
+
+	if _, err := jwks.Key(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dentro de minRefresh un kid desconocido se rechaza sin volver a descargar
+	for i := 0; i < 5; i++ {
+		if _, err := jwks.Key(ctx, "inventado"); !errors.Is(err, ErrUnknownKID) {
+			t.Fatalf("err=%v, se esperaba ErrUnknownKID", err)
+		}
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("descargas = %d, se esperaba 1", n)
+	}
+
+	// Pasado el cooldown, el kid desconocido sí dispara un refresh
+	jwks.minRefresh = 0
+	if _, err := jwks.Key(ctx, "inventado"); !errors.Is(err, ErrUnknownKID) {
+		t.Fatalf("err=%v, se esperaba ErrUnknownKID", err)
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Fatalf("descargas = %d, se esperaban 2", n)
+	}
+}
+
+func TestJWKSConcurrentRefreshIsDeduplicated(t *testing.T) {
+	key := newRSAKey(t)
+	srv := newJWKSServer(t, map[string]*rsa.PublicKey{"a": &key.PublicKey})
+	srv.delay = 200 * time.Millisecond
+	jwks := NewJWKS(srv.URL, nil)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if _, err := jwks.Key(context.Background(), "a"); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("descargas = %d, se esperaba 1", n)
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader: ID de la petición (se devuelve siempre; soporte lo pide en los tickets)
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID: Se acepta el ID del cliente/proxy solo si es corto y sin caracteres raros
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{8,64}$`)
+
+// RequestID asigna el ID de la petición y lo deja en el contexto de logs
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "request_id", id))
+		c.Next()
+	}
+}
+
+// AccessLog registra una línea por petición (sin query string: puede traer tokens, ej: /join?token=)
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "error", c.Errors.String())
+		}
+		// c.Request ya trae los campos que agregaron Auth y Tenant (user_id, tenant_id)
+		slog.Log(c.Request.Context(), level, "http", attrs...)
+	}
+}
+
+// Recovery convierte un panic en 500 y lo registra con stack (sin volcar headers de la petición)
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec) // El cliente cerró la conexión: lo maneja net/http
+				}
+				slog.ErrorContext(c.Request.Context(), "panic en handler",
+					"panic", fmt.Sprint(rec), "stack", string(debug.Stack()))
+				apierr.Respond(c, apierr.New(http.StatusInternalServerError, apierr.CodeInternal))
+			}
+		}()
+		c.Next()
+	}
+}
+
+// withLogFields agrega quién hace la petición a cada línea de log
+func withLogFields(c *gin.Context) {
+	ctx := c.Request.Context()
+	if IsServiceAccount(c) {
+		ctx = logging.With(ctx, "api_key_id", c.GetString("api_key_id"), "service_account", c.GetString("service_account"))
+	} else {
+		ctx = logging.With(ctx, "user_id", c.GetString("clerk_user_id"))
+	}
+	c.Request = c.Request.WithContext(ctx)
+}
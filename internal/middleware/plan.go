@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/entitlements"
+	"github.com/gin-gonic/gin"
+)
+
+// Plan devuelve el plan de la empresa activa (resuelto por TenantMiddleware)
+func Plan(c *gin.Context) string {
+	return c.GetString("tenant_plan")
+}
+
+// RequireModule bloquea las rutas de un módulo que no viene en el plan de la empresa.
+// Debe montarse después de TenantMiddleware.
+func RequireModule(m entitlements.Module) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !entitlements.For(Plan(c)).HasModule(m) {
+			apierr.Respond(c, apierr.New(http.StatusForbidden, "plan_upgrade_required", "module", m, "plan", Plan(c)))
+			return
+		}
+		c.Next()
+	}
+}
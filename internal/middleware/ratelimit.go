@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/metrics"
+	"github.com/Marcos1394/agritrust-backend/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Headers del borrador IETF "RateLimit header fields for HTTP" (el cliente ve cuánto le queda)
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset" // Segundos para la ventana siguiente
+	RateLimitPolicyHeader    = "RateLimit-Policy"
+)
+
+// RateLimitKey: Quién gasta el presupuesto ("" = no se limita la petición)
+type RateLimitKey func(c *gin.Context) string
+
+// ByIP: Rutas públicas. La IP sale de c.ClientIP (respeta TRUSTED_PROXIES).
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByPrincipal: Rutas privadas. Una API key tiene su propio presupuesto (un gateway IoT no agota
+// el de las personas); un usuario, uno por empresa. Debe montarse después de AuthMiddleware
+// (y de TenantMiddleware en rutas con empresa).
+func ByPrincipal(c *gin.Context) string {
+	if keyID := c.GetString("api_key_id"); keyID != "" {
+		return "key:" + keyID
+	}
+	user := c.GetString("clerk_user_id")
+	if user == "" {
+		return ByIP(c)
+	}
+	if tenantID := TenantID(c); tenantID != uuid.Nil {
+		return "tenant:" + tenantID.String() + ":user:" + user
+	}
+	return "user:" + user
+}
+
+// RateLimit responde 429 rate_limited con Retry-After cuando la llave agota el presupuesto de la política.
+// Si el store falla la petición pasa (se registra): un problema del contador no debe tirar la API.
+func RateLimit(store ratelimit.Store, p ratelimit.Policy, key RateLimitKey) gin.HandlerFunc {
+	if p.Limit <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	policy := strconv.Itoa(p.Limit) + ";w=" + strconv.Itoa(int(p.Window.Seconds()))
+	return func(c *gin.Context) {
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+		now := time.Now()
+		res, err := ratelimit.Take(c.Request.Context(), store, p, k, now)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "rate limiter sin store; la petición pasa", "policy", p.Name, "error", err)
+			c.Next()
+			return
+		}
+		reset := int(res.Reset.Sub(now).Round(time.Second).Seconds())
+		if reset < 1 {
+			reset = 1
+		}
+		c.Header(RateLimitLimitHeader, strconv.Itoa(res.Limit))
+		c.Header(RateLimitRemainingHeader, strconv.Itoa(res.Remaining))
+		c.Header(RateLimitResetHeader, strconv.Itoa(reset))
+		c.Header(RateLimitPolicyHeader, policy)
+		if !res.Allowed {
+			metrics.RateLimited.WithLabelValues(p.Name).Inc()
+			c.Header("Retry-After", strconv.Itoa(reset))
+			apierr.Respond(c, apierr.New(http.StatusTooManyRequests, "rate_limited", "retry_after", reset))
+			return
+		}
+		c.Next()
+	}
+}
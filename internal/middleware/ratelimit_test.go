@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/ratelimit"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	p := ratelimit.Policy{Name: "public", Limit: 2, Window: time.Minute}
+	r.GET("/passport", RateLimit(ratelimit.NewMemoryStore(), p, ByIP), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	get := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/passport", nil)
+		req.RemoteAddr = ip + ":40000"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		w := get("203.0.113.7")
+		if w.Code != http.StatusOK {
+			t.Fatalf("petición %d: status %d", i+1, w.Code)
+		}
+		if w.Header().Get(RateLimitLimitHeader) != "2" || w.Header().Get(RateLimitRemainingHeader) != remaining ||
+			w.Header().Get(RateLimitPolicyHeader) != "2;w=60" || w.Header().Get("Retry-After") != "" {
+			t.Fatalf("petición %d: headers %v", i+1, w.Header())
+		}
+	}
+
+	w := get("203.0.113.7")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("tercera petición: status %d", w.Code)
+	}
+	if w.Header().Get(RateLimitRemainingHeader) != "0" || w.Header().Get(RateLimitPolicyHeader) != "2;w=60" {
+		t.Fatalf("headers del 429: %v", w.Header())
+	}
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retry < 1 || retry > 60 || w.Header().Get(RateLimitResetHeader) != w.Header().Get("Retry-After") {
+		t.Fatalf("Retry-After=%q RateLimit-Reset=%q", w.Header().Get("Retry-After"), w.Header().Get(RateLimitResetHeader))
+	}
+	var body struct {
+		Code       string `json:"code"`
+		RetryAfter int    `json:"retry_after"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != "rate_limited" || body.RetryAfter != retry {
+		t.Fatalf("cuerpo del 429: %s", w.Body)
+	}
+
+	// Otra IP tiene su propio presupuesto
+	if w := get("198.51.100.1"); w.Code != http.StatusOK {
+		t.Fatalf("otra IP: status %d", w.Code)
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", RateLimit(ratelimit.NewMemoryStore(), ratelimit.Policy{Name: "public", Window: time.Minute}, ByIP), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusNoContent || w.Header().Get(RateLimitLimitHeader) != "" {
+		t.Fatalf("Limit 0: status %d, headers %v", w.Code, w.Header())
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tenantID := uuid.New()
+
+	cases := []struct {
+		name string
+		set  func(c *gin.Context)
+		want string
+	}{
+		{"API key", func(c *gin.Context) {
+			c.Set("api_key_id", "key_1")
+			c.Set("clerk_user_id", "user_1")
+		}, "key:key_1"},
+		{"usuario con empresa", func(c *gin.Context) {
+			c.Set("clerk_user_id", "user_1")
+			c.Request = c.Request.WithContext(tenancy.WithTenant(c.Request.Context(), tenantID))
+		}, "tenant:" + tenantID.String() + ":user:user_1"},
+		{"usuario sin empresa", func(c *gin.Context) { c.Set("clerk_user_id", "user_1") }, "user:user_1"},
+		{"anónimo", func(c *gin.Context) {}, "ip:203.0.113.7"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request.RemoteAddr = "203.0.113.7:40000"
+			tc.set(c)
+			if got := ByPrincipal(c); got != tc.want {
+				t.Fatalf("ByPrincipal = %q, se esperaba %q", got, tc.want)
+			}
+			if got := ByIP(c); got != "ip:203.0.113.7" {
+				t.Fatalf("ByIP = %q", got)
+			}
+		})
+	}
+}
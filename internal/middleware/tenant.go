@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Marcos1394/agritrust-backend/internal/apierr"
+	"github.com/Marcos1394/agritrust-backend/internal/authz"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/logging"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TenantHeader: Header con el que el frontend indica la empresa sobre la que opera
+const TenantHeader = "X-Tenant-ID"
+
+// TenantMiddleware resuelve la empresa activa de la petición.
+// 1. Si viene X-Tenant-ID, valida que el usuario sea dueño o miembro de esa empresa.
+// 2. Si no viene, usa la única empresa del usuario (si tiene varias, exige el header).
+// 3. Resuelve el rol del usuario en esa empresa, sus permisos efectivos y sus ranchos asignados.
+// El resultado se guarda en el contexto para que GORM filtre TODAS las queries.
+func TenantMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// API keys: la empresa y los permisos vienen fijos en la llave
+		if IsServiceAccount(c) {
+			tenantID, scopes := apiKeyTenant(c)
+			if header := c.GetHeader(TenantHeader); header != "" && header != tenantID.String() {
+				apierr.Respond(c, apierr.New(http.StatusForbidden, "api_key_tenant_mismatch"))
+				return
+			}
+			if !loadSubscription(c, db.WithContext(tenancy.WithoutScope(c.Request.Context())), tenantID) {
+				return
+			}
+			c.Set("tenant_id", tenantID.String())
+			c.Set("tenant_role", "service")
+			c.Set("permissions", scopes)
+			c.Request = c.Request.WithContext(logging.With(tenancy.WithTenant(c.Request.Context(), tenantID), "tenant_id", tenantID.String()))
+			c.Next()
+			return
+		}
+
+		clerkUserID := c.GetString("clerk_user_id")
+		// Las membresías se consultan "como sistema": aún no sabemos la empresa
+		sysDB := db.WithContext(tenancy.WithoutScope(c.Request.Context()))
+
+		var tenantID uuid.UUID
+		if header := c.GetHeader(TenantHeader); header != "" {
+			parsed, err := uuid.Parse(header)
+			if err != nil {
+				apierr.Respond(c, apierr.New(http.StatusBadRequest, "invalid_tenant_header"))
+				return
+			}
+			tenantID = parsed
+		} else {
+			ids, err := userTenantIDs(sysDB, clerkUserID)
+			if err != nil {
+				apierr.Respond(c, apierr.Internal(err))
+				return
+			}
+			switch len(ids) {
+			case 0:
+				apierr.Respond(c, apierr.New(http.StatusForbidden, "no_tenant"))
+				return
+			case 1:
+				tenantID = ids[0]
+			default:
+				apierr.Respond(c, apierr.New(http.StatusBadRequest, "tenant_required"))
+				return
+			}
+		}
+
+		role, ok := resolveRole(sysDB, tenantID, clerkUserID)
+		if !ok {
+			apierr.Respond(c, apierr.New(http.StatusForbidden, "tenant_access_denied"))
+			return
+		}
+		if !loadSubscription(c, sysDB, tenantID) {
+			return
+		}
+
+		// Ranchos asignados: un miembro restringido solo ve y registra en esos ranchos
+		ctx := logging.With(tenancy.WithTenant(c.Request.Context(), tenantID), "tenant_id", tenantID.String())
+		if role != authz.RoleAdmin {
+			farmIDs, err := memberFarmIDs(sysDB, tenantID, clerkUserID)
+			if err != nil {
+				apierr.Respond(c, apierr.Internal(err))
+				return
+			}
+			if len(farmIDs) > 0 {
+				c.Set("farm_ids", farmIDs)
+				ctx = tenancy.WithFarms(ctx, farmIDs)
+			}
+		}
+
+		perms, err := authz.Resolve(sysDB, tenantID, role)
+		if err != nil {
+			apierr.Respond(c, apierr.Internal(err))
+			return
+		}
+
+		c.Set("tenant_id", tenantID.String())
+		c.Set("tenant_role", role)
+		c.Set("permissions", perms)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// loadSubscription deja el plan de la empresa en el contexto.
+// Una empresa con la suscripción inactiva queda en solo lectura (puede consultar y exportar, no registrar).
+func loadSubscription(c *gin.Context, sysDB *gorm.DB, tenantID uuid.UUID) bool {
+	var tenant domain.Tenant
+	if err := sysDB.Select("id", "plan", "active").First(&tenant, "id = ?", tenantID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		apierr.Respond(c, apierr.New(http.StatusForbidden, "tenant_access_denied"))
+		return false
+	} else if err != nil {
+		apierr.Respond(c, apierr.Internal(err))
+		return false
+	}
+	if !tenant.Active && c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		apierr.Respond(c, apierr.New(http.StatusPaymentRequired, "subscription_inactive"))
+		return false
+	}
+	c.Set("tenant_plan", tenant.Plan)
+	return true
+}
+
+// TenantID devuelve la empresa activa resuelta por TenantMiddleware
+func TenantID(c *gin.Context) uuid.UUID {
+	id, _ := tenancy.FromContext(c.Request.Context())
+	return id
+}
+
+// FarmIDs devuelve los ranchos asignados al usuario (nil = sin restricción)
+func FarmIDs(c *gin.Context) []uuid.UUID {
+	ids, _ := c.Get("farm_ids")
+	farmIDs, _ := ids.([]uuid.UUID)
+	return farmIDs
+}
+
+// userTenantIDs: Empresas donde el usuario es dueño o miembro del equipo
+func userTenantIDs(sysDB *gorm.DB, clerkUserID string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := sysDB.Model(&domain.Tenant{}).
+		Where("owner_id = ?", clerkUserID).
+		Or("id IN (?)", sysDB.Model(&domain.TeamMember{}).Select("tenant_id").Where("user_id = ? AND deactivated_at IS NULL", clerkUserID)).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// memberFarmIDs: Ranchos asignados al miembro (vacío = todos los de la empresa)
+func memberFarmIDs(sysDB *gorm.DB, tenantID uuid.UUID, clerkUserID string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := sysDB.Model(&domain.MemberFarm{}).
+		Where("tenant_id = ? AND team_member_id IN (?)", tenantID,
+			sysDB.Model(&domain.TeamMember{}).Select("id").Where("tenant_id = ? AND user_id = ? AND deactivated_at IS NULL", tenantID, clerkUserID)).
+		Pluck("farm_id", &ids).Error
+	return ids, err
+}
+
+// resolveRole: El dueño de la empresa es admin implícito; el resto usa su TeamMember activo
+func resolveRole(sysDB *gorm.DB, tenantID uuid.UUID, clerkUserID string) (string, bool) {
+	var tenant domain.Tenant
+	if err := sysDB.First(&tenant, "id = ?", tenantID).Error; err != nil {
+		return "", false
+	}
+	if tenant.OwnerID == clerkUserID {
+		return authz.RoleAdmin, true
+	}
+
+	var member domain.TeamMember
+	if err := sysDB.Where("tenant_id = ? AND user_id = ? AND deactivated_at IS NULL", tenantID, clerkUserID).First(&member).Error; err != nil {
+		return "", false
+	}
+	return member.Role, true
+}
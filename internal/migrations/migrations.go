@@ -0,0 +1,293 @@
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 🗄️ MIGRACIONES VERSIONADAS (SQL)
+// ---------------------------------------------------------
+// Cada cambio de esquema es un par de archivos en sql/:
+//   NNNN_descripcion.up.sql   → aplica el cambio
+//   NNNN_descripcion.down.sql → lo revierte
+// Las versiones aplicadas se registran en schema_migrations.
+// Al agregar o cambiar un modelo en domain hay que escribir la migración correspondiente:
+// el servidor ya no crea tablas solo.
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// Table: Tabla donde se registran las versiones aplicadas
+const Table = "schema_migrations"
+
+// lockID: Llave del advisory lock de Postgres (evita que dos procesos migren a la vez)
+const lockID = 72_657_011
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration: Un paso versionado del esquema
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status: Estado de una migración en la base
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"` // nil = pendiente
+}
+
+// PendingError: La base no está al día con las migraciones del binario
+type PendingError struct {
+	Pending []int64
+}
+
+func (e *PendingError) Error() string {
+	versions := make([]string, len(e.Pending))
+	for i, v := range e.Pending {
+		versions[i] = strconv.FormatInt(v, 10)
+	}
+	return "hay migraciones pendientes: " + strings.Join(versions, ", ")
+}
+
+// ErrUnknownVersion: La base tiene versiones que este binario no conoce (binario viejo)
+var ErrUnknownVersion = errors.New("la base tiene migraciones más nuevas que este binario")
+
+// All devuelve las migraciones embebidas, ordenadas por versión
+func All() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("nombre de migración inválido: %s", e.Name())
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		body, err := files.ReadFile(path.Join("sql", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("versión %d repetida: %s y %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("a la migración %d_%s le falta su archivo up o down", mig.Version, mig.Name)
+		}
+		list = append(list, *mig)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// ensureTable crea schema_migrations si no existe
+func ensureTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS "` + Table + `" ("version" bigint PRIMARY KEY, "name" text NOT NULL, "applied_at" timestamptz NOT NULL DEFAULT now())`).Error
+}
+
+// applied: Versiones registradas en la base
+func applied(db *gorm.DB) (map[int64]time.Time, error) {
+	type row struct {
+		Version   int64
+		AppliedAt time.Time
+	}
+	var rows []row
+	if err := db.Table(Table).Select("version, applied_at").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[int64]time.Time, len(rows))
+	for _, r := range rows {
+		out[r.Version] = r.AppliedAt
+	}
+	return out, nil
+}
+
+// List devuelve todas las migraciones con su fecha de aplicación (o pendientes)
+func List(db *gorm.DB) ([]Status, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Status, 0, len(all))
+	for _, m := range all {
+		s := Status{Version: m.Version, Name: m.Name}
+		if at, ok := done[m.Version]; ok {
+			at := at
+			s.AppliedAt = &at
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Check falla si la base no está exactamente en la versión del binario.
+// El servidor lo llama al arrancar para no operar contra un esquema viejo.
+func Check(db *gorm.DB) error {
+	all, err := All()
+	if err != nil {
+		return err
+	}
+	if !db.Migrator().HasTable(Table) {
+		return &PendingError{Pending: versions(all)}
+	}
+	done, err := applied(db)
+	if err != nil {
+		return err
+	}
+	known := map[int64]bool{}
+	var pending []int64
+	for _, m := range all {
+		known[m.Version] = true
+		if _, ok := done[m.Version]; !ok {
+			pending = append(pending, m.Version)
+		}
+	}
+	if len(pending) > 0 {
+		return &PendingError{Pending: pending}
+	}
+	for v := range done {
+		if !known[v] {
+			return fmt.Errorf("%w (versión %d)", ErrUnknownVersion, v)
+		}
+	}
+	return nil
+}
+
+// Up aplica en orden todas las migraciones pendientes. Cada una corre en su propia
+// transacción junto con su registro en schema_migrations: si falla, no queda a medias.
+func Up(db *gorm.DB) ([]Migration, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+	var ran []Migration
+	for _, m := range all {
+		ok, err := step(db, m, true)
+		if err != nil {
+			return ran, fmt.Errorf("migración %d_%s: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			ran = append(ran, m)
+		}
+	}
+	return ran, nil
+}
+
+// Down revierte las últimas n migraciones aplicadas (la más reciente primero)
+func Down(db *gorm.DB, n int) ([]Migration, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	var ran []Migration
+	for i := len(all) - 1; i >= 0 && len(ran) < n; i-- {
+		m := all[i]
+		if _, ok := done[m.Version]; !ok {
+			continue
+		}
+		ok, err := step(db, m, false)
+		if err != nil {
+			return ran, fmt.Errorf("revirtiendo %d_%s: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			ran = append(ran, m)
+		}
+	}
+	return ran, nil
+}
+
+// step aplica (up) o revierte (down) una migración bajo el advisory lock.
+// Devuelve false si otro proceso ya la había aplicado/revertido.
+func step(db *gorm.DB, m Migration, up bool) (bool, error) {
+	ran := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockID).Error; err != nil {
+			return err
+		}
+		// Un cambio de esquema sobre tablas grandes puede tardar más que el statement_timeout del pool
+		if err := tx.Exec("SET LOCAL statement_timeout = 0").Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Table(Table).Where("version = ?", m.Version).Count(&count).Error; err != nil {
+			return err
+		}
+		if up == (count > 0) {
+			return nil
+		}
+
+		body := m.Up
+		if !up {
+			body = m.Down
+		}
+		if err := tx.Exec(body).Error; err != nil {
+			return err
+		}
+		var record *gorm.DB
+		if up {
+			record = tx.Exec(`INSERT INTO "`+Table+`" (version, name) VALUES (?, ?)`, m.Version, m.Name)
+		} else {
+			record = tx.Exec(`DELETE FROM "`+Table+`" WHERE version = ?`, m.Version)
+		}
+		if record.Error != nil {
+			return record.Error
+		}
+		ran = true
+		return nil
+	})
+	return ran, err
+}
+
+func versions(list []Migration) []int64 {
+	out := make([]int64, len(list))
+	for i, m := range list {
+		out[i] = m.Version
+	}
+	return out
+}
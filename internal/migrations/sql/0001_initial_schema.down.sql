@@ -0,0 +1,33 @@
+-- Borra todo el esquema base (orden inverso por llaves foráneas).
+DROP TABLE IF EXISTS "tenant_jobs";
+DROP TABLE IF EXISTS "audit_logs";
+DROP TABLE IF EXISTS "webhook_events";
+DROP TABLE IF EXISTS "api_keys";
+DROP TABLE IF EXISTS "role_permissions";
+DROP TABLE IF EXISTS "maintenance_logs";
+DROP TABLE IF EXISTS "assets";
+DROP TABLE IF EXISTS "telemetry_data";
+DROP TABLE IF EXISTS "devices";
+DROP TABLE IF EXISTS "purchase_order_items";
+DROP TABLE IF EXISTS "purchase_orders";
+DROP TABLE IF EXISTS "suppliers";
+DROP TABLE IF EXISTS "stock_movements";
+DROP TABLE IF EXISTS "products";
+DROP TABLE IF EXISTS "lease_contracts";
+DROP TABLE IF EXISTS "expenses";
+DROP TABLE IF EXISTS "budgets";
+DROP TABLE IF EXISTS "cost_categories";
+DROP TABLE IF EXISTS "seasons";
+DROP TABLE IF EXISTS "invitations";
+DROP TABLE IF EXISTS "member_farms";
+DROP TABLE IF EXISTS "team_members";
+DROP TABLE IF EXISTS "claims";
+DROP TABLE IF EXISTS "bins";
+DROP TABLE IF EXISTS "shipments";
+DROP TABLE IF EXISTS "harvest_batches";
+DROP TABLE IF EXISTS "crops";
+DROP TABLE IF EXISTS "application_records";
+DROP TABLE IF EXISTS "chemicals";
+DROP TABLE IF EXISTS "users";
+DROP TABLE IF EXISTS "farms";
+DROP TABLE IF EXISTS "tenants";
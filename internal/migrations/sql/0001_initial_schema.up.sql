@@ -0,0 +1,162 @@
+-- Esquema base: el mismo que generaba AutoMigrate hasta ahora.
+-- Todo es IF NOT EXISTS para adoptar bases que ya fueron creadas por AutoMigrate.
+-- CREATE TABLE IF NOT EXISTS no toca tablas existentes: las columnas e índices que se agregaron
+-- a los modelos después del esquema original van aparte con ADD COLUMN / CREATE INDEX IF NOT EXISTS.
+
+CREATE TABLE IF NOT EXISTS "tenants" ("id" uuid,"name" varchar(255) NOT NULL,"rfc" varchar(13),"plan" text DEFAULT 'basic',"active" boolean DEFAULT true,"owner_id" varchar(255),"billing_event_at" bigint,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "uni_tenants_rfc" UNIQUE ("rfc"));
+ALTER TABLE "tenants" ADD COLUMN IF NOT EXISTS "billing_event_at" bigint;
+CREATE INDEX IF NOT EXISTS "idx_tenants_owner_id" ON "tenants" ("owner_id");
+
+CREATE TABLE IF NOT EXISTS "farms" ("id" uuid,"tenant_id" uuid NOT NULL,"name" varchar(255) NOT NULL,"total_area" decimal,"location" text,"ownership_type" text DEFAULT 'own',"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_farms_tenant_id" ON "farms" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "users" ("id" uuid,"clerk_id" text,"email" text,"full_name" text,"image_url" text,"clerk_updated_at" bigint,"deleted_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "uni_users_clerk_id" UNIQUE ("clerk_id"));
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "image_url" text;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "clerk_updated_at" bigint;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_users_clerk_id" ON "users" ("clerk_id");
+
+CREATE TABLE IF NOT EXISTS "chemicals" ("id" uuid,"tenant_id" uuid,"name" varchar(255) NOT NULL,"active_ingredient" varchar(255),"is_banned" boolean DEFAULT false,"banned_markets" text,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_chemicals_tenant_id" ON "chemicals" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "application_records" ("id" uuid,"tenant_id" uuid NOT NULL,"farm_id" uuid NOT NULL,"chemical_id" uuid NOT NULL,"dosage" decimal,"unit" text,"applied_at" timestamptz,"status" text DEFAULT 'pending',"notes" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_application_records_farm_id" ON "application_records" ("farm_id");
+CREATE INDEX IF NOT EXISTS "idx_application_records_tenant_id" ON "application_records" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "crops" ("id" uuid,"tenant_id" uuid,"farm_id" uuid,"name" text,"variety" text,"planting_date" timestamptz,"status" text,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_crops_farm_id" ON "crops" ("farm_id");
+CREATE INDEX IF NOT EXISTS "idx_crops_tenant_id" ON "crops" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "harvest_batches" ("id" uuid,"tenant_id" uuid,"farm_id" uuid,"crop_id" uuid,"batch_code" text,"harvest_date" timestamptz,"total_bins" bigint,PRIMARY KEY ("id"),CONSTRAINT "fk_harvest_batches_crop" FOREIGN KEY ("crop_id") REFERENCES "crops"("id"),CONSTRAINT "uni_harvest_batches_batch_code" UNIQUE ("batch_code"));
+CREATE INDEX IF NOT EXISTS "idx_harvest_batches_crop_id" ON "harvest_batches" ("crop_id");
+CREATE INDEX IF NOT EXISTS "idx_harvest_batches_farm_id" ON "harvest_batches" ("farm_id");
+CREATE INDEX IF NOT EXISTS "idx_harvest_batches_tenant_id" ON "harvest_batches" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "shipments" ("id" uuid,"tenant_id" uuid,"customer_name" text,"destination" text,"departure_time" timestamptz,"truck_plate" text,"status" text,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_shipments_tenant_id" ON "shipments" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "bins" ("id" uuid,"tenant_id" uuid,"qr_code" text,"harvest_batch_id" uuid,"weight_kg" decimal,"status" text,"updated_at" timestamptz,"shipment_id" uuid,PRIMARY KEY ("id"),CONSTRAINT "fk_shipments_bins" FOREIGN KEY ("shipment_id") REFERENCES "shipments"("id"),CONSTRAINT "uni_bins_qr_code" UNIQUE ("qr_code"));
+CREATE INDEX IF NOT EXISTS "idx_bins_shipment_id" ON "bins" ("shipment_id");
+CREATE INDEX IF NOT EXISTS "idx_bins_harvest_batch_id" ON "bins" ("harvest_batch_id");
+CREATE INDEX IF NOT EXISTS "idx_bins_qr_code" ON "bins" ("qr_code");
+CREATE INDEX IF NOT EXISTS "idx_bins_tenant_id" ON "bins" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "claims" ("id" uuid,"tenant_id" uuid,"shipment_id" uuid,"claim_date" timestamptz,"reason" text,"amount_usd" decimal,"evidence_url" text,"internal_notes" text,"status" text,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_claims_shipment_id" ON "claims" ("shipment_id");
+CREATE INDEX IF NOT EXISTS "idx_claims_tenant_id" ON "claims" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "team_members" ("id" uuid,"tenant_id" uuid,"user_id" text,"role" text,"joined_at" timestamptz,"deactivated_at" timestamptz,PRIMARY KEY ("id"));
+ALTER TABLE "team_members" ADD COLUMN IF NOT EXISTS "deactivated_at" timestamptz;
+-- AutoMigrate no impedía membresías duplicadas: se conserva la más antigua antes del índice único
+DELETE FROM "team_members" t USING "team_members" d
+WHERE t."tenant_id" = d."tenant_id" AND t."user_id" = d."user_id"
+  AND (t."joined_at" > d."joined_at" OR (t."joined_at" = d."joined_at" AND t."id" > d."id"));
+CREATE INDEX IF NOT EXISTS "idx_team_members_user_id" ON "team_members" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_team_member" ON "team_members" ("tenant_id","user_id");
+CREATE INDEX IF NOT EXISTS "idx_team_members_tenant_id" ON "team_members" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "member_farms" ("id" uuid,"tenant_id" uuid NOT NULL,"team_member_id" uuid NOT NULL,"farm_id" uuid NOT NULL,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_member_farm" ON "member_farms" ("team_member_id","farm_id");
+CREATE INDEX IF NOT EXISTS "idx_member_farms_tenant_id" ON "member_farms" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "invitations" ("id" uuid,"tenant_id" uuid,"email" text,"role" text,"token_hash" varchar(64),"status" text,"invited_by" text,"expires_at" timestamptz,"sent_at" timestamptz,"accepted_by" text,"accepted_at" timestamptz,"revoked_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+ALTER TABLE "invitations" ADD COLUMN IF NOT EXISTS "token_hash" varchar(64);
+ALTER TABLE "invitations" ADD COLUMN IF NOT EXISTS "invited_by" text;
+ALTER TABLE "invitations" ADD COLUMN IF NOT EXISTS "expires_at" timestamptz;
+ALTER TABLE "invitations" ADD COLUMN IF NOT EXISTS "sent_at" timestamptz;
+ALTER TABLE "invitations" ADD COLUMN IF NOT EXISTS "accepted_by" text;
+ALTER TABLE "invitations" ADD COLUMN IF NOT EXISTS "accepted_at" timestamptz;
+ALTER TABLE "invitations" ADD COLUMN IF NOT EXISTS "revoked_at" timestamptz;
+-- El token viejo se guardaba en claro: se reemplaza por su SHA-256 (los links ya enviados siguen sirviendo)
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'invitations' AND column_name = 'token') THEN
+    UPDATE "invitations" SET "token_hash" = encode(sha256(convert_to("token", 'UTF8')), 'hex')
+    WHERE "token_hash" IS NULL AND COALESCE("token", '') <> '';
+    ALTER TABLE "invitations" DROP COLUMN "token";
+  END IF;
+END $$;
+-- Invitaciones anteriores a la vigencia: 7 días desde que se crearon
+UPDATE "invitations" SET "sent_at" = "created_at" WHERE "sent_at" IS NULL;
+UPDATE "invitations" SET "expires_at" = "created_at" + INTERVAL '7 days' WHERE "expires_at" IS NULL;
+CREATE INDEX IF NOT EXISTS "idx_invitations_token_hash" ON "invitations" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_invitations_email" ON "invitations" ("email");
+CREATE INDEX IF NOT EXISTS "idx_invitations_tenant_id" ON "invitations" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "seasons" ("id" uuid,"tenant_id" uuid NOT NULL,"name" varchar(100) NOT NULL,"start_date" timestamptz NOT NULL,"end_date" timestamptz NOT NULL,"active" boolean DEFAULT true,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_seasons_tenant_id" ON "seasons" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "cost_categories" ("id" uuid,"tenant_id" uuid NOT NULL,"name" varchar(100) NOT NULL,"code" varchar(20),"color" varchar(7) DEFAULT '#10b981',"parent_id" uuid,PRIMARY KEY ("id"),CONSTRAINT "fk_cost_categories_children" FOREIGN KEY ("parent_id") REFERENCES "cost_categories"("id"));
+CREATE INDEX IF NOT EXISTS "idx_cost_categories_parent_id" ON "cost_categories" ("parent_id");
+CREATE INDEX IF NOT EXISTS "idx_cost_categories_tenant_id" ON "cost_categories" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "budgets" ("id" uuid,"tenant_id" uuid NOT NULL,"season_id" uuid NOT NULL,"farm_id" uuid NOT NULL,"cost_category_id" uuid NOT NULL,"month" bigint NOT NULL,"year" bigint NOT NULL,"amount" decimal(15,2) NOT NULL,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_budgets_cost_category_id" ON "budgets" ("cost_category_id");
+CREATE INDEX IF NOT EXISTS "idx_budgets_farm_id" ON "budgets" ("farm_id");
+CREATE INDEX IF NOT EXISTS "idx_budgets_season_id" ON "budgets" ("season_id");
+CREATE INDEX IF NOT EXISTS "idx_budgets_tenant_id" ON "budgets" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "expenses" ("id" uuid,"tenant_id" uuid NOT NULL,"season_id" uuid NOT NULL,"farm_id" uuid NOT NULL,"cost_category_id" uuid NOT NULL,"description" text,"expense_date" timestamptz,"amount" decimal(15,2) NOT NULL,"receipt_url" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_expenses_cost_category_id" ON "expenses" ("cost_category_id");
+CREATE INDEX IF NOT EXISTS "idx_expenses_farm_id" ON "expenses" ("farm_id");
+CREATE INDEX IF NOT EXISTS "idx_expenses_season_id" ON "expenses" ("season_id");
+CREATE INDEX IF NOT EXISTS "idx_expenses_tenant_id" ON "expenses" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "lease_contracts" ("id" uuid,"tenant_id" uuid NOT NULL,"farm_id" uuid NOT NULL,"landowner_name" text,"start_date" timestamptz,"end_date" timestamptz,"payment_amount" decimal(15,2),"payment_freq" text,"contract_doc_url" text,"status" text,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_lease_contracts_farm" FOREIGN KEY ("farm_id") REFERENCES "farms"("id"));
+CREATE INDEX IF NOT EXISTS "idx_lease_contracts_farm_id" ON "lease_contracts" ("farm_id");
+CREATE INDEX IF NOT EXISTS "idx_lease_contracts_tenant_id" ON "lease_contracts" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "products" ("id" uuid,"tenant_id" uuid NOT NULL,"name" varchar(255) NOT NULL,"sku" varchar(50),"category" text,"unit" text,"current_stock" decimal,"min_stock_level" decimal,"avg_cost" decimal(15,2),"chemical_id" text,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_products_sku" ON "products" ("sku");
+CREATE INDEX IF NOT EXISTS "idx_products_tenant_id" ON "products" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "stock_movements" ("id" uuid,"tenant_id" uuid NOT NULL,"product_id" uuid NOT NULL,"type" text,"quantity" decimal,"cost_per_unit" decimal,"reference_id" text,"reason" text,"created_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_stock_movements_product" FOREIGN KEY ("product_id") REFERENCES "products"("id"));
+CREATE INDEX IF NOT EXISTS "idx_stock_movements_product_id" ON "stock_movements" ("product_id");
+CREATE INDEX IF NOT EXISTS "idx_stock_movements_tenant_id" ON "stock_movements" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "suppliers" ("id" uuid,"tenant_id" uuid NOT NULL,"name" varchar(255) NOT NULL,"tax_id" text,"contact_name" text,"email" text,"phone" text,"credit_days" bigint,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_suppliers_tenant_id" ON "suppliers" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "purchase_orders" ("id" uuid,"tenant_id" uuid NOT NULL,"order_number" text,"supplier_id" uuid NOT NULL,"status" text,"total_amount" decimal(15,2),"notes" text,"order_date" timestamptz,"expected_date" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"),CONSTRAINT "fk_purchase_orders_supplier" FOREIGN KEY ("supplier_id") REFERENCES "suppliers"("id"));
+CREATE INDEX IF NOT EXISTS "idx_purchase_orders_supplier_id" ON "purchase_orders" ("supplier_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_purchase_orders_order_number" ON "purchase_orders" ("order_number");
+CREATE INDEX IF NOT EXISTS "idx_purchase_orders_tenant_id" ON "purchase_orders" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "purchase_order_items" ("id" uuid,"purchase_order_id" uuid NOT NULL,"product_id" uuid NOT NULL,"quantity" decimal,"unit_cost" decimal,"subtotal" decimal,PRIMARY KEY ("id"),CONSTRAINT "fk_purchase_order_items_product" FOREIGN KEY ("product_id") REFERENCES "products"("id"),CONSTRAINT "fk_purchase_orders_items" FOREIGN KEY ("purchase_order_id") REFERENCES "purchase_orders"("id"));
+CREATE INDEX IF NOT EXISTS "idx_purchase_order_items_product_id" ON "purchase_order_items" ("product_id");
+CREATE INDEX IF NOT EXISTS "idx_purchase_order_items_purchase_order_id" ON "purchase_order_items" ("purchase_order_id");
+
+CREATE TABLE IF NOT EXISTS "devices" ("id" uuid,"tenant_id" uuid NOT NULL,"farm_id" uuid NOT NULL,"name" varchar(100),"type" text,"status" text,"min_threshold" decimal,"max_threshold" decimal,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_devices_farm_id" ON "devices" ("farm_id");
+CREATE INDEX IF NOT EXISTS "idx_devices_tenant_id" ON "devices" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "telemetry_data" ("id" uuid,"device_id" uuid NOT NULL,"value" decimal,"timestamp" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_telemetry_data_timestamp" ON "telemetry_data" ("timestamp");
+CREATE INDEX IF NOT EXISTS "idx_telemetry_data_device_id" ON "telemetry_data" ("device_id");
+
+CREATE TABLE IF NOT EXISTS "assets" ("id" uuid,"tenant_id" uuid NOT NULL,"name" varchar(255) NOT NULL,"type" text,"brand" text,"model" text,"serial_number" text,"status" text,"usage_unit" text,"current_usage" decimal,"service_interval" decimal,"next_service_at" decimal,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_assets_tenant_id" ON "assets" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "maintenance_logs" ("id" uuid,"tenant_id" uuid NOT NULL,"asset_id" uuid NOT NULL,"service_date" timestamptz,"type" text,"description" text,"cost" decimal,"usage_at_service" decimal,"mechanic_name" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_maintenance_logs_asset_id" ON "maintenance_logs" ("asset_id");
+CREATE INDEX IF NOT EXISTS "idx_maintenance_logs_tenant_id" ON "maintenance_logs" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "role_permissions" ("id" uuid,"tenant_id" uuid NOT NULL,"role" varchar(50) NOT NULL,"permission" varchar(100) NOT NULL,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_role_permission" ON "role_permissions" ("tenant_id","role","permission");
+
+CREATE TABLE IF NOT EXISTS "api_keys" ("id" uuid,"tenant_id" uuid NOT NULL,"service_account" varchar(100) NOT NULL,"prefix" varchar(16),"key_hash" varchar(64),"scopes" text,"expires_at" timestamptz,"revoked_at" timestamptz,"last_used_at" timestamptz,"created_by" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_key_hash" ON "api_keys" ("key_hash");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_prefix" ON "api_keys" ("prefix");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_tenant_id" ON "api_keys" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "webhook_events" ("id" varchar(100),"source" varchar(50),"type" varchar(100),"processed_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_webhook_events_source" ON "webhook_events" ("source");
+
+CREATE TABLE IF NOT EXISTS "audit_logs" ("id" uuid,"tenant_id" uuid,"actor" varchar(150),"entity_type" varchar(100),"entity_id" varchar(100),"action" varchar(20),"before" jsonb,"after" jsonb,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_actor" ON "audit_logs" ("tenant_id","actor");
+CREATE INDEX IF NOT EXISTS "idx_audit_entity" ON "audit_logs" ("tenant_id","entity_type","entity_id");
+
+CREATE TABLE IF NOT EXISTS "tenant_jobs" ("id" uuid,"tenant_id" uuid NOT NULL,"kind" varchar(20) NOT NULL,"status" varchar(20) NOT NULL,"requested_by" varchar(255),"error" text,"archive" bytea,"archive_size" bigint,"expires_at" timestamptz,"started_at" timestamptz,"finished_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_tenant_jobs_requested_by" ON "tenant_jobs" ("requested_by");
+CREATE INDEX IF NOT EXISTS "idx_tenant_jobs_tenant_id" ON "tenant_jobs" ("tenant_id");
@@ -0,0 +1,3 @@
+-- Falla si ya hay folios repetidos entre empresas (hay que renombrarlos antes de bajar).
+DROP INDEX IF EXISTS "idx_purchase_orders_tenant_order_number";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_purchase_orders_order_number" ON "purchase_orders" ("order_number");
@@ -0,0 +1,4 @@
+-- El folio de una orden de compra es único dentro de cada empresa, no en todo el sistema:
+-- con el índice global, dos empresas no podían usar el mismo folio (ej: PO-2025-001).
+DROP INDEX IF EXISTS "idx_purchase_orders_order_number";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_purchase_orders_tenant_order_number" ON "purchase_orders" ("tenant_id","order_number");
@@ -0,0 +1,3 @@
+-- Destinatarios de alertas por empresa (antes era un correo fijo en el código).
+CREATE TABLE IF NOT EXISTS "alert_recipients" ("id" uuid,"tenant_id" uuid NOT NULL,"topic" varchar(50) NOT NULL,"email" varchar(255) NOT NULL,"created_by" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_alert_recipient" ON "alert_recipients" ("tenant_id","topic","email");
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "idx_claims_tenant_claim_date";
+DROP INDEX IF EXISTS "idx_purchase_orders_tenant_created_at";
+DROP INDEX IF EXISTS "idx_telemetry_data_device_timestamp";
+DROP INDEX IF EXISTS "idx_bins_tenant_updated_at";
@@ -0,0 +1,6 @@
+-- Índices para la paginación por cursor de los listados más grandes:
+-- el orden es (columna, id) dentro de cada empresa (o dispositivo, en telemetría).
+CREATE INDEX IF NOT EXISTS "idx_bins_tenant_updated_at" ON "bins" ("tenant_id","updated_at","id");
+CREATE INDEX IF NOT EXISTS "idx_telemetry_data_device_timestamp" ON "telemetry_data" ("device_id","timestamp","id");
+CREATE INDEX IF NOT EXISTS "idx_purchase_orders_tenant_created_at" ON "purchase_orders" ("tenant_id","created_at","id");
+CREATE INDEX IF NOT EXISTS "idx_claims_tenant_claim_date" ON "claims" ("tenant_id","claim_date","id");
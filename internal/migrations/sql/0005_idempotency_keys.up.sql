@@ -0,0 +1,4 @@
+-- Respuestas guardadas de POST con Idempotency-Key (reintentos de la app de campo sin duplicar registros).
+CREATE TABLE IF NOT EXISTS "idempotency_keys" ("id" uuid,"tenant_id" uuid NOT NULL,"user_id" varchar(100) NOT NULL DEFAULT '',"key" varchar(255) NOT NULL,"method" varchar(10) NOT NULL,"path" varchar(255) NOT NULL,"request_hash" varchar(64) NOT NULL,"status" bigint,"content_type" varchar(100),"response_body" bytea,"created_at" timestamptz,"expires_at" timestamptz NOT NULL,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_idempotency_key" ON "idempotency_keys" ("tenant_id","user_id","key");
+CREATE INDEX IF NOT EXISTS "idx_idempotency_keys_expires_at" ON "idempotency_keys" ("expires_at");
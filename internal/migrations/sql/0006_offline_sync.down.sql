@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "sync_operations";
+ALTER TABLE "bins" DROP COLUMN IF EXISTS "scanned_by";
+ALTER TABLE "bins" DROP COLUMN IF EXISTS "scanned_at";
+ALTER TABLE "harvest_batches" DROP COLUMN IF EXISTS "updated_at";
+ALTER TABLE "crops" DROP COLUMN IF EXISTS "updated_at";
@@ -0,0 +1,13 @@
+-- Sincronización offline de la app móvil (/sync/pull y /sync/push).
+-- Cultivos y lotes necesitan updated_at para bajar solo lo que cambió.
+ALTER TABLE "crops" ADD COLUMN IF NOT EXISTS "updated_at" timestamptz;
+UPDATE "crops" SET "updated_at" = now() WHERE "updated_at" IS NULL;
+ALTER TABLE "harvest_batches" ADD COLUMN IF NOT EXISTS "updated_at" timestamptz;
+UPDATE "harvest_batches" SET "updated_at" = now() WHERE "updated_at" IS NULL;
+-- Último escaneo en campo (hora del dispositivo): resuelve la misma caja escaneada en dos dispositivos.
+ALTER TABLE "bins" ADD COLUMN IF NOT EXISTS "scanned_at" timestamptz;
+ALTER TABLE "bins" ADD COLUMN IF NOT EXISTS "scanned_by" varchar(100);
+-- Operaciones offline ya procesadas (un reenvío del lote no las aplica dos veces).
+CREATE TABLE IF NOT EXISTS "sync_operations" ("id" uuid,"tenant_id" uuid NOT NULL,"op_id" varchar(100) NOT NULL,"device_id" varchar(100),"type" varchar(30) NOT NULL,"status" varchar(20) NOT NULL,"actor" varchar(150),"client_time" timestamptz,"result" jsonb,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_sync_operation" ON "sync_operations" ("tenant_id","op_id");
+CREATE INDEX IF NOT EXISTS "idx_sync_operations_device_id" ON "sync_operations" ("device_id");
@@ -0,0 +1,30 @@
+DROP INDEX IF EXISTS "idx_cost_categories_deleted_at";
+ALTER TABLE "cost_categories" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "cost_categories" DROP COLUMN IF EXISTS "version";
+DROP INDEX IF EXISTS "idx_seasons_deleted_at";
+ALTER TABLE "seasons" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "seasons" DROP COLUMN IF EXISTS "version";
+DROP INDEX IF EXISTS "idx_lease_contracts_deleted_at";
+ALTER TABLE "lease_contracts" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "lease_contracts" DROP COLUMN IF EXISTS "version";
+DROP INDEX IF EXISTS "idx_assets_deleted_at";
+ALTER TABLE "assets" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "assets" DROP COLUMN IF EXISTS "version";
+DROP INDEX IF EXISTS "idx_devices_deleted_at";
+ALTER TABLE "devices" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "devices" DROP COLUMN IF EXISTS "version";
+DROP INDEX IF EXISTS "idx_products_deleted_at";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "products" DROP COLUMN IF EXISTS "version";
+DROP INDEX IF EXISTS "idx_suppliers_deleted_at";
+ALTER TABLE "suppliers" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "suppliers" DROP COLUMN IF EXISTS "version";
+DROP INDEX IF EXISTS "idx_crops_deleted_at";
+ALTER TABLE "crops" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "crops" DROP COLUMN IF EXISTS "version";
+DROP INDEX IF EXISTS "idx_chemicals_deleted_at";
+ALTER TABLE "chemicals" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "chemicals" DROP COLUMN IF EXISTS "version";
+DROP INDEX IF EXISTS "idx_farms_deleted_at";
+ALTER TABLE "farms" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "farms" DROP COLUMN IF EXISTS "version";
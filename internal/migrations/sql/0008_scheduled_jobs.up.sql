@@ -0,0 +1,6 @@
+-- Tareas programadas (purgas y resúmenes por empresa): una fila por tarea con su candado entre réplicas.
+CREATE TABLE IF NOT EXISTS "scheduled_jobs" ("name" varchar(100),"schedule" varchar(100) NOT NULL,"due_at" timestamptz NOT NULL,"next_run_at" timestamptz NOT NULL,"attempt" bigint NOT NULL DEFAULT 0,"locked_by" varchar(150) NOT NULL DEFAULT '',"locked_until" timestamptz,"checkpoint" jsonb,"last_status" varchar(20) NOT NULL DEFAULT '',"last_error" text,"last_started_at" timestamptz,"last_finished_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("name"));
+CREATE INDEX IF NOT EXISTS "idx_scheduled_jobs_next_run_at" ON "scheduled_jobs" ("next_run_at");
+-- Historial: un registro por intento.
+CREATE TABLE IF NOT EXISTS "job_runs" ("id" uuid,"job_name" varchar(100) NOT NULL,"due_at" timestamptz NOT NULL,"attempt" bigint NOT NULL,"worker" varchar(150) NOT NULL,"status" varchar(20) NOT NULL,"summary" text,"error" text,"retry_at" timestamptz,"started_at" timestamptz NOT NULL,"finished_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_job_run" ON "job_runs" ("job_name","started_at");
@@ -0,0 +1,4 @@
+-- Outbox transaccional: correos guardados junto con el cambio que los origina; el despachador los entrega con reintentos.
+CREATE TABLE IF NOT EXISTS "outbox_messages" ("id" uuid,"tenant_id" uuid NOT NULL,"kind" varchar(20) NOT NULL,"topic" varchar(50) NOT NULL,"target" text,"subject" text,"payload" jsonb,"status" varchar(20) NOT NULL,"attempts" bigint NOT NULL DEFAULT 0,"next_attempt_at" timestamptz NOT NULL,"last_error" text,"locked_by" varchar(150) NOT NULL DEFAULT '',"locked_until" timestamptz,"sent_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_outbox_due" ON "outbox_messages" ("status","next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_outbox_tenant" ON "outbox_messages" ("tenant_id","created_at");
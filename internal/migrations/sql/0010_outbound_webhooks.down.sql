@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "webhook_attempts";
+DROP TABLE IF EXISTS "webhook_endpoints";
+DROP TABLE IF EXISTS "events";
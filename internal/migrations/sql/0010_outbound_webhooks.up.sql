@@ -0,0 +1,9 @@
+-- Webhooks salientes: endpoints de cada empresa, eventos que reciben y bitácora de entregas (un registro por intento).
+CREATE TABLE IF NOT EXISTS "events" ("id" uuid,"tenant_id" uuid NOT NULL,"type" varchar(100) NOT NULL,"data" jsonb,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_events_created_at" ON "events" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_events_tenant_id" ON "events" ("tenant_id");
+CREATE TABLE IF NOT EXISTS "webhook_endpoints" ("id" uuid,"tenant_id" uuid NOT NULL,"url" varchar(2048) NOT NULL,"description" varchar(255),"event_types" text NOT NULL,"secret" varchar(100) NOT NULL,"active" boolean NOT NULL,"created_by" text,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_webhook_endpoints_tenant_id" ON "webhook_endpoints" ("tenant_id");
+CREATE TABLE IF NOT EXISTS "webhook_attempts" ("id" uuid,"tenant_id" uuid NOT NULL,"endpoint_id" uuid NOT NULL,"event_id" uuid NOT NULL,"event_type" varchar(100) NOT NULL,"message_id" uuid NOT NULL,"attempt" bigint NOT NULL,"success" boolean NOT NULL,"status_code" bigint,"error" text,"response_body" text,"duration_ms" bigint,"created_at" timestamptz NOT NULL,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_webhook_attempt" ON "webhook_attempts" ("endpoint_id","created_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_attempts_tenant_id" ON "webhook_attempts" ("tenant_id");
@@ -0,0 +1,3 @@
+-- Rate limiting compartido entre réplicas (RATE_LIMIT_STORE=postgres): un contador por llave y ventana.
+CREATE TABLE IF NOT EXISTS "rate_limit_buckets" ("key" varchar(255),"window_start" timestamptz NOT NULL,"count" bigint NOT NULL,"expires_at" timestamptz NOT NULL,PRIMARY KEY ("key"));
+CREATE INDEX IF NOT EXISTS "idx_rate_limit_buckets_expires_at" ON "rate_limit_buckets" ("expires_at");
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "idx_bins_farm_id";
+ALTER TABLE "bins" DROP COLUMN IF EXISTS "farm_id";
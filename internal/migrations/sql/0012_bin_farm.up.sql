@@ -0,0 +1,4 @@
+-- Restricción por rancho en cajas: el rancho se copia del lote al escanear.
+ALTER TABLE "bins" ADD COLUMN IF NOT EXISTS "farm_id" uuid;
+CREATE INDEX IF NOT EXISTS "idx_bins_farm_id" ON "bins" ("farm_id");
+UPDATE "bins" SET "farm_id" = "harvest_batches"."farm_id" FROM "harvest_batches" WHERE "bins"."harvest_batch_id" = "harvest_batches"."id" AND "bins"."farm_id" IS NULL;
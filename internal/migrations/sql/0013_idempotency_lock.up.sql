@@ -0,0 +1,2 @@
+-- Candado renovable de las Idempotency-Key en proceso (antes se daba por abandonado al minuto de created_at).
+ALTER TABLE "idempotency_keys" ADD COLUMN IF NOT EXISTS "locked_until" timestamptz;
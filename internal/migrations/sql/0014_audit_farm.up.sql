@@ -0,0 +1,8 @@
+-- Rancho de cada registro de auditoría: los miembros restringidos a ciertos ranchos solo ven esos.
+-- Las filas viejas toman el rancho de su snapshot; las que no lo traen (ej: un update que no tocó farm_id)
+-- quedan en NULL y solo las ven los miembros sin restricción.
+ALTER TABLE "audit_logs" ADD COLUMN IF NOT EXISTS "farm_id" uuid;
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_farm_id" ON "audit_logs" ("farm_id");
+UPDATE "audit_logs" SET "farm_id" = "entity_id"::uuid WHERE "entity_type" = 'farms' AND "farm_id" IS NULL;
+UPDATE "audit_logs" SET "farm_id" = COALESCE("after"->>'farm_id', "before"->>'farm_id')::uuid
+WHERE "farm_id" IS NULL AND COALESCE("after"->>'farm_id', "before"->>'farm_id') ~* '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$';
@@ -0,0 +1,5 @@
+-- Falla si ya hay QR o códigos de lote repetidos entre empresas (hay que renombrarlos antes de bajar).
+DROP INDEX IF EXISTS "idx_harvest_batches_tenant_batch_code";
+ALTER TABLE "harvest_batches" ADD CONSTRAINT "uni_harvest_batches_batch_code" UNIQUE ("batch_code");
+DROP INDEX IF EXISTS "idx_bins_tenant_qr_code";
+ALTER TABLE "bins" ADD CONSTRAINT "uni_bins_qr_code" UNIQUE ("qr_code");
@@ -0,0 +1,7 @@
+-- El QR de una caja y el código de un lote son únicos dentro de cada empresa, no en todo el sistema:
+-- con la restricción global, una empresa no podía imprimir un código que otra ya usaba (ej: LOT-20251025-A).
+-- idx_bins_qr_code se queda (no único): el pasaporte busca la caja solo por su QR.
+ALTER TABLE "bins" DROP CONSTRAINT IF EXISTS "uni_bins_qr_code";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_bins_tenant_qr_code" ON "bins" ("tenant_id","qr_code");
+ALTER TABLE "harvest_batches" DROP CONSTRAINT IF EXISTS "uni_harvest_batches_batch_code";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_harvest_batches_tenant_batch_code" ON "harvest_batches" ("tenant_id","batch_code");
@@ -0,0 +1,388 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------
+// 📘 OPENAPI: Especificación generada desde el código
+// ---------------------------------------------------------
+// Cada ruta de la API se declara una vez en una tabla de Route (ver cmd/api/openapi.go) con
+// los mismos structs que usan los handlers para bindear el body y el query string.
+// Build arma el documento OpenAPI 3.0; Missing compara la tabla contra las rutas registradas
+// en gin para que ningún endpoint quede sin documentar.
+
+// Auth: Cómo se autentica una ruta
+type Auth int
+
+const (
+	AuthPublic  Auth = iota // Sin credenciales
+	AuthUser                // JWT de Clerk de una persona (no API keys), sin empresa activa
+	AuthTenant              // JWT de Clerk o API key, con empresa activa (X-Tenant-ID opcional)
+	AuthPerson              // JWT de Clerk de una persona (no API keys), con empresa activa
+	AuthMetrics             // Bearer METRICS_TOKEN
+	AuthWebhook             // Firma del proveedor (Svix / Stripe) en headers
+)
+
+// Nombres de los esquemas de seguridad en components/securitySchemes
+const (
+	SecurityBearer  = "clerkJWT"
+	SecurityAPIKey  = "apiKey"
+	SecurityMetrics = "metricsToken"
+)
+
+// Route: Documentación de un endpoint
+type Route struct {
+	Method      string // GET, POST...
+	Path        string // Como en gin: /fleet/assets/:id/usage
+	Tag         string // Agrupa en la UI (ej: "Flota")
+	Summary     string
+	Description string
+	Auth        Auth
+	Permission  string // Permiso de authz que exige (x-permission)
+	Module      string // Módulo del plan que exige (x-module)
+
+	Query     interface{} // Struct con tags form (se documenta cada campo como parámetro)
+	Params    []Param     // Parámetros extra del query string (ej: filtros de listados)
+	Body      interface{} // Ejemplo del body: ScanRequest{}
+	Status    int         // Respuesta exitosa (default 200)
+	Response  interface{} // Ejemplo de la respuesta: domain.Bin{}, []domain.Bin{}, Fields{...}
+	Paged     bool        // Listado por cursor: documenta X-Next-Cursor y Link
+	Versioned bool        // Registro con versión: ETag en la respuesta e If-Match opcional en PATCH/DELETE
+	Produces  string      // Content-Type de la respuesta si no es JSON (ej: application/zip)
+	Limited   bool        // Ruta pública con rate limit por IP (las autenticadas siempre lo tienen)
+}
+
+// Param: Parámetro del query string
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+	Schema      *Schema
+}
+
+// Info: Encabezado del documento
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document: Especificación OpenAPI 3.0
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Tags       []Tag                           `json:"tags,omitempty"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// Tag: Grupo de operaciones en la UI
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// Components: Esquemas y mecanismos de seguridad compartidos
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme: Mecanismo de autenticación
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Operation: Un método de un path
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security"` // Vacío = pública
+	Permission  string                `json:"x-permission,omitempty"`
+	Module      string                `json:"x-module,omitempty"`
+}
+
+// Parameter: Parámetro de path, query o header
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody: Body JSON de la petición
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response: Respuesta de una operación
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header: Header de respuesta
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType: Esquema de un Content-Type
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Options: Datos del documento que no salen de las rutas
+type Options struct {
+	Info Info
+	// ErrorBody: Sobre de error común (apierr); se documenta como respuesta "default"
+	ErrorBody interface{}
+	// TenantHeader: Header con la empresa activa en rutas AuthTenant/AuthPerson
+	TenantHeader string
+	// APIKeyHeader: Header alternativo para API keys
+	APIKeyHeader string
+	// Headers de paginación por cursor
+	NextCursorHeader string
+	// IdempotencyHeader: Llave opcional para reintentar un POST autenticado sin duplicarlo
+	IdempotencyHeader string
+}
+
+var pathParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// Path convierte la ruta de gin al formato OpenAPI: /assets/:id -> /assets/{id}
+func Path(ginPath string) string {
+	return pathParam.ReplaceAllString(ginPath, "{$1}")
+}
+
+// Build arma el documento a partir de la tabla de rutas
+func Build(opts Options, routes []Route) *Document {
+	reg := &registry{schemas: map[string]*Schema{}, owners: map[string]reflect.Type{}}
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    opts.Info,
+		Paths:   map[string]map[string]Operation{},
+		Components: Components{
+			Schemas: reg.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				SecurityBearer: {Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+					Description: "Sesión de Clerk (web admin y app móvil)"},
+				SecurityAPIKey: {Type: "apiKey", In: "header", Name: opts.APIKeyHeader,
+					Description: "API key de service account (también se acepta como Authorization: Bearer agt_...)"},
+				SecurityMetrics: {Type: "http", Scheme: "bearer",
+					Description: "METRICS_TOKEN del scraper de Prometheus"},
+			},
+		},
+	}
+
+	var errorSchema *Schema
+	if opts.ErrorBody != nil {
+		errorSchema = reg.schemaOf(opts.ErrorBody)
+	}
+
+	tags := map[string]bool{}
+	for _, r := range routes {
+		path := Path(r.Path)
+		op := Operation{
+			Summary:     r.Summary,
+			Description: r.Description,
+			OperationID: operationID(r.Method, r.Path),
+			Responses:   map[string]Response{},
+			Security:    security(r.Auth),
+			Permission:  r.Permission,
+			Module:      r.Module,
+		}
+		if r.Tag != "" {
+			op.Tags = []string{r.Tag}
+			if !tags[r.Tag] {
+				tags[r.Tag] = true
+				doc.Tags = append(doc.Tags, Tag{Name: r.Tag})
+			}
+		}
+
+		// Parámetros: path, query (struct + extras) y empresa activa
+		for _, m := range pathParam.FindAllStringSubmatch(r.Path, -1) {
+			op.Parameters = append(op.Parameters, Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+		if r.Query != nil {
+			op.Parameters = append(op.Parameters, reg.queryParams(r.Query)...)
+		}
+		for _, p := range r.Params {
+			op.Parameters = append(op.Parameters, Parameter{Name: p.Name, In: "query", Description: p.Description, Required: p.Required, Schema: p.Schema})
+		}
+		if (r.Auth == AuthTenant || r.Auth == AuthPerson) && opts.TenantHeader != "" {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name: opts.TenantHeader, In: "header", Schema: &Schema{Type: "string", Format: "uuid"},
+				Description: "Empresa activa. Si se omite se usa la empresa propia del usuario; las API keys ya traen la suya.",
+			})
+		}
+		if r.Method == http.MethodPost && r.Auth != AuthPublic && r.Auth != AuthWebhook && r.Auth != AuthMetrics && opts.IdempotencyHeader != "" {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name: opts.IdempotencyHeader, In: "header", Schema: &Schema{Type: "string"},
+				Description: "Opcional (hasta 255 caracteres, ej: un UUID por operación). Los reintentos con la misma llave reciben la primera respuesta (header Idempotent-Replayed); con otro cuerpo responden 409.",
+			})
+		}
+
+		if r.Versioned && (r.Method == http.MethodPatch || r.Method == http.MethodDelete) {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name: "If-Match", In: "header", Schema: &Schema{Type: "string"},
+				Description: `Opcional: ETag que se leyó antes (ej: "3"). Si el registro cambió desde entonces responde 412 precondition_failed.`,
+			})
+		}
+
+		if r.Body != nil {
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+				"application/json": {Schema: reg.schemaOf(r.Body)},
+			}}
+		}
+
+		status := r.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		resp := Response{Description: http.StatusText(status)}
+		if r.Response != nil {
+			mediaType := r.Produces
+			if mediaType == "" {
+				mediaType = "application/json"
+			}
+			resp.Content = map[string]MediaType{mediaType: {Schema: reg.schemaOf(r.Response)}}
+		}
+		if r.Paged {
+			resp.Headers = map[string]Header{
+				opts.NextCursorHeader: {Description: "Cursor de la siguiente página (ausente en la última)", Schema: &Schema{Type: "string"}},
+				"Link":                {Description: `URL de la siguiente página (rel="next")`, Schema: &Schema{Type: "string"}},
+			}
+		}
+		if r.Versioned && r.Method != http.MethodDelete {
+			resp.Headers = map[string]Header{
+				"ETag": {Description: "Versión del registro (se manda en If-Match al editar o borrar)", Schema: &Schema{Type: "string"}},
+			}
+		}
+		op.Responses[strconv.Itoa(status)] = resp
+		if r.Limited || r.Auth == AuthUser || r.Auth == AuthTenant || r.Auth == AuthPerson {
+			op.Responses[strconv.Itoa(http.StatusTooManyRequests)] = Response{
+				Description: "Se agotó el presupuesto de peticiones (rate_limited). Los headers RateLimit-* vienen en todas las respuestas.",
+				Headers: map[string]Header{
+					"Retry-After": {Description: "Segundos para volver a intentar", Schema: &Schema{Type: "integer"}},
+				},
+			}
+		}
+		if errorSchema != nil {
+			op.Responses["default"] = Response{Description: "Error", Content: map[string]MediaType{"application/json": {Schema: errorSchema}}}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]Operation{}
+		}
+		doc.Paths[path][strings.ToLower(r.Method)] = op
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	return doc
+}
+
+// queryParams documenta cada campo de un struct de query string (tags form + binding)
+func (reg *registry) queryParams(query interface{}) []Parameter {
+	t := reflect.TypeOf(query)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s := &Schema{Properties: map[string]*Schema{}}
+	reg.addFields(s, t)
+
+	required := map[string]bool{}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]Parameter, 0, len(names))
+	for _, name := range names {
+		params = append(params, Parameter{Name: name, In: "query", Required: required[name], Schema: s.Properties[name]})
+	}
+	return params
+}
+
+func security(auth Auth) []map[string][]string {
+	switch auth {
+	case AuthUser, AuthPerson:
+		return []map[string][]string{{SecurityBearer: {}}}
+	case AuthTenant:
+		return []map[string][]string{{SecurityBearer: {}}, {SecurityAPIKey: {}}}
+	case AuthMetrics:
+		return []map[string][]string{{SecurityMetrics: {}}}
+	}
+	return []map[string][]string{}
+}
+
+// operationID: POST /fleet/assets/:id/usage -> post_fleet_assets_id_usage
+func operationID(method, path string) string {
+	id := strings.ToLower(method) + "_" + strings.Trim(path, "/")
+	return strings.NewReplacer("/", "_", ":", "", "*", "", "-", "_").Replace(id)
+}
+
+// Registered: Ruta registrada en el router (método + path de gin)
+type Registered struct {
+	Method string
+	Path   string
+}
+
+// Missing devuelve las rutas registradas que no están en la tabla (ordenadas)
+func Missing(routes []Route, registered []Registered) []Registered {
+	documented := make([]Registered, 0, len(routes))
+	for _, r := range routes {
+		documented = append(documented, Registered{Method: r.Method, Path: r.Path})
+	}
+	return diff(registered, documented)
+}
+
+// Stale devuelve las rutas de la tabla que ya no existen en el router (ordenadas)
+func Stale(routes []Route, registered []Registered) []Registered {
+	documented := make([]Registered, 0, len(routes))
+	for _, r := range routes {
+		documented = append(documented, Registered{Method: r.Method, Path: r.Path})
+	}
+	return diff(documented, registered)
+}
+
+// diff: Rutas de a que no están en b
+func diff(a, b []Registered) []Registered {
+	in := map[string]bool{}
+	for _, r := range b {
+		in[r.Method+" "+Path(r.Path)] = true
+	}
+	var out []Registered
+	for _, r := range a {
+		if !in[r.Method+" "+Path(r.Path)] {
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
+}
@@ -0,0 +1,279 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ---------------------------------------------------------
+// 🧬 ESQUEMAS: Tipos de Go -> JSON Schema
+// ---------------------------------------------------------
+// Los nombres salen del tag json (o form en query strings) y las reglas del tag binding:
+// required, min/max, gt/gte/lt/lte, oneof, email, url, uuid, hexcolor, dive.
+// Los structs con nombre (domain.Farm, ScanRequest...) se publican una vez en components/schemas.
+
+// Schema: Subconjunto de JSON Schema que usa OpenAPI 3.0
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"` // *Schema o true
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	uuidType    = reflect.TypeOf(uuid.UUID{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Fields: Objeto armado en el handler (gin.H). Cada valor es un ejemplo del tipo o un *Schema.
+//
+//	Fields{"message": "", "data": domain.Bin{}}
+type Fields map[string]interface{}
+
+// registry: components/schemas del documento
+type registry struct {
+	schemas map[string]*Schema
+	owners  map[string]reflect.Type // Detecta dos tipos distintos con el mismo nombre
+}
+
+// schemaOf describe un valor de ejemplo (domain.Farm{}, []domain.Farm{}, Fields{...} o *Schema)
+func (reg *registry) schemaOf(v interface{}) *Schema {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case *Schema:
+		return v
+	case Fields:
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for name, field := range v {
+			s.Properties[name] = reg.schemaOf(field)
+		}
+		return s
+	}
+	return reg.typeSchema(reflect.TypeOf(v))
+}
+
+func (reg *registry) typeSchema(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var s *Schema
+	switch {
+	case t == timeType:
+		s = &Schema{Type: "string", Format: "date-time"}
+	case t == uuidType:
+		s = &Schema{Type: "string", Format: "uuid"}
+	case t == rawJSONType:
+		s = &Schema{}
+	default:
+		switch t.Kind() {
+		case reflect.Bool:
+			s = &Schema{Type: "boolean"}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+			s = &Schema{Type: "integer", Format: "int32"}
+		case reflect.Int64, reflect.Uint64:
+			s = &Schema{Type: "integer", Format: "int64"}
+		case reflect.Float32:
+			s = &Schema{Type: "number", Format: "float"}
+		case reflect.Float64:
+			s = &Schema{Type: "number", Format: "double"}
+		case reflect.String:
+			s = &Schema{Type: "string"}
+		case reflect.Slice, reflect.Array:
+			if t.Elem().Kind() == reflect.Uint8 {
+				s = &Schema{Type: "string", Format: "byte"} // []byte viaja en base64
+			} else {
+				s = &Schema{Type: "array", Items: reg.typeSchema(t.Elem())}
+			}
+		case reflect.Map:
+			s = &Schema{Type: "object", AdditionalProperties: reg.typeSchema(t.Elem())}
+		case reflect.Interface:
+			s = &Schema{}
+		case reflect.Struct:
+			s = reg.structRef(t)
+		default:
+			s = &Schema{}
+		}
+	}
+	if nullable && s.Ref == "" {
+		s.Nullable = true
+	}
+	return s
+}
+
+// structRef publica el struct en components/schemas y devuelve la referencia.
+// Los structs anónimos se describen en línea.
+func (reg *registry) structRef(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return reg.structSchema(t)
+	}
+	name := schemaName(t)
+	if owner, ok := reg.owners[name]; ok && owner != t {
+		name = strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + t.Name()
+	}
+	if _, ok := reg.schemas[name]; !ok {
+		reg.owners[name] = t
+		reg.schemas[name] = &Schema{} // Reservado antes de recorrer: soporta tipos recursivos (Children)
+		*reg.schemas[name] = *reg.structSchema(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// schemaName: domain.Farm -> "Farm"; los tipos sin exportar de cmd/api quedan igual
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	// Genéricos: Page[domain.Farm] -> Page_Farm
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		inner := name[i+1 : len(name)-1]
+		inner = inner[strings.LastIndexByte(inner, '.')+1:]
+		name = name[:i] + "_" + inner
+	}
+	return name
+}
+
+func (reg *registry) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	reg.addFields(s, t)
+	if len(s.Properties) == 0 {
+		s.Properties = nil
+	}
+	return s
+}
+
+func (reg *registry) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, skip := fieldName(f)
+		if skip {
+			continue
+		}
+		// Embebidos sin nombre JSON (ej: tenantField): sus campos suben al objeto
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				reg.addFields(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := reg.typeSchema(f.Type)
+		if required := applyBinding(prop, f.Tag.Get("binding")); required {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// fieldName: Nombre del campo en JSON (o en el query string). skip = json:"-"
+func fieldName(f reflect.StructField) (name string, skip bool) {
+	tag, ok := f.Tag.Lookup("json")
+	if !ok {
+		tag = f.Tag.Get("form")
+	}
+	name = strings.Split(tag, ",")[0]
+	return name, name == "-"
+}
+
+// applyBinding traduce las reglas de validación al esquema. Devuelve si el campo es obligatorio.
+func applyBinding(s *Schema, tag string) (required bool) {
+	if tag == "" || s.Ref != "" {
+		return strings.Contains(tag, "required")
+	}
+	// dive: las reglas que siguen aplican a cada elemento del arreglo
+	rules, itemRules, _ := strings.Cut(tag, ",dive")
+	if s.Items != nil && itemRules != "" {
+		applyBinding(s.Items, strings.TrimPrefix(itemRules, ","))
+	}
+
+	isString := s.Type == "string"
+	for _, rule := range strings.Split(rules, ",") {
+		key, param, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "oneof":
+			s.Enum = strings.Fields(param)
+		case "email", "url", "uuid":
+			s.Format = key
+			if key == "url" {
+				s.Format = "uri"
+			}
+		case "hexcolor":
+			s.Pattern = "^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$"
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			switch {
+			case isString:
+				l := int(n)
+				if key != "max" {
+					s.MinLength = &l
+				}
+				if key != "min" {
+					s.MaxLength = &l
+				}
+			case s.Type == "array":
+				l := int(n)
+				if key != "max" {
+					s.MinItems = &l
+				}
+				if key != "min" {
+					s.MaxItems = &l
+				}
+			default:
+				if key != "max" {
+					s.Minimum = &n
+				}
+				if key != "min" {
+					s.Maximum = &n
+				}
+			}
+		case "gt", "gte":
+			if n, err := strconv.ParseFloat(param, 64); err == nil && !isString {
+				s.Minimum = &n
+				s.ExclusiveMinimum = key == "gt"
+			}
+		case "lt", "lte":
+			if n, err := strconv.ParseFloat(param, 64); err == nil && !isString {
+				s.Maximum = &n
+				s.ExclusiveMaximum = key == "lt"
+			}
+		}
+	}
+	return required
+}
@@ -0,0 +1,341 @@
+package outbox
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/background"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/logging"
+	"github.com/Marcos1394/agritrust-backend/internal/metrics"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/Marcos1394/agritrust-backend/pkg/mailer"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 📮 OUTBOX TRANSACCIONAL (correos y webhooks con entrega garantizada)
+// ---------------------------------------------------------
+// Quien cambia datos y tiene que avisar afuera guarda el aviso con Enqueue en la MISMA transacción.
+// El despachador de cada réplica busca mensajes pendientes cada PollInterval, toma cada uno con un
+// UPDATE condicional (solo una réplica lo logra) y llama al handler de su tipo. Si falla, se reintenta
+// con espera exponencial; después de MaxAttempts queda como dead hasta que alguien lo reintente a mano.
+// La entrega es "al menos una vez": si la réplica muere después de entregar y antes de marcarlo,
+// otra lo vuelve a entregar al vencer el candado. Cada despachador atiende solo los tipos que tiene
+// registrados (Handle): así los webhooks lentos no detienen los correos.
+
+// Valores por omisión
+const (
+	DefaultPollInterval = 5 * time.Second
+	DefaultLease        = 2 * time.Minute
+	DefaultMaxAttempts  = 8
+	DefaultBatchSize    = 50
+)
+
+// backoffBase y backoffMax: Espera antes del reintento n (30s, 1m, 2m... hasta 1h)
+const (
+	backoffBase = 30 * time.Second
+	backoffMax  = time.Hour
+)
+
+// Email: Contenido de un mensaje domain.OutboxEmail
+type Email struct {
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	HTML    string   `json:"html"`
+}
+
+// Enqueue guarda el mensaje con tx, que debe ser la transacción del cambio que lo origina.
+// La empresa la pone el scope de tenancy del contexto de tx.
+func Enqueue(tx *gorm.DB, kind, topic, target, subject string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&domain.OutboxMessage{
+		Kind:          kind,
+		Topic:         topic,
+		Target:        target,
+		Subject:       subject,
+		Payload:       body,
+		Status:        domain.OutboxPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// EnqueueEmail guarda un correo. Sin destinatarios no guarda nada.
+func EnqueueEmail(tx *gorm.DB, topic string, email Email) error {
+	if len(email.To) == 0 {
+		return nil
+	}
+	return Enqueue(tx, domain.OutboxEmail, topic, strings.Join(email.To, ", "), email.Subject, email)
+}
+
+// SendEmail: Handler de domain.OutboxEmail (pkg/mailer)
+func SendEmail(ctx context.Context, msg domain.OutboxMessage) error {
+	var email Email
+	if err := json.Unmarshal(msg.Payload, &email); err != nil {
+		return fmt.Errorf("%w: contenido inválido: %v", ErrPermanent, err)
+	}
+	return mailer.SendEmail(ctx, email.To, email.Subject, email.HTML)
+}
+
+// Handler entrega un mensaje. Un error cuenta como intento fallido.
+type Handler func(ctx context.Context, msg domain.OutboxMessage) error
+
+// ErrPermanent: Falla que no se arregla reintentando (ej: se borró el destino). El handler la envuelve
+// con %w y el mensaje queda como dead sin gastar los intentos que le quedan.
+var ErrPermanent = errors.New("falla permanente")
+
+// Options: Configuración del despachador
+type Options struct {
+	PollInterval time.Duration // Cada cuánto se buscan mensajes pendientes (0 = DefaultPollInterval)
+	Lease        time.Duration // Candado de un mensaje en entrega; el handler tiene la mitad (0 = DefaultLease)
+	MaxAttempts  int           // Intentos antes de dejarlo como dead (0 = DefaultMaxAttempts)
+	BatchSize    int           // Mensajes por revisión (0 = DefaultBatchSize)
+	Worker       string        // Identificador de la réplica (vacío = host:pid:aleatorio)
+}
+
+// Dispatcher: Entrega los mensajes de los tipos con handler en esta réplica
+type Dispatcher struct {
+	db       *gorm.DB
+	tasks    *background.Group
+	opts     Options
+	handlers map[string]Handler
+}
+
+// New crea el despachador. El ciclo corre dentro de tasks para que el apagado lo espere.
+func New(db *gorm.DB, tasks *background.Group, opts Options) *Dispatcher {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = DefaultLease
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Worker == "" {
+		host, _ := os.Hostname()
+		opts.Worker = fmt.Sprintf("%s:%d:%s", host, os.Getpid(), uuid.NewString()[:8])
+	}
+	return &Dispatcher{db: db, tasks: tasks, opts: opts, handlers: map[string]Handler{}}
+}
+
+// Handle registra el handler de un tipo de mensaje. Los tipos sin handler se quedan pendientes
+// (ej: durante un deploy, los que solo sabe entregar la versión nueva).
+func (d *Dispatcher) Handle(kind string, h Handler) {
+	d.handlers[kind] = h
+}
+
+// Start arranca el ciclo de revisión (sale al iniciar el apagado)
+func (d *Dispatcher) Start() {
+	ctx := logging.With(context.Background(), "task", "outbox", "worker", d.opts.Worker)
+	d.tasks.Go(ctx, func(ctx context.Context) {
+		ticker := time.NewTicker(d.opts.PollInterval)
+		defer ticker.Stop()
+		for {
+			// Una revisión que entregó un lote lleno significa que hay más esperando: se sigue sin
+			// esperar al ticker. Si algo falló (ej: la base no responde) se espera al siguiente tick;
+			// si no, los mismos mensajes seguirían vencidos y el ciclo giraría sin pausa.
+			for {
+				n, err := d.poll(ctx)
+				if err != nil || n < d.opts.BatchSize {
+					break
+				}
+				if d.stopping() {
+					return
+				}
+			}
+			select {
+			case <-d.tasks.Stopping():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+func (d *Dispatcher) stopping() bool {
+	select {
+	case <-d.tasks.Stopping():
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *Dispatcher) sysDB(ctx context.Context) *gorm.DB {
+	return d.db.WithContext(tenancy.WithoutScope(ctx))
+}
+
+func (d *Dispatcher) kinds() []string {
+	kinds := make([]string, 0, len(d.handlers))
+	for k := range d.handlers {
+		kinds = append(kinds, k)
+	}
+	return kinds
+}
+
+// Poll entrega los mensajes pendientes cuyo candado consiga esta réplica. Devuelve cuántos entregó
+// (con éxito o no: un fallo del handler se reprograma). Start lo llama cada PollInterval; las pruebas lo llaman directo.
+func (d *Dispatcher) Poll(ctx context.Context) int {
+	n, _ := d.poll(ctx)
+	return n
+}
+
+// poll: Poll más el primer error de la base (búsqueda, candado o guardado del resultado), ya registrado
+func (d *Dispatcher) poll(ctx context.Context) (int, error) {
+	if len(d.handlers) == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	var due []uuid.UUID
+	if err := d.sysDB(ctx).Model(&domain.OutboxMessage{}).
+		Where("status = ? AND next_attempt_at <= ? AND kind IN ? AND (locked_until IS NULL OR locked_until < ?)",
+			domain.OutboxPending, now, d.kinds(), now).
+		Order("next_attempt_at").
+		Limit(d.opts.BatchSize).
+		Pluck("id", &due).Error; err != nil {
+		slog.ErrorContext(ctx, "error buscando mensajes pendientes del outbox", "error", err)
+		return 0, err
+	}
+	delivered := 0
+	var firstErr error
+	for _, id := range due {
+		if d.stopping() {
+			break
+		}
+		msg, ok, err := d.acquire(ctx, id)
+		if err != nil {
+			slog.ErrorContext(ctx, "error tomando el candado del mensaje", "outbox_id", id, "error", err)
+			firstErr = cmp.Or(firstErr, err)
+			continue
+		}
+		if !ok {
+			continue // Otra réplica lo tomó primero
+		}
+		if err := d.deliver(logging.With(ctx, "outbox_id", id, "tenant_id", msg.TenantID, "kind", msg.Kind), msg); err != nil {
+			firstErr = cmp.Or(firstErr, err)
+		}
+		delivered++
+	}
+	return delivered, firstErr
+}
+
+// acquire toma el candado si el mensaje sigue pendiente y libre, y cuenta el intento
+func (d *Dispatcher) acquire(ctx context.Context, id uuid.UUID) (domain.OutboxMessage, bool, error) {
+	var msg domain.OutboxMessage
+	now := time.Now()
+	res := d.sysDB(ctx).Model(&domain.OutboxMessage{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ? AND (locked_until IS NULL OR locked_until < ?)", id, domain.OutboxPending, now, now).
+		Updates(map[string]interface{}{
+			"locked_by":    d.opts.Worker,
+			"locked_until": now.Add(d.opts.Lease),
+			"attempts":     gorm.Expr("attempts + 1"),
+		})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return msg, false, res.Error
+	}
+	err := d.sysDB(ctx).First(&msg, "id = ?", id).Error
+	return msg, err == nil, err
+}
+
+// deliver llama al handler y guarda el resultado (entregado, reintento o dead).
+// El error es solo el de guardar el resultado: el del handler queda en el mensaje.
+func (d *Dispatcher) deliver(ctx context.Context, msg domain.OutboxMessage) error {
+	// El handler termina antes de que venza el candado: así otra réplica no lo entrega en paralelo
+	callCtx, cancel := context.WithTimeout(tenancy.WithTenant(ctx, msg.TenantID), d.opts.Lease/2)
+	err := d.call(callCtx, msg)
+	cancel()
+
+	ctx = context.WithoutCancel(ctx)
+	now := time.Now()
+	result := metrics.OutboxSent
+	updates := map[string]interface{}{"locked_by": "", "locked_until": nil}
+	switch {
+	case err == nil:
+		updates["status"], updates["sent_at"], updates["last_error"], updates["payload"] = domain.OutboxSent, now, "", nil
+	case msg.Attempts < d.opts.MaxAttempts && !errors.Is(err, ErrPermanent):
+		result = metrics.OutboxRetry
+		updates["next_attempt_at"], updates["last_error"] = now.Add(Backoff(msg.Attempts)), err.Error()
+	default:
+		result = metrics.OutboxDead
+		updates["status"], updates["last_error"] = domain.OutboxDead, err.Error()
+	}
+	res := d.sysDB(ctx).Model(&domain.OutboxMessage{}).Where("id = ? AND locked_by = ?", msg.ID, d.opts.Worker).Updates(updates)
+	if res.Error != nil {
+		slog.ErrorContext(ctx, "no se pudo guardar el resultado de la entrega", "error", res.Error)
+	} else if res.RowsAffected == 0 {
+		slog.WarnContext(ctx, "la entrega terminó sin candado: otra réplica pudo repetirla")
+	}
+
+	metrics.OutboxDeliveries.WithLabelValues(msg.Kind, result).Inc()
+	switch result {
+	case metrics.OutboxRetry:
+		slog.WarnContext(ctx, "entrega del outbox falló; se reintenta", "attempt", msg.Attempts, "error", err, "next_attempt_at", updates["next_attempt_at"])
+	case metrics.OutboxDead:
+		slog.ErrorContext(ctx, "entrega del outbox falló; sin más intentos", "attempt", msg.Attempts, "error", err)
+	}
+	return res.Error
+}
+
+// call ejecuta el handler convirtiendo un panic en error (cuenta como intento fallido)
+func (d *Dispatcher) call(ctx context.Context, msg domain.OutboxMessage) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			slog.ErrorContext(ctx, "panic entregando mensaje del outbox", "panic", fmt.Sprint(rec), "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", rec)
+		}
+	}()
+	return d.handlers[msg.Kind](ctx, msg)
+}
+
+// Backoff: Espera antes de reintentar después del intento n (30s, 1m, 2m... hasta 1h)
+func Backoff(attempt int) time.Duration {
+	d := backoffBase
+	for i := 1; i < attempt && d < backoffMax; i++ {
+		d *= 2
+	}
+	return min(d, backoffMax)
+}
+
+// ErrAlreadySent: El mensaje ya se entregó (no se reintenta)
+var ErrAlreadySent = errors.New("outbox: mensaje ya entregado")
+
+// Retry pone el mensaje en la fila para la siguiente revisión. Un dead vuelve con los intentos en cero.
+func Retry(db *gorm.DB, msg *domain.OutboxMessage) error {
+	if msg.Status == domain.OutboxSent {
+		return ErrAlreadySent
+	}
+	updates := map[string]interface{}{"status": domain.OutboxPending, "next_attempt_at": time.Now()}
+	if msg.Status == domain.OutboxDead {
+		updates["attempts"] = 0
+	}
+	res := db.Model(msg).Where("status <> ?", domain.OutboxSent).Updates(updates)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrAlreadySent // Se entregó entre la lectura y la actualización
+	}
+	return res.Error
+}
+
+// Purge borra los entregados antes de sentBefore y los dead creados antes de deadBefore.
+// Devuelve cuántos registros borró.
+func Purge(db *gorm.DB, sentBefore, deadBefore time.Time) (int64, error) {
+	res := db.Where("(status = ? AND sent_at < ?) OR (status = ? AND created_at < ?)",
+		domain.OutboxSent, sentBefore, domain.OutboxDead, deadBefore).
+		Delete(&domain.OutboxMessage{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,109 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/background"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDispatcher: Outbox en SQLite con n mensajes vencidos; searches cuenta las búsquedas de pendientes
+func newTestDispatcher(t *testing.T, n int) (*gorm.DB, *Dispatcher, *atomic.Int32) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&domain.OutboxMessage{}); err != nil {
+		t.Fatal(err)
+	}
+	for range n {
+		if err := Enqueue(db, domain.OutboxEmail, "security", "ops@example.com", "Alerta", Email{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	searches := &atomic.Int32{}
+	err = db.Callback().Query().Before("gorm:query").Register("test:count_searches", func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Dest.(*[]uuid.UUID); ok {
+			searches.Add(1)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks := background.New()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		tasks.Shutdown(ctx)
+	})
+	d := New(db, tasks, Options{PollInterval: time.Hour, BatchSize: 1})
+	return db, d, searches
+}
+
+func TestStartDrainsFullBatches(t *testing.T) {
+	db, d, searches := newTestDispatcher(t, 3)
+	var calls atomic.Int32
+	d.Handle(domain.OutboxEmail, func(ctx context.Context, msg domain.OutboxMessage) error {
+		calls.Add(1)
+		return nil
+	})
+	d.Start()
+
+	// Tres lotes llenos de un mensaje y una revisión vacía, sin esperar el ticker de una hora
+	deadline := time.Now().Add(2 * time.Second)
+	for searches.Load() < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("%d entregas, se esperaban 3", got)
+	}
+	var sent int64
+	db.Model(&domain.OutboxMessage{}).Where("status = ?", domain.OutboxSent).Count(&sent)
+	if sent != 3 {
+		t.Fatalf("%d mensajes enviados, se esperaban 3", sent)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := searches.Load(); got != 4 {
+		t.Fatalf("%d revisiones, se esperaban 4", got)
+	}
+}
+
+func TestStartWaitsAfterError(t *testing.T) {
+	db, d, searches := newTestDispatcher(t, 1)
+	d.Handle(domain.OutboxEmail, func(ctx context.Context, msg domain.OutboxMessage) error { return nil })
+
+	// La base rechaza el candado: el mensaje sigue vencido en cada revisión
+	err := db.Callback().Update().Before("gorm:update").Register("test:fail_lock", func(tx *gorm.DB) {
+		tx.AddError(errors.New("conexión perdida"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.poll(context.Background()); n != 0 || err == nil {
+		t.Fatalf("poll = %d, %v; se esperaba 0 y el error del candado", n, err)
+	}
+
+	// Start no vuelve a revisar hasta el siguiente tick (antes giraba sin pausa)
+	searches.Store(0)
+	d.Start()
+	time.Sleep(100 * time.Millisecond)
+	if got := searches.Load(); got != 1 {
+		t.Fatalf("%d revisiones después de un error, se esperaba 1", got)
+	}
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"gorm.io/gorm"
+)
+
+// ---------------------------------------------------------
+// 🚦 RATE LIMITING: Presupuesto de peticiones por ventana
+// ---------------------------------------------------------
+// Ventana fija: cada llave tiene Limit peticiones por Window (la ventana empieza en múltiplos de
+// Window, así todas las réplicas la calculan igual). Es más simple que una ventana deslizante y
+// alcanza para frenar abusos; el costo es que en el cambio de ventana pueden pasar hasta 2×Limit.
+// La llave la arma quien llama (middleware.RateLimit): IP en rutas públicas; API key o usuario
+// y empresa en rutas privadas.
+
+// Policy: Presupuesto de un grupo de rutas. Limit 0 = sin límite.
+type Policy struct {
+	Name   string // Grupo (public, account, tenant, heavy); va en la llave y en las métricas
+	Limit  int
+	Window time.Duration
+}
+
+// Result: Estado de la llave después de contar la petición
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Time // Cuándo empieza la ventana siguiente
+}
+
+// Store cuenta las peticiones de una llave en la ventana que empieza en windowStart y
+// devuelve cuántas lleva (incluida esta).
+type Store interface {
+	Increment(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int, error)
+}
+
+// Take cuenta una petición de key contra la política
+func Take(ctx context.Context, store Store, p Policy, key string, now time.Time) (Result, error) {
+	start := now.Truncate(p.Window)
+	count, err := store.Increment(ctx, p.Name+":"+key, start, p.Window)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:   count <= p.Limit,
+		Limit:     p.Limit,
+		Remaining: max(p.Limit-count, 0),
+		Reset:     start.Add(p.Window),
+	}, nil
+}
+
+// ---------------------------------------------------------
+// Store en memoria (una réplica, o desarrollo)
+// ---------------------------------------------------------
+
+// sweepEvery: Cada cuánto se borran las llaves de ventanas vencidas
+const sweepEvery = time.Minute
+
+type memoryBucket struct {
+	start   time.Time
+	count   int
+	expires time.Time
+}
+
+// MemoryStore: Contadores en el proceso. Con varias réplicas cada una lleva su cuenta
+// (el presupuesto efectivo se multiplica por el número de réplicas): usar PostgresStore.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	nextSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*memoryBucket{}}
+}
+
+func (s *MemoryStore) Increment(_ context.Context, key string, windowStart time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.After(s.nextSweep) {
+		for k, b := range s.buckets {
+			if now.After(b.expires) {
+				delete(s.buckets, k)
+			}
+		}
+		s.nextSweep = now.Add(sweepEvery)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok || !b.start.Equal(windowStart) {
+		b = &memoryBucket{start: windowStart, expires: windowStart.Add(window)}
+		s.buckets[key] = b
+	}
+	b.count++
+	return b.count, nil
+}
+
+// ---------------------------------------------------------
+// Store en Postgres (varias réplicas comparten el presupuesto)
+// ---------------------------------------------------------
+
+// PostgresStore: Una fila por llave en rate_limit_buckets, actualizada con un solo UPSERT atómico.
+// Agrega una escritura por petición: conviene para despliegues con varias réplicas.
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Increment(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int, error) {
+	var count int
+	// Misma ventana: suma. Ventana nueva: reinicia en 1.
+	err := s.db.WithContext(tenancy.WithoutScope(ctx)).Raw(`
+		INSERT INTO rate_limit_buckets (key, window_start, count, expires_at) VALUES (?, ?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			count = CASE WHEN rate_limit_buckets.window_start = EXCLUDED.window_start THEN rate_limit_buckets.count + 1 ELSE 1 END,
+			window_start = EXCLUDED.window_start,
+			expires_at = EXCLUDED.expires_at
+		RETURNING count`, key, windowStart, windowStart.Add(window)).Scan(&count).Error
+	return count, err
+}
+
+// Purge borra las llaves cuya ventana ya venció (sysDB: sesión sin scope de empresa)
+func Purge(sysDB *gorm.DB, now time.Time) (int64, error) {
+	res := sysDB.Where("expires_at < ?", now).Delete(&domain.RateLimitBucket{})
+	return res.RowsAffected, res.Error
+}
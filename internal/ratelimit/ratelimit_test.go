@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// take cuenta una petición y falla la prueba si el store falla
+func take(t *testing.T, store Store, p Policy, key string, now time.Time) Result {
+	t.Helper()
+	res, err := Take(context.Background(), store, p, key, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// checkWindow: Dos permitidas, la tercera no, y la ventana siguiente reinicia el presupuesto
+func checkWindow(t *testing.T, store Store) {
+	t.Helper()
+	p := Policy{Name: "public", Limit: 2, Window: time.Minute}
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	for i, want := range []Result{
+		{Allowed: true, Limit: 2, Remaining: 1, Reset: start.Add(time.Minute)},
+		{Allowed: true, Limit: 2, Remaining: 0, Reset: start.Add(time.Minute)},
+		{Allowed: false, Limit: 2, Remaining: 0, Reset: start.Add(time.Minute)},
+	} {
+		if got := take(t, store, p, "ip:203.0.113.7", start.Add(time.Duration(i*10)*time.Second)); got != want {
+			t.Fatalf("petición %d: %+v, se esperaba %+v", i+1, got, want)
+		}
+	}
+
+	// Otra llave tiene su propio presupuesto
+	if got := take(t, store, p, "ip:198.51.100.1", start.Add(30*time.Second)); !got.Allowed || got.Remaining != 1 {
+		t.Fatalf("otra llave: %+v", got)
+	}
+
+	// Ventana nueva: se reinicia la cuenta
+	next := start.Add(time.Minute + 5*time.Second)
+	want := Result{Allowed: true, Limit: 2, Remaining: 1, Reset: start.Add(2 * time.Minute)}
+	if got := take(t, store, p, "ip:203.0.113.7", next); got != want {
+		t.Fatalf("ventana nueva: %+v, se esperaba %+v", got, want)
+	}
+}
+
+func TestMemoryStoreWindow(t *testing.T) {
+	checkWindow(t, NewMemoryStore())
+}
+
+func TestPostgresStoreWindow(t *testing.T) {
+	// SQLite entiende el mismo INSERT ... ON CONFLICT ... RETURNING
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&domain.RateLimitBucket{}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkWindow(t, NewPostgresStore(db))
+
+	// Una fila por llave, aunque haya pasado otra ventana
+	var rows int64
+	if err := db.Model(&domain.RateLimitBucket{}).Count(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if rows != 2 {
+		t.Fatalf("%d filas en rate_limit_buckets, se esperaban 2", rows)
+	}
+
+	// La purga borra solo las ventanas vencidas
+	purged, err := Purge(db, time.Date(2026, 3, 1, 10, 1, 30, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Fatalf("purga borró %d filas, se esperaba 1", purged)
+	}
+}
@@ -0,0 +1,411 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/background"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/logging"
+	"github.com/Marcos1394/agritrust-backend/internal/metrics"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ---------------------------------------------------------
+// ⏰ TAREAS PROGRAMADAS (cron con candado en la base)
+// ---------------------------------------------------------
+// Cada réplica revisa cada PollInterval qué tareas ya tocan. Para ejecutar una, toma el candado
+// con un UPDATE condicional sobre scheduled_jobs: solo una réplica lo logra, aunque haya varias.
+// Mientras corre renueva el candado; si la réplica muere, el candado vence y otra la retoma
+// (cuenta como un intento fallido). Una falla se reintenta con espera exponencial hasta MaxAttempts;
+// después se espera a la siguiente hora programada. Cada intento queda en job_runs.
+
+// Valores por omisión
+const (
+	DefaultMaxAttempts  = 3
+	DefaultPollInterval = 30 * time.Second
+	DefaultLease        = 5 * time.Minute
+)
+
+// backoffBase y backoffMax: Espera antes del reintento n (1m, 2m, 4m... hasta 1h)
+const (
+	backoffBase = time.Minute
+	backoffMax  = time.Hour
+)
+
+// Job: Tarea periódica. Run debe ser idempotente: un reintento (o una réplica que retoma
+// un candado vencido) vuelve a ejecutarla para la misma DueAt.
+type Job struct {
+	Name        string
+	Schedule    string // Cron de 5 campos en UTC
+	MaxAttempts int    // Intentos por corrida (0 = DefaultMaxAttempts)
+	Run         func(ctx context.Context, run *Run) error
+}
+
+// Options: Configuración del scheduler
+type Options struct {
+	PollInterval time.Duration // Cada cuánto se buscan tareas pendientes (0 = DefaultPollInterval)
+	Lease        time.Duration // Duración del candado; se renueva cada Lease/3 (0 = DefaultLease)
+	Worker       string        // Identificador de la réplica (vacío = host:pid:aleatorio)
+}
+
+// Scheduler: Tareas registradas en esta réplica
+type Scheduler struct {
+	db    *gorm.DB
+	tasks *background.Group
+	opts  Options
+	jobs  map[string]registered
+}
+
+type registered struct {
+	Job
+	schedule Schedule
+}
+
+// New crea el scheduler. Las tareas corren dentro de tasks para que el apagado las espere.
+func New(db *gorm.DB, tasks *background.Group, opts Options) *Scheduler {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = DefaultLease
+	}
+	if opts.Worker == "" {
+		host, _ := os.Hostname()
+		opts.Worker = fmt.Sprintf("%s:%d:%s", host, os.Getpid(), uuid.NewString()[:8])
+	}
+	return &Scheduler{db: db, tasks: tasks, opts: opts, jobs: map[string]registered{}}
+}
+
+// Register agrega una tarea. Falla si el nombre se repite o la expresión cron es inválida.
+func (s *Scheduler) Register(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return errors.New("tarea sin nombre o sin función")
+	}
+	if _, dup := s.jobs[job.Name]; dup {
+		return fmt.Errorf("tarea %q registrada dos veces", job.Name)
+	}
+	sched, err := Parse(job.Schedule)
+	if err != nil {
+		return err
+	}
+	if sched.Next(time.Now()).IsZero() {
+		return fmt.Errorf("cron %q: nunca coincide", job.Schedule)
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = DefaultMaxAttempts
+	}
+	s.jobs[job.Name] = registered{Job: job, schedule: sched}
+	return nil
+}
+
+// Names devuelve las tareas registradas en orden alfabético
+func (s *Scheduler) Names() []string {
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Start da de alta las tareas en la base y arranca el ciclo de revisión (sale al iniciar el apagado)
+func (s *Scheduler) Start() error {
+	ctx := logging.With(context.Background(), "task", "scheduler", "worker", s.opts.Worker)
+	if err := s.sync(ctx); err != nil {
+		return err
+	}
+	s.tasks.Go(ctx, func(ctx context.Context) {
+		ticker := time.NewTicker(s.opts.PollInterval)
+		defer ticker.Stop()
+		for {
+			s.poll(ctx)
+			select {
+			case <-s.tasks.Stopping():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+	return nil
+}
+
+func (s *Scheduler) sysDB(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(tenancy.WithoutScope(ctx))
+}
+
+// sync crea las filas que faltan. Si la expresión cron cambió en el código, la tarea se reprograma
+// (salvo que esté corriendo: se ajusta en el siguiente arranque).
+func (s *Scheduler) sync(ctx context.Context) error {
+	now := time.Now()
+	for _, name := range s.Names() {
+		job := s.jobs[name]
+		next := job.schedule.Next(now)
+		row := domain.ScheduledJob{Name: name, Schedule: job.Schedule, DueAt: next, NextRunAt: next}
+		if err := s.sysDB(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return fmt.Errorf("registrando tarea %s: %w", name, err)
+		}
+		if err := s.sysDB(ctx).Model(&domain.ScheduledJob{}).
+			Where("name = ? AND schedule <> ? AND locked_by = ''", name, job.Schedule).
+			Updates(map[string]interface{}{
+				"schedule": job.Schedule, "due_at": next, "next_run_at": next, "attempt": 0, "checkpoint": nil,
+			}).Error; err != nil {
+			return fmt.Errorf("reprogramando tarea %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// poll lanza las tareas pendientes cuyo candado consiga esta réplica
+func (s *Scheduler) poll(ctx context.Context) {
+	now := time.Now()
+	var due []string
+	if err := s.sysDB(ctx).Model(&domain.ScheduledJob{}).
+		Where("name IN ? AND next_run_at <= ? AND (locked_until IS NULL OR locked_until < ?)", s.Names(), now, now).
+		Order("next_run_at").
+		Pluck("name", &due).Error; err != nil {
+		slog.ErrorContext(ctx, "error buscando tareas pendientes", "error", err)
+		return
+	}
+	for _, name := range due {
+		row, ok, err := s.acquire(ctx, name)
+		if err != nil {
+			slog.ErrorContext(ctx, "error tomando el candado de la tarea", "job", name, "error", err)
+			continue
+		}
+		if !ok {
+			continue // Otra réplica la tomó primero
+		}
+		job := s.jobs[name]
+		s.tasks.Go(logging.With(ctx, "job", name), func(ctx context.Context) { s.execute(ctx, job, row) })
+	}
+}
+
+// acquire toma el candado si la tarea sigue pendiente y libre. Las corridas que quedaron "running"
+// son de una réplica que murió con el candado: se cierran como fallidas.
+func (s *Scheduler) acquire(ctx context.Context, name string) (domain.ScheduledJob, bool, error) {
+	var row domain.ScheduledJob
+	now := time.Now()
+	res := s.sysDB(ctx).Model(&domain.ScheduledJob{}).
+		Where("name = ? AND next_run_at <= ? AND (locked_until IS NULL OR locked_until < ?)", name, now, now).
+		Updates(map[string]interface{}{
+			"locked_by":       s.opts.Worker,
+			"locked_until":    now.Add(s.opts.Lease),
+			"attempt":         gorm.Expr("attempt + 1"),
+			"last_status":     domain.JobRunning,
+			"last_started_at": now,
+		})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return row, false, res.Error
+	}
+	if err := s.sysDB(ctx).First(&row, "name = ?", name).Error; err != nil {
+		return row, false, err
+	}
+	if err := s.sysDB(ctx).Model(&domain.JobRun{}).
+		Where("job_name = ? AND status = ?", name, domain.JobRunning).
+		Updates(map[string]interface{}{"status": domain.JobFailed, "error": "candado vencido: la réplica se detuvo a media corrida", "finished_at": now}).Error; err != nil {
+		slog.WarnContext(ctx, "no se pudieron cerrar corridas abandonadas", "job", name, "error", err)
+	}
+	return row, true, nil
+}
+
+// execute corre la tarea con el candado tomado y guarda el resultado
+func (s *Scheduler) execute(ctx context.Context, job registered, row domain.ScheduledJob) {
+	started := time.Now()
+	run := &Run{JobName: job.Name, DueAt: row.DueAt, Attempt: row.Attempt, s: s, done: map[string]bool{}}
+	if len(row.Checkpoint) > 0 {
+		var keys []string
+		if err := json.Unmarshal(row.Checkpoint, &keys); err == nil {
+			for _, k := range keys {
+				run.done[k] = true
+			}
+			run.keys = keys
+		}
+	}
+	record := domain.JobRun{
+		JobName:   job.Name,
+		DueAt:     row.DueAt,
+		Attempt:   row.Attempt,
+		Worker:    s.opts.Worker,
+		Status:    domain.JobRunning,
+		StartedAt: started,
+	}
+	if err := s.sysDB(ctx).Create(&record).Error; err != nil {
+		slog.ErrorContext(ctx, "no se pudo registrar la corrida", "error", err)
+	}
+
+	// Si se pierde el candado (ej: la base no respondió a tiempo para renovarlo), se cancela la corrida
+	jobCtx, cancel := context.WithCancel(ctx)
+	stopRenew := s.keepLease(jobCtx, cancel, job.Name)
+	err := s.call(jobCtx, job, run)
+	stopRenew()
+	cancel()
+
+	// El resultado se guarda aunque el apagado haya cancelado ctx
+	ctx = context.WithoutCancel(ctx)
+	finished := time.Now()
+	status := domain.JobCompleted
+	updates := map[string]interface{}{
+		"locked_by": "", "locked_until": nil, "last_finished_at": finished, "last_error": "",
+	}
+	next := job.schedule.Next(finished)
+	var retryAt *time.Time
+	if err != nil {
+		status = domain.JobFailed
+		updates["last_error"] = err.Error()
+		if at := finished.Add(Backoff(row.Attempt)); row.Attempt < job.MaxAttempts && at.Before(next) {
+			retryAt = &at
+		}
+	}
+	updates["last_status"] = status
+	if retryAt != nil {
+		updates["next_run_at"] = *retryAt
+	} else {
+		// Corrida cerrada (con éxito o sin más intentos): se pasa a la siguiente hora programada
+		updates["due_at"], updates["next_run_at"], updates["attempt"], updates["checkpoint"] = next, next, 0, nil
+	}
+	res := s.sysDB(ctx).Model(&domain.ScheduledJob{}).Where("name = ? AND locked_by = ?", job.Name, s.opts.Worker).Updates(updates)
+	if res.Error != nil {
+		slog.ErrorContext(ctx, "no se pudo guardar el estado de la tarea", "error", res.Error)
+	} else if res.RowsAffected == 0 {
+		slog.WarnContext(ctx, "la tarea terminó sin candado: otra réplica pudo retomarla")
+	}
+
+	runUpdates := map[string]interface{}{"status": status, "summary": run.summary, "finished_at": finished, "retry_at": retryAt}
+	if err != nil {
+		runUpdates["error"] = err.Error()
+	}
+	if record.ID != uuid.Nil {
+		if err := s.sysDB(ctx).Model(&record).Updates(runUpdates).Error; err != nil {
+			slog.ErrorContext(ctx, "no se pudo guardar la corrida", "error", err)
+		}
+	}
+
+	metrics.JobRuns.WithLabelValues(job.Name, status).Inc()
+	metrics.JobDuration.WithLabelValues(job.Name).Observe(finished.Sub(started).Seconds())
+	attrs := []any{"attempt", row.Attempt, "status", status, "duration_ms", finished.Sub(started).Milliseconds(), "summary", run.summary}
+	switch {
+	case err == nil:
+		slog.InfoContext(ctx, "tarea programada terminada", attrs...)
+	case retryAt != nil:
+		slog.WarnContext(ctx, "tarea programada falló; se reintenta", append(attrs, "error", err, "retry_at", *retryAt)...)
+	default:
+		slog.ErrorContext(ctx, "tarea programada falló; sin más intentos hasta la siguiente corrida", append(attrs, "error", err, "next_run_at", next)...)
+	}
+}
+
+// call ejecuta la función de la tarea convirtiendo un panic en error (cuenta como intento fallido)
+func (s *Scheduler) call(ctx context.Context, job registered, run *Run) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			slog.ErrorContext(ctx, "panic en tarea programada", "panic", fmt.Sprint(rec), "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", rec)
+		}
+	}()
+	return job.Run(ctx, run)
+}
+
+// keepLease renueva el candado cada Lease/3 mientras la tarea corre. Devuelve la función para detenerlo.
+func (s *Scheduler) keepLease(ctx context.Context, lost context.CancelFunc, name string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(s.opts.Lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			res := s.sysDB(ctx).Model(&domain.ScheduledJob{}).
+				Where("name = ? AND locked_by = ?", name, s.opts.Worker).
+				Update("locked_until", time.Now().Add(s.opts.Lease))
+			if res.Error == nil && res.RowsAffected == 0 {
+				slog.ErrorContext(ctx, "se perdió el candado de la tarea: se cancela la corrida")
+				lost()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// Backoff: Espera antes de reintentar después del intento n (1m, 2m, 4m... hasta 1h)
+func Backoff(attempt int) time.Duration {
+	d := backoffBase
+	for i := 1; i < attempt && d < backoffMax; i++ {
+		d *= 2
+	}
+	return min(d, backoffMax)
+}
+
+// RunNow adelanta la tarea para que la tome la siguiente revisión (no interrumpe una corrida en curso).
+// Devuelve false si la tarea no existe.
+func RunNow(db *gorm.DB, name string) (bool, error) {
+	now := time.Now()
+	res := db.Model(&domain.ScheduledJob{}).Where("name = ?", name).Updates(map[string]interface{}{
+		"next_run_at": now,
+		// Sin reintento pendiente, la corrida manual cuenta como la de ahora
+		"due_at": gorm.Expr("CASE WHEN attempt = 0 THEN ? ELSE due_at END", now),
+	})
+	return res.RowsAffected > 0, res.Error
+}
+
+// PurgeRuns borra el historial anterior a before. Devuelve cuántos registros borró.
+func PurgeRuns(db *gorm.DB, before time.Time) (int64, error) {
+	res := db.Where("started_at < ? AND status <> ?", before, domain.JobRunning).Delete(&domain.JobRun{})
+	return res.RowsAffected, res.Error
+}
+
+// Run: Contexto de una corrida
+type Run struct {
+	JobName string
+	DueAt   time.Time // Hora programada (igual en todos los reintentos)
+	Attempt int       // 1 en el primer intento
+
+	s       *Scheduler
+	done    map[string]bool
+	keys    []string
+	summary string
+}
+
+// Done indica si la clave (ej: el ID de una empresa) ya se completó en un intento anterior de esta corrida
+func (r *Run) Done(key string) bool {
+	return r.done[key]
+}
+
+// Mark guarda la clave como completada: si la corrida falla después, el reintento la salta
+func (r *Run) Mark(ctx context.Context, key string) error {
+	if r.done[key] {
+		return nil
+	}
+	r.done[key] = true
+	r.keys = append(r.keys, key)
+	checkpoint, err := json.Marshal(r.keys)
+	if err != nil {
+		return err
+	}
+	return r.s.sysDB(context.WithoutCancel(ctx)).Model(&domain.ScheduledJob{}).
+		Where("name = ? AND locked_by = ?", r.JobName, r.s.opts.Worker).
+		Update("checkpoint", json.RawMessage(checkpoint)).Error
+}
+
+// Summarize deja un resumen legible en el historial (ej: "3 empresas, 2 avisos")
+func (r *Run) Summarize(format string, args ...any) {
+	r.summary = fmt.Sprintf(format, args...)
+}
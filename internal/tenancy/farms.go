@@ -0,0 +1,189 @@
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Restricción por Rancho
+// ---------------------------------------------------------
+// Un TeamMember puede quedar limitado a algunos ranchos de la empresa (ej: el capataz de un rancho).
+// Si el contexto trae esa lista, los callbacks además:
+//   - Filtran "farm_id IN (...)" en modelos con FarmID y "id IN (...)" en Farm.
+//   - Rechazan altas o cambios que apunten a un rancho fuera de la lista.
+
+// ErrFarmForbidden: El registro pertenece a un rancho que el usuario no tiene asignado
+var ErrFarmForbidden = errors.New("no tienes acceso a este rancho")
+
+type farmsKey struct{}
+
+// farmTable: Tabla de ranchos (se filtra por su propia llave)
+var farmTable string
+
+// WithFarms limita la petición a los ranchos indicados
+func WithFarms(ctx context.Context, farmIDs []uuid.UUID) context.Context {
+	allowed := make(map[uuid.UUID]bool, len(farmIDs))
+	for _, id := range farmIDs {
+		allowed[id] = true
+	}
+	return context.WithValue(ctx, farmsKey{}, allowed)
+}
+
+// WithoutFarms quita la restricción por rancho (se conserva la de empresa).
+// Para búsquedas que deben ver todo el tenant antes de decidir si el usuario puede tocar el registro.
+func WithoutFarms(ctx context.Context) context.Context {
+	return context.WithValue(ctx, farmsKey{}, nil)
+}
+
+// FarmsFromContext devuelve los ranchos permitidos (ok = false si no hay restricción)
+func FarmsFromContext(ctx context.Context) ([]uuid.UUID, bool) {
+	allowed, ok := ctx.Value(farmsKey{}).(map[uuid.UUID]bool)
+	if !ok {
+		return nil, false
+	}
+	ids := make([]uuid.UUID, 0, len(allowed))
+	for id := range allowed {
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// FarmAllowed indica si la petición puede tocar el rancho
+func FarmAllowed(ctx context.Context, farmID uuid.UUID) bool {
+	allowed, ok := ctx.Value(farmsKey{}).(map[uuid.UUID]bool)
+	return !ok || allowed[farmID]
+}
+
+// RegisterFarms instala los callbacks de restricción por rancho.
+// farmModel es el modelo de ranchos (ej: &domain.Farm{}), que se filtra por su ID.
+func RegisterFarms(db *gorm.DB, farmModel interface{}) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(farmModel); err != nil {
+		return err
+	}
+	farmTable = stmt.Schema.Table
+
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").After("tenancy:scope_query").Register("tenancy:farm_query", scopeFarms); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").After("tenancy:scope_row").Register("tenancy:farm_row", scopeFarms); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").After("tenancy:scope_update").Register("tenancy:farm_update", scopeFarmUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").After("tenancy:scope_delete").Register("tenancy:farm_delete", scopeFarms); err != nil {
+		return err
+	}
+	return cb.Create().Before("gorm:create").After("tenancy:assign_create").Register("tenancy:farm_create", checkFarmCreate)
+}
+
+// farmColumn: Columna que identifica el rancho del modelo (nil si no aplica)
+func farmColumn(db *gorm.DB) *schema.Field {
+	if db.Error != nil || db.Statement.Schema == nil || isUnscoped(db.Statement.Context) {
+		return nil
+	}
+	if _, ok := db.Statement.Context.Value(farmsKey{}).(map[uuid.UUID]bool); !ok {
+		return nil
+	}
+	if db.Statement.Schema.Table == farmTable {
+		return db.Statement.Schema.PrioritizedPrimaryField
+	}
+	return db.Statement.Schema.LookUpField("FarmID")
+}
+
+func scopeFarms(db *gorm.DB) {
+	field := farmColumn(db)
+	if field == nil {
+		return
+	}
+	ids, _ := FarmsFromContext(db.Statement.Context)
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	col := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+	if len(values) == 0 {
+		db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "1 = 0"}}})
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{clause.IN{Column: col, Values: values}}})
+}
+
+// scopeFarmUpdate filtra las filas y además impide mover un registro a un rancho no asignado
+func scopeFarmUpdate(db *gorm.DB) {
+	field := farmColumn(db)
+	if field == nil {
+		return
+	}
+	scopeFarms(db)
+	if db.Statement.Schema.Table == farmTable {
+		return
+	}
+	switch dest := db.Statement.Dest.(type) {
+	case map[string]interface{}:
+		for _, key := range []string{field.DBName, field.Name} {
+			if v, ok := dest[key]; ok && !farmValueAllowed(db.Statement.Context, v) {
+				db.AddError(ErrFarmForbidden)
+				return
+			}
+		}
+	default:
+		checkFarmValues(db, field)
+	}
+}
+
+func checkFarmCreate(db *gorm.DB) {
+	field := farmColumn(db)
+	if field == nil {
+		return
+	}
+	// Crear ranchos es de administradores: un usuario restringido no puede dar de alta otros
+	if db.Statement.Schema.Table == farmTable {
+		db.AddError(ErrFarmForbidden)
+		return
+	}
+	checkFarmValues(db, field)
+}
+
+// checkFarmValues revisa el FarmID de cada registro de la sentencia
+func checkFarmValues(db *gorm.DB, field *schema.Field) {
+	ctx := db.Statement.Context
+	check := func(rv reflect.Value) {
+		if rv.Kind() != reflect.Struct {
+			return
+		}
+		if v, zero := field.ValueOf(ctx, rv); !zero && !farmValueAllowed(ctx, v) {
+			db.AddError(ErrFarmForbidden)
+		}
+	}
+	rv := reflect.Indirect(db.Statement.ReflectValue)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			check(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		check(rv)
+	}
+}
+
+func farmValueAllowed(ctx context.Context, v interface{}) bool {
+	switch id := v.(type) {
+	case uuid.UUID:
+		return FarmAllowed(ctx, id)
+	case *uuid.UUID:
+		return id == nil || FarmAllowed(ctx, *id)
+	case string:
+		parsed, err := uuid.Parse(id)
+		return err == nil && FarmAllowed(ctx, parsed)
+	}
+	return false
+}
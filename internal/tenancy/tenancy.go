@@ -0,0 +1,153 @@
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Aislamiento Multi-Tenant
+// ---------------------------------------------------------
+// La empresa activa viaja en el context.Context de cada petición. Los callbacks
+// de GORM registrados en Register() leen ese contexto y:
+//   - Agregan "tenant_id = ?" a todo SELECT / UPDATE / DELETE de modelos con TenantID.
+//     Las filas globales (tenant_id NULL) se leen desde cualquier empresa pero ninguna las modifica.
+//   - Asignan el TenantID en cada INSERT y rechazan registros de otra empresa.
+// Así ningún handler puede filtrar o escribir datos cruzados aunque olvide el WHERE.
+
+var (
+	// ErrMissingTenant: Se intentó tocar una tabla multi-tenant sin empresa activa
+	ErrMissingTenant = errors.New("operación sin empresa activa en el contexto")
+	// ErrTenantMismatch: El registro trae un tenant_id distinto a la empresa activa
+	ErrTenantMismatch = errors.New("el registro pertenece a otra empresa")
+)
+
+type tenantKey struct{}
+type unscopedKey struct{}
+
+// WithTenant liga la empresa activa al contexto
+func WithTenant(ctx context.Context, tenantID uuid.UUID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// FromContext devuelve la empresa activa (si existe)
+func FromContext(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(tenantKey{}).(uuid.UUID)
+	return id, ok && id != uuid.Nil
+}
+
+// WithoutScope marca el contexto como "proceso de sistema": los callbacks no filtran.
+// Úsalo SOLO en rutas públicas controladas (pasaporte) o procesos internos.
+func WithoutScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unscopedKey{}, true)
+}
+
+func isUnscoped(ctx context.Context) bool {
+	v, _ := ctx.Value(unscopedKey{}).(bool)
+	return v
+}
+
+// Register instala los callbacks de aislamiento en la conexión
+func Register(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("tenancy:scope_query", scopeQuery); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("tenancy:scope_row", scopeQuery); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("tenancy:scope_update", scopeWrite); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("tenancy:scope_delete", scopeWrite); err != nil {
+		return err
+	}
+	return cb.Create().Before("gorm:create").Register("tenancy:assign_create", assignCreate)
+}
+
+// tenantField devuelve el campo TenantID del modelo (nil si la tabla no es multi-tenant)
+func tenantField(db *gorm.DB) *schema.Field {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil
+	}
+	return db.Statement.Schema.LookUpField("TenantID")
+}
+
+// scopeQuery: Lecturas (incluyen el catálogo global)
+func scopeQuery(db *gorm.DB) { scope(db, true) }
+
+// scopeWrite: UPDATE / DELETE (solo filas propias; el catálogo global es del sistema)
+func scopeWrite(db *gorm.DB) { scope(db, false) }
+
+func scope(db *gorm.DB, withGlobal bool) {
+	field := tenantField(db)
+	if field == nil || isUnscoped(db.Statement.Context) {
+		return
+	}
+	tenantID, ok := FromContext(db.Statement.Context)
+	if !ok {
+		db.AddError(ErrMissingTenant)
+		return
+	}
+
+	col := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+	expr := clause.Expr{SQL: "? = ?", Vars: []interface{}{col, tenantID}}
+	// Los campos *uuid.UUID (ej: Chemical) aceptan NULL = catálogo global del sistema
+	if withGlobal && field.FieldType.Kind() == reflect.Ptr {
+		expr = clause.Expr{SQL: "(? = ? OR ? IS NULL)", Vars: []interface{}{col, tenantID, col}}
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{expr}})
+}
+
+func assignCreate(db *gorm.DB) {
+	field := tenantField(db)
+	if field == nil || isUnscoped(db.Statement.Context) {
+		return
+	}
+	tenantID, ok := FromContext(db.Statement.Context)
+	if !ok {
+		db.AddError(ErrMissingTenant)
+		return
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			assignOne(db, field, reflect.Indirect(rv.Index(i)), tenantID)
+		}
+	case reflect.Struct:
+		assignOne(db, field, rv, tenantID)
+	}
+}
+
+func assignOne(db *gorm.DB, field *schema.Field, rv reflect.Value, tenantID uuid.UUID) {
+	ctx := db.Statement.Context
+	current, isZero := field.ValueOf(ctx, rv)
+	if !isZero {
+		var existing uuid.UUID
+		switch v := current.(type) {
+		case uuid.UUID:
+			existing = v
+		case *uuid.UUID:
+			existing = *v
+		}
+		if existing != uuid.Nil && existing != tenantID {
+			db.AddError(ErrTenantMismatch)
+			return
+		}
+	}
+
+	var value interface{} = tenantID
+	if field.FieldType.Kind() == reflect.Ptr {
+		value = &tenantID
+	}
+	if err := field.Set(ctx, rv, value); err != nil {
+		db.AddError(err)
+	}
+}
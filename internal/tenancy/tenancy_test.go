@@ -0,0 +1,313 @@
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Modelos mínimos con la forma de los de domain
+type testFarm struct {
+	ID       uuid.UUID `gorm:"type:uuid;primaryKey"`
+	TenantID uuid.UUID `gorm:"type:uuid;index"`
+	Name     string
+}
+
+func (testFarm) TableName() string { return "farms" }
+
+type testBatch struct {
+	ID       uuid.UUID `gorm:"type:uuid;primaryKey"`
+	TenantID uuid.UUID `gorm:"type:uuid;index"`
+	FarmID   uuid.UUID `gorm:"type:uuid"`
+	Code     string
+}
+
+// testChemical: TenantID nulo = catálogo global del sistema
+type testChemical struct {
+	ID       uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	TenantID *uuid.UUID `gorm:"type:uuid;index"`
+	Name     string
+}
+
+// testPlan: Tabla sin TenantID (no se filtra)
+type testPlan struct {
+	ID   uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Name string
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&testFarm{}, &testBatch{}, &testChemical{}, &testPlan{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterFarms(db, &testFarm{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestTenantIsolation(t *testing.T) {
+	db := newTestDB(t)
+	tenantA, tenantB := uuid.New(), uuid.New()
+	dbA := db.WithContext(WithTenant(context.Background(), tenantA))
+	dbB := db.WithContext(WithTenant(context.Background(), tenantB))
+
+	// El alta toma la empresa del contexto
+	batchA := testBatch{ID: uuid.New(), Code: "LOT-A"}
+	if err := dbA.Create(&batchA).Error; err != nil {
+		t.Fatal(err)
+	}
+	if batchA.TenantID != tenantA {
+		t.Fatalf("tenant_id = %s, se esperaba %s", batchA.TenantID, tenantA)
+	}
+	batchesB := []testBatch{{ID: uuid.New(), Code: "LOT-B1"}, {ID: uuid.New(), Code: "LOT-B2"}}
+	if err := dbB.Create(&batchesB).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range batchesB {
+		if b.TenantID != tenantB {
+			t.Fatalf("alta en lote: tenant_id = %s, se esperaba %s", b.TenantID, tenantB)
+		}
+	}
+
+	// No se puede dar de alta un registro a nombre de otra empresa
+	if err := dbA.Create(&testBatch{ID: uuid.New(), TenantID: tenantB}).Error; !errors.Is(err, ErrTenantMismatch) {
+		t.Fatalf("alta con otro tenant_id: err=%v, se esperaba ErrTenantMismatch", err)
+	}
+
+	// Lectura: A no ve los lotes de B, ni buscándolos por ID
+	var seen []testBatch
+	if err := dbA.Find(&seen).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 1 || seen[0].ID != batchA.ID {
+		t.Fatalf("A ve %+v, se esperaba solo su lote", seen)
+	}
+	if err := dbA.First(&testBatch{}, "id = ?", batchesB[0].ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("A lee un lote de B: err=%v", err)
+	}
+	var count int64
+	if err := dbA.Model(&testBatch{}).Count(&count).Error; err != nil || count != 1 {
+		t.Fatalf("A cuenta %d lotes (err=%v), se esperaba 1", count, err)
+	}
+
+	// Escritura: cambiar o borrar un lote de B desde A no toca nada
+	res := dbA.Model(&testBatch{}).Where("id = ?", batchesB[0].ID).Update("code", "ROBADO")
+	if res.Error != nil || res.RowsAffected != 0 {
+		t.Fatalf("A actualiza un lote de B: filas=%d err=%v", res.RowsAffected, res.Error)
+	}
+	res = dbA.Where("id = ?", batchesB[1].ID).Delete(&testBatch{})
+	if res.Error != nil || res.RowsAffected != 0 {
+		t.Fatalf("A borra un lote de B: filas=%d err=%v", res.RowsAffected, res.Error)
+	}
+	var stillB []testBatch
+	if err := dbB.Order("code").Find(&stillB).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(stillB) != 2 || stillB[0].Code != "LOT-B1" {
+		t.Fatalf("lotes de B después de los intentos de A: %+v", stillB)
+	}
+
+	// Sus propios registros sí los actualiza y borra
+	if res := dbA.Model(&batchA).Update("code", "LOT-A2"); res.Error != nil || res.RowsAffected != 1 {
+		t.Fatalf("A actualiza su lote: filas=%d err=%v", res.RowsAffected, res.Error)
+	}
+	if res := dbA.Delete(&batchA); res.Error != nil || res.RowsAffected != 1 {
+		t.Fatalf("A borra su lote: filas=%d err=%v", res.RowsAffected, res.Error)
+	}
+}
+
+func TestMissingTenant(t *testing.T) {
+	db := newTestDB(t).WithContext(context.Background())
+
+	// Sin empresa activa las tablas multi-tenant fallan en vez de devolver todo
+	if err := db.Find(&[]testBatch{}).Error; !errors.Is(err, ErrMissingTenant) {
+		t.Fatalf("lectura: err=%v, se esperaba ErrMissingTenant", err)
+	}
+	if err := db.Create(&testBatch{ID: uuid.New()}).Error; !errors.Is(err, ErrMissingTenant) {
+		t.Fatalf("alta: err=%v, se esperaba ErrMissingTenant", err)
+	}
+	if err := db.Model(&testBatch{}).Where("1 = 1").Update("code", "x").Error; !errors.Is(err, ErrMissingTenant) {
+		t.Fatalf("cambio: err=%v, se esperaba ErrMissingTenant", err)
+	}
+	if err := db.Where("1 = 1").Delete(&testBatch{}).Error; !errors.Is(err, ErrMissingTenant) {
+		t.Fatalf("borrado: err=%v, se esperaba ErrMissingTenant", err)
+	}
+
+	// Las tablas sin TenantID no se tocan
+	if err := db.Create(&testPlan{ID: uuid.New(), Name: "pro"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Find(&[]testPlan{}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithoutScope(t *testing.T) {
+	db := newTestDB(t)
+	tenantA, tenantB := uuid.New(), uuid.New()
+	for _, tenantID := range []uuid.UUID{tenantA, tenantB} {
+		if err := db.WithContext(WithTenant(context.Background(), tenantID)).Create(&testBatch{ID: uuid.New()}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Proceso de sistema: ve todas las empresas y escribe el tenant_id que trae el registro
+	sysDB := db.WithContext(WithoutScope(context.Background()))
+	var all []testBatch
+	if err := sysDB.Find(&all).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("sistema ve %d lotes, se esperaban 2", len(all))
+	}
+	if err := sysDB.Create(&testBatch{ID: uuid.New(), TenantID: tenantB}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// WithoutScope sobre un contexto con empresa también deja de filtrar
+	scoped := WithTenant(context.Background(), tenantA)
+	if err := db.WithContext(WithoutScope(scoped)).Find(&all).Error; err != nil || len(all) != 3 {
+		t.Fatalf("WithoutScope con empresa: %d lotes (err=%v), se esperaban 3", len(all), err)
+	}
+
+	// Ni Unscoped ni una sesión nueva saltan el filtro: solo WithoutScope
+	if err := db.WithContext(scoped).Unscoped().Find(&all).Error; err != nil || len(all) != 1 {
+		t.Fatalf("Unscoped: %d lotes (err=%v), se esperaba 1", len(all), err)
+	}
+	if err := db.WithContext(scoped).Session(&gorm.Session{NewDB: true}).Find(&all).Error; err != nil || len(all) != 1 {
+		t.Fatalf("sesión nueva: %d lotes (err=%v), se esperaba 1", len(all), err)
+	}
+}
+
+func TestGlobalRows(t *testing.T) {
+	db := newTestDB(t)
+	tenantA, tenantB := uuid.New(), uuid.New()
+	dbA := db.WithContext(WithTenant(context.Background(), tenantA))
+	dbB := db.WithContext(WithTenant(context.Background(), tenantB))
+
+	// El catálogo global lo siembra el sistema con tenant_id NULL
+	global := testChemical{ID: uuid.New(), Name: "Azufre"}
+	if err := db.WithContext(WithoutScope(context.Background())).Create(&global).Error; err != nil {
+		t.Fatal(err)
+	}
+	if global.TenantID != nil {
+		t.Fatalf("químico global con tenant_id %s", global.TenantID)
+	}
+
+	// El alta desde una empresa queda en su catálogo privado
+	private := testChemical{ID: uuid.New(), Name: "Mezcla propia"}
+	if err := dbA.Create(&private).Error; err != nil {
+		t.Fatal(err)
+	}
+	if private.TenantID == nil || *private.TenantID != tenantA {
+		t.Fatalf("químico privado con tenant_id %v, se esperaba %s", private.TenantID, tenantA)
+	}
+
+	// Lectura: cada empresa ve el global y solo su catálogo privado
+	var seenA, seenB []testChemical
+	if err := dbA.Order("name").Find(&seenA).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := dbB.Find(&seenB).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(seenA) != 2 {
+		t.Fatalf("A ve %d químicos, se esperaban 2 (global y privado)", len(seenA))
+	}
+	if len(seenB) != 1 || seenB[0].ID != global.ID {
+		t.Fatalf("B ve %+v, se esperaba solo el global", seenB)
+	}
+
+	// Escritura: ninguna empresa cambia ni borra el catálogo global
+	for name, tx := range map[string]*gorm.DB{"A": dbA, "B": dbB} {
+		if res := tx.Model(&testChemical{}).Where("id = ?", global.ID).Update("name", "Otro"); res.Error != nil || res.RowsAffected != 0 {
+			t.Fatalf("%s actualiza el global: filas=%d err=%v", name, res.RowsAffected, res.Error)
+		}
+		if res := tx.Where("id = ?", global.ID).Delete(&testChemical{}); res.Error != nil || res.RowsAffected != 0 {
+			t.Fatalf("%s borra el global: filas=%d err=%v", name, res.RowsAffected, res.Error)
+		}
+	}
+	var current testChemical
+	if err := dbB.First(&current, "id = ?", global.ID).Error; err != nil || current.Name != "Azufre" {
+		t.Fatalf("global después de los intentos: %+v (err=%v)", current, err)
+	}
+
+	// B tampoco toca el catálogo privado de A; A sí
+	if res := dbB.Model(&testChemical{}).Where("id = ?", private.ID).Update("name", "Otro"); res.RowsAffected != 0 {
+		t.Fatalf("B actualiza el químico privado de A: filas=%d", res.RowsAffected)
+	}
+	if res := dbA.Model(&private).Update("name", "Mezcla 2"); res.Error != nil || res.RowsAffected != 1 {
+		t.Fatalf("A actualiza su químico: filas=%d err=%v", res.RowsAffected, res.Error)
+	}
+}
+
+func TestFarmScope(t *testing.T) {
+	db := newTestDB(t)
+	tenantCtx := WithTenant(context.Background(), uuid.New())
+	adminDB := db.WithContext(tenantCtx)
+
+	farmA, farmB := testFarm{ID: uuid.New(), Name: "Rancho A"}, testFarm{ID: uuid.New(), Name: "Rancho B"}
+	if err := adminDB.Create(&[]testFarm{farmA, farmB}).Error; err != nil {
+		t.Fatal(err)
+	}
+	batchB := testBatch{ID: uuid.New(), FarmID: farmB.ID, Code: "LOT-B"}
+	if err := adminDB.Create(&batchB).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	foreman := db.WithContext(WithFarms(tenantCtx, []uuid.UUID{farmA.ID}))
+
+	// Solo ve su rancho y los lotes de su rancho
+	var farms []testFarm
+	if err := foreman.Find(&farms).Error; err != nil || len(farms) != 1 || farms[0].ID != farmA.ID {
+		t.Fatalf("capataz ve ranchos %+v (err=%v)", farms, err)
+	}
+	if err := foreman.First(&testBatch{}, "id = ?", batchB.ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("capataz lee un lote del rancho B: err=%v", err)
+	}
+
+	// No da de alta en otro rancho, ni mueve un lote ahí, ni crea ranchos
+	if err := foreman.Create(&testBatch{ID: uuid.New(), FarmID: farmB.ID}).Error; !errors.Is(err, ErrFarmForbidden) {
+		t.Fatalf("alta en el rancho B: err=%v, se esperaba ErrFarmForbidden", err)
+	}
+	batchA := testBatch{ID: uuid.New(), FarmID: farmA.ID, Code: "LOT-A"}
+	if err := foreman.Create(&batchA).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := foreman.Model(&batchA).Update("farm_id", farmB.ID).Error; !errors.Is(err, ErrFarmForbidden) {
+		t.Fatalf("mover al rancho B: err=%v, se esperaba ErrFarmForbidden", err)
+	}
+	if err := foreman.Create(&testFarm{ID: uuid.New(), Name: "Rancho C"}).Error; !errors.Is(err, ErrFarmForbidden) {
+		t.Fatalf("alta de rancho: err=%v, se esperaba ErrFarmForbidden", err)
+	}
+	if res := foreman.Where("id = ?", batchB.ID).Delete(&testBatch{}); res.Error != nil || res.RowsAffected != 0 {
+		t.Fatalf("borrar un lote del rancho B: filas=%d err=%v", res.RowsAffected, res.Error)
+	}
+
+	// Sin ranchos asignados no ve nada; WithoutFarms conserva solo el filtro de empresa
+	if err := db.WithContext(WithFarms(tenantCtx, nil)).Find(&farms).Error; err != nil || len(farms) != 0 {
+		t.Fatalf("sin ranchos: %d ranchos (err=%v)", len(farms), err)
+	}
+	var batches []testBatch
+	if err := db.WithContext(WithoutFarms(WithFarms(tenantCtx, []uuid.UUID{farmA.ID}))).Find(&batches).Error; err != nil || len(batches) != 2 {
+		t.Fatalf("WithoutFarms: %d lotes (err=%v), se esperaban 2", len(batches), err)
+	}
+}
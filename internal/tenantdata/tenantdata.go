@@ -0,0 +1,333 @@
+package tenantdata
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/Marcos1394/agritrust-backend/internal/audit"
+	"github.com/Marcos1394/agritrust-backend/internal/domain"
+	"github.com/Marcos1394/agritrust-backend/internal/tenancy"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ---------------------------------------------------------
+// 📦 DATOS DE UNA EMPRESA: Exportación completa y borrado
+// ---------------------------------------------------------
+
+// table: Tabla propiedad de la empresa y cómo encontrar sus filas
+type table struct {
+	model interface{}
+	// scope filtra las filas de la empresa (por default: tenant_id = ?)
+	scope func(tx *gorm.DB, tenantID uuid.UUID) *gorm.DB
+}
+
+func byTenant(tx *gorm.DB, tenantID uuid.UUID) *gorm.DB {
+	return tx.Where("tenant_id = ?", tenantID)
+}
+
+// tables: Orden de dependencias (hijos antes que padres). El borrado lo recorre así;
+// la exportación al revés, para que al reimportar los padres existan primero.
+// Al agregar un modelo con TenantID hay que sumarlo aquí.
+var tables = []table{
+	{model: &domain.TelemetryData{}, scope: func(tx *gorm.DB, tenantID uuid.UUID) *gorm.DB {
+		return tx.Where("device_id IN (?)", tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&domain.Device{}).Select("id").Where("tenant_id = ?", tenantID))
+	}},
+	{model: &domain.Device{}},
+	{model: &domain.SyncOperation{}},
+	{model: &domain.MaintenanceLog{}},
+	{model: &domain.Asset{}},
+	{model: &domain.StockMovement{}},
+	{model: &domain.PurchaseOrderItem{}, scope: func(tx *gorm.DB, tenantID uuid.UUID) *gorm.DB {
+		return tx.Where("purchase_order_id IN (?)", tx.Session(&gorm.Session{NewDB: true}).Model(&domain.PurchaseOrder{}).Select("id").Where("tenant_id = ?", tenantID))
+	}},
+	{model: &domain.PurchaseOrder{}},
+	{model: &domain.Supplier{}},
+	{model: &domain.Product{}},
+	{model: &domain.Expense{}},
+	{model: &domain.Budget{}},
+	// Primero las subcategorías (FK parent_id → cost_categories)
+	{model: &domain.CostCategory{}, scope: func(tx *gorm.DB, tenantID uuid.UUID) *gorm.DB {
+		return tx.Where("tenant_id = ? AND parent_id IS NOT NULL", tenantID)
+	}},
+	{model: &domain.CostCategory{}, scope: func(tx *gorm.DB, tenantID uuid.UUID) *gorm.DB {
+		return tx.Where("tenant_id = ? AND parent_id IS NULL", tenantID)
+	}},
+	{model: &domain.Season{}},
+	{model: &domain.Claim{}},
+	{model: &domain.Bin{}},
+	{model: &domain.Shipment{}},
+	{model: &domain.HarvestBatch{}},
+	{model: &domain.Crop{}},
+	{model: &domain.ApplicationRecord{}},
+	{model: &domain.LeaseContract{}},
+	{model: &domain.Chemical{}}, // Solo el catálogo privado: los globales tienen tenant_id NULL
+	{model: &domain.MemberFarm{}},
+	{model: &domain.Farm{}},
+	{model: &domain.Invitation{}},
+	{model: &domain.TeamMember{}},
+	{model: &domain.RolePermission{}},
+	{model: &domain.APIKey{}},
+	{model: &domain.WebhookAttempt{}},
+	{model: &domain.Event{}},
+	{model: &domain.WebhookEndpoint{}}, // Sin el secreto (json:"-")
+	{model: &domain.AlertRecipient{}},
+	{model: &domain.AuditLog{}},
+}
+
+// rows aplica el filtro de la empresa sobre q
+func (t table) rows(q *gorm.DB, tenantID uuid.UUID) *gorm.DB {
+	if t.scope != nil {
+		return t.scope(q, tenantID)
+	}
+	return byTenant(q, tenantID)
+}
+
+// systemDB: Sesión "como sistema" (la empresa se filtra explícitamente en cada tabla)
+func systemDB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	return db.WithContext(tenancy.WithoutScope(ctx))
+}
+
+// TableManifest: Resumen de una tabla dentro del ZIP
+type TableManifest struct {
+	Table string   `json:"table"`
+	Rows  int      `json:"rows"`
+	Files []string `json:"files"`
+}
+
+// Manifest: Índice del ZIP (manifest.json)
+type Manifest struct {
+	FormatVersion int             `json:"format_version"`
+	TenantID      uuid.UUID       `json:"tenant_id"`
+	TenantName    string          `json:"tenant_name"`
+	GeneratedAt   time.Time       `json:"generated_at"`
+	Tables        []TableManifest `json:"tables"`
+}
+
+// Export genera un ZIP con cada tabla de la empresa en JSON y CSV, más manifest.json.
+// Los campos ocultos (json:"-", ej: hashes de llaves) no se exportan.
+func Export(ctx context.Context, db *gorm.DB, tenantID uuid.UUID) ([]byte, error) {
+	sysDB := systemDB(ctx, db)
+
+	var tenant domain.Tenant
+	if err := sysDB.First(&tenant, "id = ?", tenantID).Error; err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	manifest := Manifest{FormatVersion: 1, TenantID: tenantID, TenantName: tenant.Name, GeneratedAt: time.Now().UTC()}
+
+	// La empresa misma va primero
+	if err := writeJSON(zw, "tenant.json", tenant); err != nil {
+		return nil, err
+	}
+
+	written := map[string]*TableManifest{}
+	for i := len(tables) - 1; i >= 0; i-- {
+		t := tables[i]
+		sch, err := parse(sysDB, t.model)
+		if err != nil {
+			return nil, err
+		}
+		entry, ok := written[sch.Table]
+		if !ok {
+			manifest.Tables = append(manifest.Tables, TableManifest{Table: sch.Table})
+			entry = &manifest.Tables[len(manifest.Tables)-1]
+			written[sch.Table] = entry
+		}
+		n, err := exportTable(zw, sysDB, t, sch, tenantID, entry)
+		if err != nil {
+			return nil, fmt.Errorf("exportando %s: %w", sch.Table, err)
+		}
+		entry.Rows += n
+	}
+
+	if err := writeJSON(zw, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func parse(db *gorm.DB, model interface{}) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// deletedAtType: La baja lógica no sale en la API pero sí en la exportación (distingue los registros dados de baja)
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// exportColumns: Columnas exportables en el orden del modelo
+func exportColumns(sch *schema.Schema) []string {
+	var cols []string
+	for _, f := range sch.Fields {
+		if f.DBName == "" || (f.Tag.Get("json") == "-" && f.FieldType != deletedAtType) {
+			continue
+		}
+		cols = append(cols, f.DBName)
+	}
+	return cols
+}
+
+// exportTable escribe <tabla>.json y <tabla>.csv. Una tabla que aparece dos veces en el orden
+// (ej: categorías hijas y padres) se escribe en partes: <tabla>.part2.json, etc.
+func exportTable(zw *zip.Writer, sysDB *gorm.DB, t table, sch *schema.Schema, tenantID uuid.UUID, entry *TableManifest) (int, error) {
+	base := sch.Table
+	if len(entry.Files) > 0 {
+		base = fmt.Sprintf("%s.part%d", sch.Table, len(entry.Files)/2+1)
+	}
+	cols := exportColumns(sch)
+
+	rows, err := t.rows(sysDB.Table(sch.Table), tenantID).Select(cols).Order(sch.PrioritizedPrimaryField.DBName).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	// JSON y CSV se arman a la par en memoria (zip solo permite un archivo abierto a la vez)
+	jsonBuf := &bytes.Buffer{}
+	csvBuf := &bytes.Buffer{}
+	cw := csv.NewWriter(csvBuf)
+	if err := cw.Write(cols); err != nil {
+		return 0, err
+	}
+	jsonBuf.WriteString("[")
+
+	n := 0
+	values := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return n, err
+		}
+		record := make([]string, len(cols))
+		obj := make(map[string]interface{}, len(cols))
+		for i, col := range cols {
+			v := normalize(values[i])
+			obj[col] = v
+			record[i] = csvValue(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return n, err
+		}
+		line, err := json.Marshal(obj)
+		if err != nil {
+			return n, err
+		}
+		if n > 0 {
+			jsonBuf.WriteString(",")
+		}
+		jsonBuf.WriteString("\n")
+		jsonBuf.Write(line)
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	jsonBuf.WriteString("\n]\n")
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return n, err
+	}
+
+	if err := writeFile(zw, base+".json", jsonBuf); err != nil {
+		return n, err
+	}
+	if err := writeFile(zw, base+".csv", csvBuf); err != nil {
+		return n, err
+	}
+	entry.Files = append(entry.Files, base+".json", base+".csv")
+	return n, nil
+}
+
+// normalize: jsonb/bytea llegan como []byte; si es JSON válido se conserva como JSON
+func normalize(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		if json.Valid(b) {
+			return json.RawMessage(b)
+		}
+		return string(b)
+	}
+	return v
+}
+
+func csvValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return val.UTC().Format(time.RFC3339)
+	case json.RawMessage:
+		return string(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func writeFile(zw *zip.Writer, name string, content io.Reader) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, content)
+	return err
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(zw, name, bytes.NewReader(b))
+}
+
+// Erase borra todas las filas de la empresa en orden de dependencias y al final la empresa misma,
+// en una sola transacción. Sin bitácora: los registros de auditoría de la empresa también se borran.
+// Las exportaciones previas se descartan (el ZIP contiene los mismos datos); el TenantJob queda
+// como constancia del borrado.
+func Erase(ctx context.Context, db *gorm.DB, tenantID uuid.UUID) error {
+	sysDB := systemDB(audit.WithoutAudit(ctx), db)
+	return sysDB.Transaction(func(tx *gorm.DB) error {
+		for _, t := range tables {
+			// Unscoped: borrado físico, también de los registros con baja lógica
+			if err := t.rows(tx.Unscoped().Model(t.model), tenantID).Delete(t.model).Error; err != nil {
+				sch, _ := parse(tx, t.model)
+				if sch != nil {
+					return fmt.Errorf("borrando %s: %w", sch.Table, err)
+				}
+				return err
+			}
+		}
+		// Respuestas guardadas por Idempotency-Key: caché de corta vida, no se exporta
+		if err := tx.Where("tenant_id = ?", tenantID).Delete(&domain.IdempotencyKey{}).Error; err != nil {
+			return err
+		}
+		// Correos del outbox: se descartan aunque no se hayan entregado (las invitaciones traen el link en claro)
+		if err := tx.Where("tenant_id = ?", tenantID).Delete(&domain.OutboxMessage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.TenantJob{}).
+			Where("tenant_id = ? AND archive IS NOT NULL", tenantID).
+			Updates(map[string]interface{}{"archive": nil, "archive_size": 0}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Tenant{}, "id = ?", tenantID).Error
+	})
+}
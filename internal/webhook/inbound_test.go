@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	testSvixSecret   = "whsec_" + "c2VjcmV0by1kZS1wcnVlYmEtY2xlcms="
+	testStripeSecret = "whsec_prueba_facturacion"
+)
+
+func svixHeaders(t *testing.T, secret, msgID string, ts time.Time, body []byte) http.Header {
+	t.Helper()
+	sig, err := SignSvix(secret, msgID, ts, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := http.Header{}
+	h.Set(SvixIDHeader, msgID)
+	h.Set(SvixTimestampHeader, strconv.FormatInt(ts.Unix(), 10))
+	h.Set(SvixSignatureHeader, sig)
+	return h
+}
+
+func TestVerifySvix(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"type":"user.updated","data":{"id":"user_1"}}`)
+	rotated := "whsec_" + base64.StdEncoding.EncodeToString([]byte("secreto-nuevo"))
+
+	t.Run("firma válida", func(t *testing.T) {
+		id, err := VerifySvix(testSvixSecret, svixHeaders(t, testSvixSecret, "msg_1", now, body), body, now)
+		if err != nil || id != "msg_1" {
+			t.Fatalf("id=%q err=%v", id, err)
+		}
+	})
+
+	t.Run("body alterado", func(t *testing.T) {
+		h := svixHeaders(t, testSvixSecret, "msg_1", now, body)
+		tampered := []byte(`{"type":"user.updated","data":{"id":"user_2"}}`)
+		if _, err := VerifySvix(testSvixSecret, h, tampered, now); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("err=%v, se esperaba ErrInvalidSignature", err)
+		}
+	})
+
+	t.Run("svix-id alterado", func(t *testing.T) {
+		h := svixHeaders(t, testSvixSecret, "msg_1", now, body)
+		h.Set(SvixIDHeader, "msg_2")
+		if _, err := VerifySvix(testSvixSecret, h, body, now); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("err=%v, se esperaba ErrInvalidSignature", err)
+		}
+	})
+
+	t.Run("timestamp viejo", func(t *testing.T) {
+		old := now.Add(-SvixTolerance - time.Second)
+		h := svixHeaders(t, testSvixSecret, "msg_1", old, body)
+		if _, err := VerifySvix(testSvixSecret, h, body, now); !errors.Is(err, ErrInvalidTimestamp) {
+			t.Fatalf("err=%v, se esperaba ErrInvalidTimestamp", err)
+		}
+	})
+
+	t.Run("timestamp en el futuro", func(t *testing.T) {
+		h := svixHeaders(t, testSvixSecret, "msg_1", now.Add(SvixTolerance+time.Second), body)
+		if _, err := VerifySvix(testSvixSecret, h, body, now); !errors.Is(err, ErrInvalidTimestamp) {
+			t.Fatalf("err=%v, se esperaba ErrInvalidTimestamp", err)
+		}
+	})
+
+	t.Run("secreto rotado", func(t *testing.T) {
+		// Durante la rotación Svix firma con ambos secretos; el servidor con cualquiera de los dos acepta
+		h := svixHeaders(t, rotated, "msg_1", now, body)
+		oldSig, err := SignSvix(testSvixSecret, "msg_1", now, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Set(SvixSignatureHeader, h.Get(SvixSignatureHeader)+" "+oldSig)
+		for _, secret := range []string{testSvixSecret, rotated} {
+			if _, err := VerifySvix(secret, h, body, now); err != nil {
+				t.Fatalf("secreto %s: %v", secret, err)
+			}
+		}
+
+		// Ya sin la firma vieja, el secreto anterior deja de servir
+		h = svixHeaders(t, rotated, "msg_1", now, body)
+		if _, err := VerifySvix(testSvixSecret, h, body, now); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("err=%v, se esperaba ErrInvalidSignature", err)
+		}
+	})
+
+	t.Run("sin headers", func(t *testing.T) {
+		if _, err := VerifySvix(testSvixSecret, http.Header{}, body, now); !errors.Is(err, ErrMissingHeaders) {
+			t.Fatalf("err=%v, se esperaba ErrMissingHeaders", err)
+		}
+	})
+
+	t.Run("secreto mal formado", func(t *testing.T) {
+		h := svixHeaders(t, testSvixSecret, "msg_1", now, body)
+		if _, err := VerifySvix("whsec_%%%", h, body, now); !errors.Is(err, ErrInvalidSecret) {
+			t.Fatalf("err=%v, se esperaba ErrInvalidSecret", err)
+		}
+	})
+}
+
+func TestVerifyStripe(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"id":"evt_1","type":"customer.subscription.updated"}`)
+
+	t.Run("firma válida", func(t *testing.T) {
+		if err := VerifyStripe(testStripeSecret, SignStripe(testStripeSecret, now, body), body, now); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("body alterado", func(t *testing.T) {
+		header := SignStripe(testStripeSecret, now, body)
+		if err := VerifyStripe(testStripeSecret, header, []byte(`{"id":"evt_2"}`), now); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("err=%v, se esperaba ErrInvalidSignature", err)
+		}
+	})
+
+	t.Run("timestamp viejo", func(t *testing.T) {
+		header := SignStripe(testStripeSecret, now.Add(-StripeTolerance-time.Second), body)
+		if err := VerifyStripe(testStripeSecret, header, body, now); !errors.Is(err, ErrInvalidTimestamp) {
+			t.Fatalf("err=%v, se esperaba ErrInvalidTimestamp", err)
+		}
+	})
+
+	t.Run("secreto rotado", func(t *testing.T) {
+		// Stripe manda un v1 por cada secreto vigente durante la rotación
+		const rotated = "whsec_prueba_facturacion_nuevo"
+		header := SignStripe(rotated, now, body)
+		old := SignStripe(testStripeSecret, now, body)
+		header += "," + old[len("t="+strconv.FormatInt(now.Unix(), 10)+","):]
+		for _, secret := range []string{testStripeSecret, rotated} {
+			if err := VerifyStripe(secret, header, body, now); err != nil {
+				t.Fatalf("secreto %s: %v", secret, err)
+			}
+		}
+		if err := VerifyStripe(testStripeSecret, SignStripe(rotated, now, body), body, now); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("err=%v, se esperaba ErrInvalidSignature", err)
+		}
+	})
+
+	t.Run("header incompleto", func(t *testing.T) {
+		for _, header := range []string{"", "t=123", "v1=abcd"} {
+			if err := VerifyStripe(testStripeSecret, header, body, now); !errors.Is(err, ErrMissingHeaders) {
+				t.Fatalf("header %q: err=%v, se esperaba ErrMissingHeaders", header, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Webhooks salientes: eventos que mandamos a los sistemas de las empresas (ERP, bots). Cada envío trae:
+//   - AgriTrust-Event-Id:   identificador del evento (se repite en los reintentos y reenvíos: sirve para deduplicar)
+//   - AgriTrust-Event-Type: tipo de evento (ej: claim.opened)
+//   - AgriTrust-Signature:  t=<segundos Unix>,v1=<hex>
+//
+// La firma es HMAC-SHA256 de "<t>.<body>" con el secreto del endpoint tal cual: el mismo esquema
+// que Stripe, así que el receptor puede usar cualquier verificador compatible (o Verify).
+const (
+	EventIDHeader   = "AgriTrust-Event-Id"
+	EventTypeHeader = "AgriTrust-Event-Type"
+	SignatureHeader = "AgriTrust-Signature"
+)
+
+// maxResponseBody: Bytes de la respuesta que se guardan en la bitácora
+const maxResponseBody = 1024
+
+// ErrPrivateAddress: La URL resuelve a una dirección interna (loopback, red privada, metadata de la nube)
+var ErrPrivateAddress = errors.New("la URL del webhook apunta a una dirección interna")
+
+// NewSecret genera el secreto de un endpoint ("whsec_" + 32 bytes aleatorios)
+func NewSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Sign calcula el header AgriTrust-Signature
+func Sign(secret string, ts time.Time, body []byte) string {
+	return SignStripe(secret, ts, body)
+}
+
+// Verify valida la firma y la frescura de un envío (lado del receptor; tolerancia de StripeTolerance)
+func Verify(secret, header string, body []byte, now time.Time) error {
+	return VerifyStripe(secret, header, body, now)
+}
+
+// NewClient arma el cliente de los envíos: sin redirecciones (un 3xx cuenta como falla) y, salvo
+// allowPrivate (desarrollo), sin conexiones a direcciones internas. La IP se revisa al conectar,
+// después de resolver el DNS, para que un dominio no pueda apuntar a la red interna.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || internalIP(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext, // Sin proxy del entorno: la revisión de IP debe ver el destino real
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 2,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()
+}
+
+// Result: Lo que respondió el receptor (para la bitácora)
+type Result struct {
+	StatusCode int
+	Body       string
+	Duration   time.Duration
+}
+
+// Post manda el evento firmado. Devuelve error si no hubo respuesta o si no fue 2xx.
+func Post(ctx context.Context, client *http.Client, url, secret, eventID, eventType string, body []byte) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AgriTrust-Webhooks/1.0")
+	req.Header.Set(EventIDHeader, eventID)
+	req.Header.Set(EventTypeHeader, eventType)
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{Duration: time.Since(started)}, err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Para reusar la conexión
+	res := Result{StatusCode: resp.StatusCode, Body: string(snippet), Duration: time.Since(started)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return res, fmt.Errorf("el receptor respondió %d", resp.StatusCode)
+	}
+	return res, nil
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPostSignsBody(t *testing.T) {
+	const secret = "whsec_endpoint_de_prueba"
+	body := []byte(`{"id":"evt_1","type":"claim.opened","data":{}}`)
+
+	var got http.Header
+	var gotBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Write([]byte("ok"))
+	}))
+	defer receiver.Close()
+
+	res, err := Post(context.Background(), NewClient(time.Second, true), receiver.URL, secret, "evt_1", "claim.opened", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || res.Body != "ok" {
+		t.Fatalf("resultado = %+v", res)
+	}
+	if got.Get(EventIDHeader) != "evt_1" || got.Get(EventTypeHeader) != "claim.opened" {
+		t.Fatalf("headers = %v", got)
+	}
+	// El receptor valida la firma contra el cuerpo que le llegó
+	if err := Verify(secret, got.Get(SignatureHeader), gotBody, time.Now()); err != nil {
+		t.Fatalf("firma: %v", err)
+	}
+	if err := Verify(secret, got.Get(SignatureHeader), append(gotBody, ' '), time.Now()); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("cuerpo alterado: err=%v, se esperaba ErrInvalidSignature", err)
+	}
+}
+
+func TestPostFailures(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/caido":
+			http.Error(w, "mantenimiento", http.StatusServiceUnavailable)
+		case "/redirige":
+			http.Redirect(w, r, "/otro", http.StatusFound)
+		}
+	}))
+	defer receiver.Close()
+	client := NewClient(time.Second, true)
+
+	t.Run("5xx", func(t *testing.T) {
+		res, err := Post(context.Background(), client, receiver.URL+"/caido", "whsec_x", "evt_1", "claim.opened", []byte(`{}`))
+		if err == nil || res.StatusCode != http.StatusServiceUnavailable || res.Body != "mantenimiento\n" {
+			t.Fatalf("res=%+v err=%v", res, err)
+		}
+	})
+
+	t.Run("redirección", func(t *testing.T) {
+		res, err := Post(context.Background(), client, receiver.URL+"/redirige", "whsec_x", "evt_1", "claim.opened", []byte(`{}`))
+		if err == nil || res.StatusCode != http.StatusFound {
+			t.Fatalf("res=%+v err=%v", res, err)
+		}
+	})
+
+	t.Run("dirección interna", func(t *testing.T) {
+		_, err := Post(context.Background(), NewClient(time.Second, false), receiver.URL, "whsec_x", "evt_1", "claim.opened", []byte(`{}`))
+		if !errors.Is(err, ErrPrivateAddress) {
+			t.Fatalf("err=%v, se esperaba ErrPrivateAddress", err)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhooks de facturación (Stripe). Un solo header:
+//
+//	Stripe-Signature: t=<segundos Unix>,v1=<hex>[,v1=<hex>...]
+//
+// La firma es HMAC-SHA256 de "<t>.<body>" con el secreto "whsec_..." tal cual (sin decodificar).
+const StripeSignatureHeader = "Stripe-Signature"
+
+// StripeTolerance: Antigüedad máxima de un envío
+const StripeTolerance = 5 * time.Minute
+
+// SignStripe calcula el header Stripe-Signature (para fixtures de prueba)
+func SignStripe(secret string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + t + ",v1=" + hex.EncodeToString(stripeMAC(secret, t, body))
+}
+
+func stripeMAC(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s.", timestamp)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// VerifyStripe valida la firma y la frescura de un webhook de facturación
+func VerifyStripe(secret, header string, body []byte, now time.Time) error {
+	if header == "" {
+		return ErrMissingHeaders
+	}
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return ErrMissingHeaders
+	}
+
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	sent := time.Unix(secs, 0)
+	if now.Sub(sent) > StripeTolerance || sent.Sub(now) > StripeTolerance {
+		return ErrInvalidTimestamp
+	}
+
+	expected := stripeMAC(secret, timestamp, body)
+	for _, sig := range signatures {
+		got, err := hex.DecodeString(sig)
+		if err == nil && hmac.Equal(got, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
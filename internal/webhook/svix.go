@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clerk entrega sus webhooks vía Svix. Cada envío trae tres headers:
+//   - svix-id:        identificador único del mensaje (se repite en los reintentos)
+//   - svix-timestamp: segundos Unix del envío
+//   - svix-signature: lista "v1,<base64>" separada por espacios (varias durante una rotación de secreto)
+//
+// La firma es HMAC-SHA256 de "<id>.<timestamp>.<body>" con el secreto "whsec_<base64>".
+const (
+	SvixIDHeader        = "svix-id"
+	SvixTimestampHeader = "svix-timestamp"
+	SvixSignatureHeader = "svix-signature"
+)
+
+// SvixTolerance: Antigüedad máxima de un envío (protege contra replays con firma válida)
+const SvixTolerance = 5 * time.Minute
+
+var (
+	ErrMissingHeaders   = errors.New("faltan los headers de firma del webhook")
+	ErrInvalidTimestamp = errors.New("timestamp del webhook inválido o fuera de tolerancia")
+	ErrInvalidSignature = errors.New("firma del webhook inválida")
+	ErrInvalidSecret    = errors.New("secreto de webhook inválido")
+)
+
+// svixKey decodifica el secreto "whsec_..." que entrega el dashboard de Clerk
+func svixKey(secret string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+// SignSvix calcula el valor del header svix-signature para un mensaje.
+// Sirve para generar payloads firmados de prueba (fixtures) con el mismo secreto del servidor.
+func SignSvix(secret, msgID string, ts time.Time, body []byte) (string, error) {
+	key, err := svixKey(secret)
+	if err != nil {
+		return "", err
+	}
+	return "v1," + base64.StdEncoding.EncodeToString(svixMAC(key, msgID, strconv.FormatInt(ts.Unix(), 10), body)), nil
+}
+
+func svixMAC(key []byte, msgID, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s.%s.", msgID, timestamp)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// VerifySvix valida la firma y la frescura de un webhook. Devuelve el svix-id del mensaje,
+// que es la llave de idempotencia: Svix reintenta con el mismo id.
+func VerifySvix(secret string, header http.Header, body []byte, now time.Time) (string, error) {
+	msgID := header.Get(SvixIDHeader)
+	timestamp := header.Get(SvixTimestampHeader)
+	signatures := header.Get(SvixSignatureHeader)
+	if msgID == "" || timestamp == "" || signatures == "" {
+		return "", ErrMissingHeaders
+	}
+
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", ErrInvalidTimestamp
+	}
+	sent := time.Unix(secs, 0)
+	if now.Sub(sent) > SvixTolerance || sent.Sub(now) > SvixTolerance {
+		return "", ErrInvalidTimestamp
+	}
+
+	key, err := svixKey(secret)
+	if err != nil {
+		return "", err
+	}
+	expected := svixMAC(key, msgID, timestamp, body)
+	for _, sig := range strings.Fields(signatures) {
+		version, value, ok := strings.Cut(sig, ",")
+		if !ok || version != "v1" {
+			continue
+		}
+		got, err := base64.StdEncoding.DecodeString(value)
+		if err == nil && hmac.Equal(got, expected) {
+			return msgID, nil
+		}
+	}
+	return "", ErrInvalidSignature
+}
@@ -82,7 +82,7 @@ export default function ApplicationScreen() {
       if (error.response) {
         if (error.response.status === 403) {
            // EL BLOQUEO DEL ESCUDO 🛡️
-           Alert.alert("⛔ BLOQUEO DE SEGURIDAD", error.response.data.details || "Producto PROHIBIDO.");
+           Alert.alert("⛔ BLOQUEO DE SEGURIDAD", error.response.data.error || "Producto PROHIBIDO.");
         } else {
            Alert.alert("Error", error.response.data.error || "Ocurrió un error.");
         }
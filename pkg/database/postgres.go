@@ -1,28 +1,50 @@
 package database
 
 import (
-	"fmt"
 	"log"
-	"os"
+	"strconv"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
-// Connect inicializa la conexión a PostgreSQL
-func Connect() *gorm.DB {
-	// 1. Intentamos leer la variable de entorno (Nube - Render)
-	dsn := os.Getenv("DATABASE_URL")
-
-	// 2. Si está vacía, usamos la local (Desarrollo - Codespaces)
-	if dsn == "" {
-		dsn = "host=localhost user=agritrust_user password=secret_password dbname=agritrust_db port=5432 sslmode=disable"
-		fmt.Println("⚠️ Usando configuración LOCAL de base de datos")
-	} else {
-		fmt.Println("☁️ Usando configuración CLOUD de base de datos")
+// Options: DSN y ajustes del pool de conexiones
+type Options struct {
+	DSN string
+
+	MaxOpenConns    int           // Tope de conexiones abiertas (0 = sin tope)
+	MaxIdleConns    int           // Conexiones ociosas que se conservan
+	ConnMaxLifetime time.Duration // Se recicla la conexión después de este tiempo (0 = nunca)
+	ConnMaxIdleTime time.Duration // Se cierra la conexión ociosa después de este tiempo (0 = nunca)
+
+	// StatementTimeout: Postgres cancela cualquier query que tarde más (0 = sin límite).
+	// Evita que una query atorada retenga conexiones del pool indefinidamente.
+	StatementTimeout time.Duration
+
+	Logger logger.Interface // nil = logger default de GORM
+}
+
+// Connect inicializa la conexión a PostgreSQL (el DSN viene de la configuración)
+func Connect(opts Options) *gorm.DB {
+	connConfig, err := pgx.ParseConfig(opts.DSN)
+	if err != nil {
+		log.Fatal("❌ DATABASE_URL inválido:", err)
 	}
+	if opts.StatementTimeout > 0 {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(opts.StatementTimeout.Milliseconds(), 10)
+	}
+
+	sqlDB := stdlib.OpenDB(*connConfig)
+	sqlDB.SetMaxOpenConns(opts.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(opts.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: opts.Logger})
 	if err != nil {
 		log.Fatal("❌ Error fatal conectando a la base de datos:", err)
 	}
@@ -1,45 +1,64 @@
 package mailer
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"html"
+	"log/slog"
+	"strings"
 
+	"github.com/Marcos1394/agritrust-backend/internal/metrics"
 	"github.com/resend/resend-go/v2"
 )
 
-// SendEmail envía un correo HTML
-func SendEmail(to []string, subject string, htmlContent string) error {
-	apiKey := os.Getenv("RESEND_API_KEY")
+// Config: Credenciales y remitente (se cargan una vez al arrancar)
+type Config struct {
+	APIKey string // Vacío = modo simulado
+	From   string // Ej: "AgriTrust <notificaciones@kinetis.org>"
+}
+
+var settings Config
+
+// Configure fija las credenciales del mailer. Se llama en main antes de servir.
+func Configure(cfg Config) {
+	settings = cfg
+}
+
+// SendEmail envía un correo HTML. ctx solo se usa para correlacionar los logs (request_id, empresa).
+func SendEmail(ctx context.Context, to []string, subject string, htmlContent string) error {
+	apiKey := settings.APIKey
 	if apiKey == "" {
-		// Modo Local sin API Key: Solo imprimimos en consola
-		fmt.Println("⚠️ [MAILER SIMULADO] Faltan credenciales RESEND_API_KEY")
-		fmt.Printf("TO: %v\nSUBJECT: %s\n", to, subject)
+		// Modo Local sin API Key: Solo se registra (los destinatarios no van al log)
+		slog.WarnContext(ctx, "correo simulado: falta RESEND_API_KEY", "subject", subject, "recipient_count", len(to))
+		metrics.Emails.WithLabelValues(metrics.EmailSimulated).Inc()
 		return nil
 	}
 
 	client := resend.NewClient(apiKey)
 
 	params := &resend.SendEmailRequest{
-		// 👇 AQUÍ USAMOS TU DOMINIO OFICIAL
-		From:    "AgriTrust <notificaciones@kinetis.org>", 
+		From:    settings.From,
 		To:      to,
 		Subject: subject,
 		Html:    htmlContent,
 	}
 
-	sent, err := client.Emails.Send(params)
+	sent, err := client.Emails.SendWithContext(ctx, params)
 	if err != nil {
-		fmt.Println("❌ Error enviando correo:", err)
+		slog.ErrorContext(ctx, "error enviando correo", "subject", subject, "recipient_count", len(to), "error", err)
+		metrics.Emails.WithLabelValues(metrics.EmailFailed).Inc()
 		return err
 	}
 
-	fmt.Println("✅ Correo enviado con éxito. ID:", sent.Id)
+	metrics.Emails.WithLabelValues(metrics.EmailSent).Inc()
+	slog.InfoContext(ctx, "correo enviado", "subject", subject, "recipient_count", len(to), "message_id", sent.Id)
 	return nil
 }
 
 // --- PLANTILLAS HTML (Templates) ---
 
-// 1. Plantilla para Alerta de Seguridad (Intento de uso de químico prohibido)
+// 1. Plantilla para Alerta de Seguridad (Intento de uso de químico prohibido).
+// Los valores se escapan: el nombre del usuario viene de Clerk y lo escribe él mismo.
 func GetSecurityAlertTemplate(farmName, chemicalName, user string) string {
 	return fmt.Sprintf(`
 		<div style="font-family: sans-serif; padding: 20px; border: 1px solid #fee2e2; border-radius: 8px; background-color: #fffaf9;">
@@ -57,7 +76,7 @@ func GetSecurityAlertTemplate(farmName, chemicalName, user string) string {
 			<hr style="border: 0; border-top: 1px solid #eee; margin: 20px 0;">
 			<p style="font-size: 12px; color: #888;">AgriTrust Security System • kinetis.org</p>
 		</div>
-	`, farmName, user, chemicalName)
+	`, html.EscapeString(farmName), html.EscapeString(user), html.EscapeString(chemicalName))
 }
 
 // 2. Plantilla para Invitación de Equipo
@@ -77,5 +96,36 @@ func GetInviteTemplate(link string, role string) string {
 			<br><br>
 			<p style="font-size: 12px; color: #9ca3af;">Si el botón no funciona, copia este enlace: %s</p>
 		</div>
-	`, roleName, link, link)
-}
\ No newline at end of file
+	`, roleName, html.EscapeString(link), html.EscapeString(link))
+}
+
+// 3. Plantilla para Resúmenes periódicos (contratos por vencer, servicios pendientes, stock bajo).
+// rows trae una fila por registro con las mismas columnas que headers; los valores se escapan.
+func GetDigestTemplate(title, intro string, headers []string, rows [][]string) string {
+	var b strings.Builder
+	for _, h := range headers {
+		fmt.Fprintf(&b, `<th style="text-align: left; padding: 6px 8px; border-bottom: 2px solid #e5e7eb;">%s</th>`, html.EscapeString(h))
+	}
+	head := b.String()
+	b.Reset()
+	for _, row := range rows {
+		b.WriteString("<tr>")
+		for _, cell := range row {
+			fmt.Fprintf(&b, `<td style="padding: 6px 8px; border-bottom: 1px solid #f3f4f6;">%s</td>`, html.EscapeString(cell))
+		}
+		b.WriteString("</tr>")
+	}
+
+	return fmt.Sprintf(`
+		<div style="font-family: sans-serif; padding: 20px; border: 1px solid #e5e7eb; border-radius: 8px;">
+			<h2 style="color: #0f172a; margin-top: 0;">%s</h2>
+			<p style="color: #374151;">%s</p>
+			<table style="border-collapse: collapse; width: 100%%; font-size: 14px; color: #374151;">
+				<thead><tr>%s</tr></thead>
+				<tbody>%s</tbody>
+			</table>
+			<hr style="border: 0; border-top: 1px solid #eee; margin: 20px 0;">
+			<p style="font-size: 12px; color: #888;">AgriTrust • Puedes cambiar quién recibe este resumen en Configuración → Alertas</p>
+		</div>
+	`, html.EscapeString(title), html.EscapeString(intro), head, b.String())
+}
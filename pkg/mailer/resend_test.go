@@ -0,0 +1,18 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSecurityAlertTemplateEscapes(t *testing.T) {
+	out := GetSecurityAlertTemplate("Rancho <Norte>", "Paraquat & Co", `Ana <script>alert(1)</script> <ana@example.com>`)
+	for _, want := range []string{"Rancho &lt;Norte&gt;", "Paraquat &amp; Co", "&lt;ana@example.com&gt;", "&lt;script&gt;"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("falta %q en la plantilla", want)
+		}
+	}
+	if strings.Contains(out, "<script>") || strings.Contains(out, "<ana@example.com>") {
+		t.Error("la plantilla trae HTML del usuario sin escapar")
+	}
+}